@@ -1,14 +1,29 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"golang-gin-app/internal/app"
 )
 
 func main() {
 	appInstance := app.NewApp()
 
-	// Start the server
+	// 帶有子命令時執行命令列工具，不啟動伺服器
+	if len(os.Args) > 1 {
+		err := appInstance.RunCommand(os.Args[1:])
+		appInstance.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Start the server，收到 SIGINT 或 SIGTERM 時等待進行中的請求完成後結束
 	if err := appInstance.Run(""); err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
@@ -1,137 +1,511 @@
 package app
 
 import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
 	"database/sql"
 	"fmt"
 	"golang-gin-app/internal/handlers"
+	"golang-gin-app/internal/jobs"
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/metrics"
+	"golang-gin-app/internal/migrations"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/ratelimit"
 	"golang-gin-app/internal/repository"
 	"golang-gin-app/internal/service"
+	"golang-gin-app/internal/tenant"
+	"golang-gin-app/internal/tracing"
+	"golang-gin-app/internal/utils"
+	"golang-gin-app/pkg/middleware"
 	"html/template"
+	"log/slog"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// DatabaseConfig 單一租戶資料庫的連線設定
+type DatabaseConfig struct {
+	Tenant      string // 租戶名稱，用於 URL 查詢參數、X-Tenant 標頭與頁面下拉選單
+	Driver      string // mysql 或 sqlite
+	SQLitePath  string // SQLite 檔案路徑，:memory: 表示記憶體資料庫
+	Host        string
+	Port        int
+	User        string
+	Password    string
+	Name        string
+	AutoMigrate bool // 啟動時自動套用尚未套用的遷移
+	Pool        PoolConfig
+}
+
+// PoolConfig 資料庫連線池設定，0 表示不限制；SQLite 固定使用單一連線，不套用此設定
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// RetryConfig 連線失敗時的重試設定，每次重試的等待時間加倍，直到 MaxBackoff
+type RetryConfig struct {
+	Retries        int // 啟動時預設租戶的重試次數
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
 // Config struct to hold configuration
 type Config struct {
-	Server struct {
-		Port int
-	}
-	Database struct {
-		Host     string
-		Port     int
-		User     string
-		Password string
-		Name     string
-	}
-	DatabaseSecondary struct {
-		Host     string
-		Port     int
-		User     string
-		Password string
-		Name     string
-	}
-	Log struct {
+	Server        ServerConfig
+	Databases     []DatabaseConfig // 所有租戶資料庫，依設定順序顯示
+	DefaultTenant string           // 未指定租戶時使用的租戶，必須能連線
+	Connect       RetryConfig      // 預設租戶啟動時的重試與其他租戶背景重新連線的間隔
+	Log           struct {
 		Level  string
 		Format string
 	}
-	JWT struct {
+	// RateLimit 生成端點的請求頻率與每日配額
+	RateLimit ratelimit.Config
+	// JobWorkers 同時執行的背景生成工作數量
+	JobWorkers int
+	// AuditUserHeader 稽核紀錄中識別操作者的請求標頭
+	AuditUserHeader string
+	// Tracing OpenTelemetry 追蹤設定，預設不匯出
+	Tracing tracing.Config
+	JWT     struct {
 		Secret     string
 		Expiration string
 	}
+	// AnonymizationKey 病患去識別化的 HMAC 金鑰，相同金鑰下相同的原始值會得到相同的假名
+	AnonymizationKey string
+	// TemplateDir HTML 模板所在的目錄，預設為相對於工作目錄的 templates
+	TemplateDir string
 }
 
 type App struct {
-	Router           *gin.Engine
-	DB               *sql.DB
-	DBSecondary      *sql.DB
-	Config           *Config
-	Service          *service.Service
-	ServiceSecondary *service.Service
+	Router  *gin.Engine
+	Config  *Config
+	Tenants *tenant.Registry
+	Logger  *slog.Logger
+	Limiter *ratelimit.Limiter
+	// Jobs 執行背景生成工作，伺服器啟動後才開始執行
+	Jobs *jobs.Runner
+	// Progress 轉發同步生成請求的進度給 /progress/:id/events
+	Progress *jobs.Hub
+
+	// stopReconnect 停止背景重新連線
+	stopReconnect context.CancelFunc
+	// stopSweep 停止定期排入工作，在 Start 後設定
+	stopSweep context.CancelFunc
+	// shutdownTracing 送出尚未匯出的 span
+	shutdownTracing func(context.Context) error
+	// unregisterDBStats 移除本 App 的資料庫連線池指標來源
+	unregisterDBStats func()
+	closeOnce         sync.Once
+	closeErr          error
+
+	// server 與 serveErr 在 Start 後設定，serveErr 收到 Serve 結束的結果
+	server   *http.Server
+	serveErr chan error
 }
 
 func NewApp() *App {
 	config := loadConfig()
-	fmt.Printf("Attempting to connect to primary database with settings: Host=%s, Port=%d, User=%s, DB=%s\n",
-		config.Database.Host, config.Database.Port, config.Database.User, config.Database.Name)
-	db, err := initDB(config, "primary")
+
+	// 日誌輸出到 stderr，命令列子命令的結果則輸出到 stdout
+	logger, err := logging.New(config.Log.Level, config.Log.Format, os.Stderr)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to connect to primary database: %v. Please ensure your MariaDB server is running and set the correct credentials using environment variables: DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, DB_NAME", err))
-	}
-	// Secondary database connection is optional
-	fmt.Printf("Attempting to connect to secondary database with settings: Host=%s, Port=%d, User=%s, DB=%s\n",
-		config.DatabaseSecondary.Host, config.DatabaseSecondary.Port, config.DatabaseSecondary.User, config.DatabaseSecondary.Name)
-	dbSecondary, err := initDB(config, "secondary")
-	var svcSecondary *service.Service
-	if err == nil {
-		repoSecondary := repository.NewUserRepository(dbSecondary)
-		svcSecondary = service.NewService(repoSecondary)
-	} else {
-		fmt.Printf("Warning: Could not connect to secondary database: %v. Secondary API will be disabled. Set environment variables DB_SECONDARY_HOST, DB_SECONDARY_PORT, DB_SECONDARY_USER, DB_SECONDARY_PASSWORD, DB_SECONDARY_NAME if needed.\n", err)
-		svcSecondary = nil
-		dbSecondary = nil
+		panic(fmt.Sprintf("Invalid LOG_LEVEL or LOG_FORMAT: %v", err))
+	}
+	slog.SetDefault(logger)
+
+	// stdout 匯出的 span 與日誌同樣寫入 stderr，避免混入命令列子命令的輸出
+	shutdownTracing, err := tracing.Setup(context.Background(), config.Tracing, os.Stderr)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid tracing configuration: %v", err))
 	}
-	repo := repository.NewUserRepository(db)
-	svc := service.NewService(repo)
-	router := gin.Default()
+
+	anonymizer := newAnonymizer(logger, config.AnonymizationKey)
+
+	reconnectCtx, stopReconnect := context.WithCancel(logging.WithLogger(context.Background(), logger))
+	registry := tenant.NewRegistry(config.DefaultTenant)
+	// 背景工作不隨背景重新連線停止，由 Jobs.Stop 中斷
+	runner := jobs.NewRunner(logging.WithLogger(context.Background(), logger), jobExecutor(registry))
+	for _, dbConfig := range config.Databases {
+		// 預設租戶必須可用，啟動時依設定重試；其他租戶只嘗試一次，失敗時在背景重新連線
+		retry := config.Connect
+		if dbConfig.Tenant != config.DefaultTenant {
+			retry.Retries = 0
+		}
+		tenantCtx := logging.With(reconnectCtx, logging.KeyTenant, dbConfig.Tenant)
+		t, err := openTenant(tenantCtx, dbConfig, retry, anonymizer)
+		if err != nil {
+			if dbConfig.Tenant == config.DefaultTenant {
+				panic(fmt.Sprintf("Failed to connect to database for default tenant %s: %v. Please ensure your MariaDB server is running and set the correct credentials using environment variables: DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, DB_NAME (or DB_<TENANT>_HOST etc.), or set DB_DRIVER=sqlite to use a local SQLite database", dbConfig.Tenant, err))
+			}
+			logging.FromContext(tenantCtx).Warn("Could not connect to database. Tenant is unavailable and will reconnect in the background",
+				logging.KeyError, err.Error(),
+				"hint", fmt.Sprintf("set DB_%[1]s_HOST, DB_%[1]s_PORT, DB_%[1]s_USER, DB_%[1]s_PASSWORD, DB_%[1]s_NAME if needed", envName(dbConfig.Tenant)))
+			registry.MarkUnavailable(dbConfig.Tenant, err)
+			go reconnectTenant(tenantCtx, registry, runner, dbConfig, config.Connect, anonymizer)
+			continue
+		}
+		if err := registry.Register(t); err != nil {
+			panic(err.Error())
+		}
+	}
+
+	router := gin.New()
+	// gin 預設信任所有代理的 X-Forwarded-For；本服務不設定信任的代理，用戶端位址一律為連線的來源位址
+	router.ForwardedByClientIP = false
+	router.TrustedProxies = nil
 	app := &App{
-		Router:           router,
-		DB:               db,
-		DBSecondary:      dbSecondary,
-		Config:           config,
-		Service:          svc,
-		ServiceSecondary: svcSecondary,
+		Router:        router,
+		Config:        config,
+		Tenants:       registry,
+		Logger:        logger,
+		Limiter:       ratelimit.New(config.RateLimit),
+		Jobs:          runner,
+		Progress:      jobs.NewHub(),
+		stopReconnect: stopReconnect,
+
+		shutdownTracing: shutdownTracing,
 	}
+	app.unregisterDBStats = metrics.RegisterDBStats(app.dbStats)
 	app.initializeMiddleware()
 	app.initializeRoutes()
 	app.loadTemplates()
 	return app
 }
 
+// newAnonymizer 以設定的金鑰建立 Anonymizer；未設定時使用隨機金鑰，假名只在本次執行期間一致
+func newAnonymizer(logger *slog.Logger, key string) *utils.Anonymizer {
+	if key == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			panic(fmt.Sprintf("Failed to generate anonymization key: %v", err))
+		}
+		logger.Warn("ANONYMIZE_KEY is not set. Using a random key, so pseudonyms will change after a restart")
+		key = string(random)
+	}
+	anonymizer, err := utils.NewAnonymizer([]byte(key))
+	if err != nil {
+		panic(fmt.Sprintf("Invalid ANONYMIZE_KEY: %v", err))
+	}
+	return anonymizer
+}
+
+// dbStats 回傳所有租戶（含尚未連線者）的資料庫連線池統計，供 Prometheus 收集
+func (a *App) dbStats() []metrics.DBStats {
+	statuses := a.Tenants.Statuses()
+	stats := make([]metrics.DBStats, 0, len(statuses))
+	for _, status := range statuses {
+		db := metrics.DBStats{Tenant: status.Name, Up: status.Available}
+		if t, ok := a.Tenants.Get(status.Name); ok && t.DB != nil {
+			db.Stats = t.DB.Stats()
+		}
+		stats = append(stats, db)
+	}
+	return stats
+}
+
+// Close 停止背景重新連線與背景工作、關閉所有租戶的資料庫連線，並送出尚未匯出的 span；重複呼叫時回傳第一次的結果
+func (a *App) Close() error {
+	a.closeOnce.Do(func() {
+		a.stopReconnect()
+		if a.stopSweep != nil {
+			a.stopSweep()
+		}
+		a.unregisterDBStats()
+		// 經由 Shutdown 結束時背景工作已停止；直接呼叫 Close 時中斷執行中的工作並放回佇列
+		stopped, cancel := context.WithCancel(context.Background())
+		cancel()
+		a.Jobs.Stop(stopped)
+		a.closeErr = a.Tenants.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := a.shutdownTracing(ctx); err != nil && a.closeErr == nil {
+			a.closeErr = err
+		}
+	})
+	return a.closeErr
+}
+
+// jobExecutor 在工作所屬租戶的 Service 上執行工作；租戶暫時無法使用時工作留在佇列中，重新連線後再排入
+func jobExecutor(registry *tenant.Registry) jobs.Executor {
+	return func(ctx context.Context, task jobs.Task, observe func(models.Job)) error {
+		t, ok := registry.Get(task.Tenant)
+		if !ok {
+			return fmt.Errorf("租戶 %s 目前無法使用", task.Tenant)
+		}
+		ctx = logging.With(ctx, logging.KeyTenant, task.Tenant)
+		return t.Service.RunJob(ctx, task.JobID, observe)
+	}
+}
+
+// resumeJobs 將租戶中上次未完成的背景工作重新排入，失敗時只記錄警告。
+// 執行中的工作只有在執行的程序已無心跳時才會放回佇列，因此多個伺服器程序可共用同一個資料庫
+func resumeJobs(ctx context.Context, runner *jobs.Runner, t *tenant.Tenant) {
+	ids, err := t.Service.ResumeJobs(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Could not resume background jobs", logging.KeyError, err.Error())
+		return
+	}
+	for _, id := range ids {
+		if err := runner.Enqueue(jobs.Task{Tenant: t.Name, JobID: id}); err != nil {
+			return
+		}
+	}
+	if len(ids) > 0 {
+		logging.FromContext(ctx).Info("Resumed background jobs", "count", len(ids))
+	}
+}
+
+// sweepJobs 定期排入所有租戶排隊中的工作，並接手心跳逾時的工作，直到 ctx 被取消；
+// 讓其他程序送出的工作或已結束的程序中斷的工作也能在本程序執行
+func (a *App) sweepJobs(ctx context.Context) {
+	ticker := time.NewTicker(service.JobHeartbeatTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, t := range a.Tenants.List() {
+			tenantCtx := logging.With(ctx, logging.KeyTenant, t.Name)
+			ids, err := t.Service.ResumeJobs(tenantCtx)
+			if err != nil {
+				logging.FromContext(tenantCtx).Warn("Could not resume background jobs", logging.KeyError, err.Error())
+				continue
+			}
+			for _, id := range ids {
+				if err := a.Jobs.Enqueue(jobs.Task{Tenant: t.Name, JobID: id}); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// openTenant 連線租戶資料庫（失敗時依 retry 重試）、視設定套用遷移，並建立該租戶的 Service
+func openTenant(ctx context.Context, dbConfig DatabaseConfig, retry RetryConfig, anonymizer *utils.Anonymizer) (*tenant.Tenant, error) {
+	dialect, err := repository.ParseDialect(dbConfig.Driver)
+	if err != nil {
+		return nil, err
+	}
+	logger := logging.FromContext(ctx)
+	if dialect == repository.DialectSQLite {
+		logger.Info("Attempting to open SQLite database", "path", dbConfig.SQLitePath)
+	} else {
+		logger.Info("Attempting to connect to database",
+			"host", dbConfig.Host, "port", dbConfig.Port, "user", dbConfig.User, "db", dbConfig.Name)
+	}
+
+	db, err := connectWithRetry(ctx, dbConfig, retry)
+	if err != nil {
+		return nil, err
+	}
+	if dbConfig.AutoMigrate {
+		if err := autoMigrate(ctx, db, dialect, dbConfig.Tenant); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	store, err := repository.NewStore(db, dialect)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	svc := service.NewService(store)
+	svc.SetAnonymizer(anonymizer)
+	// 啟動時載入疾病目錄，失敗時在首次使用時重試
+	if err := svc.RefreshCatalogue(ctx); err != nil {
+		logger.Warn("Could not load disease catalogue", logging.KeyError, err.Error())
+	}
+	return &tenant.Tenant{Name: dbConfig.Tenant, DB: db, Dialect: dialect, Service: svc}, nil
+}
+
 func loadConfig() *Config {
 	// Load configuration from environment variables or use defaults
-	return &Config{
-		Server: struct {
-			Port int
-		}{Port: getEnvInt("SERVER_PORT", 5000)},
-		Database: struct {
-			Host     string
-			Port     int
-			User     string
-			Password string
-			Name     string
-		}{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnvInt("DB_PORT", 3306),
-			User:     getEnv("DB_USER", "root"),
-			Password: getEnv("DB_PASSWORD", "P@ssw0rd"),
-			Name:     getEnv("DB_NAME", "dtxcasemgnt"),
+	config := &Config{}
+	config.Server = ServerConfig{
+		Port:              getEnvInt("SERVER_PORT", 5000),
+		ReadTimeout:       getEnvDuration("SERVER_READ_TIMEOUT", 30*time.Second),
+		ReadHeaderTimeout: getEnvDuration("SERVER_READ_HEADER_TIMEOUT", 10*time.Second),
+		// 生成大量假資料的請求可能需要數分鐘
+		WriteTimeout:    getEnvDuration("SERVER_WRITE_TIMEOUT", 5*time.Minute),
+		IdleTimeout:     getEnvDuration("SERVER_IDLE_TIMEOUT", 2*time.Minute),
+		ShutdownTimeout: getEnvDuration("SERVER_SHUTDOWN_TIMEOUT", 30*time.Second),
+
+		MaxBodyBytes: int64(getEnvInt("SERVER_MAX_BODY_BYTES", 10<<20)),
+		Gzip:         getEnvBool("SERVER_GZIP", true),
+		Security: middleware.SecurityConfig{
+			ContentSecurityPolicy: getEnv("SERVER_CONTENT_SECURITY_POLICY", middleware.DefaultContentSecurityPolicy),
+			HSTSMaxAge:            getEnvDuration("SERVER_HSTS_MAX_AGE", 0),
+		},
+		CORS: middleware.CORSConfig{
+			AllowedOrigins:   getEnvList("CORS_ALLOWED_ORIGINS", nil),
+			AllowedMethods:   getEnvList("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE"}),
+			AllowedHeaders:   getEnvList("CORS_ALLOWED_HEADERS", []string{"Content-Type", "X-Tenant", middleware.RequestIDHeader, "traceparent"}),
+			ExposedHeaders:   []string{middleware.RequestIDHeader, middleware.ErrorIDHeader},
+			AllowCredentials: getEnvBool("CORS_ALLOW_CREDENTIALS", false),
+			MaxAge:           getEnvDuration("CORS_MAX_AGE", 10*time.Minute),
+		},
+	}
+
+	// DB_TENANTS 列出所有租戶；每個租戶以 DB_<TENANT>_* 設定，
+	// 前兩個租戶另外沿用原本的 DB_* 與 DB_SECONDARY_* 環境變數
+	tenants := strings.Split(getEnv("DB_TENANTS", "dtxcasemgnt,dtxtraining"), ",")
+	for i, name := range tenants {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		legacyPrefix := ""
+		switch i {
+		case 0:
+			legacyPrefix = "DB_"
+		case 1:
+			legacyPrefix = "DB_SECONDARY_"
+		}
+		env := tenantEnv{prefix: "DB_" + envName(name) + "_", legacyPrefix: legacyPrefix}
+
+		driver := env.get("DRIVER", getEnv("DB_DRIVER", "mysql"))
+		config.Databases = append(config.Databases, DatabaseConfig{
+			Tenant:     name,
+			Driver:     driver,
+			SQLitePath: env.get("SQLITE_PATH", name+".db"),
+			Host:       env.get("HOST", "localhost"),
+			Port:       env.getInt("PORT", 3306),
+			User:       env.get("USER", "root"),
+			Password:   env.get("PASSWORD", "P@ssw0rd"),
+			Name:       env.get("NAME", name),
+			// SQLite 通常是全新的本機檔案或記憶體資料庫，預設自動建立資料表
+			AutoMigrate: env.getBool("AUTO_MIGRATE", getEnvBool("DB_AUTO_MIGRATE", driver == string(repository.DialectSQLite))),
+			Pool: PoolConfig{
+				MaxOpenConns:    env.getInt("MAX_OPEN_CONNS", getEnvInt("DB_MAX_OPEN_CONNS", 25)),
+				MaxIdleConns:    env.getInt("MAX_IDLE_CONNS", getEnvInt("DB_MAX_IDLE_CONNS", 10)),
+				ConnMaxLifetime: env.getDuration("CONN_MAX_LIFETIME", getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute)),
+				ConnMaxIdleTime: env.getDuration("CONN_MAX_IDLE_TIME", getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute)),
+			},
+		})
+	}
+	if len(config.Databases) == 0 {
+		panic("DB_TENANTS must list at least one tenant")
+	}
+
+	config.Connect = RetryConfig{
+		Retries:        getEnvInt("DB_CONNECT_RETRIES", 5),
+		InitialBackoff: getEnvDuration("DB_CONNECT_BACKOFF", time.Second),
+		MaxBackoff:     getEnvDuration("DB_CONNECT_MAX_BACKOFF", 30*time.Second),
+	}
+
+	config.DefaultTenant = getEnv("DB_DEFAULT_TENANT", config.Databases[0].Tenant)
+	found := false
+	for _, dbConfig := range config.Databases {
+		found = found || dbConfig.Tenant == config.DefaultTenant
+	}
+	if !found {
+		panic(fmt.Sprintf("DB_DEFAULT_TENANT %s is not listed in DB_TENANTS", config.DefaultTenant))
+	}
+
+	config.Log.Level = getEnv("LOG_LEVEL", "info")
+	config.Log.Format = getEnv("LOG_FORMAT", "json")
+	config.RateLimit = ratelimit.Config{
+		Client: ratelimit.Limit{
+			PerMinute: getEnvInt("RATE_LIMIT_CLIENT_PER_MINUTE", 30),
+			Burst:     getEnvInt("RATE_LIMIT_CLIENT_BURST", 10),
 		},
-		DatabaseSecondary: struct {
-			Host     string
-			Port     int
-			User     string
-			Password string
-			Name     string
-		}{
-			Host:     getEnv("DB_SECONDARY_HOST", "localhost"),
-			Port:     getEnvInt("DB_SECONDARY_PORT", 3306),
-			User:     getEnv("DB_SECONDARY_USER", "root"),
-			Password: getEnv("DB_SECONDARY_PASSWORD", "P@ssw0rd"),
-			Name:     getEnv("DB_SECONDARY_NAME", "dtxtraining"),
+		User: ratelimit.Limit{
+			PerMinute: getEnvInt("RATE_LIMIT_USER_PER_MINUTE", 60),
+			Burst:     getEnvInt("RATE_LIMIT_USER_BURST", 20),
 		},
-		Log: struct {
-			Level  string
-			Format string
-		}{Level: "info", Format: "json"},
-		JWT: struct {
-			Secret     string
-			Expiration string
-		}{Secret: "your_jwt_secret", Expiration: "24h"},
+		ClientDailyQuota: getEnvInt("RATE_LIMIT_CLIENT_DAILY_QUOTA", 20000),
+		UserDailyQuota:   getEnvInt("RATE_LIMIT_USER_DAILY_QUOTA", 50000),
+		UserHeader:       getEnv("RATE_LIMIT_USER_HEADER", "X-Forwarded-User"),
+	}
+	config.JobWorkers = getEnvInt("JOB_WORKERS", 2)
+	config.AuditUserHeader = getEnv("AUDIT_USER_HEADER", "X-Forwarded-User")
+	config.Tracing = tracing.Config{
+		Exporter:    getEnv("TRACING_EXPORTER", tracing.ExporterNone),
+		ServiceName: getEnv("OTEL_SERVICE_NAME", "golang-gin-app"),
+		SampleRatio: getEnvFloat("TRACING_SAMPLE_RATIO", 1),
+	}
+	config.JWT.Secret, config.JWT.Expiration = "your_jwt_secret", "24h"
+	config.AnonymizationKey = getEnv("ANONYMIZE_KEY", "")
+	config.TemplateDir = getEnv("TEMPLATE_DIR", "templates")
+	return config
+}
+
+// envName 將租戶名稱轉為環境變數名稱的一部分，例如 dtx-training 轉為 DTX_TRAINING
+func envName(tenantName string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, tenantName)
+}
+
+// tenantEnv 讀取租戶設定的環境變數，依序查找 DB_<TENANT>_<KEY>、舊有的環境變數與預設值
+type tenantEnv struct {
+	prefix       string
+	legacyPrefix string // 為空時不查找舊有的環境變數
+}
+
+func (e tenantEnv) lookup(key string) (string, bool) {
+	if value, exists := os.LookupEnv(e.prefix + key); exists {
+		return value, true
+	}
+	if e.legacyPrefix != "" {
+		return os.LookupEnv(e.legacyPrefix + key)
 	}
+	return "", false
+}
+
+func (e tenantEnv) get(key, defaultValue string) string {
+	if value, ok := e.lookup(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+func (e tenantEnv) getInt(key string, defaultValue int) int {
+	if value, ok := e.lookup(key); ok {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+func (e tenantEnv) getBool(key string, defaultValue bool) bool {
+	if value, ok := e.lookup(key); ok {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func (e tenantEnv) getDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, ok := e.lookup(key); ok {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
 }
 
 func getEnv(key, defaultValue string) string {
@@ -150,21 +524,82 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
-func initDB(config *Config, dbType string) (*sql.DB, error) {
-	var dsn string
-	if dbType == "primary" {
-		dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-			config.Database.User, config.Database.Password, config.Database.Host,
-			config.Database.Port, config.Database.Name)
-	} else {
-		dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-			config.DatabaseSecondary.User, config.DatabaseSecondary.Password, config.DatabaseSecondary.Host,
-			config.DatabaseSecondary.Port, config.DatabaseSecondary.Name)
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList 讀取以逗號分隔的清單，忽略空白項目
+func getEnvList(key string, defaultValue []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// autoMigrate 在啟動時套用尚未套用的遷移
+func autoMigrate(ctx context.Context, db *sql.DB, dialect repository.Dialect, tenantName string) error {
+	migrator, err := migrations.NewMigrator(db, string(dialect))
+	if err != nil {
+		return err
 	}
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to migrate database for tenant %s: %v", tenantName, err)
+	}
+	for _, migration := range applied {
+		logging.FromContext(ctx).Info("Applied migration", "version", migration.Version, "name", migration.Name)
+	}
+	return nil
+}
+
+func initDB(dbConfig DatabaseConfig) (*sql.DB, error) {
+	dialect, err := repository.ParseDialect(dbConfig.Driver)
+	if err != nil {
+		return nil, err
+	}
+	if dialect == repository.DialectSQLite {
+		return repository.OpenSQLite(dbConfig.SQLitePath)
+	}
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Name)
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		return nil, err
 	}
+	db.SetMaxOpenConns(dbConfig.Pool.MaxOpenConns)
+	db.SetMaxIdleConns(dbConfig.Pool.MaxIdleConns)
+	db.SetConnMaxLifetime(dbConfig.Pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(dbConfig.Pool.ConnMaxIdleTime)
 	if err := db.Ping(); err != nil {
 		db.Close()
 		return nil, err
@@ -175,38 +610,102 @@ func initDB(config *Config, dbType string) (*sql.DB, error) {
 func (a *App) initializeRoutes() {
 	// Initialize your routes here
 	a.Router.GET("/hello", handlers.HelloHandler)
-	a.Router.GET("/fake-users", handlers.GenerateFakeUsersFormHandler(a.Service))
-	a.Router.POST("/fake-users", handlers.GenerateFakeUsersHandler(a.Service))
+
+	// 健康檢查路由，供負載平衡器與容器編排系統探測
+	a.Router.GET("/healthz", handlers.HealthzHandler)
+	a.Router.GET("/readyz", handlers.ReadyzHandler(a.Tenants))
+	a.Router.GET("/version", handlers.VersionHandler)
+	a.Router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})))
+
+	a.Router.GET("/api/db/stats", handlers.DatabaseStatsHandler(a.Tenants))
+
+	// 生成請求限制的使用狀況
+	a.Router.GET("/admin/rate-limits", handlers.RateLimitsPageHandler(a.Limiter))
+	a.Router.GET("/api/rate-limits", handlers.RateLimitsHandler(a.Limiter))
+
+	// 以下路由都會依 ?tenant=、X-Tenant 標頭或下拉選單選擇的租戶操作對應的資料庫；
+	// 背景重新連線中的租戶回傳 503，連線成功後即可使用
+	r := a.Router.Group("/", handlers.TenantMiddleware(a.Tenants), handlers.AuditMiddleware(a.Config.AuditUserHeader))
+	r.GET("/api/tenants", handlers.ListTenantsHandler(a.Tenants))
+	r.POST("/tenants/switch", handlers.SwitchTenantHandler(a.Tenants))
+
+	r.GET("/fake-users", handlers.GenerateFakeUsersFormHandler())
+	r.POST("/fake-users", handlers.GenerateFakeUsersHandler(a.Limiter, a.Jobs, a.Progress))
 
 	// 新增假病患生成路由
-	a.Router.GET("/fake-patients", handlers.GenerateFakePatientsFormHandler())
-	a.Router.POST("/fake-patients", handlers.GenerateFakePatientsHandler(a.DB))
+	r.GET("/fake-patients", handlers.GenerateFakePatientsFormHandler())
+	r.POST("/fake-patients", handlers.GenerateFakePatientsHandler(a.Limiter, a.Jobs, a.Progress))
+	r.GET("/api/fake-patients/profiles", handlers.ListPatientProfilesHandler())
+	r.POST("/api/fake-patients", handlers.GenerateFakePatientsAPIHandler(a.Limiter))
+	r.POST("/api/patients/anonymize", handlers.AnonymizePatientsHandler(a.Tenants))
+
+	// 疾病目錄路由
+	r.GET("/api/catalogue", handlers.GetCatalogueHandler())
+	r.POST("/api/catalogue/refresh", handlers.RefreshCatalogueHandler())
 
 	// 新增可預約時段管理路由
-	a.Router.GET("/available-slots", handlers.AvailableSlotsFormHandler(a.Service))
-	a.Router.POST("/available-slots/generate", handlers.GenerateAvailableSlotsHandler(a.Service))
-	a.Router.GET("/available-slots/view", handlers.ViewAvailableSlotsHandler(a.Service))
+	r.GET("/available-slots", handlers.AvailableSlotsFormHandler())
+	r.POST("/available-slots/generate", handlers.GenerateAvailableSlotsHandler(a.Limiter, a.Jobs, a.Progress))
+	r.GET("/available-slots/view", handlers.ViewAvailableSlotsHandler())
 	// 時段編輯與刪除路由
-	a.Router.GET("/available-slots/edit/:id", handlers.EditAvailableSlotFormHandler(a.Service))
-	a.Router.POST("/available-slots/update/:id", handlers.UpdateAvailableSlotHandler(a.Service))
-	a.Router.POST("/available-slots/delete/:id", handlers.DeleteAvailableSlotHandler(a.Service))
-	a.Router.DELETE("/available-slots/delete/:id", handlers.DeleteAvailableSlotHandler(a.Service))
+	r.GET("/available-slots/edit/:id", handlers.EditAvailableSlotFormHandler())
+	r.POST("/available-slots/update/:id", handlers.UpdateAvailableSlotHandler())
+	r.POST("/available-slots/delete/:id", handlers.DeleteAvailableSlotHandler())
+	r.DELETE("/available-slots/delete/:id", handlers.DeleteAvailableSlotHandler())
 
-	// Route for secondary database API, only if connection succeeded
-	if a.ServiceSecondary != nil {
-		a.Router.GET("/fake-users-secondary", handlers.GenerateFakeUsersFormHandler(a.ServiceSecondary))
-		a.Router.POST("/fake-users-secondary", handlers.GenerateFakeUsersHandler(a.ServiceSecondary))
-	}
+	// 生成批次管理與清除路由
+	r.GET("/batches", handlers.BatchesPageHandler())
+	r.POST("/batches/:id/purge", handlers.PurgeBatchPageHandler())
+	r.GET("/api/batches", handlers.ListBatchesHandler())
+	r.POST("/api/batches/:id/purge", handlers.PurgeBatchHandler())
 
-	repoRole := repository.NewRoleRepository(a.DB)
-	a.Router.POST("/roles/add", handlers.AddRoleHandler(repoRole))
-	a.Router.POST("/roles/delete/:id", handlers.DeleteRoleHandler(repoRole))
+	// 同步生成請求的進度串流，表單送出時帶有 progressID
+	r.GET("/progress/:id/events", handlers.ProgressEventsHandler(a.Progress))
+
+	// 背景生成工作路由；生成表單勾選背景執行時也會建立工作
+	r.GET("/jobs", handlers.JobsPageHandler(a.Jobs))
+	r.GET("/jobs/:id", handlers.JobPageHandler(a.Jobs))
+	r.POST("/jobs/:id/cancel", handlers.CancelJobPageHandler(a.Jobs))
+	r.GET("/api/jobs", handlers.ListJobsHandler(a.Jobs))
+	r.POST("/api/jobs", handlers.SubmitJobHandler(a.Jobs, a.Limiter))
+	r.GET("/api/jobs/:id", handlers.GetJobHandler(a.Jobs))
+	r.GET("/api/jobs/:id/events", handlers.JobEventsHandler(a.Jobs))
+	r.POST("/api/jobs/:id/cancel", handlers.CancelJobHandler(a.Jobs))
+
+	// 跨租戶複製路由，來源為目前的租戶
+	r.GET("/copy", handlers.CopyPageHandler(a.Tenants))
+	r.POST("/copy", handlers.CopyHandler(a.Tenants))
+	r.POST("/api/copy", handlers.CopyAPIHandler(a.Tenants))
+
+	// 角色管理路由
+	r.GET("/roles", handlers.ManageRolesPageHandler())
+	r.POST("/roles/add", handlers.AddRoleHandler())
+	r.POST("/roles/delete/:id", handlers.DeleteRoleHandler())
+
+	// 稽核紀錄
+	r.GET("/audit", handlers.AuditPageHandler())
+	r.GET("/audit/export", handlers.ExportAuditHandler())
+	r.GET("/api/audit", handlers.ListAuditHandler())
 }
 
 func (a *App) initializeMiddleware() {
 	// Initialize your middleware here
-	a.Router.Use(gin.Logger())
-	a.Router.Use(gin.Recovery())
+	// 結構化請求日誌（含請求 ID）、追蹤與請求指標，Recovery 在其後以便 panic 也以 500 記錄在同一個請求 ID 與 span 下
+	a.Router.Use(middleware.Logger(a.Logger))
+	a.Router.Use(middleware.Tracing())
+	a.Router.Use(middleware.Metrics())
+	a.Router.Use(middleware.Recovery())
+
+	server := a.Config.Server
+	a.Router.Use(middleware.SecurityHeaders(server.Security))
+	if server.CORS.Enabled() {
+		a.Router.Use(middleware.CORS(server.CORS))
+	}
+	a.Router.Use(middleware.BodyLimit(server.MaxBodyBytes))
+	if server.Gzip {
+		// promhttp 自行處理壓縮
+		a.Router.Use(middleware.Gzip(gzip.DefaultCompression, "/metrics"))
+	}
 
 	// 添加靜態文件服務
 	a.Router.Static("/static", "./static")
@@ -233,12 +732,5 @@ func (a *App) loadTemplates() {
 		},
 	})
 	// Load HTML templates
-	a.Router.LoadHTMLGlob("templates/*")
-}
-
-func (a *App) Run(addr string) error {
-	if addr == "" {
-		addr = fmt.Sprintf(":%d", a.Config.Server.Port)
-	}
-	return a.Router.Run(addr)
+	a.Router.LoadHTMLGlob(filepath.Join(a.Config.TemplateDir, "*"))
 }
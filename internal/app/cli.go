@@ -0,0 +1,289 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/migrations"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/tenant"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RunCommand 執行命令列子命令，args 不含程式名稱
+func (a *App) RunCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("請指定子命令")
+	}
+	ctx := logging.WithLogger(context.Background(), a.Logger)
+
+	switch args[0] {
+	case "batches":
+		return a.listBatchesCommand(ctx, args[1:], os.Stdout)
+	case "purge-batch":
+		return a.purgeBatchCommand(ctx, args[1:], os.Stdout)
+	case "migrate":
+		return a.migrateCommand(ctx, args[1:], os.Stdout)
+	case "copy":
+		return a.copyCommand(ctx, args[1:], os.Stdout)
+	case "anonymize":
+		return a.anonymizeCommand(ctx, args[1:], os.Stdout)
+	default:
+		return fmt.Errorf("未知的子命令: %s（可用: batches, purge-batch, migrate, copy, anonymize）", args[0])
+	}
+}
+
+// tenantFlag 為子命令加入 -tenant 參數，未指定時使用預設租戶
+func (a *App) tenantFlag(fs *flag.FlagSet) *string {
+	return fs.String("tenant", a.Tenants.DefaultName(), "目標租戶（可用: "+strings.Join(a.Tenants.Names(), ", ")+"）")
+}
+
+// lookupTenant 依名稱取得已連線的租戶
+func (a *App) lookupTenant(name string) (*tenant.Tenant, error) {
+	t, ok := a.Tenants.Get(name)
+	if !ok {
+		if err := a.Tenants.Unavailable(name); err != nil {
+			return nil, fmt.Errorf("租戶 %s 的資料庫無法連線: %v", name, err)
+		}
+		return nil, fmt.Errorf("租戶 %s 不存在或未連線（可用: %s）", name, strings.Join(a.Tenants.Names(), ", "))
+	}
+	return t, nil
+}
+
+// listBatchesCommand 列出最近的生成批次，用法: batches [-tenant NAME]
+func (a *App) listBatchesCommand(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("batches", flag.ContinueOnError)
+	tenantName := a.tenantFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	t, err := a.lookupTenant(*tenantName)
+	if err != nil {
+		return err
+	}
+
+	batches, err := t.Service.ListGenerationBatches(ctx)
+	if err != nil {
+		return err
+	}
+	for _, batch := range batches {
+		fmt.Fprintf(out, "%s\t%s\t%s\t%v\n",
+			batch.ID, batch.Kind, batch.CreatedAt.Format("2006-01-02 15:04:05"), batch.Counts)
+	}
+	return nil
+}
+
+// purgeBatchCommand 清除指定批次，用法: purge-batch [-tenant NAME] [-dry-run] <batch-id>
+func (a *App) purgeBatchCommand(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("purge-batch", flag.ContinueOnError)
+	tenantName := a.tenantFlag(fs)
+	dryRun := fs.Bool("dry-run", false, "只顯示將刪除的筆數，不實際刪除")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("用法: purge-batch [-tenant NAME] [-dry-run] <batch-id>")
+	}
+	t, err := a.lookupTenant(*tenantName)
+	if err != nil {
+		return err
+	}
+
+	result, err := t.Service.PurgeGenerationBatch(ctx, fs.Arg(0), *dryRun)
+	if err != nil {
+		return err
+	}
+	if result.DryRun {
+		fmt.Fprintf(out, "批次 %s 預覽（尚未刪除任何資料）:\n", result.BatchID)
+	} else {
+		fmt.Fprintf(out, "批次 %s 已清除:\n", result.BatchID)
+	}
+	for _, step := range result.Counts {
+		fmt.Fprintf(out, "  %-25s %d\n", step.Table, step.Rows)
+	}
+	return nil
+}
+
+// migrateCommand 管理資料庫結構遷移，用法: migrate [-tenant NAME] up|down [-steps N]|status
+func (a *App) migrateCommand(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	tenantName := a.tenantFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return fmt.Errorf("用法: migrate [-tenant NAME] up|down [-steps N]|status")
+	}
+	t, err := a.lookupTenant(*tenantName)
+	if err != nil {
+		return err
+	}
+
+	migrator, err := migrations.NewMigrator(t.DB, string(t.Dialect))
+	if err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Fprintf(out, "已套用 %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "資料庫已是最新版本")
+		}
+		return nil
+	case "down":
+		downFs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := downFs.Int("steps", 1, "回復的版本數")
+		if err := downFs.Parse(fs.Args()[1:]); err != nil {
+			return err
+		}
+		reverted, err := migrator.Down(ctx, *steps)
+		for _, migration := range reverted {
+			fmt.Fprintf(out, "已回復 %04d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "尚未套用"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%04d_%-35s %s\n", status.Version, status.Name, appliedAt)
+		}
+		return nil
+	default:
+		return fmt.Errorf("未知的 migrate 子命令: %s（可用: up, down, status）", fs.Arg(0))
+	}
+}
+
+// parseIDList 解析以逗號分隔的ID清單
+func parseIDList(value string) ([]int64, error) {
+	ids := make([]int64, 0)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("無效的ID: %s", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// copyCommand 將使用者與病患從一個租戶複製到另一個租戶，
+// 用法: copy [-from NAME] -to NAME [-users 1,2] [-patients 3,4] [-slots] [-anonymize] [-dry-run]
+func (a *App) copyCommand(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("copy", flag.ContinueOnError)
+	fromName := fs.String("from", a.Tenants.DefaultName(), "來源租戶（可用: "+strings.Join(a.Tenants.Names(), ", ")+"）")
+	toName := fs.String("to", "", "目標租戶")
+	userList := fs.String("users", "", "要複製的使用者ID，以逗號分隔")
+	patientList := fs.String("patients", "", "要複製的病患ID，以逗號分隔")
+	includeSlots := fs.Bool("slots", false, "一併複製使用者的可預約時段")
+	anonymize := fs.Bool("anonymize", false, "病患個資去識別化")
+	dryRun := fs.Bool("dry-run", false, "只顯示報告，不保留任何寫入")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *toName == "" {
+		return fmt.Errorf("用法: copy [-from NAME] -to NAME [-users 1,2] [-patients 3,4] [-slots] [-anonymize] [-dry-run]")
+	}
+	from, err := a.lookupTenant(*fromName)
+	if err != nil {
+		return err
+	}
+	to, err := a.lookupTenant(*toName)
+	if err != nil {
+		return err
+	}
+
+	req := models.CopyRequest{IncludeSlots: *includeSlots, Anonymize: *anonymize, DryRun: *dryRun}
+	if req.UserIDs, err = parseIDList(*userList); err != nil {
+		return err
+	}
+	if req.PatientIDs, err = parseIDList(*patientList); err != nil {
+		return err
+	}
+
+	report, err := from.Service.CopyTo(ctx, to.Service, req)
+	if err != nil {
+		return err
+	}
+	if report.DryRun {
+		fmt.Fprintf(out, "預覽從 %s 複製到 %s（尚未寫入任何資料）:\n", from.Name, to.Name)
+	} else {
+		fmt.Fprintf(out, "已從 %s 複製到 %s，批次 %s:\n", from.Name, to.Name, report.BatchID)
+	}
+	for _, mapping := range report.Users {
+		fmt.Fprintf(out, "  user    %d -> %d\t%s\n", mapping.SourceID, mapping.TargetID, mapping.Label)
+	}
+	for _, mapping := range report.Patients {
+		fmt.Fprintf(out, "  patient %d -> %d\t%s\n", mapping.SourceID, mapping.TargetID, mapping.Label)
+	}
+	fmt.Fprintf(out, "  slots   %d\n", report.Slots)
+	for _, alias := range report.CreatedRoles {
+		fmt.Fprintf(out, "  新增角色 %s\n", alias)
+	}
+	for _, conflict := range report.Conflicts {
+		fmt.Fprintf(out, "  衝突 %s %d %s: %s\n", conflict.Entity, conflict.SourceID, conflict.Label, conflict.Reason)
+	}
+	return nil
+}
+
+// anonymizeCommand 就地將租戶的病患去識別化，預設租戶需加上 -force，
+// 用法: anonymize -tenant NAME [-patients 1,2] [-dry-run] [-force]
+func (a *App) anonymizeCommand(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("anonymize", flag.ContinueOnError)
+	tenantName := fs.String("tenant", "", "目標租戶（可用: "+strings.Join(a.Tenants.Names(), ", ")+"）")
+	patientList := fs.String("patients", "", "要去識別化的病患ID，以逗號分隔，未指定時處理所有病患")
+	dryRun := fs.Bool("dry-run", false, "只顯示筆數與對照，不實際修改")
+	force := fs.Bool("force", false, "允許在預設租戶上執行")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	// 就地修改資料，必須明確指定租戶
+	if *tenantName == "" {
+		return fmt.Errorf("用法: anonymize -tenant NAME [-patients 1,2] [-dry-run] [-force]")
+	}
+	t, err := a.lookupTenant(*tenantName)
+	if err != nil {
+		return err
+	}
+	if t.Name == a.Tenants.DefaultName() && !*force && !*dryRun {
+		return fmt.Errorf("租戶 %s 是預設租戶，就地去識別化請加上 -force", t.Name)
+	}
+	patientIDs, err := parseIDList(*patientList)
+	if err != nil {
+		return err
+	}
+
+	result, err := t.Service.AnonymizePatients(ctx, patientIDs, *dryRun)
+	if err != nil {
+		return err
+	}
+	if result.DryRun {
+		fmt.Fprintf(out, "預覽去識別化 %s 的 %d 位病患（尚未修改任何資料）:\n", t.Name, result.Count)
+	} else {
+		fmt.Fprintf(out, "已去識別化 %s 的 %d 位病患:\n", t.Name, result.Count)
+	}
+	for _, sample := range result.Samples {
+		fmt.Fprintf(out, "  %d\t%s -> %s\n", sample.ID, sample.Name, sample.Pseudonym)
+	}
+	return nil
+}
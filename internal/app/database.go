@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"golang-gin-app/internal/jobs"
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/tenant"
+	"golang-gin-app/internal/utils"
+	"time"
+)
+
+// connectWithRetry 連線資料庫，失敗時依 retry 以指數退避重試，直到成功、次數用盡或 ctx 取消
+func connectWithRetry(ctx context.Context, dbConfig DatabaseConfig, retry RetryConfig) (*sql.DB, error) {
+	backoff := retry.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		db, err := initDB(dbConfig)
+		if err == nil {
+			return db, nil
+		}
+		if attempt > retry.Retries {
+			return nil, err
+		}
+		logging.FromContext(ctx).Warn("Could not connect to database, retrying",
+			"attempt", attempt, "attempts", retry.Retries+1, "backoff", backoff.String(), logging.KeyError, err.Error())
+		if !sleep(ctx, backoff) {
+			return nil, err
+		}
+		backoff = nextBackoff(backoff, retry.MaxBackoff)
+	}
+}
+
+// reconnectTenant 在背景持續重新連線無法使用的租戶，成功後註冊到 registry 以啟用該租戶的路由。
+// ctx 取消時停止重試。
+func reconnectTenant(ctx context.Context, registry *tenant.Registry, runner *jobs.Runner, dbConfig DatabaseConfig, retry RetryConfig, anonymizer *utils.Anonymizer) {
+	backoff := retry.InitialBackoff
+	for sleep(ctx, backoff) {
+		t, err := openTenant(ctx, dbConfig, RetryConfig{}, anonymizer)
+		if err != nil {
+			registry.MarkUnavailable(dbConfig.Tenant, err)
+			backoff = nextBackoff(backoff, retry.MaxBackoff)
+			continue
+		}
+		if err := registry.Register(t); err != nil {
+			// Registry 已關閉（應用程式結束中）
+			t.DB.Close()
+			return
+		}
+		logging.FromContext(ctx).Info("Reconnected to database")
+		if runner.Started() {
+			resumeJobs(ctx, runner, t)
+		}
+		return
+	}
+}
+
+// nextBackoff 將等待時間加倍，不超過 max
+func nextBackoff(backoff, max time.Duration) time.Duration {
+	backoff *= 2
+	if backoff <= 0 || backoff > max {
+		return max
+	}
+	return backoff
+}
+
+// sleep 等待 d，ctx 在等待期間取消時回傳 false
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/pkg/middleware"
+)
+
+// ServerConfig HTTP 伺服器設定，逾時為 0 表示不限制
+type ServerConfig struct {
+	Port              int
+	ReadTimeout       time.Duration // 讀取整個請求（含內容）的時限
+	ReadHeaderTimeout time.Duration // 讀取請求標頭的時限
+	WriteTimeout      time.Duration // 自讀完請求標頭起寫完回應的時限，需涵蓋最長的生成作業
+	IdleTimeout       time.Duration // keep-alive 連線閒置的時限
+	ShutdownTimeout   time.Duration // 收到結束信號後等待進行中請求完成的時限
+
+	MaxBodyBytes int64 // 請求內容大小上限，0 表示不限制
+	Gzip         bool  // 用戶端接受時壓縮回應
+	Security     middleware.SecurityConfig
+	CORS         middleware.CORSConfig // 未設定允許的來源時不處理跨來源請求
+}
+
+// Start 在 addr 上監聽並於背景提供服務，回傳實際監聽的位址；addr 為空時使用設定的埠號，
+// 埠號為 0 時使用隨機埠號（例如測試時傳入 127.0.0.1:0）
+func (a *App) Start(addr string) (net.Addr, error) {
+	if a.server != nil {
+		return nil, errors.New("伺服器已啟動")
+	}
+	if addr == "" {
+		addr = fmt.Sprintf(":%d", a.Config.Server.Port)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	a.server = &http.Server{
+		Handler:           a.Router,
+		ReadTimeout:       a.Config.Server.ReadTimeout,
+		ReadHeaderTimeout: a.Config.Server.ReadHeaderTimeout,
+		WriteTimeout:      a.Config.Server.WriteTimeout,
+		IdleTimeout:       a.Config.Server.IdleTimeout,
+	}
+	// http.Server.Shutdown 不會取消請求的 context，需主動結束 SSE 串流，否則會等到 ctx 到期
+	a.server.RegisterOnShutdown(func() {
+		a.Progress.CloseAll()
+		a.Jobs.CloseSubscriptions()
+	})
+	a.serveErr = make(chan error, 1)
+	go func() {
+		err := a.server.Serve(listener)
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		a.serveErr <- err
+	}()
+	// 命令列子命令不執行背景工作，因此在伺服器啟動時才重新排入上次未完成的工作
+	for _, t := range a.Tenants.List() {
+		resumeJobs(logging.With(logging.WithLogger(context.Background(), a.Logger), logging.KeyTenant, t.Name), a.Jobs, t)
+	}
+	a.Jobs.Start(a.Config.JobWorkers)
+	sweepCtx, stopSweep := context.WithCancel(logging.WithLogger(context.Background(), a.Logger))
+	a.stopSweep = stopSweep
+	go a.sweepJobs(sweepCtx)
+	a.Logger.Info("Server started", "addr", listener.Addr().String())
+	return listener.Addr(), nil
+}
+
+// Shutdown 停止接受新連線、結束進度串流，並同時等待進行中的請求與背景工作完成；ctx 到期時強制關閉
+// 仍在處理的連線（其請求的 context 隨之取消，未完成的交易會回滾）並中斷背景工作（放回佇列，
+// 下次啟動時重新執行），最後關閉所有資料庫連線
+func (a *App) Shutdown(ctx context.Context) error {
+	// 背景工作與請求共用同一個時限，避免請求用完時限後工作才開始等待
+	jobsStopped := make(chan struct{})
+	go func() {
+		a.Jobs.Stop(ctx)
+		close(jobsStopped)
+	}()
+
+	var err error
+	if a.server != nil {
+		if err = a.server.Shutdown(ctx); err != nil {
+			a.Logger.Warn("Server did not drain in time, closing remaining connections", logging.KeyError, err.Error())
+			a.server.Close()
+		}
+		if serveErr := <-a.serveErr; serveErr != nil && err == nil {
+			err = serveErr
+		}
+	}
+	<-jobsStopped
+	if closeErr := a.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Run 啟動伺服器，直到收到 SIGINT 或 SIGTERM（或伺服器發生錯誤）後，
+// 在 ShutdownTimeout 內等待進行中的請求完成並關閉資料庫連線
+func (a *App) Run(addr string) error {
+	if _, err := a.Start(addr); err != nil {
+		a.Close()
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case sig := <-signals:
+		a.Logger.Info("Shutting down", "signal", sig.String(), "timeout", a.Config.Server.ShutdownTimeout.String())
+	case err := <-a.serveErr:
+		// Serve 已結束，放回結果供 Shutdown 讀取
+		a.serveErr <- err
+	}
+
+	ctx := context.Background()
+	if a.Config.Server.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.Config.Server.ShutdownTimeout)
+		defer cancel()
+	}
+	return a.Shutdown(ctx)
+}
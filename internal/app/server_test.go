@@ -0,0 +1,141 @@
+package app
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestApp 以記憶體中的 SQLite 建立 App
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	for key, value := range map[string]string{
+		"DB_TENANTS":       "smoke",
+		"DB_DRIVER":        "sqlite",
+		"DB_SQLITE_PATH":   ":memory:",
+		"LOG_LEVEL":        "error",
+		"ANONYMIZE_KEY":    "0123456789abcdef0123456789abcdef",
+		"TRACING_EXPORTER": "none",
+	} {
+		t.Setenv(key, value)
+	}
+	// 模板預設以相對於專案根目錄的路徑載入，測試改以絕對路徑指定，不切換工作目錄
+	templates, err := filepath.Abs(filepath.Join("..", "..", "templates"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEMPLATE_DIR", templates)
+	return NewApp()
+}
+
+// newTestClient 建立不重用連線的 HTTP 用戶端，避免閒置的 keep-alive 連線延後 Shutdown 或跨測試重用
+func newTestClient(t *testing.T) *http.Client {
+	t.Helper()
+	transport := &http.Transport{DisableKeepAlives: true}
+	t.Cleanup(transport.CloseIdleConnections)
+	return &http.Client{Transport: transport}
+}
+
+// shutdownTimeout 測試中 Shutdown 的時限，需遠大於 net/http 對尚未送出請求的新連線的 5 秒寬限
+const shutdownTimeout = 30 * time.Second
+
+func TestStartAndShutdownDrainsRequests(t *testing.T) {
+	a := newTestApp(t)
+	client := newTestClient(t)
+	started := make(chan struct{})
+	a.Router.GET("/slow", func(c *gin.Context) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+
+	addr, err := a.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	base := "http://" + addr.String()
+
+	resp, err := client.Get(base + "/healthz")
+	if err != nil {
+		t.Fatalf("GET /healthz: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /healthz status = %d", resp.StatusCode)
+	}
+
+	// 結束時進行中的請求仍應完成
+	type result struct {
+		body string
+		err  error
+	}
+	slow := make(chan result, 1)
+	go func() {
+		resp, err := client.Get(base + "/slow")
+		if err != nil {
+			slow <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		slow <- result{string(body), err}
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := a.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if r := <-slow; r.err != nil || r.body != "done" {
+		t.Fatalf("in-flight request = %q, %v; want done", r.body, r.err)
+	}
+
+	if _, err := client.Get(base + "/healthz"); err == nil {
+		t.Error("server still accepts requests after Shutdown")
+	}
+	db, _ := a.Tenants.Get("smoke")
+	if err := db.DB.PingContext(context.Background()); err == nil {
+		t.Error("database still open after Shutdown")
+	}
+}
+
+func TestShutdownEndsEventStreams(t *testing.T) {
+	a := newTestApp(t)
+	client := newTestClient(t)
+	addr, err := a.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	// 沒有任何生成請求的進度串流只會送出心跳，不會自行結束
+	resp, err := client.Get("http://" + addr.String() + "/progress/idle/events")
+	if err != nil {
+		t.Fatalf("GET /progress/idle/events: %v", err)
+	}
+	defer resp.Body.Close()
+	streamEnded := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(resp.Body)
+		streamEnded <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	start := time.Now()
+	if err := a.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Shutdown took %s, event streams should end immediately", elapsed)
+	}
+	if err := <-streamEnded; err != nil {
+		t.Errorf("event stream ended with %v, want a clean end", err)
+	}
+}
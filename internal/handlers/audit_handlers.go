@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/service"
+	"golang-gin-app/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditDateLayout 稽核紀錄篩選條件中日期的格式
+const auditDateLayout = "2006-01-02"
+
+// 稽核紀錄頁面篩選條件的選項
+var (
+	auditActions = []string{
+		models.AuditActionCreate, models.AuditActionUpdate, models.AuditActionDelete,
+		models.AuditActionAssignRoles, models.AuditActionGenerate, models.AuditActionPurge,
+		models.AuditActionAnonymize, models.AuditActionCopy, models.AuditActionCancel,
+	}
+	auditEntityTypes = []string{
+		models.AuditEntityUser, models.AuditEntityRole, models.AuditEntitySlot,
+		models.AuditEntityPatient, models.AuditEntityBatch, models.AuditEntityJob,
+	}
+)
+
+// AuditMiddleware 將操作者與請求資訊放入請求的 context，供 service 記錄稽核紀錄。
+// 操作者取自 userHeader 標頭，未設定或請求未帶此標頭時記錄為 models.AuditActorAnonymous
+func AuditMiddleware(userHeader string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := ""
+		if userHeader != "" {
+			actor = c.GetHeader(userHeader)
+		}
+		if actor == "" {
+			actor = models.AuditActorAnonymous
+		}
+		ctx := service.WithAuditRequest(c.Request.Context(), models.AuditRequest{
+			Actor:     actor,
+			RequestID: c.Writer.Header().Get(middleware.RequestIDHeader),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			ClientIP:  c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// parseAuditFilter 解析查詢參數中的篩選條件：actor、action、entity_type、entity_id、
+// from 與 to（YYYY-MM-DD，包含 to 當天）以及 limit，未指定 limit 時使用 defaultLimit
+func parseAuditFilter(c *gin.Context, defaultLimit int) (models.AuditFilter, error) {
+	filter := models.AuditFilter{
+		Actor:      c.Query("actor"),
+		Action:     c.Query("action"),
+		EntityType: c.Query("entity_type"),
+		EntityID:   c.Query("entity_id"),
+		Limit:      defaultLimit,
+	}
+	if from := c.Query("from"); from != "" {
+		date, err := time.ParseInLocation(auditDateLayout, from, time.Local)
+		if err != nil {
+			return filter, models.Validationf("無效的開始日期: %s", from)
+		}
+		filter.From = date
+	}
+	if to := c.Query("to"); to != "" {
+		date, err := time.ParseInLocation(auditDateLayout, to, time.Local)
+		if err != nil {
+			return filter, models.Validationf("無效的結束日期: %s", to)
+		}
+		filter.To = date.AddDate(0, 0, 1)
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return filter, models.Validationf("無效的筆數: %s", limit)
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
+// listAuditEntries 依查詢參數獲取目前租戶的稽核紀錄
+func listAuditEntries(c *gin.Context, defaultLimit int) ([]*models.AuditEntry, error) {
+	filter, err := parseAuditFilter(c, defaultLimit)
+	if err != nil {
+		return nil, err
+	}
+	return currentService(c).ListAuditEntries(c.Request.Context(), filter)
+}
+
+// auditExportURL 回傳以目前的篩選條件匯出稽核紀錄的網址
+func auditExportURL(c *gin.Context, format string) string {
+	query := c.Request.URL.Query()
+	query.Del("limit")
+	query.Set("format", format)
+	return "/audit/export?" + query.Encode()
+}
+
+// AuditPageHandler 處理 GET /audit 路由，顯示符合篩選條件的稽核紀錄
+func AuditPageHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data := gin.H{
+			"title":       "稽核紀錄",
+			"filter":      c.Request.URL.Query(),
+			"actions":     auditActions,
+			"entityTypes": auditEntityTypes,
+			"exportCSV":   auditExportURL(c, "csv"),
+			"exportJSON":  auditExportURL(c, "json"),
+		}
+		entries, err := listAuditEntries(c, 100)
+		if err != nil {
+			renderError(c, "audit.html", data, "獲取稽核紀錄失敗: ", err)
+			return
+		}
+		data["entries"] = entries
+		renderHTML(c, http.StatusOK, "audit.html", data)
+	}
+}
+
+// ListAuditHandler 處理 GET /api/audit 路由，以 JSON 回傳符合篩選條件的稽核紀錄
+func ListAuditHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entries, err := listAuditEntries(c, 100)
+		if err != nil {
+			respondError(c, "獲取稽核紀錄失敗: ", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"entries": entries})
+	}
+}
+
+// ExportAuditHandler 處理 GET /audit/export 路由，以 CSV（預設）或 JSON（?format=json）下載
+// 符合篩選條件的稽核紀錄，未指定 limit 時最多 service.MaxAuditEntries 筆
+func ExportAuditHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", "csv")
+		if format != "csv" && format != "json" {
+			respondError(c, "", models.Validationf("無效的匯出格式: %s（可用: csv, json）", format))
+			return
+		}
+		entries, err := listAuditEntries(c, service.MaxAuditEntries)
+		if err != nil {
+			respondError(c, "匯出稽核紀錄失敗: ", err)
+			return
+		}
+
+		filename := fmt.Sprintf("audit-%s-%s.%s", currentTenant(c).Name, time.Now().Format("20060102150405"), format)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		if format == "json" {
+			c.JSON(http.StatusOK, gin.H{"entries": entries})
+			return
+		}
+
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"id", "created_at", "actor", "action", "entity_type", "entity_id",
+			"changes", "before", "after", "request_id", "method", "path", "client_ip", "user_agent"})
+		for _, entry := range entries {
+			changes, _ := json.Marshal(entry.Changes)
+			w.Write([]string{
+				strconv.FormatInt(entry.ID, 10), entry.CreatedAt.Format(time.RFC3339), csvCell(entry.Actor), entry.Action,
+				entry.EntityType, csvCell(entry.EntityID), string(changes), string(entry.Before), string(entry.After),
+				csvCell(entry.RequestID), entry.Method, csvCell(entry.Path), entry.ClientIP, csvCell(entry.UserAgent),
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			logError(c, http.StatusInternalServerError, "internal_error", err)
+		}
+	}
+}
+
+// csvCell 在來自請求的值前加上單引號，避免試算表將以 =、+、-、@ 開頭的值當作公式執行
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-gin-app/internal/models"
+	"golang-gin-app/pkg/middleware"
+)
+
+func TestAuditAPI(t *testing.T) {
+	r, registry := newTenantRouterWithRegistry(t)
+	r.Use(middleware.Logger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	g := r.Group("/", TenantMiddleware(registry), AuditMiddleware("X-Forwarded-User"))
+	g.POST("/roles/add", AddRoleHandler())
+	g.GET("/api/audit", ListAuditHandler())
+	g.GET("/audit/export", ExportAuditHandler())
+
+	do := func(method, path, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	list := func(query string) []models.AuditEntry {
+		t.Helper()
+		w := do(http.MethodGet, "/api/audit?"+query, "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /api/audit?%s: status = %d: %s", query, w.Code, w.Body.String())
+		}
+		var body struct{ Entries []models.AuditEntry }
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode entries: %v", err)
+		}
+		return body.Entries
+	}
+
+	w := do(http.MethodPost, "/roles/add", `{"alias": "AUDITOR"}`,
+		http.Header{"X-Forwarded-User": {"bob"}, "User-Agent": {"=HYPERLINK(\"x\")"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /roles/add: status = %d: %s", w.Code, w.Body.String())
+	}
+	requestID := w.Header().Get(middleware.RequestIDHeader)
+	do(http.MethodPost, "/roles/add?tenant=dtxtraining", `{"alias": "OTHER"}`, nil)
+
+	entries := list("actor=bob")
+	if len(entries) != 1 {
+		t.Fatalf("actor=bob: %d entries, want 1", len(entries))
+	}
+	entry := entries[0]
+	if entry.Action != models.AuditActionCreate || entry.EntityType != models.AuditEntityRole ||
+		entry.Method != http.MethodPost || entry.Path != "/roles/add" || entry.RequestID != requestID {
+		t.Errorf("稽核紀錄 = %+v, request ID %s", entry, requestID)
+	}
+	// 未帶使用者標頭的請求記錄為匿名，且稽核紀錄屬於各自的租戶
+	if got := list("actor=anonymous"); len(got) != 0 {
+		t.Errorf("預設租戶不應有其他租戶的稽核紀錄: %+v", got)
+	}
+	if got := list("tenant=dtxtraining&actor=anonymous"); len(got) != 1 {
+		t.Errorf("dtxtraining 的匿名稽核紀錄 = %+v, want 1", got)
+	}
+
+	if w := do(http.MethodGet, "/api/audit?from=yesterday", "", nil); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("無效的日期: status = %d, want 422", w.Code)
+	}
+	if w := do(http.MethodGet, "/audit/export?format=xml", "", nil); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("無效的匯出格式: status = %d, want 422", w.Code)
+	}
+
+	w = do(http.MethodGet, "/audit/export?actor=bob", "", nil)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="audit-dtxcasemgnt-`) {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil || len(records) != 2 {
+		t.Fatalf("CSV = %v (%v), want header and 1 row", records, err)
+	}
+	row := map[string]string{}
+	for i, column := range records[0] {
+		row[column] = records[1][i]
+	}
+	if row["actor"] != "bob" || row["entity_type"] != models.AuditEntityRole || !strings.Contains(row["changes"], `"field":"alias"`) {
+		t.Errorf("CSV row = %v", row)
+	}
+	if row["user_agent"] != `'=HYPERLINK("x")` {
+		t.Errorf("以 = 開頭的值應加上單引號: %q", row["user_agent"])
+	}
+
+	w = do(http.MethodGet, "/audit/export?format=json&actor=bob", "", nil)
+	var exported struct{ Entries []models.AuditEntry }
+	if err := json.Unmarshal(w.Body.Bytes(), &exported); err != nil || len(exported.Entries) != 1 {
+		t.Errorf("JSON export = %s (%v)", w.Body.String(), err)
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchesPageHandler 處理 GET /batches 路由，顯示生成批次列表
+func BatchesPageHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		svc := currentService(c)
+		batches, err := svc.ListGenerationBatches(c.Request.Context())
+		if err != nil {
+			renderError(c, "batches.html", gin.H{"title": "生成批次管理"}, "獲取批次列表失敗: ", err)
+			return
+		}
+		renderHTML(c, http.StatusOK, "batches.html", gin.H{
+			"title":   "生成批次管理",
+			"batches": batches,
+		})
+	}
+}
+
+// PurgeBatchPageHandler 處理 POST /batches/:id/purge 路由，預覽或清除批次後重新顯示批次列表
+func PurgeBatchPageHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		svc := currentService(c)
+		batchID := c.Param("id")
+		dryRun := c.PostForm("dryRun") == "true"
+
+		result, purgeErr := svc.PurgeGenerationBatch(c.Request.Context(), batchID, dryRun)
+		batches, err := svc.ListGenerationBatches(c.Request.Context())
+		if err != nil {
+			renderError(c, "batches.html", gin.H{"title": "生成批次管理"}, "獲取批次列表失敗: ", err)
+			return
+		}
+		if purgeErr != nil {
+			renderError(c, "batches.html", gin.H{
+				"title":   "生成批次管理",
+				"batches": batches,
+			}, "清除批次失敗: ", purgeErr)
+			return
+		}
+
+		message := "批次 " + batchID + " 已清除"
+		if dryRun {
+			message = "批次 " + batchID + " 預覽（尚未刪除任何資料）"
+		}
+		renderHTML(c, http.StatusOK, "batches.html", gin.H{
+			"title":   "生成批次管理",
+			"message": message,
+			"result":  result,
+			"batches": batches,
+		})
+	}
+}
+
+// ListBatchesHandler 處理 GET /api/batches 路由，以 JSON 回傳生成批次列表
+func ListBatchesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		svc := currentService(c)
+		batches, err := svc.ListGenerationBatches(c.Request.Context())
+		if err != nil {
+			respondError(c, "獲取批次列表失敗: ", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"batches": batches})
+	}
+}
+
+// PurgeBatchHandler 處理 POST /api/batches/:id/purge 路由；帶 ?dryRun=true 時只回傳將刪除的筆數
+func PurgeBatchHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		svc := currentService(c)
+		batchID := c.Param("id")
+		dryRun := c.Query("dryRun") == "true"
+
+		result, err := svc.PurgeGenerationBatch(c.Request.Context(), batchID, dryRun)
+		if err != nil {
+			respondError(c, "清除批次失敗: ", err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// copyAPIRequest 是 POST /api/copy 的請求內容，來源為目前的租戶
+type copyAPIRequest struct {
+	Target string `json:"target" binding:"required"`
+	models.CopyRequest
+}
+
+// copyPageData 準備複製頁面的資料：目前租戶（來源）最近的使用者與病患，以及可選的目標租戶
+func copyPageData(c *gin.Context, registry *tenant.Registry) (gin.H, error) {
+	svc := currentService(c)
+	source := currentTenant(c).Name
+
+	targets := make([]string, 0)
+	for _, name := range registry.Names() {
+		if name != source {
+			targets = append(targets, name)
+		}
+	}
+	data := gin.H{
+		"title":   "跨資料庫複製",
+		"source":  source,
+		"targets": targets,
+	}
+
+	users, err := svc.ListUsers(c.Request.Context())
+	if err != nil {
+		return data, err
+	}
+	data["users"] = users
+
+	patients, err := svc.ListPatients(c.Request.Context())
+	if err != nil {
+		return data, err
+	}
+	data["patients"] = patients
+	return data, nil
+}
+
+// parseIDs 將表單中的ID轉為數字，忽略無效的ID
+func parseIDs(values []string) []int64 {
+	ids := make([]int64, 0, len(values))
+	for _, value := range values {
+		if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// CopyPageHandler 處理 GET /copy 路由，顯示從目前租戶複製資料到其他租戶的表單
+func CopyPageHandler(registry *tenant.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := copyPageData(c, registry)
+		if err != nil {
+			renderError(c, "copy.html", data, "獲取來源資料失敗: ", err)
+			return
+		}
+		renderHTML(c, http.StatusOK, "copy.html", data)
+	}
+}
+
+// CopyHandler 處理 POST /copy 路由，將選取的資料複製到目標租戶後顯示報告
+func CopyHandler(registry *tenant.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := copyPageData(c, registry)
+		if err != nil {
+			renderError(c, "copy.html", data, "獲取來源資料失敗: ", err)
+			return
+		}
+
+		target, err := lookupTenant(registry, c.PostForm("target"))
+		if err != nil {
+			renderError(c, "copy.html", data, "目標租戶無法使用: ", err)
+			return
+		}
+		req := models.CopyRequest{
+			UserIDs:      parseIDs(c.PostFormArray("userIDs")),
+			PatientIDs:   parseIDs(c.PostFormArray("patientIDs")),
+			IncludeSlots: c.PostForm("includeSlots") == "true",
+			Anonymize:    c.PostForm("anonymize") == "true",
+			DryRun:       c.PostForm("dryRun") == "true",
+		}
+
+		report, err := currentService(c).CopyTo(c.Request.Context(), target.Service, req)
+		if err != nil {
+			renderError(c, "copy.html", data, "複製資料失敗: ", err)
+			return
+		}
+
+		message := "已複製到 " + target.Name
+		if report.DryRun {
+			message = "預覽複製到 " + target.Name + "（尚未寫入任何資料）"
+		}
+		data["message"] = message
+		data["report"] = report
+		data["target"] = target.Name
+		renderHTML(c, http.StatusOK, "copy.html", data)
+	}
+}
+
+// CopyAPIHandler 處理 POST /api/copy 路由，以 JSON 回傳複製報告
+func CopyAPIHandler(registry *tenant.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req copyAPIRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, "", models.Validationf("無效的請求內容: %v", err))
+			return
+		}
+		target, err := lookupTenant(registry, req.Target)
+		if err != nil {
+			respondError(c, "目標租戶無法使用: ", err)
+			return
+		}
+
+		report, err := currentService(c).CopyTo(c.Request.Context(), target.Service, req.CopyRequest)
+		if err != nil {
+			respondError(c, "複製資料失敗: ", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"source": currentTenant(c).Name,
+			"target": target.Name,
+			"report": report,
+		})
+	}
+}
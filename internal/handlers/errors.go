@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorKinds 領域錯誤種類與對應的 HTTP 狀態碼及 JSON 錯誤代碼
+var errorKinds = []struct {
+	kind   error
+	status int
+	code   string
+}{
+	{models.ErrNotFound, http.StatusNotFound, "not_found"},
+	{models.ErrConflict, http.StatusConflict, "conflict"},
+	{models.ErrValidation, http.StatusUnprocessableEntity, "validation_failed"},
+	{models.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{models.ErrUnavailable, http.StatusServiceUnavailable, "unavailable"},
+	{models.ErrTooManyRequests, http.StatusTooManyRequests, "rate_limited"},
+}
+
+// errorStatus 依領域錯誤種類決定 HTTP 狀態碼與錯誤代碼，其他錯誤視為 500
+func errorStatus(err error) (int, string) {
+	for _, k := range errorKinds {
+		if errors.Is(err, k.kind) {
+			return k.status, k.code
+		}
+	}
+	return http.StatusInternalServerError, "internal_error"
+}
+
+// logError 記錄回傳給用戶端的錯誤：500 以 error 等級記錄，領域錯誤以 debug 等級記錄
+func logError(c *gin.Context, status int, code string, err error) {
+	level := slog.LevelDebug
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	logging.FromContext(c.Request.Context()).Log(c.Request.Context(), level, "request failed",
+		logging.KeyRoute, c.FullPath(),
+		logging.KeyStatus, status,
+		"code", code,
+		logging.KeyError, err.Error(),
+	)
+}
+
+// setRetryAfter 超過請求頻率或每日配額時設定 Retry-After 標頭
+func setRetryAfter(c *gin.Context, err error) {
+	var exceeded *ratelimit.ExceededError
+	if errors.As(err, &exceeded) {
+		c.Header("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(exceeded.RetryAfter)))
+	}
+}
+
+// respondError 以 JSON 回傳 service 錯誤，message 為錯誤訊息的前綴
+func respondError(c *gin.Context, message string, err error) {
+	status, code := errorStatus(err)
+	logError(c, status, code, err)
+	setRetryAfter(c, err)
+	c.JSON(status, gin.H{"error": message + err.Error(), "code": code})
+}
+
+// renderError 以 HTML 頁面回傳 service 錯誤，data 的 "error" 設為 message 加上錯誤訊息
+func renderError(c *gin.Context, template string, data gin.H, message string, err error) {
+	status, code := errorStatus(err)
+	logError(c, status, code, err)
+	setRetryAfter(c, err)
+	data["error"] = message + err.Error()
+	renderHTML(c, status, template, data)
+}
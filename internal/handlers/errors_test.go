@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/ratelimit"
+)
+
+func TestErrorStatus(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"not found", models.NotFoundf("未找到ID為 %d 的時段", 1), http.StatusNotFound},
+		{"conflict", models.Conflictf("該時段已被預約，無法刪除"), http.StatusConflict},
+		{"validation", models.Validationf("無效的時段ID"), http.StatusUnprocessableEntity},
+		{"forbidden", models.Forbiddenf("不允許的操作"), http.StatusForbidden},
+		{"unavailable", models.Unavailablef("租戶 %s 的資料庫尚未連線", "dtxtraining"), http.StatusServiceUnavailable},
+		{"rate limited", &ratelimit.ExceededError{Reason: ratelimit.ReasonRate, RetryAfter: time.Second}, http.StatusTooManyRequests},
+		{"wrapped", fmt.Errorf("批量創建使用者失敗: %w", models.Conflictf("帳號 %s 已存在", "doctor1")), http.StatusConflict},
+		{"unknown", errors.New("資料庫連線失敗"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, _ := errorStatus(tc.err); got != tc.want {
+				t.Errorf("errorStatus(%v) = %d, want %d", tc.err, got, tc.want)
+			}
+		})
+	}
+}
@@ -1,14 +1,14 @@
 package handlers
 
 import (
-	"golang-gin-app/internal/service"
+	"golang-gin-app/internal/jobs"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/ratelimit"
 	"golang-gin-app/internal/utils"
 	"net/http"
 	"strconv"
 	"strings"
 
-	"golang-gin-app/internal/repository"
-
 	"github.com/gin-gonic/gin"
 )
 
@@ -38,39 +38,42 @@ func CreateUserHandler(c *gin.Context) {
 }
 
 // GenerateFakeUsersFormHandler handles the GET /fake-users route to display the form
-func GenerateFakeUsersFormHandler(svc *service.Service) gin.HandlerFunc {
+func GenerateFakeUsersFormHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		svc := currentService(c)
 		// 獲取所有角色
 		roles, err := svc.ListAllRoles(c.Request.Context())
 		if err != nil {
-			c.HTML(http.StatusInternalServerError, "fake_users.html", gin.H{
-				"title": "Generate Fake Users",
-				"error": "Failed to fetch roles: " + err.Error(),
-			})
+			renderError(c, "fake_users.html", gin.H{
+				"title":     "Generate Fake Users",
+				"userTypes": utils.ListFakeUserTypes(),
+			}, "Failed to fetch roles: ", err)
 			return
 		}
 
 		// 獲取用戶列表
 		users, err := svc.ListUsers(c.Request.Context())
 		if err != nil {
-			c.HTML(http.StatusInternalServerError, "fake_users.html", gin.H{
-				"title": "Generate Fake Users",
-				"error": "Failed to fetch user list: " + err.Error(),
-				"roles": roles,
-			})
+			renderError(c, "fake_users.html", gin.H{
+				"title":     "Generate Fake Users",
+				"userTypes": utils.ListFakeUserTypes(),
+				"roles":     roles,
+			}, "Failed to fetch user list: ", err)
 			return
 		}
-		c.HTML(http.StatusOK, "fake_users.html", gin.H{
-			"title": "Generate Fake Users",
-			"users": users,
-			"roles": roles,
+		renderHTML(c, http.StatusOK, "fake_users.html", gin.H{
+			"title":     "Generate Fake Users",
+			"userTypes": utils.ListFakeUserTypes(),
+			"users":     users,
+			"roles":     roles,
 		})
 	}
 }
 
 // GenerateFakeUsersHandler handles the POST /fake-users route to generate fake users
-func GenerateFakeUsersHandler(svc *service.Service) gin.HandlerFunc {
+func GenerateFakeUsersHandler(limiter *ratelimit.Limiter, runner *jobs.Runner, hub *jobs.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		svc := currentService(c)
 		countStr := c.PostForm("count")
 		userType := c.PostForm("userType") // 取得使用者類型
 
@@ -85,52 +88,70 @@ func GenerateFakeUsersHandler(svc *service.Service) gin.HandlerFunc {
 			}
 		}
 
-		// 如果沒有選擇角色，則使用預設角色
-		if len(roleIDs) == 0 {
-			roleIDs = utils.GetDefaultRoleIDsForUserType(userType)
-		}
-
-		// 如果沒有選擇角色，則使用預設角色
-		if len(roleIDs) == 0 {
-			roleIDs = utils.GetDefaultRoleIDsForUserType(userType)
-		}
+		// 如果沒有選擇角色，service 會為每個使用者指派該類型的預設角色
 
 		count, err := strconv.Atoi(countStr)
 		if err != nil || count < 1 {
-			c.HTML(http.StatusBadRequest, "fake_users.html", gin.H{
-				"title": "Generate Fake Users",
-				"error": "Please enter a valid number greater than 0",
+			renderHTML(c, http.StatusBadRequest, "fake_users.html", gin.H{
+				"title":     "Generate Fake Users",
+				"userTypes": utils.ListFakeUserTypes(),
+				"error":     "Please enter a valid number greater than 0",
 			})
 			return
 		}
 
+		// 勾選背景執行時建立工作並導向工作狀態頁
+		if wantsBackground(c) {
+			params := models.UserJobParams{Count: count, UserType: userType, RoleIDs: roleIDs}
+			if err := submitJobForm(c, runner, limiter, models.BatchKindUsers, params); err != nil {
+				renderError(c, "fake_users.html", gin.H{
+					"title":     "Generate Fake Users",
+					"userTypes": utils.ListFakeUserTypes(),
+				}, "建立背景工作失敗: ", err)
+			}
+			return
+		}
+
+		reservation, err := reserveGeneration(c, limiter, count)
+		if err != nil {
+			renderError(c, "fake_users.html", gin.H{
+				"title":     "Generate Fake Users",
+				"userTypes": utils.ListFakeUserTypes(),
+			}, "", err)
+			return
+		}
+
 		// 將使用者類型和角色傳遞給 service 方法
-		createdCount, err := svc.GenerateFakeUsers(c.Request.Context(), count, userType, roleIDs)
+		// 頁面以 /progress/:id/events 顯示建立使用者與分配角色的進度
+		ctx, finish := trackProgress(c, hub)
+		createdCount, batchID, err := svc.GenerateFakeUsers(ctx, count, userType, roleIDs)
+		finish(err)
 		if err != nil {
-			c.HTML(http.StatusInternalServerError, "fake_users.html", gin.H{
-				"title": "Generate Fake Users",
-				"error": "Failed to generate users: " + err.Error(),
-			})
+			reservation.Cancel()
+			renderError(c, "fake_users.html", gin.H{
+				"title":     "Generate Fake Users",
+				"userTypes": utils.ListFakeUserTypes(),
+			}, "Failed to generate users: ", err)
 			return
 		}
 
 		// 獲取所有角色供表單使用
 		roles, err := svc.ListAllRoles(c.Request.Context())
 		if err != nil {
-			c.HTML(http.StatusInternalServerError, "fake_users.html", gin.H{
-				"title": "Generate Fake Users",
-				"error": "Failed to fetch roles: " + err.Error(),
-			})
+			renderError(c, "fake_users.html", gin.H{
+				"title":     "Generate Fake Users",
+				"userTypes": utils.ListFakeUserTypes(),
+			}, "Failed to fetch roles: ", err)
 			return
 		}
 
 		users, err := svc.ListUsers(c.Request.Context())
 		if err != nil {
-			c.HTML(http.StatusInternalServerError, "fake_users.html", gin.H{
-				"title": "Generate Fake Users",
-				"error": "Failed to fetch user list after generation: " + err.Error(),
-				"roles": roles,
-			})
+			renderError(c, "fake_users.html", gin.H{
+				"title":     "Generate Fake Users",
+				"userTypes": utils.ListFakeUserTypes(),
+				"roles":     roles,
+			}, "Failed to fetch user list after generation: ", err)
 			return
 		} // 生成角色名稱列表，用於訊息顯示
 		roleNames := make([]string, 0, len(roleIDs))
@@ -150,46 +171,105 @@ func GenerateFakeUsersHandler(svc *service.Service) gin.HandlerFunc {
 		message := strconv.Itoa(createdCount) + " fake " + userType + " users"
 		if len(roleNames) > 0 {
 			message += " with roles: " + strings.Join(roleNames, ", ")
+		} else {
+			message += " with default roles"
 		}
 		message += " have been successfully generated and saved to the database."
+		if batchID != "" {
+			message += " Batch ID: " + batchID
+		}
+
+		renderHTML(c, http.StatusOK, "fake_users.html", gin.H{
+			"title":     "Generate Fake Users",
+			"userTypes": utils.ListFakeUserTypes(),
+			"message":   message,
+			"users":     users,
+			"roles":     roles,
+		})
+	}
+}
 
-		c.HTML(http.StatusOK, "fake_users.html", gin.H{
-			"title":   "Generate Fake Users",
-			"message": message,
-			"users":   users,
-			"roles":   roles,
+// ManageRolesPageHandler 處理 GET /roles 路由，顯示角色管理頁面
+func ManageRolesPageHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		svc := currentService(c)
+		roles, err := svc.ListAllRoles(c.Request.Context())
+		if err != nil {
+			renderError(c, "manage_roles.html", gin.H{"title": "角色管理"}, "獲取角色列表失敗: ", err)
+			return
+		}
+		renderHTML(c, http.StatusOK, "manage_roles.html", gin.H{
+			"title": "角色管理",
+			"roles": roles,
 		})
 	}
 }
 
-func AddRoleHandler(repo *repository.RoleRepository) gin.HandlerFunc {
+// wantsJSON 判斷請求是否來自 API 或 AJAX，否則視為頁面表單送出
+func wantsJSON(c *gin.Context) bool {
+	return c.ContentType() == "application/json" ||
+		strings.Contains(c.GetHeader("Accept"), "application/json") ||
+		c.GetHeader("X-Requested-With") == "XMLHttpRequest"
+}
+
+// roleFormError 角色管理頁面的表單送出失敗時，重新顯示頁面與錯誤訊息
+func roleFormError(c *gin.Context, message string, err error) {
+	roles, _ := currentService(c).ListAllRoles(c.Request.Context())
+	renderError(c, "manage_roles.html", gin.H{"title": "角色管理", "roles": roles}, message, err)
+}
+
+// AddRoleHandler 處理 POST /roles/add 路由，接受 JSON 或角色管理頁面的表單
+func AddRoleHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		svc := currentService(c)
 		var role struct {
-			Alias       string `json:"alias" binding:"required"`
-			Description string `json:"description"`
+			Alias       string `json:"alias" form:"alias" binding:"required"`
+			Description string `json:"description" form:"description"`
 		}
-		if err := c.ShouldBindJSON(&role); err != nil {
+		if err := c.ShouldBind(&role); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 			return
 		}
 
-		if err := repo.AddRole(role.Alias, role.Description); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add role"})
+		if err := svc.AddRole(c.Request.Context(), role.Alias, role.Description); err != nil {
+			if !wantsJSON(c) {
+				roleFormError(c, "新增角色失敗: ", err)
+				return
+			}
+			respondError(c, "Failed to add role: ", err)
 			return
 		}
 
+		if !wantsJSON(c) {
+			c.Redirect(http.StatusFound, "/roles")
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"message": "Role added successfully"})
 	}
 }
 
-func DeleteRoleHandler(repo *repository.RoleRepository) gin.HandlerFunc {
+// DeleteRoleHandler 處理 POST /roles/delete/:id 路由，接受 API 呼叫或角色管理頁面的表單
+func DeleteRoleHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		roleID := c.Param("id")
-		if err := repo.DeleteRole(roleID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		svc := currentService(c)
+		roleID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+			return
+		}
+		if err := svc.DeleteRole(c.Request.Context(), roleID); err != nil {
+			if !wantsJSON(c) {
+				roleFormError(c, "刪除角色失敗: ", err)
+				return
+			}
+			respondError(c, "Failed to delete role: ", err)
 			return
 		}
 
+		if !wantsJSON(c) {
+			c.Redirect(http.StatusFound, "/roles")
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
 	}
 }
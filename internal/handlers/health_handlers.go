@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"golang-gin-app/internal/tenant"
+	"golang-gin-app/internal/version"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout 就緒檢查的逾時時間，避免資料庫無回應時探測請求卡住
+const readinessTimeout = 3 * time.Second
+
+// HealthzHandler 處理 GET /healthz 路由，只表示程序仍在運作，不檢查資料庫
+func HealthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ReadyzHandler 處理 GET /readyz 路由，檢查每個租戶的資料庫連線、資料表與遷移版本。
+// 預設租戶未就緒時回傳 503；只有其他租戶未就緒時仍回傳 200，status 為 degraded。
+func ReadyzHandler(registry *tenant.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+
+		results := registry.CheckReadiness(ctx)
+		status, code := "ok", http.StatusOK
+		for _, result := range results {
+			if result.Ready {
+				continue
+			}
+			if result.Default {
+				status, code = "unavailable", http.StatusServiceUnavailable
+				break
+			}
+			status = "degraded"
+		}
+		c.JSON(code, gin.H{"status": status, "tenants": results})
+	}
+}
+
+// VersionHandler 處理 GET /version 路由，回傳建置版本、commit 與 Go 版本
+func VersionHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, version.Get())
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang-gin-app/internal/migrations"
+	"golang-gin-app/internal/repository"
+	"golang-gin-app/internal/service"
+	"golang-gin-app/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+type readyzResponse struct {
+	Status  string             `json:"status"`
+	Tenants []tenant.Readiness `json:"tenants"`
+}
+
+func getReadyz(t *testing.T, registry *tenant.Registry) (int, readyzResponse) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/readyz", ReadyzHandler(registry))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var body readyzResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode readyz: %v", err)
+	}
+	return w.Code, body
+}
+
+func TestReadyzChecksSchema(t *testing.T) {
+	db, err := repository.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer db.Close()
+	store, _ := repository.NewStore(db, repository.DialectSQLite)
+
+	registry := tenant.NewRegistry("dtxcasemgnt")
+	registry.Register(&tenant.Tenant{Name: "dtxcasemgnt", DB: db, Dialect: repository.DialectSQLite, Service: service.NewService(store)})
+
+	// 尚未建立資料表時未就緒
+	if code, body := getReadyz(t, registry); code != http.StatusServiceUnavailable || body.Tenants[0].Ready {
+		t.Fatalf("expected an empty database to be unavailable, got %d %+v", code, body)
+	}
+
+	migrator, _ := migrations.NewMigrator(db, migrations.DialectSQLite)
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	code, body := getReadyz(t, registry)
+	if code != http.StatusOK || body.Status != "ok" || !body.Tenants[0].Ready {
+		t.Fatalf("expected a migrated database to be ready, got %d %+v", code, body)
+	}
+	if body.Tenants[0].SchemaVersion != migrator.LatestVersion() {
+		t.Errorf("schema_version = %d, want %d", body.Tenants[0].SchemaVersion, migrator.LatestVersion())
+	}
+
+	// 有尚未套用的遷移時未就緒
+	if _, err := migrator.Down(context.Background(), 1); err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+	if code, body := getReadyz(t, registry); code != http.StatusServiceUnavailable || body.Tenants[0].Error == "" {
+		t.Errorf("expected pending migrations to be unavailable, got %d %+v", code, body)
+	}
+}
+
+func TestReadyzReportsDegradedTenant(t *testing.T) {
+	registry := tenant.NewRegistry("dtxcasemgnt")
+	registry.Register(&tenant.Tenant{Name: "dtxcasemgnt", Service: service.NewService(repository.NewMemoryStore())})
+	registry.MarkUnavailable("dtxtraining", errors.New("connection refused"))
+
+	code, body := getReadyz(t, registry)
+	if code != http.StatusOK || body.Status != "degraded" || len(body.Tenants) != 2 || body.Tenants[1].Ready {
+		t.Errorf("expected degraded readiness, got %d %+v", code, body)
+	}
+
+	unavailable := tenant.NewRegistry("dtxcasemgnt")
+	unavailable.MarkUnavailable("dtxcasemgnt", errors.New("connection refused"))
+	if code, body := getReadyz(t, unavailable); code != http.StatusServiceUnavailable || body.Status != "unavailable" {
+		t.Errorf("expected unavailable readiness, got %d %+v", code, body)
+	}
+}
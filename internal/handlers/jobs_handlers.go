@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"golang-gin-app/internal/jobs"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/ratelimit"
+	"golang-gin-app/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// wantsBackground 判斷表單是否勾選以背景工作執行
+func wantsBackground(c *gin.Context) bool {
+	return c.PostForm("background") == "true"
+}
+
+// jobTask 回傳目前租戶中工作 id 對應的 jobs.Task
+func jobTask(c *gin.Context, id string) jobs.Task {
+	return jobs.Task{Tenant: currentTenant(c).Name, JobID: id}
+}
+
+// submitJob 驗證參數、預留生成配額並建立工作後排入 runner；建立失敗時歸還配額
+func submitJob(c *gin.Context, runner *jobs.Runner, limiter *ratelimit.Limiter, kind string, params json.RawMessage) (*models.Job, error) {
+	records, err := service.ValidateJob(kind, params)
+	if err != nil {
+		return nil, err
+	}
+	reservation, err := reserveGeneration(c, limiter, records)
+	if err != nil {
+		return nil, err
+	}
+	job, err := currentService(c).SubmitJob(c.Request.Context(), kind, params)
+	if err != nil {
+		reservation.Cancel()
+		return nil, err
+	}
+	// 排入失敗（應用程式結束中）時工作仍留在資料庫中，下次啟動時執行
+	if err := runner.Enqueue(jobTask(c, job.ID)); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// submitJobForm 以表單參數建立背景工作，成功時導向工作狀態頁，失敗時回傳錯誤由呼叫者顯示
+func submitJobForm(c *gin.Context, runner *jobs.Runner, limiter *ratelimit.Limiter, kind string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	job, err := submitJob(c, runner, limiter, kind, data)
+	if err != nil {
+		return err
+	}
+	c.Redirect(http.StatusSeeOther, jobPath(c, job.ID))
+	return nil
+}
+
+// jobPath 回傳工作狀態頁的網址，並帶上目前租戶以免 cookie 中的租戶不同
+func jobPath(c *gin.Context, id string) string {
+	return "/jobs/" + url.PathEscape(id) + "?" + TenantQueryParam + "=" + url.QueryEscape(currentTenant(c).Name)
+}
+
+// loadJob 取得工作；執行中的工作直接使用 runner 中最新的狀態與進度，
+// 不需等待資料庫連線（SQLite 只有一個連線，執行中的交易結束前無法查詢）
+func loadJob(c *gin.Context, runner *jobs.Runner, id string) (*models.Job, error) {
+	if job, ok := runner.Active(jobTask(c, id)); ok {
+		return &job, nil
+	}
+	return currentService(c).GetJob(c.Request.Context(), id)
+}
+
+// listJobs 獲取最近的工作，並以 runner 中最新的狀態取代執行中的工作
+func listJobs(c *gin.Context, runner *jobs.Runner) ([]*models.Job, error) {
+	list, err := currentService(c).ListJobs(c.Request.Context())
+	if err != nil {
+		return nil, err
+	}
+	for i, job := range list {
+		if active, ok := runner.Active(jobTask(c, job.ID)); ok {
+			list[i] = &active
+		}
+	}
+	return list, nil
+}
+
+// cancelJob 取消工作：排隊中的工作直接取消，執行中的工作通知 runner 中斷，結束後標記為已取消
+func cancelJob(c *gin.Context, runner *jobs.Runner, id string) (*models.Job, error) {
+	return currentService(c).CancelJob(c.Request.Context(), id, func() bool {
+		return runner.Cancel(jobTask(c, id))
+	})
+}
+
+// JobsPageHandler 處理 GET /jobs 路由，顯示背景工作列表
+func JobsPageHandler(runner *jobs.Runner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := listJobs(c, runner)
+		if err != nil {
+			renderError(c, "jobs.html", gin.H{"title": "背景工作"}, "獲取工作列表失敗: ", err)
+			return
+		}
+		renderHTML(c, http.StatusOK, "jobs.html", gin.H{
+			"title": "背景工作",
+			"jobs":  list,
+		})
+	}
+}
+
+// JobPageHandler 處理 GET /jobs/:id 路由，顯示工作的狀態與進度，工作結束前頁面會自動重新整理
+func JobPageHandler(runner *jobs.Runner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := loadJob(c, runner, c.Param("id"))
+		if err != nil {
+			renderError(c, "job.html", gin.H{"title": "背景工作"}, "獲取工作失敗: ", err)
+			return
+		}
+		renderHTML(c, http.StatusOK, "job.html", gin.H{
+			"title": "背景工作",
+			"job":   job,
+		})
+	}
+}
+
+// CancelJobPageHandler 處理 POST /jobs/:id/cancel 路由，取消工作後返回工作狀態頁
+func CancelJobPageHandler(runner *jobs.Runner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if _, err := cancelJob(c, runner, id); err != nil {
+			job, _ := loadJob(c, runner, id)
+			renderError(c, "job.html", gin.H{"title": "背景工作", "job": job}, "取消工作失敗: ", err)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, jobPath(c, id))
+	}
+}
+
+// ListJobsHandler 處理 GET /api/jobs 路由，以 JSON 回傳背景工作列表
+func ListJobsHandler(runner *jobs.Runner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := listJobs(c, runner)
+		if err != nil {
+			respondError(c, "獲取工作列表失敗: ", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"jobs": list})
+	}
+}
+
+// GetJobHandler 處理 GET /api/jobs/:id 路由，以 JSON 回傳工作的狀態、進度與結果
+func GetJobHandler(runner *jobs.Runner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := loadJob(c, runner, c.Param("id"))
+		if err != nil {
+			respondError(c, "獲取工作失敗: ", err)
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// SubmitJobHandler 處理 POST /api/jobs 路由，建立背景生成工作並回傳 202 與工作內容。
+// 請求格式為 {"kind": "users|patients|slots", "params": {...}}，參數與對應的生成端點相同
+func SubmitJobHandler(runner *jobs.Runner, limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Kind   string          `json:"kind" binding:"required"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		job, err := submitJob(c, runner, limiter, req.Kind, req.Params)
+		if err != nil {
+			respondError(c, "建立工作失敗: ", err)
+			return
+		}
+		c.Header("Location", "/api/jobs/"+url.PathEscape(job.ID))
+		c.JSON(http.StatusAccepted, job)
+	}
+}
+
+// CancelJobHandler 處理 POST /api/jobs/:id/cancel 路由；排隊中的工作回傳 200 與已取消的工作，
+// 執行中的工作回傳 202，工作中斷後狀態變為已取消
+func CancelJobHandler(runner *jobs.Runner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := cancelJob(c, runner, c.Param("id"))
+		if err != nil {
+			respondError(c, "取消工作失敗: ", err)
+			return
+		}
+		status := http.StatusOK
+		if job.Status == models.JobStatusRunning {
+			status = http.StatusAccepted
+		}
+		c.JSON(status, job)
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-gin-app/internal/jobs"
+	"golang-gin-app/internal/models"
+)
+
+func TestJobsAPI(t *testing.T) {
+	r, registry := newTenantRouterWithRegistry(t)
+	runner := jobs.NewRunner(context.Background(), func(ctx context.Context, task jobs.Task, observe func(models.Job)) error {
+		target, _ := registry.Get(task.Tenant)
+		return target.Service.RunJob(ctx, task.JobID, observe)
+	})
+	runner.Start(1)
+	defer runner.Stop(context.Background())
+
+	g := r.Group("/", TenantMiddleware(registry))
+	g.GET("/api/jobs", ListJobsHandler(runner))
+	g.POST("/api/jobs", SubmitJobHandler(runner, nil))
+	g.GET("/api/jobs/:id", GetJobHandler(runner))
+	g.POST("/api/jobs/:id/cancel", CancelJobHandler(runner))
+	g.GET("/api/jobs/:id/events", JobEventsHandler(runner))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/jobs?tenant=dtxtraining", `{"kind": "users", "params": {"count": 2, "user_type": "doctor"}}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", w.Code, w.Body.String())
+	}
+	var job models.Job
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+	if w.Header().Get("Location") != "/api/jobs/"+job.ID {
+		t.Errorf("Location = %q", w.Header().Get("Location"))
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !job.Finished() {
+		if time.Now().After(deadline) {
+			t.Fatalf("工作未在時限內完成: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+		w = do(http.MethodGet, "/api/jobs/"+job.ID+"?tenant=dtxtraining", "")
+		json.Unmarshal(w.Body.Bytes(), &job)
+	}
+	if job.Status != models.JobStatusSucceeded || job.Result == nil || job.Result.Created != 2 {
+		t.Fatalf("工作結果不符: %+v", job)
+	}
+
+	// 已結束的工作只推送最後的狀態
+	w = do(http.MethodGet, "/api/jobs/"+job.ID+"/events?tenant=dtxtraining", "")
+	if body := w.Body.String(); !strings.HasPrefix(body, "event:done\n") || strings.Count(body, "event:") != 1 {
+		t.Errorf("events = %q, want a single done event", body)
+	}
+
+	// 工作屬於建立時的租戶
+	for tenantName, want := range map[string]int{"dtxtraining": 1, "dtxcasemgnt": 0} {
+		var resp struct {
+			Jobs []models.Job `json:"jobs"`
+		}
+		w = do(http.MethodGet, "/api/jobs?tenant="+tenantName, "")
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if len(resp.Jobs) != want {
+			t.Errorf("%s: %d jobs, want %d", tenantName, len(resp.Jobs), want)
+		}
+	}
+
+	cases := []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodPost, "/api/jobs", `{"kind": "unknown", "params": {}}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/api/jobs", `{"params": {}}`, http.StatusBadRequest},
+		{http.MethodGet, "/api/jobs/missing", "", http.StatusNotFound},
+		{http.MethodPost, fmt.Sprintf("/api/jobs/%s/cancel?tenant=dtxtraining", job.ID), "", http.StatusConflict},
+	}
+	for _, tc := range cases {
+		if w := do(tc.method, tc.path, tc.body); w.Code != tc.status {
+			t.Errorf("%s %s: status = %d, want %d: %s", tc.method, tc.path, w.Code, tc.status, w.Body.String())
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
-	"database/sql"
-	"fmt"
+	"golang-gin-app/internal/jobs"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/ratelimit"
+	"golang-gin-app/internal/service"
+	"golang-gin-app/internal/tenant"
 	"golang-gin-app/internal/utils"
 	"net/http"
 	"strconv"
@@ -14,147 +17,261 @@ import (
 // GenerateFakePatientsFormHandler 處理 GET /fake-patients 路由，顯示生成假病患資料的表單
 func GenerateFakePatientsFormHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.HTML(http.StatusOK, "fake_patients.html", gin.H{
-			"title": "產生假病患資料",
+		svc := currentService(c)
+		catalogue, err := svc.GetCatalogue(c.Request.Context())
+		if err != nil {
+			renderError(c, "fake_patients.html", gin.H{
+				"title":    "產生假病患資料",
+				"profiles": utils.ListPatientProfiles(),
+			}, "載入疾病目錄失敗: ", err)
+			return
+		}
+
+		renderHTML(c, http.StatusOK, "fake_patients.html", gin.H{
+			"title":           "產生假病患資料",
+			"profiles":        utils.ListPatientProfiles(),
+			"selectedProfile": utils.PatientProfileDefault,
+			"catalogue":       catalogue,
+		})
+	}
+}
+
+// GetCatalogueHandler 處理 GET /api/catalogue 路由，回傳目前快取的疾病目錄
+func GetCatalogueHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		svc := currentService(c)
+		catalogue, err := svc.GetCatalogue(c.Request.Context())
+		if err != nil {
+			respondError(c, "載入疾病目錄失敗: ", err)
+			return
+		}
+		c.JSON(http.StatusOK, catalogue)
+	}
+}
+
+// RefreshCatalogueHandler 處理 POST /api/catalogue/refresh 路由，從資料庫重新載入疾病目錄
+func RefreshCatalogueHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		svc := currentService(c)
+		if err := svc.RefreshCatalogue(c.Request.Context()); err != nil {
+			respondError(c, "重新載入疾病目錄失敗: ", err)
+			return
+		}
+		catalogue, err := svc.GetCatalogue(c.Request.Context())
+		if err != nil {
+			respondError(c, "載入疾病目錄失敗: ", err)
+			return
+		}
+		c.JSON(http.StatusOK, catalogue)
+	}
+}
+
+// ListPatientProfilesHandler 處理 GET /api/fake-patients/profiles 路由，回傳可用的擬真設定檔
+func ListPatientProfilesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"profiles": utils.ListPatientProfiles()})
+	}
+}
+
+// GenerateFakePatientsAPIHandler 處理 POST /api/fake-patients 路由，以 JSON 回傳依設定檔生成的假病患資料（不寫入資料庫）
+func GenerateFakePatientsAPIHandler(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		svc := currentService(c)
+		var req struct {
+			Count   int    `json:"count" binding:"required"`
+			Profile string `json:"profile"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		if req.Count <= 0 || req.Count > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "請輸入有效的數量（1-100）"})
+			return
+		}
+
+		profile, ok := utils.GetPatientProfile(req.Profile)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "未知的擬真設定檔: " + req.Profile})
+			return
+		}
+
+		reservation, err := reserveGeneration(c, limiter, req.Count)
+		if err != nil {
+			respondError(c, "", err)
+			return
+		}
+
+		catalogue, err := svc.GetPatientCatalogue(c.Request.Context())
+		if err != nil {
+			reservation.Cancel()
+			respondError(c, "載入疾病目錄失敗: ", err)
+			return
+		}
+
+		patients, err := utils.GenerateFakePatientsWithProfile(req.Count, profile, catalogue)
+		if err != nil {
+			reservation.Cancel()
+			respondError(c, "生成假病患資料失敗: ", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"profile":  profile.Name,
+			"count":    len(patients),
+			"patients": patients,
 		})
 	}
 }
 
 // GenerateFakePatientsHandler 處理 POST /fake-patients 路由，生成假病患資料並顯示
-func GenerateFakePatientsHandler(db *sql.DB) gin.HandlerFunc {
+func GenerateFakePatientsHandler(limiter *ratelimit.Limiter, runner *jobs.Runner, hub *jobs.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 獲取表單參數
+		svc := currentService(c)
+		profiles := utils.ListPatientProfiles()
+
+		// 獲取表單參數；背景工作不顯示生成的資料，可生成較多筆
+		background := wantsBackground(c)
+		maxCount := 100
+		if background {
+			maxCount = service.MaxPatientJobCount
+		}
 		countStr := c.PostForm("count")
 		count, err := strconv.Atoi(countStr)
-		if err != nil || count <= 0 || count > 100 {
-			c.HTML(http.StatusBadRequest, "fake_patients.html", gin.H{
-				"title": "產生假病患資料",
-				"error": "請輸入有效的數量（1-100）",
+		if err != nil || count <= 0 || count > maxCount {
+			renderHTML(c, http.StatusBadRequest, "fake_patients.html", gin.H{
+				"title":    "產生假病患資料",
+				"error":    "請輸入有效的數量（1-" + strconv.Itoa(maxCount) + "）",
+				"profiles": profiles,
 			})
 			return
 		}
 
+		profileName := c.DefaultPostForm("profile", utils.PatientProfileDefault)
+		profile, ok := utils.GetPatientProfile(profileName)
+		if !ok {
+			renderHTML(c, http.StatusBadRequest, "fake_patients.html", gin.H{
+				"title":    "產生假病患資料",
+				"error":    "未知的擬真設定檔: " + profileName,
+				"profiles": profiles,
+			})
+			return
+		}
+
+		// 背景工作一律寫入資料庫，完成後可在工作狀態頁查看筆數與批次ID
+		if background {
+			params := models.PatientJobParams{Count: count, Profile: profile.Name}
+			if err := submitJobForm(c, runner, limiter, models.BatchKindPatients, params); err != nil {
+				renderError(c, "fake_patients.html", gin.H{
+					"title":           "產生假病患資料",
+					"profiles":        profiles,
+					"selectedProfile": profile.Name,
+				}, "建立背景工作失敗: ", err)
+			}
+			return
+		}
+
 		// 檢查是否要直接插入到資料庫
 		insertToDBStr := c.PostForm("insertToDB")
 		insertToDB := insertToDBStr == "true"
 
+		reservation, err := reserveGeneration(c, limiter, count)
+		if err != nil {
+			renderError(c, "fake_patients.html", gin.H{
+				"title":           "產生假病患資料",
+				"profiles":        profiles,
+				"selectedProfile": profile.Name,
+			}, "", err)
+			return
+		}
+
+		// 載入疾病目錄
+		catalogue, err := svc.GetPatientCatalogue(c.Request.Context())
+		if err != nil {
+			reservation.Cancel()
+			renderError(c, "fake_patients.html", gin.H{
+				"title":           "產生假病患資料",
+				"profiles":        profiles,
+				"selectedProfile": profile.Name,
+			}, "載入疾病目錄失敗: ", err)
+			return
+		}
+
 		// 生成假病患資料
-		patients, err := utils.GenerateFakePatients(count)
+		patients, err := utils.GenerateFakePatientsWithProfile(count, profile, catalogue)
 		if err != nil {
-			c.HTML(http.StatusInternalServerError, "fake_patients.html", gin.H{
-				"title": "產生假病患資料",
-				"error": "生成假病患資料失敗: " + err.Error(),
-			})
+			reservation.Cancel()
+			renderError(c, "fake_patients.html", gin.H{
+				"title":           "產生假病患資料",
+				"profiles":        profiles,
+				"selectedProfile": profile.Name,
+			}, "生成假病患資料失敗: ", err)
 			return
 		}
 
 		// 如果需要插入到資料庫
 		var successCount int
 		var errorMessages []string
-		var ids []int64
+		var batchID string
 
 		if insertToDB {
-			tx, err := db.Begin()
+			// 驗證失敗的病患會被略過，其餘病患與批次紀錄在同一個交易中寫入
+			// 頁面以 /progress/:id/events 顯示寫入病患的進度
+			ctx, finish := trackProgress(c, hub)
+			successCount, batchID, errorMessages, err = svc.SaveFakePatients(ctx, patients)
+			finish(err)
 			if err != nil {
-				c.HTML(http.StatusInternalServerError, "fake_patients.html", gin.H{
-					"title":    "產生假病患資料",
-					"error":    "資料庫交易開始失敗: " + err.Error(),
-					"patients": patients,
-				})
+				renderError(c, "fake_patients.html", gin.H{
+					"title":           "產生假病患資料",
+					"patients":        patients,
+					"profiles":        profiles,
+					"selectedProfile": profile.Name,
+				}, "寫入假病患資料失敗: ", err)
 				return
 			}
-			defer tx.Rollback()
-
-			for _, patient := range patients {
-				// 插入主病患資料
-				var patientID int64
-				err := tx.QueryRow(`
-					INSERT INTO patient (name, gender, idno, age, birth, address, city, district, 
-						phone, mail, disease_id, emergency_contact, emergency_phone, emergency_relation,
-						OTHERHISTORYDISEASE, OTHERMEDICALHISTORY, user_id)
-					VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) 
-					RETURNING ID`,
-					patient.Name, patient.Gender, patient.IDNo, patient.Age, patient.Birth,
-					patient.Address, patient.City, patient.District, patient.Phone, patient.Mail,
-					patient.DiseaseID, patient.EmergencyContact, patient.EmergencyPhone,
-					patient.EmergencyRelation, patient.OtherHistoryDisease, patient.OtherMedicalHistory,
-					patient.UserID,
-				).Scan(&patientID)
-
-				if err != nil {
-					errorMessages = append(errorMessages, fmt.Sprintf("插入病患 %s 失敗: %v", patient.Name, err))
-					continue
-				}
-
-				// 更新 ID 以便在前端顯示
-				patient.ID = patientID
-				ids = append(ids, patientID)
-				// 插入病史資料
-				for _, historyDisease := range patient.HistoryDiseases {
-					// 根據疾病名稱查詢對應的 ID
-					var diseaseID int64
-					err = tx.QueryRow(`
-						SELECT ID FROM history_disease 
-						WHERE disease_name = ?`,
-						historyDisease,
-					).Scan(&diseaseID)
-					if err != nil {
-						errorMessages = append(errorMessages, fmt.Sprintf("查詢疾病 %s 的ID失敗: %v", historyDisease, err))
-						continue
-					}
-
-					_, err = tx.Exec(`
-						INSERT INTO patient_history_disease (patient_id, history_disease, disease_id)
-						VALUES (?, ?, ?)`,
-						patientID, historyDisease, diseaseID,
-					)
-					if err != nil {
-						errorMessages = append(errorMessages, fmt.Sprintf("插入病患 %s 的病史資料失敗: %v", patient.Name, err))
-					}
-				}
-
-				// 插入醫療史資料
-				for _, medicalHistory := range patient.MedicalHistories {
-					_, err = tx.Exec(`
-						INSERT INTO patient_medical_history (patient_id, medical_history)
-						VALUES (?, ?)`,
-						patientID, medicalHistory,
-					)
-					if err != nil {
-						errorMessages = append(errorMessages, fmt.Sprintf("插入病患 %s 的醫療史資料失敗: %v", patient.Name, err))
-					}
-				}
-
-				successCount++
-			}
-
-			// 如果全部成功，提交事務
-			if len(errorMessages) == 0 {
-				if err := tx.Commit(); err != nil {
-					c.HTML(http.StatusInternalServerError, "fake_patients.html", gin.H{
-						"title":    "產生假病患資料",
-						"error":    "無法提交資料庫交易: " + err.Error(),
-						"patients": patients,
-					})
-					return
-				}
-			} else {
-				if err := tx.Commit(); err != nil {
-					errorMessages = append(errorMessages, "無法提交資料庫交易: "+err.Error())
-				}
-			}
 		}
 
 		// 返回結果
-		c.HTML(http.StatusOK, "fake_patients.html", gin.H{
-			"title":        "產生假病患資料",
-			"patients":     patients,
-			"count":        count,
-			"successCount": successCount,
-			"insertToDB":   insertToDB,
-			"errors":       errorMessages,
-			"generated":    true,
-			"timestamp":    time.Now().Format("2006-01-02 15:04:05"),
+		renderHTML(c, http.StatusOK, "fake_patients.html", gin.H{
+			"title":           "產生假病患資料",
+			"patients":        patients,
+			"count":           count,
+			"successCount":    successCount,
+			"insertToDB":      insertToDB,
+			"errors":          errorMessages,
+			"generated":       true,
+			"timestamp":       time.Now().Format("2006-01-02 15:04:05"),
+			"profiles":        profiles,
+			"selectedProfile": profile.Name,
+			"profileName":     profile.DisplayName,
+			"batchID":         batchID,
 		})
 	}
 }
+
+// AnonymizePatientsHandler 處理 POST /api/patients/anonymize 路由，就地將目前租戶的病患去識別化。
+// 預設租戶通常是正式資料，不允許就地去識別化，應先複製到其他租戶
+func AnonymizePatientsHandler(registry *tenant.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			PatientIDs []int64 `json:"patient_ids"` // 為空時處理所有病患
+			DryRun     bool    `json:"dry_run"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, "", models.Validationf("無效的請求內容: %v", err))
+			return
+		}
+		current := currentTenant(c)
+		if current.Name == registry.DefaultName() {
+			respondError(c, "", models.Forbiddenf("預設租戶 %s 不可就地去識別化，請先複製到其他租戶", current.Name))
+			return
+		}
+
+		result, err := current.Service.AnonymizePatients(c.Request.Context(), req.PatientIDs, req.DryRun)
+		if err != nil {
+			respondError(c, "去識別化失敗: ", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"tenant": current.Name, "result": result})
+	}
+}
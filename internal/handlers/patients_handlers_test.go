@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-gin-app/internal/utils"
+)
+
+func TestListPatientProfilesAPI(t *testing.T) {
+	r, registry := newTenantRouterWithRegistry(t)
+	r.GET("/api/fake-patients/profiles", TenantMiddleware(registry), ListPatientProfilesHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/fake-patients/profiles", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var resp struct {
+		Profiles []utils.PatientProfile `json:"profiles"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析回應失敗: %v", err)
+	}
+	if len(resp.Profiles) != len(utils.ListPatientProfiles()) || resp.Profiles[0].Name != utils.PatientProfileDefault {
+		t.Fatalf("設定檔清單不正確: %+v", resp.Profiles)
+	}
+	for _, profile := range resp.Profiles {
+		if len(profile.AgeBands) == 0 {
+			t.Errorf("%s: 回應缺少年齡分佈", profile.Name)
+		}
+	}
+}
+
+func TestGenerateFakePatientsAPI(t *testing.T) {
+	r, registry := newTenantRouterWithRegistry(t)
+	r.POST("/api/fake-patients", TenantMiddleware(registry), GenerateFakePatientsAPIHandler(nil))
+
+	cases := []struct {
+		name    string
+		body    string
+		status  int
+		profile string
+		count   int
+	}{
+		{"default profile", `{"count": 5}`, http.StatusOK, utils.PatientProfileDefault, 5},
+		{"pediatric profile", `{"count": 20, "profile": "pediatric"}`, http.StatusOK, utils.PatientProfilePediatric, 20},
+		{"max count", `{"count": 100, "profile": "population"}`, http.StatusOK, utils.PatientProfilePopulation, 100},
+		{"zero count", `{"count": 0}`, http.StatusBadRequest, "", 0},
+		{"negative count", `{"count": -1}`, http.StatusBadRequest, "", 0},
+		{"over max count", `{"count": 101}`, http.StatusBadRequest, "", 0},
+		{"unknown profile", `{"count": 5, "profile": "unknown"}`, http.StatusBadRequest, "", 0},
+		{"invalid json", `{"count":`, http.StatusBadRequest, "", 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/fake-patients", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.status, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Profile  string `json:"profile"`
+				Count    int    `json:"count"`
+				Patients []struct {
+					Name            string   `json:"name"`
+					Age             int      `json:"age"`
+					HistoryDiseases []string `json:"history_diseases"`
+				} `json:"patients"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("解析回應失敗: %v", err)
+			}
+			if resp.Profile != tc.profile || resp.Count != tc.count || len(resp.Patients) != tc.count {
+				t.Fatalf("profile = %q, count = %d, patients = %d, want %q, %d",
+					resp.Profile, resp.Count, len(resp.Patients), tc.profile, tc.count)
+			}
+			for _, p := range resp.Patients {
+				if p.Name == "" {
+					t.Errorf("病患缺少姓名")
+				}
+				if tc.profile == utils.PatientProfilePediatric && p.Age > 17 {
+					t.Errorf("兒科病患年齡 %d 超過 17 歲", p.Age)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"golang-gin-app/internal/jobs"
+	"golang-gin-app/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProgressIDField 是生成表單中進度ID的欄位名稱；頁面送出表單前產生隨機ID，
+// 並以 GET /progress/:id/events 接收這次請求的生成進度
+const ProgressIDField = "progressID"
+
+const (
+	// progressInterval 進度事件最多每隔多久推送一次，最後的 done/error 事件立即推送
+	progressInterval = 200 * time.Millisecond
+	// heartbeatInterval 沒有事件時每隔多久送出註解，避免代理伺服器關閉閒置連線
+	heartbeatInterval = 15 * time.Second
+)
+
+// progressKey 回傳同步請求的進度在 Hub 中的鍵值；與租戶一起識別，避免不同租戶的頁面互相干擾
+func progressKey(c *gin.Context, id string) string {
+	return currentTenant(c).Name + "/request/" + id
+}
+
+// trackProgress 在表單帶有進度ID時，回傳會將生成進度推送給該ID訂閱者的 ctx，
+// 以及生成結束時呼叫的 finish（推送 done 或 error 事件後結束串流）；沒有進度ID時不推送任何事件
+func trackProgress(c *gin.Context, hub *jobs.Hub) (context.Context, func(err error)) {
+	ctx := c.Request.Context()
+	id := c.PostForm(ProgressIDField)
+	if hub == nil || id == "" {
+		return ctx, func(error) {}
+	}
+
+	key := progressKey(c, id)
+	var last models.JobProgress
+	ctx = jobs.WithProgress(ctx, func(progress models.JobProgress) {
+		last = progress
+		hub.Publish(key, jobs.Event{Type: jobs.EventProgress, Progress: progress})
+	})
+	return ctx, func(err error) {
+		event := jobs.Event{Type: jobs.EventDone, Progress: last}
+		if err != nil {
+			event.Type, event.Error = jobs.EventError, err.Error()
+		}
+		hub.Publish(key, event)
+		hub.Close(key)
+	}
+}
+
+// setEventStreamHeaders 設定 SSE 回應標頭，需在寫入第一個事件前呼叫
+func setEventStreamHeaders(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	// 避免 nginx 等反向代理暫存串流內容
+	c.Header("X-Accel-Buffering", "no")
+}
+
+// streamEvents 以 SSE 推送 events 中的事件，直到最後的 done/error 事件、用戶端斷線或 events 被關閉；
+// events 被關閉時以 closed 回傳的事件（ok 為 true 時）作為最後的事件。
+// 連續的 progress 事件只推送最新的一個，最多每 progressInterval 推送一次
+func streamEvents(c *gin.Context, events <-chan jobs.Event, closed func() (jobs.Event, bool)) {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	var pending *jobs.Event
+	lastSent := time.Now()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				if final, ok := closed(); ok {
+					c.SSEvent(final.Type, final)
+				}
+				return false
+			}
+			if !event.Final() {
+				pending = &event
+				return true
+			}
+			c.SSEvent(event.Type, event)
+			return false
+		case now := <-ticker.C:
+			if pending != nil {
+				c.SSEvent(pending.Type, *pending)
+				pending, lastSent = nil, now
+			} else if now.Sub(lastSent) >= heartbeatInterval {
+				io.WriteString(w, ": heartbeat\n\n")
+				lastSent = now
+			}
+			return true
+		}
+	})
+}
+
+// ProgressEventsHandler 處理 GET /progress/:id/events 路由，以 SSE 推送送出時帶有此進度ID的
+// 生成請求（使用者、病患或時段）的進度，生成結束後推送 done 或 error 事件並結束串流
+func ProgressEventsHandler(hub *jobs.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		events, unsubscribe := hub.Subscribe(progressKey(c, c.Param("id")))
+		defer unsubscribe()
+		setEventStreamHeaders(c)
+		c.Writer.Flush()
+		streamEvents(c, events, func() (jobs.Event, bool) { return jobs.Event{}, false })
+	}
+}
+
+// JobEventsHandler 處理 GET /api/jobs/:id/events 路由，以 SSE 推送背景工作目前的狀態與之後的進度；
+// 工作結束時推送 done 或 error 事件並結束串流，工作已結束時只推送最後的狀態
+func JobEventsHandler(runner *jobs.Runner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		// 先訂閱再讀取目前狀態，避免遺漏兩者之間的事件
+		events, unsubscribe := runner.Subscribe(jobTask(c, id))
+		defer unsubscribe()
+
+		job, err := loadJob(c, runner, id)
+		if err != nil {
+			respondError(c, "獲取工作失敗: ", err)
+			return
+		}
+		current := jobs.JobEvent(*job)
+		setEventStreamHeaders(c)
+		c.SSEvent(current.Type, current)
+		if current.Final() {
+			return
+		}
+		c.Writer.Flush()
+
+		// 工作執行結束（例如放回佇列）時以資料庫中的狀態作為最後的事件
+		streamEvents(c, events, func() (jobs.Event, bool) {
+			job, err := currentService(c).GetJob(c.Request.Context(), id)
+			if err != nil {
+				return jobs.Event{}, false
+			}
+			return jobs.JobEvent(*job), true
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang-gin-app/internal/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readEvents 讀取 SSE 回應中的所有事件，直到伺服器結束串流
+func readEvents(t *testing.T, resp *http.Response) []jobs.Event {
+	t.Helper()
+	defer resp.Body.Close()
+	var events []jobs.Event
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var event jobs.Event
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("decode event %q: %v", data, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestProgressEventsStreamGeneration(t *testing.T) {
+	r, registry := newTenantRouterWithRegistry(t)
+	hub := jobs.NewHub()
+	g := r.Group("/", TenantMiddleware(registry))
+	g.GET("/progress/:id/events", ProgressEventsHandler(hub))
+	g.POST("/generate", func(c *gin.Context) {
+		count, _ := strconv.Atoi(c.PostForm("count"))
+		ctx, finish := trackProgress(c, hub)
+		created, _, err := currentService(c).GenerateFakeUsers(ctx, count, "doctor", nil)
+		finish(err)
+		c.JSON(http.StatusOK, gin.H{"created": created})
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	cases := []struct {
+		name  string
+		count string
+		want  string
+	}{
+		{"done", "3", jobs.EventDone},
+		{"error", "0", jobs.EventError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 回應標頭送達時已完成訂閱
+			stream, err := http.Get(server.URL + "/progress/" + tc.name + "/events")
+			if err != nil {
+				t.Fatalf("GET events: %v", err)
+			}
+			if ct := stream.Header.Get("Content-Type"); ct != "text/event-stream" {
+				t.Fatalf("Content-Type = %q", ct)
+			}
+
+			resp, err := http.PostForm(server.URL+"/generate", url.Values{"count": {tc.count}, ProgressIDField: {tc.name}})
+			if err != nil {
+				t.Fatalf("POST generate: %v", err)
+			}
+			resp.Body.Close()
+
+			events := readEvents(t, stream)
+			if len(events) == 0 {
+				t.Fatal("沒有收到任何事件")
+			}
+			last := events[len(events)-1]
+			if last.Type != tc.want {
+				t.Fatalf("最後的事件 = %+v, want %s", last, tc.want)
+			}
+			if tc.want == jobs.EventDone && (last.Progress.Stage != "roles" || last.Progress.Done != 3) {
+				t.Errorf("done 事件應帶有最後的進度: %+v", last.Progress)
+			}
+			if tc.want == jobs.EventError && last.Error == "" {
+				t.Errorf("error 事件應帶有錯誤訊息: %+v", last)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+
+	"golang-gin-app/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reserveGeneration 檢查目前用戶端與使用者的請求頻率與每日配額，並預留這次要生成的 records 筆；
+// limiter 為 nil 時不限制。生成失敗時呼叫回傳值的 Cancel 歸還配額。
+func reserveGeneration(c *gin.Context, limiter *ratelimit.Limiter, records int) (*ratelimit.Reservation, error) {
+	if limiter == nil {
+		return nil, nil
+	}
+	return limiter.Allow(limiter.Subjects(remoteIP(c), c.Request.Header), records)
+}
+
+// remoteIP 回傳連線的來源位址作為用戶端識別。不使用 c.ClientIP()：
+// X-Forwarded-For 等標頭可由用戶端任意設定，每次換一個值就能取得新的請求額度與每日配額
+func remoteIP(c *gin.Context) string {
+	if ip, _ := c.RemoteIP(); ip != nil {
+		return ip.String()
+	}
+	return c.Request.RemoteAddr
+}
+
+// RateLimitsHandler 處理 GET /api/rate-limits 路由，回傳限制設定與今日各用戶端及使用者的使用狀況
+func RateLimitsHandler(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		config := limiter.Config()
+		c.JSON(http.StatusOK, gin.H{
+			"limits": gin.H{
+				"client": gin.H{"per_minute": config.Client.PerMinute, "burst": config.Client.Burst, "daily_quota": config.ClientDailyQuota},
+				"user":   gin.H{"per_minute": config.User.PerMinute, "burst": config.User.Burst, "daily_quota": config.UserDailyQuota},
+			},
+			"user_header": config.UserHeader,
+			"usage":       limiter.Usage(),
+		})
+	}
+}
+
+// RateLimitsPageHandler 處理 GET /admin/rate-limits 路由，顯示限制設定與今日的使用狀況
+func RateLimitsPageHandler(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renderHTML(c, http.StatusOK, "rate_limits.html", gin.H{
+			"title":  "生成請求限制",
+			"config": limiter.Config(),
+			"usage":  limiter.Usage(),
+		})
+	}
+}
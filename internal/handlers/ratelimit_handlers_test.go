@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-gin-app/internal/ratelimit"
+)
+
+func TestGenerationRateLimit(t *testing.T) {
+	r, registry := newTenantRouterWithRegistry(t)
+	limiter := ratelimit.New(ratelimit.Config{
+		User:           ratelimit.Limit{PerMinute: 1, Burst: 2},
+		UserDailyQuota: 5,
+		UserHeader:     "X-Forwarded-User",
+	})
+	r.POST("/api/fake-patients", TenantMiddleware(registry), GenerateFakePatientsAPIHandler(limiter))
+	r.GET("/api/rate-limits", RateLimitsHandler(limiter))
+
+	cases := []struct {
+		name   string
+		user   string
+		count  int
+		status int
+		code   string
+	}{
+		{"within limits", "alice", 3, http.StatusOK, ""},
+		{"over daily quota", "alice", 3, http.StatusTooManyRequests, "rate_limited"},
+		{"remaining quota", "alice", 2, http.StatusOK, ""},
+		{"over rate", "alice", 1, http.StatusTooManyRequests, "rate_limited"},
+		{"other user", "bob", 1, http.StatusOK, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := strings.NewReader(fmt.Sprintf(`{"count": %d}`, tc.count))
+			req := httptest.NewRequest(http.MethodPost, "/api/fake-patients", body)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Forwarded-User", tc.user)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.status, w.Body.String())
+			}
+			if tc.status != http.StatusTooManyRequests {
+				return
+			}
+			var resp struct {
+				Code string `json:"code"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.Code != tc.code || w.Header().Get("Retry-After") == "" {
+				t.Errorf("code = %q, Retry-After = %q", resp.Code, w.Header().Get("Retry-After"))
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rate-limits", nil))
+	var resp struct {
+		Usage []ratelimit.Usage `json:"usage"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode usage: %v", err)
+	}
+	for _, usage := range resp.Usage {
+		if usage.Kind == ratelimit.KindUser && usage.ID == "alice" && (usage.Records != 5 || usage.Rejected != 2) {
+			t.Errorf("alice usage = %+v, want 5 records and 2 rejected", usage)
+		}
+	}
+}
+
+func TestGenerationRateLimitIgnoresForwardedFor(t *testing.T) {
+	r, registry := newTenantRouterWithRegistry(t)
+	limiter := ratelimit.New(ratelimit.Config{
+		Client:           ratelimit.Limit{PerMinute: 1, Burst: 1},
+		ClientDailyQuota: 100,
+	})
+	r.POST("/api/fake-patients", TenantMiddleware(registry), GenerateFakePatientsAPIHandler(limiter))
+
+	// 每次偽造不同的 X-Forwarded-For 都不應取得新的請求額度
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodPost, "/api/fake-patients", strings.NewReader(`{"count": 1}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i+1))
+		req.Header.Set("X-Real-IP", fmt.Sprintf("198.51.100.%d", i+1))
+		req.RemoteAddr = "192.0.2.10:12345"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Fatalf("request %d: status = %d, want %d: %s", i+1, w.Code, want, w.Body.String())
+		}
+	}
+
+	usage := limiter.Usage()
+	if len(usage) != 1 || usage[0].ID != "192.0.2.10" {
+		t.Fatalf("usage = %+v, want a single entry for the remote address", usage)
+	}
+}
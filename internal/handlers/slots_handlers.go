@@ -5,36 +5,32 @@ import (
 	"strconv"
 	"time"
 
+	"golang-gin-app/internal/jobs"
 	"golang-gin-app/internal/models"
-	"golang-gin-app/internal/service"
+	"golang-gin-app/internal/ratelimit"
 
 	"github.com/gin-gonic/gin"
 )
 
 // AvailableSlotsFormHandler 處理顯示可預約時段表單的請求
-func AvailableSlotsFormHandler(svc *service.Service) gin.HandlerFunc {
+func AvailableSlotsFormHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		svc := currentService(c)
 		// 獲取所有醫師
 		doctors, err := svc.GetDoctorUsers(c.Request.Context())
 		if err != nil {
-			c.HTML(http.StatusInternalServerError, "available_slots.html", gin.H{
-				"title": "可預約時段管理",
-				"error": "獲取醫師列表失敗: " + err.Error(),
-			})
+			renderError(c, "available_slots.html", gin.H{"title": "可預約時段管理"}, "獲取醫師列表失敗: ", err)
 			return
 		}
 
 		// 獲取所有治療師
 		therapists, err := svc.GetTherapistUsers(c.Request.Context())
 		if err != nil {
-			c.HTML(http.StatusInternalServerError, "available_slots.html", gin.H{
-				"title": "可預約時段管理",
-				"error": "獲取治療師列表失敗: " + err.Error(),
-			})
+			renderError(c, "available_slots.html", gin.H{"title": "可預約時段管理"}, "獲取治療師列表失敗: ", err)
 			return
 		}
 
-		c.HTML(http.StatusOK, "available_slots.html", gin.H{
+		renderHTML(c, http.StatusOK, "available_slots.html", gin.H{
 			"title":      "可預約時段管理",
 			"doctors":    doctors,
 			"therapists": therapists,
@@ -43,8 +39,9 @@ func AvailableSlotsFormHandler(svc *service.Service) gin.HandlerFunc {
 }
 
 // GenerateAvailableSlotsHandler 處理生成可預約時段的請求
-func GenerateAvailableSlotsHandler(svc *service.Service) gin.HandlerFunc {
+func GenerateAvailableSlotsHandler(limiter *ratelimit.Limiter, runner *jobs.Runner, hub *jobs.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		svc := currentService(c)
 		doctorIDStr := c.PostForm("doctorID")
 		daysStr := c.PostForm("days")
 		slotsPerDayStr := c.PostForm("slotsPerDay")
@@ -54,7 +51,7 @@ func GenerateAvailableSlotsHandler(svc *service.Service) gin.HandlerFunc {
 		// 轉換參數
 		doctorID, err := strconv.ParseInt(doctorIDStr, 10, 64)
 		if err != nil || doctorID <= 0 {
-			c.HTML(http.StatusBadRequest, "available_slots.html", gin.H{
+			renderHTML(c, http.StatusBadRequest, "available_slots.html", gin.H{
 				"title": "可預約時段管理",
 				"error": "請提供有效的醫師/治療師ID",
 			})
@@ -63,7 +60,7 @@ func GenerateAvailableSlotsHandler(svc *service.Service) gin.HandlerFunc {
 
 		days, err := strconv.Atoi(daysStr)
 		if err != nil || days <= 0 || days > 365 {
-			c.HTML(http.StatusBadRequest, "available_slots.html", gin.H{
+			renderHTML(c, http.StatusBadRequest, "available_slots.html", gin.H{
 				"title": "可預約時段管理",
 				"error": "天數必須在1到365之間",
 			})
@@ -72,7 +69,7 @@ func GenerateAvailableSlotsHandler(svc *service.Service) gin.HandlerFunc {
 
 		slotsPerDay, err := strconv.Atoi(slotsPerDayStr)
 		if err != nil || slotsPerDay <= 0 || slotsPerDay > 24 {
-			c.HTML(http.StatusBadRequest, "available_slots.html", gin.H{
+			renderHTML(c, http.StatusBadRequest, "available_slots.html", gin.H{
 				"title": "可預約時段管理",
 				"error": "每天的時段數量必須在1到24之間",
 			})
@@ -81,7 +78,7 @@ func GenerateAvailableSlotsHandler(svc *service.Service) gin.HandlerFunc {
 
 		startHour, err := strconv.Atoi(startHourStr)
 		if err != nil || startHour < 0 || startHour > 23 {
-			c.HTML(http.StatusBadRequest, "available_slots.html", gin.H{
+			renderHTML(c, http.StatusBadRequest, "available_slots.html", gin.H{
 				"title": "可預約時段管理",
 				"error": "開始時間必須在0到23小時之間",
 			})
@@ -90,20 +87,36 @@ func GenerateAvailableSlotsHandler(svc *service.Service) gin.HandlerFunc {
 
 		slotDuration, err := strconv.Atoi(slotDurationStr)
 		if err != nil || slotDuration <= 0 || slotDuration > 240 {
-			c.HTML(http.StatusBadRequest, "available_slots.html", gin.H{
+			renderHTML(c, http.StatusBadRequest, "available_slots.html", gin.H{
 				"title": "可預約時段管理",
 				"error": "時段持續時間必須在1到240分鐘之間",
 			})
 			return
 		}
 
+		// 勾選背景執行時建立工作並導向工作狀態頁
+		if wantsBackground(c) {
+			params := models.SlotJobParams{DoctorID: doctorID, Days: days, SlotsPerDay: slotsPerDay, StartHour: startHour, SlotDuration: slotDuration}
+			if err := submitJobForm(c, runner, limiter, models.BatchKindSlots, params); err != nil {
+				renderError(c, "available_slots.html", gin.H{"title": "可預約時段管理"}, "建立背景工作失敗: ", err)
+			}
+			return
+		}
+
+		reservation, err := reserveGeneration(c, limiter, days*slotsPerDay)
+		if err != nil {
+			renderError(c, "available_slots.html", gin.H{"title": "可預約時段管理"}, "", err)
+			return
+		}
+
 		// 生成時段
-		slots, err := svc.GenerateAvailableSlots(c.Request.Context(), doctorID, days, slotsPerDay, startHour, slotDuration)
+		// 頁面以 /progress/:id/events 顯示儲存時段的進度
+		ctx, finish := trackProgress(c, hub)
+		slots, batchID, err := svc.GenerateAvailableSlots(ctx, doctorID, days, slotsPerDay, startHour, slotDuration)
+		finish(err)
 		if err != nil {
-			c.HTML(http.StatusInternalServerError, "available_slots.html", gin.H{
-				"title": "可預約時段管理",
-				"error": "生成時段失敗: " + err.Error(),
-			})
+			reservation.Cancel()
+			renderError(c, "available_slots.html", gin.H{"title": "可預約時段管理"}, "生成時段失敗: ", err)
 			return
 		}
 
@@ -141,8 +154,11 @@ func GenerateAvailableSlotsHandler(svc *service.Service) gin.HandlerFunc {
 
 		// 返回結果
 		message := strconv.Itoa(len(slots)) + " 個時段已經成功為 " + providerName + " 生成"
+		if batchID != "" {
+			message += "（批次ID: " + batchID + "）"
+		}
 
-		c.HTML(http.StatusOK, "available_slots.html", gin.H{
+		renderHTML(c, http.StatusOK, "available_slots.html", gin.H{
 			"title":      "可預約時段管理",
 			"message":    message,
 			"doctors":    allDoctors,
@@ -154,12 +170,13 @@ func GenerateAvailableSlotsHandler(svc *service.Service) gin.HandlerFunc {
 }
 
 // ViewAvailableSlotsHandler 顯示特定醫師/治療師的可預約時段
-func ViewAvailableSlotsHandler(svc *service.Service) gin.HandlerFunc {
+func ViewAvailableSlotsHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		svc := currentService(c)
 		doctorIDStr := c.Query("doctorID")
 
 		if doctorIDStr == "" {
-			c.HTML(http.StatusBadRequest, "available_slots.html", gin.H{
+			renderHTML(c, http.StatusBadRequest, "available_slots.html", gin.H{
 				"title": "可預約時段管理",
 				"error": "請提供醫師/治療師ID",
 			})
@@ -168,7 +185,7 @@ func ViewAvailableSlotsHandler(svc *service.Service) gin.HandlerFunc {
 
 		doctorID, err := strconv.ParseInt(doctorIDStr, 10, 64)
 		if err != nil {
-			c.HTML(http.StatusBadRequest, "available_slots.html", gin.H{
+			renderHTML(c, http.StatusBadRequest, "available_slots.html", gin.H{
 				"title": "可預約時段管理",
 				"error": "無效的醫師/治療師ID",
 			})
@@ -178,10 +195,7 @@ func ViewAvailableSlotsHandler(svc *service.Service) gin.HandlerFunc {
 		// 獲取該醫師/治療師的所有時段
 		slots, err := svc.GetAvailableSlotsByDoctor(c.Request.Context(), doctorID)
 		if err != nil {
-			c.HTML(http.StatusInternalServerError, "available_slots.html", gin.H{
-				"title": "可預約時段管理",
-				"error": "獲取時段失敗: " + err.Error(),
-			})
+			renderError(c, "available_slots.html", gin.H{"title": "可預約時段管理"}, "獲取時段失敗: ", err)
 			return
 		}
 
@@ -213,7 +227,7 @@ func ViewAvailableSlotsHandler(svc *service.Service) gin.HandlerFunc {
 			}
 		}
 
-		c.HTML(http.StatusOK, "available_slots.html", gin.H{
+		renderHTML(c, http.StatusOK, "available_slots.html", gin.H{
 			"title":        "可預約時段管理",
 			"doctors":      doctors,
 			"therapists":   therapists,
@@ -225,12 +239,13 @@ func ViewAvailableSlotsHandler(svc *service.Service) gin.HandlerFunc {
 }
 
 // EditAvailableSlotFormHandler 顯示編輯可預約時段的表單
-func EditAvailableSlotFormHandler(svc *service.Service) gin.HandlerFunc {
+func EditAvailableSlotFormHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		svc := currentService(c)
 		slotIDStr := c.Param("id")
 		slotID, err := strconv.ParseInt(slotIDStr, 10, 64)
 		if err != nil {
-			c.HTML(http.StatusBadRequest, "available_slots.html", gin.H{
+			renderHTML(c, http.StatusBadRequest, "available_slots.html", gin.H{
 				"title": "編輯可預約時段",
 				"error": "無效的時段ID",
 			})
@@ -240,10 +255,7 @@ func EditAvailableSlotFormHandler(svc *service.Service) gin.HandlerFunc {
 		// 獲取時段信息
 		slot, err := svc.GetAvailableSlotByID(c.Request.Context(), slotID)
 		if err != nil {
-			c.HTML(http.StatusInternalServerError, "available_slots.html", gin.H{
-				"title": "編輯可預約時段",
-				"error": "獲取時段信息失敗: " + err.Error(),
-			})
+			renderError(c, "available_slots.html", gin.H{"title": "編輯可預約時段"}, "獲取時段信息失敗: ", err)
 			return
 		}
 
@@ -251,7 +263,7 @@ func EditAvailableSlotFormHandler(svc *service.Service) gin.HandlerFunc {
 		doctors, _ := svc.GetDoctorUsers(c.Request.Context())
 		therapists, _ := svc.GetTherapistUsers(c.Request.Context())
 
-		c.HTML(http.StatusOK, "edit_slot.html", gin.H{
+		renderHTML(c, http.StatusOK, "edit_slot.html", gin.H{
 			"title":      "編輯可預約時段",
 			"slot":       slot,
 			"doctors":    doctors,
@@ -261,12 +273,13 @@ func EditAvailableSlotFormHandler(svc *service.Service) gin.HandlerFunc {
 }
 
 // UpdateAvailableSlotHandler 處理更新可預約時段的請求
-func UpdateAvailableSlotHandler(svc *service.Service) gin.HandlerFunc {
+func UpdateAvailableSlotHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		svc := currentService(c)
 		slotIDStr := c.Param("id")
 		slotID, err := strconv.ParseInt(slotIDStr, 10, 64)
 		if err != nil {
-			c.HTML(http.StatusBadRequest, "available_slots.html", gin.H{
+			renderHTML(c, http.StatusBadRequest, "available_slots.html", gin.H{
 				"title": "更新可預約時段",
 				"error": "無效的時段ID",
 			})
@@ -276,10 +289,7 @@ func UpdateAvailableSlotHandler(svc *service.Service) gin.HandlerFunc {
 		// 獲取現有時段
 		slot, err := svc.GetAvailableSlotByID(c.Request.Context(), slotID)
 		if err != nil {
-			c.HTML(http.StatusInternalServerError, "available_slots.html", gin.H{
-				"title": "更新可預約時段",
-				"error": "獲取時段信息失敗: " + err.Error(),
-			})
+			renderError(c, "available_slots.html", gin.H{"title": "更新可預約時段"}, "獲取時段信息失敗: ", err)
 			return
 		}
 
@@ -292,7 +302,7 @@ func UpdateAvailableSlotHandler(svc *service.Service) gin.HandlerFunc {
 
 		doctorID, err := strconv.ParseInt(doctorIDStr, 10, 64)
 		if err != nil || doctorID <= 0 {
-			c.HTML(http.StatusBadRequest, "edit_slot.html", gin.H{
+			renderHTML(c, http.StatusBadRequest, "edit_slot.html", gin.H{
 				"title": "更新可預約時段",
 				"error": "無效的醫師/治療師ID",
 				"slot":  slot,
@@ -303,7 +313,7 @@ func UpdateAvailableSlotHandler(svc *service.Service) gin.HandlerFunc {
 		// 解析日期和時間
 		date, err := time.Parse("2006-01-02", dateStr)
 		if err != nil {
-			c.HTML(http.StatusBadRequest, "edit_slot.html", gin.H{
+			renderHTML(c, http.StatusBadRequest, "edit_slot.html", gin.H{
 				"title": "更新可預約時段",
 				"error": "無效的日期格式",
 				"slot":  slot,
@@ -314,7 +324,7 @@ func UpdateAvailableSlotHandler(svc *service.Service) gin.HandlerFunc {
 		// 解析開始時間和結束時間
 		beginTime, err := time.Parse("15:04", beginTimeStr)
 		if err != nil {
-			c.HTML(http.StatusBadRequest, "edit_slot.html", gin.H{
+			renderHTML(c, http.StatusBadRequest, "edit_slot.html", gin.H{
 				"title": "更新可預約時段",
 				"error": "無效的開始時間格式",
 				"slot":  slot,
@@ -324,7 +334,7 @@ func UpdateAvailableSlotHandler(svc *service.Service) gin.HandlerFunc {
 
 		endTime, err := time.Parse("15:04", endTimeStr)
 		if err != nil {
-			c.HTML(http.StatusBadRequest, "edit_slot.html", gin.H{
+			renderHTML(c, http.StatusBadRequest, "edit_slot.html", gin.H{
 				"title": "更新可預約時段",
 				"error": "無效的結束時間格式",
 				"slot":  slot,
@@ -343,7 +353,7 @@ func UpdateAvailableSlotHandler(svc *service.Service) gin.HandlerFunc {
 
 		// 檢查時間順序
 		if slotBeginTime.After(slotEndTime) {
-			c.HTML(http.StatusBadRequest, "edit_slot.html", gin.H{
+			renderHTML(c, http.StatusBadRequest, "edit_slot.html", gin.H{
 				"title": "更新可預約時段",
 				"error": "開始時間不能晚於結束時間",
 				"slot":  slot,
@@ -359,11 +369,10 @@ func UpdateAvailableSlotHandler(svc *service.Service) gin.HandlerFunc {
 		slot.IsBooked = isBookedStr == "true"
 
 		if err := svc.UpdateAvailableSlot(c.Request.Context(), slot); err != nil {
-			c.HTML(http.StatusInternalServerError, "edit_slot.html", gin.H{
+			renderError(c, "edit_slot.html", gin.H{
 				"title": "更新可預約時段",
-				"error": "更新時段失敗: " + err.Error(),
 				"slot":  slot,
-			})
+			}, "更新時段失敗: ", err)
 			return
 		}
 
@@ -373,8 +382,9 @@ func UpdateAvailableSlotHandler(svc *service.Service) gin.HandlerFunc {
 }
 
 // DeleteAvailableSlotHandler 處理刪除可預約時段的請求
-func DeleteAvailableSlotHandler(svc *service.Service) gin.HandlerFunc {
+func DeleteAvailableSlotHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		svc := currentService(c)
 		slotIDStr := c.Param("id")
 		doctorIDStr := c.Query("doctorID") // 保存醫師/治療師ID用於重定向
 
@@ -386,7 +396,7 @@ func DeleteAvailableSlotHandler(svc *service.Service) gin.HandlerFunc {
 
 		// 刪除時段
 		if err := svc.DeleteAvailableSlot(c.Request.Context(), slotID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "刪除時段失敗: " + err.Error()})
+			respondError(c, "刪除時段失敗: ", err)
 			return
 		}
 
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/service"
+	"golang-gin-app/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 指定目標租戶（資料庫）的方式：URL 查詢參數、HTTP 標頭，或 UI 下拉選單設定的 cookie
+const (
+	TenantQueryParam = "tenant"
+	TenantHeader     = "X-Tenant"
+	tenantCookie     = "tenant"
+)
+
+// gin.Context 中存放目前租戶的鍵
+const (
+	tenantKey  = "tenant"
+	tenantsKey = "tenants"
+)
+
+// tenantCookieMaxAge UI 選擇的租戶保留 30 天
+const tenantCookieMaxAge = 30 * 24 * 60 * 60
+
+// lookupTenant 依名稱取得已連線的租戶；未知的租戶回傳 ErrNotFound，
+// 尚未連線（背景重新連線中）的租戶回傳 ErrUnavailable
+func lookupTenant(registry *tenant.Registry, name string) (*tenant.Tenant, error) {
+	if t, ok := registry.Get(name); ok {
+		return t, nil
+	}
+	if err := registry.Unavailable(name); err != nil {
+		return nil, models.Unavailablef("租戶 %s 的資料庫尚未連線: %v", name, err)
+	}
+	return nil, models.NotFoundf("未知的租戶: %s", name)
+}
+
+// TenantMiddleware 依查詢參數、X-Tenant 標頭或 cookie 決定本次請求的目標租戶，
+// 皆未指定時使用預設租戶。明確指定未知的租戶時回傳 404，指定尚未連線的租戶時回傳 503；
+// cookie 中的租戶已不存在或尚未連線時改用預設租戶。
+func TenantMiddleware(registry *tenant.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, explicit := c.Query(TenantQueryParam), true
+		if name == "" {
+			name = c.GetHeader(TenantHeader)
+		}
+		if name == "" {
+			name, _ = c.Cookie(tenantCookie)
+			explicit = false
+		}
+
+		t, err := lookupTenant(registry, name)
+		if err != nil {
+			if explicit {
+				respondError(c, "", err)
+				c.Abort()
+				return
+			}
+			t = registry.Default()
+		}
+
+		c.Set(tenantKey, t)
+		c.Set(tenantsKey, registry.Names())
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), logging.KeyTenant, t.Name))
+		c.Next()
+	}
+}
+
+// currentTenant 取得 TenantMiddleware 決定的目前租戶
+func currentTenant(c *gin.Context) *tenant.Tenant {
+	return c.MustGet(tenantKey).(*tenant.Tenant)
+}
+
+// currentService 取得目前租戶的 Service
+func currentService(c *gin.Context) *service.Service {
+	return currentTenant(c).Service
+}
+
+// renderHTML 渲染頁面，並加入租戶下拉選單所需的資料
+func renderHTML(c *gin.Context, status int, template string, data gin.H) {
+	if t, ok := c.Get(tenantKey); ok {
+		data["tenant"] = t.(*tenant.Tenant).Name
+		data["tenants"] = c.GetStringSlice(tenantsKey)
+		data["tenantReturn"] = tenantReturnPath(c)
+	}
+	c.HTML(status, template, data)
+}
+
+// tenantReturnPath 切換租戶後返回的頁面：GET 請求返回目前頁面，
+// 表單送出後的結果頁則返回送出表單的頁面
+func tenantReturnPath(c *gin.Context) string {
+	if c.Request.Method == http.MethodGet {
+		return c.Request.URL.RequestURI()
+	}
+	if referer, err := url.Parse(c.GetHeader("Referer")); err == nil && referer.Path != "" {
+		return referer.RequestURI()
+	}
+	return "/fake-users"
+}
+
+// SwitchTenantHandler 處理 POST /tenants/switch 路由，記住 UI 選擇的租戶並返回原頁面
+func SwitchTenantHandler(registry *tenant.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.PostForm(TenantQueryParam)
+		if _, err := lookupTenant(registry, name); err != nil {
+			respondError(c, "", err)
+			return
+		}
+		c.SetCookie(tenantCookie, name, tenantCookieMaxAge, "/", "", false, true)
+
+		// 只允許返回站內路徑，並移除會覆蓋 cookie 的 tenant 查詢參數
+		target := "/fake-users"
+		if redirect, err := url.Parse(c.PostForm("redirect")); err == nil &&
+			strings.HasPrefix(redirect.Path, "/") && !strings.HasPrefix(redirect.Path, "//") && redirect.Host == "" {
+			query := redirect.Query()
+			query.Del(TenantQueryParam)
+			redirect.RawQuery = query.Encode()
+			target = redirect.RequestURI()
+		}
+		c.Redirect(http.StatusFound, target)
+	}
+}
+
+// ListTenantsHandler 處理 GET /api/tenants 路由，回傳所有租戶與目前使用的租戶
+func ListTenantsHandler(registry *tenant.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"tenants": registry.Names(),
+			"default": registry.DefaultName(),
+			"current": currentTenant(c).Name,
+		})
+	}
+}
+
+// DatabaseStatsHandler 處理 GET /api/db/stats 路由，回傳每個租戶的連線狀態與連線池統計
+func DatabaseStatsHandler(registry *tenant.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"tenants": registry.Statuses()})
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-gin-app/internal/repository"
+	"golang-gin-app/internal/service"
+	"golang-gin-app/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTenantRouter(t *testing.T) *gin.Engine {
+	r, _ := newTenantRouterWithRegistry(t)
+	return r
+}
+
+// newTenantRouterWithRegistry 建立兩個已連線的租戶與一個尚未連線的租戶 dtxarchive
+func newTenantRouterWithRegistry(t *testing.T) (*gin.Engine, *tenant.Registry) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	registry := tenant.NewRegistry("dtxcasemgnt")
+	for _, name := range []string{"dtxcasemgnt", "dtxtraining"} {
+		svc := service.NewService(repository.NewMemoryStore())
+		if err := registry.Register(&tenant.Tenant{Name: name, Service: svc}); err != nil {
+			t.Fatalf("Register(%s): %v", name, err)
+		}
+	}
+
+	registry.MarkUnavailable("dtxarchive", errors.New("connection refused"))
+
+	r := gin.New()
+	r.GET("/api/db/stats", DatabaseStatsHandler(registry))
+	g := r.Group("/", TenantMiddleware(registry))
+	g.GET("/api/tenants", ListTenantsHandler(registry))
+	g.POST("/tenants/switch", SwitchTenantHandler(registry))
+	return r, registry
+}
+
+func TestTenantMiddleware(t *testing.T) {
+	r := newTenantRouter(t)
+
+	cases := []struct {
+		name       string
+		query      string
+		header     string
+		cookie     string
+		wantStatus int
+		wantTenant string
+	}{
+		{"default", "", "", "", http.StatusOK, "dtxcasemgnt"},
+		{"query", "dtxtraining", "", "", http.StatusOK, "dtxtraining"},
+		{"header", "", "dtxtraining", "", http.StatusOK, "dtxtraining"},
+		{"cookie", "", "", "dtxtraining", http.StatusOK, "dtxtraining"},
+		{"query overrides cookie", "dtxcasemgnt", "", "dtxtraining", http.StatusOK, "dtxcasemgnt"},
+		{"unknown query", "nope", "", "", http.StatusNotFound, ""},
+		{"unknown header", "", "nope", "", http.StatusNotFound, ""},
+		{"stale cookie", "", "", "nope", http.StatusOK, "dtxcasemgnt"},
+		{"unavailable query", "dtxarchive", "", "", http.StatusServiceUnavailable, ""},
+		{"unavailable header", "", "dtxarchive", "", http.StatusServiceUnavailable, ""},
+		{"unavailable cookie", "", "", "dtxarchive", http.StatusOK, "dtxcasemgnt"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			target := "/api/tenants"
+			if tc.query != "" {
+				target += "?tenant=" + tc.query
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			if tc.header != "" {
+				req.Header.Set(TenantHeader, tc.header)
+			}
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: tenantCookie, Value: tc.cookie})
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.wantStatus, w.Body)
+			}
+			if tc.wantTenant != "" && !strings.Contains(w.Body.String(), `"current":"`+tc.wantTenant+`"`) {
+				t.Errorf("body = %s, want current tenant %s", w.Body, tc.wantTenant)
+			}
+		})
+	}
+}
+
+func TestSwitchTenantHandler(t *testing.T) {
+	r := newTenantRouter(t)
+
+	cases := []struct {
+		name         string
+		form         string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"local redirect", "tenant=dtxtraining&redirect=/batches?tenant=dtxcasemgnt", http.StatusFound, "/batches"},
+		{"external redirect", "tenant=dtxtraining&redirect=https://example.com/", http.StatusFound, "/fake-users"},
+		{"protocol-relative redirect", "tenant=dtxtraining&redirect=//example.com/", http.StatusFound, "/fake-users"},
+		{"unknown tenant", "tenant=nope&redirect=/batches", http.StatusNotFound, ""},
+		{"unavailable tenant", "tenant=dtxarchive&redirect=/batches", http.StatusServiceUnavailable, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/tenants/switch", strings.NewReader(tc.form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.wantStatus, w.Body)
+			}
+			if got := w.Header().Get("Location"); got != tc.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tc.wantLocation)
+			}
+		})
+	}
+}
+
+func TestUnavailableTenantIsEnabledAfterReconnect(t *testing.T) {
+	r, registry := newTenantRouterWithRegistry(t)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	var stats struct {
+		Tenants []tenant.Status `json:"tenants"`
+	}
+	if err := json.Unmarshal(get("/api/db/stats").Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decode stats: %v", err)
+	}
+	if len(stats.Tenants) != 3 || stats.Tenants[2].Available || stats.Tenants[2].Error != "connection refused" {
+		t.Fatalf("unexpected stats before reconnect: %+v", stats.Tenants)
+	}
+	if w := get("/api/tenants"); strings.Contains(w.Body.String(), "dtxarchive") {
+		t.Errorf("unavailable tenant must not be listed: %s", w.Body)
+	}
+
+	svc := service.NewService(repository.NewMemoryStore())
+	if err := registry.Register(&tenant.Tenant{Name: "dtxarchive", Service: svc}); err != nil {
+		t.Fatalf("Register after reconnect: %v", err)
+	}
+	if w := get("/api/tenants?tenant=dtxarchive"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"current":"dtxarchive"`) {
+		t.Errorf("status = %d, body = %s, want the reconnected tenant", w.Code, w.Body)
+	}
+	if got := registry.Names(); len(got) != 3 || got[2] != "dtxarchive" {
+		t.Errorf("Names() = %v, want the reconnected tenant in its configured position", got)
+	}
+}
@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"sync"
+
+	"golang-gin-app/internal/models"
+)
+
+// 進度事件的類型，同時作為 SSE 的事件名稱
+const (
+	EventProgress = "progress" // 進度更新，之後還會有其他事件
+	EventDone     = "done"     // 成功或已取消，最後一個事件
+	EventError    = "error"    // 失敗，最後一個事件
+)
+
+// Event 是推送給訂閱者的生成進度事件
+type Event struct {
+	Type     string             `json:"type"`
+	Status   string             `json:"status,omitempty"` // 背景工作的狀態，同步請求沒有此欄位
+	Progress models.JobProgress `json:"progress"`
+	Result   *models.JobResult  `json:"result,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// Final 判斷是否為最後一個事件
+func (e Event) Final() bool {
+	return e.Type == EventDone || e.Type == EventError
+}
+
+// JobEvent 將工作的狀態轉為事件：失敗為 error，成功或已取消為 done，其餘為 progress
+func JobEvent(job models.Job) Event {
+	event := Event{Type: EventProgress, Status: job.Status, Progress: job.Progress, Result: job.Result, Error: job.Error}
+	switch job.Status {
+	case models.JobStatusFailed:
+		event.Type = EventError
+	case models.JobStatusSucceeded, models.JobStatusCancelled:
+		event.Type = EventDone
+	}
+	return event
+}
+
+// subscriberBuffer 每個訂閱者最多暫存的事件數，訂閱者來不及讀取時丟棄最舊的事件
+const subscriberBuffer = 16
+
+// Hub 依鍵值將進度事件轉發給訂閱者，可同時被多個 goroutine 使用。
+// 沒有訂閱者時事件直接丟棄，不保存歷史事件
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[string]map[chan Event]struct{}
+	closed bool // CloseAll 之後新的訂閱立即結束
+}
+
+// NewHub 建立 Hub
+func NewHub() *Hub {
+	return &Hub{subs: make(map[string]map[chan Event]struct{})}
+}
+
+// Subscribe 訂閱 key 的事件，回傳事件 channel 與取消訂閱的函式；
+// key 的事件結束（Close）或 Hub 關閉（CloseAll）時 channel 會被關閉
+func (h *Hub) Subscribe(key string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if h.subs[key] == nil {
+		h.subs[key] = make(map[chan Event]struct{})
+	}
+	h.subs[key][ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs[key], ch)
+		if len(h.subs[key]) == 0 {
+			delete(h.subs, key)
+		}
+	}
+}
+
+// Publish 將事件送給 key 的所有訂閱者，不會阻塞
+func (h *Hub) Publish(key string, event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[key] {
+		select {
+		case ch <- event:
+		default:
+			// 丟棄最舊的事件，確保最後的 done/error 事件能送達
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- event
+		}
+	}
+}
+
+// Close 關閉 key 所有訂閱者的 channel，表示不會再有事件
+func (h *Hub) Close(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[key] {
+		close(ch)
+	}
+	delete(h.subs, key)
+}
+
+// CloseAll 關閉所有訂閱者的 channel，之後的訂閱也立即結束；應用程式結束時呼叫，讓 SSE 串流結束
+func (h *Hub) CloseAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for key, subs := range h.subs {
+		for ch := range subs {
+			close(ch)
+		}
+		delete(h.subs, key)
+	}
+}
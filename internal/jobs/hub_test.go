@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"testing"
+
+	"golang-gin-app/internal/models"
+)
+
+func TestHubDeliversLatestAndFinalEvents(t *testing.T) {
+	hub := NewHub()
+	events, unsubscribe := hub.Subscribe("t/1")
+	defer unsubscribe()
+	other, unsubscribeOther := hub.Subscribe("t/2")
+
+	// 訂閱者沒有讀取時丟棄最舊的事件，最後的事件一定會送達
+	for i := 1; i <= subscriberBuffer*2; i++ {
+		hub.Publish("t/1", Event{Type: EventProgress, Progress: models.JobProgress{Done: i, Total: 100}})
+	}
+	hub.Publish("t/1", Event{Type: EventDone})
+	hub.Close("t/1")
+
+	var received []Event
+	for event := range events {
+		received = append(received, event)
+	}
+	if len(received) != subscriberBuffer || !received[len(received)-1].Final() {
+		t.Fatalf("應收到 %d 個事件且最後為 done，實際為 %+v", subscriberBuffer, received)
+	}
+	if prev := received[len(received)-2]; prev.Progress.Done != subscriberBuffer*2 {
+		t.Fatalf("應保留最新的進度，實際為 %+v", prev)
+	}
+
+	select {
+	case event := <-other:
+		t.Fatalf("其他鍵值的訂閱者不應收到事件: %+v", event)
+	default:
+	}
+	unsubscribeOther()
+	hub.Publish("t/2", Event{Type: EventProgress})
+}
+
+func TestJobEvent(t *testing.T) {
+	cases := map[string]string{
+		models.JobStatusQueued:    EventProgress,
+		models.JobStatusRunning:   EventProgress,
+		models.JobStatusSucceeded: EventDone,
+		models.JobStatusCancelled: EventDone,
+		models.JobStatusFailed:    EventError,
+	}
+	for status, want := range cases {
+		if got := JobEvent(models.Job{Status: status}).Type; got != want {
+			t.Errorf("%s: event type = %s, want %s", status, got, want)
+		}
+	}
+}
+
+func TestHubCloseAllEndsSubscriptions(t *testing.T) {
+	hub := NewHub()
+	events, unsubscribe := hub.Subscribe("t/1")
+	defer unsubscribe()
+
+	hub.CloseAll()
+	if _, ok := <-events; ok {
+		t.Fatal("CloseAll 後 channel 應該被關閉")
+	}
+	late, unsubscribeLate := hub.Subscribe("t/2")
+	defer unsubscribeLate()
+	if _, ok := <-late; ok {
+		t.Fatal("CloseAll 後的訂閱應該立即結束")
+	}
+	// 關閉後發佈與結束鍵值都不應 panic
+	hub.Publish("t/1", Event{Type: EventDone})
+	hub.Close("t/1")
+}
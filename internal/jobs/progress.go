@@ -0,0 +1,25 @@
+package jobs
+
+import (
+	"context"
+
+	"golang-gin-app/internal/models"
+)
+
+// progressKey 是 context 中存放進度回報函式的鍵
+type progressKey struct{}
+
+// ProgressFunc 接收生成作業的進度
+type ProgressFunc func(models.JobProgress)
+
+// WithProgress 回傳帶有進度回報函式的 ctx，之後以此 ctx 呼叫的生成作業會透過 fn 回報進度
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress 回報目前步驟 stage 已完成 done 筆、共 total 筆；ctx 沒有進度回報函式時不做任何事
+func ReportProgress(ctx context.Context, stage string, done, total int) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok {
+		fn(models.JobProgress{Stage: stage, Done: done, Total: total})
+	}
+}
@@ -0,0 +1,240 @@
+// Package jobs 在程序內以固定數量的 worker 執行背景生成工作。
+// 工作本身保存在各租戶的資料庫中，Runner 只負責排程、取消與保存執行中工作的即時狀態。
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/models"
+)
+
+// 取消執行中工作的原因，以 context.Cause 取得
+var (
+	// ErrCancelled 使用者取消工作，工作應標記為已取消
+	ErrCancelled = errors.New("工作已取消")
+	// ErrShutdown 應用程式結束時中斷工作，工作應放回佇列，下次啟動時重新執行
+	ErrShutdown = errors.New("應用程式結束，工作已中斷")
+)
+
+// Task 識別一個工作：工作保存在 Tenant 的資料庫中
+type Task struct {
+	Tenant string
+	JobID  string
+}
+
+// key 回傳工作在 Hub 中的鍵值
+func (t Task) key() string {
+	return t.Tenant + "/" + t.JobID
+}
+
+// Executor 執行一個工作；每次工作狀態或進度改變時呼叫 observe，Runner 保存最後一次的狀態。
+// ctx 被取消時應盡快結束，並依 context.Cause(ctx) 決定工作的狀態。
+type Executor func(ctx context.Context, task Task, observe func(models.Job)) error
+
+// Runner 依序執行排入的工作，可同時被多個 goroutine 使用
+type Runner struct {
+	ctx    context.Context
+	exec   Executor
+	events *Hub // 執行中工作的狀態變化，鍵值為 Task.key()
+
+	mu       sync.Mutex
+	pending  []Task
+	active   map[Task]*activeJob
+	started  bool
+	stopping bool
+	wake     chan struct{}
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// activeJob 執行中工作的取消函式與最後回報的狀態
+type activeJob struct {
+	cancel   context.CancelCauseFunc
+	job      models.Job
+	observed bool
+}
+
+// NewRunner 建立 Runner；工作在 Start 之後才開始執行，ctx 為工作的基礎 context（例如帶有 logger）
+func NewRunner(ctx context.Context, exec Executor) *Runner {
+	return &Runner{
+		ctx:    ctx,
+		exec:   exec,
+		events: NewHub(),
+		active: make(map[Task]*activeJob),
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Start 啟動 workers 個 worker（至少一個）；重複呼叫或 Stop 之後呼叫時不做任何事
+func (r *Runner) Start(workers int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started || r.stopping {
+		return
+	}
+	r.started = true
+	for i := 0; i < max(workers, 1); i++ {
+		r.wg.Add(1)
+		go r.work()
+	}
+	r.signal()
+}
+
+// Started 回傳 Start 是否已被呼叫
+func (r *Runner) Started() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.started
+}
+
+// Enqueue 排入工作；工作已在佇列中或執行中時不重複排入，Runner 已停止時回傳錯誤
+func (r *Runner) Enqueue(task Task) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopping {
+		return models.Unavailablef("應用程式結束中，無法排入工作")
+	}
+	if _, ok := r.active[task]; ok {
+		return nil
+	}
+	for _, pending := range r.pending {
+		if pending == task {
+			return nil
+		}
+	}
+	r.pending = append(r.pending, task)
+	r.signal()
+	return nil
+}
+
+// Cancel 取消執行中的工作，回傳工作是否正在執行
+func (r *Runner) Cancel(task Task) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	active, ok := r.active[task]
+	if ok {
+		active.cancel(ErrCancelled)
+	}
+	return ok
+}
+
+// Active 回傳執行中工作最後回報的狀態；工作不在執行中或尚未回報時 ok 為 false
+func (r *Runner) Active(task Task) (job models.Job, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	active, ok := r.active[task]
+	if !ok || !active.observed {
+		return models.Job{}, false
+	}
+	return active.job, true
+}
+
+// Subscribe 訂閱工作的狀態變化，每次工作回報狀態或進度時收到 JobEvent 轉換的事件；
+// 工作執行結束（包含被放回佇列）時 channel 會被關閉。可在工作開始執行前訂閱
+func (r *Runner) Subscribe(task Task) (<-chan Event, func()) {
+	return r.events.Subscribe(task.key())
+}
+
+// CloseSubscriptions 結束所有狀態訂閱，之後的訂閱也立即結束；應用程式結束時呼叫，
+// 讓 SSE 串流不會阻擋伺服器關閉。不影響工作的執行
+func (r *Runner) CloseSubscriptions() {
+	r.events.CloseAll()
+}
+
+// Stop 停止排程新的工作並等待執行中的工作完成；ctx 到期時以 ErrShutdown 取消仍在執行的工作，
+// 並等待它們結束。佇列中尚未開始的工作保留在資料庫中，下次啟動時重新排入。
+func (r *Runner) Stop(ctx context.Context) {
+	r.mu.Lock()
+	if !r.stopping {
+		r.stopping = true
+		close(r.stop)
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	r.mu.Lock()
+	for _, active := range r.active {
+		active.cancel(ErrShutdown)
+	}
+	r.mu.Unlock()
+	<-done
+}
+
+// signal 喚醒一個等待中的 worker；呼叫時需持有 r.mu
+func (r *Runner) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next 取出下一個工作並標記為執行中，Runner 停止時回傳 false
+func (r *Runner) next() (Task, context.Context, bool) {
+	for {
+		r.mu.Lock()
+		if r.stopping {
+			r.mu.Unlock()
+			return Task{}, nil, false
+		}
+		if len(r.pending) > 0 {
+			task := r.pending[0]
+			r.pending = r.pending[1:]
+			ctx, cancel := context.WithCancelCause(r.ctx)
+			r.active[task] = &activeJob{cancel: cancel}
+			if len(r.pending) > 0 {
+				// 還有工作時喚醒其他 worker
+				r.signal()
+			}
+			r.mu.Unlock()
+			return task, ctx, true
+		}
+		r.mu.Unlock()
+
+		select {
+		case <-r.wake:
+		case <-r.stop:
+			return Task{}, nil, false
+		}
+	}
+}
+
+func (r *Runner) work() {
+	defer r.wg.Done()
+	for {
+		task, ctx, ok := r.next()
+		if !ok {
+			return
+		}
+		err := r.exec(ctx, task, func(job models.Job) {
+			r.mu.Lock()
+			if active, ok := r.active[task]; ok {
+				active.job, active.observed = job, true
+			}
+			r.mu.Unlock()
+			r.events.Publish(task.key(), JobEvent(job))
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("Job failed to run", logging.KeyTenant, task.Tenant, "job_id", task.JobID, logging.KeyError, err.Error())
+		}
+
+		r.mu.Lock()
+		r.active[task].cancel(nil)
+		delete(r.active, task)
+		r.mu.Unlock()
+		r.events.Close(task.key())
+	}
+}
@@ -0,0 +1,108 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang-gin-app/internal/models"
+)
+
+func TestRunnerRunsQueuedTasksAfterStart(t *testing.T) {
+	var mu sync.Mutex
+	var ran []string
+	done := make(chan struct{}, 3)
+	runner := NewRunner(context.Background(), func(ctx context.Context, task Task, observe func(models.Job)) error {
+		mu.Lock()
+		ran = append(ran, task.JobID)
+		mu.Unlock()
+		done <- struct{}{}
+		return nil
+	})
+
+	for _, id := range []string{"a", "b", "a", "c"} {
+		if err := runner.Enqueue(Task{Tenant: "t", JobID: id}); err != nil {
+			t.Fatalf("排入工作失敗: %v", err)
+		}
+	}
+	select {
+	case <-done:
+		t.Fatal("Start 之前不應執行工作")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	runner.Start(2)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("工作未在時限內執行")
+		}
+	}
+	runner.Stop(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ran) != 3 {
+		t.Fatalf("重複排入的工作只應執行一次，實際執行 %v", ran)
+	}
+	if err := runner.Enqueue(Task{Tenant: "t", JobID: "d"}); !errors.Is(err, models.ErrUnavailable) {
+		t.Fatalf("停止後排入工作應回傳 ErrUnavailable，實際為 %v", err)
+	}
+}
+
+// blockingRunner 建立執行時回報進度並等待取消的 Runner，回傳每個工作取消原因的 channel
+func blockingRunner(t *testing.T) (*Runner, chan error, chan struct{}) {
+	t.Helper()
+	causes := make(chan error, 1)
+	started := make(chan struct{}, 1)
+	runner := NewRunner(context.Background(), func(ctx context.Context, task Task, observe func(models.Job)) error {
+		observe(models.Job{ID: task.JobID, Status: models.JobStatusRunning, Progress: models.JobProgress{Done: 1, Total: 2}})
+		started <- struct{}{}
+		<-ctx.Done()
+		causes <- context.Cause(ctx)
+		return nil
+	})
+	runner.Start(1)
+	return runner, causes, started
+}
+
+func TestRunnerCancelsRunningTask(t *testing.T) {
+	runner, causes, started := blockingRunner(t)
+	task := Task{Tenant: "t", JobID: "job"}
+	if runner.Cancel(task) {
+		t.Fatal("未執行的工作不應可取消")
+	}
+	runner.Enqueue(task)
+	<-started
+
+	job, ok := runner.Active(task)
+	if !ok || job.Progress.Done != 1 {
+		t.Fatalf("應回傳執行中工作最後回報的狀態: %+v (%v)", job, ok)
+	}
+	if !runner.Cancel(task) {
+		t.Fatal("執行中的工作應可取消")
+	}
+	if cause := <-causes; !errors.Is(cause, ErrCancelled) {
+		t.Fatalf("取消原因應為 ErrCancelled，實際為 %v", cause)
+	}
+	runner.Stop(context.Background())
+	if _, ok := runner.Active(task); ok {
+		t.Fatal("結束的工作不應仍在執行中")
+	}
+}
+
+func TestRunnerStopInterruptsAfterDeadline(t *testing.T) {
+	runner, causes, started := blockingRunner(t)
+	runner.Enqueue(Task{Tenant: "t", JobID: "job"})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	runner.Stop(ctx)
+	if cause := <-causes; !errors.Is(cause, ErrShutdown) {
+		t.Fatalf("逾時後應以 ErrShutdown 中斷工作，實際為 %v", cause)
+	}
+}
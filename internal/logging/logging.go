@@ -0,0 +1,60 @@
+// Package logging 提供以 log/slog 為基礎的結構化日誌，並以 context 傳遞請求範圍的 logger
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// 日誌中一致使用的欄位名稱
+const (
+	KeyRequestID = "request_id"
+	KeyTenant    = "tenant"
+	KeyRoute     = "route"
+	KeyMethod    = "method"
+	KeyStatus    = "status"
+	KeyLatency   = "latency_ms"
+	KeyUser      = "user_id"
+	KeyError     = "error"
+)
+
+// New 依日誌等級（debug、info、warn、error）與格式（json、text）建立 logger
+func New(level, format string, w io.Writer) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("無效的日誌等級 %q: %v", level, err)
+	}
+	options := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case "json", "":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("無效的日誌格式 %q（可用: json, text）", format)
+	}
+}
+
+type loggerKey struct{}
+
+// WithLogger 回傳帶有 logger 的 context
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext 取得 context 中的 logger，沒有時回傳 slog.Default()
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With 回傳 logger 加上欄位後的 context，之後以該 context 記錄的日誌都會帶有這些欄位
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
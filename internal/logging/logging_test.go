@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		level, format string
+		wantErr       bool
+	}{
+		{"info", "json", false},
+		{"DEBUG", "text", false},
+		{"warn", "", false},
+		{"verbose", "json", true},
+		{"info", "xml", true},
+	}
+	for _, tc := range cases {
+		if _, err := New(tc.level, tc.format, &bytes.Buffer{}); (err != nil) != tc.wantErr {
+			t.Errorf("New(%q, %q) error = %v, wantErr %v", tc.level, tc.format, err, tc.wantErr)
+		}
+	}
+}
+
+func TestNewHonorsLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New("warn", "json", &buf)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown")
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "shown") {
+		t.Errorf("unexpected output for level warn: %s", out)
+	}
+}
+
+func TestWithAddsFieldsToContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := New("info", "json", &buf)
+
+	ctx := WithLogger(context.Background(), logger.With(KeyRequestID, "abc"))
+	ctx = With(ctx, KeyTenant, "dtxtraining")
+	FromContext(ctx).Info("request", KeyUser, int64(7))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log entry %q: %v", buf.String(), err)
+	}
+	if entry[KeyRequestID] != "abc" || entry[KeyTenant] != "dtxtraining" || entry[KeyUser] != float64(7) {
+		t.Errorf("unexpected log entry: %v", entry)
+	}
+
+	if FromContext(context.Background()) == nil {
+		t.Error("FromContext must fall back to the default logger")
+	}
+}
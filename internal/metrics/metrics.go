@@ -0,0 +1,209 @@
+// Package metrics 定義應用程式的 Prometheus 指標，於 /metrics 輸出
+package metrics
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// namespace 所有指標名稱的前綴
+const namespace = "dtx"
+
+// Registry 收集應用程式所有指標，另含 Go 執行環境與程序指標
+var Registry = prometheus.NewRegistry()
+
+// HTTP 請求指標，route 為 Gin 的路由樣板（例如 /available-slots/edit/:id），避免以實際路徑造成標籤數量過多
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP 請求數，依方法、路由與狀態碼分類",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP 請求耗時（秒），依方法與路由分類",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// 領域指標
+var (
+	// UsersGenerated 已生成的假使用者數，依使用者類型分類
+	UsersGenerated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "users_generated_total",
+		Help:      "已生成的假使用者數",
+	}, []string{"user_type"})
+
+	// PatientsInserted 已寫入的假病患數
+	PatientsInserted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "patients_inserted_total",
+		Help:      "已寫入的假病患數",
+	})
+
+	// SlotsGenerated 已生成的可預約時段數
+	SlotsGenerated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slots_generated_total",
+		Help:      "已生成的可預約時段數",
+	})
+
+	// SlotsBooked 由未預約改為已預約的時段數
+	SlotsBooked = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slots_booked_total",
+		Help:      "由未預約改為已預約的時段數",
+	})
+
+	// SlotsDeleted 已刪除的時段數
+	SlotsDeleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slots_deleted_total",
+		Help:      "已刪除的時段數",
+	})
+
+	// RoleAssignmentFailures 為使用者分配角色失敗的次數
+	RoleAssignmentFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "role_assignment_failures_total",
+		Help:      "為使用者分配角色失敗的次數",
+	})
+
+	// GenerationDuration 生成作業的耗時（秒），kind 為 users、patients 或 slots，status 為 ok 或 error
+	GenerationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "generation_duration_seconds",
+		Help:      "生成作業耗時（秒）",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"kind", "status"})
+
+	// RateLimited 因超過請求頻率或每日配額而拒絕的生成請求數，kind 為 client 或 user，reason 為 rate 或 quota
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "因超過請求頻率或每日配額而拒絕的生成請求數",
+	}, []string{"kind", "reason"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		UsersGenerated, PatientsInserted,
+		SlotsGenerated, SlotsBooked, SlotsDeleted,
+		RoleAssignmentFailures, GenerationDuration, RateLimited,
+		dbStats,
+	)
+}
+
+// ObserveHTTPRequest 記錄一次 HTTP 請求
+func ObserveHTTPRequest(method, route, status string, duration time.Duration) {
+	httpRequests.WithLabelValues(method, route, status).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveGeneration 記錄一次生成作業自 start 起的耗時，err 不為 nil 時狀態為 error
+func ObserveGeneration(kind string, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	GenerationDuration.WithLabelValues(kind, status).Observe(time.Since(start).Seconds())
+}
+
+// DBStats 單一租戶資料庫在收集當下的狀態；Up 為 false 時 Stats 不輸出
+type DBStats struct {
+	Tenant string
+	Up     bool
+	Stats  sql.DBStats
+}
+
+// dbStats 資料庫連線池指標的收集器，只註冊一次，來源由 RegisterDBStats 設定
+var dbStats = &dbStatsCollector{}
+
+// RegisterDBStats 設定資料庫連線池指標的來源，每次收集時呼叫 source 取得所有租戶的狀態；
+// 再次呼叫時取代先前的來源。回傳的函式移除此來源，來源已被取代時不做任何事
+func RegisterDBStats(source func() []DBStats) (unregister func()) {
+	dbStats.mu.Lock()
+	defer dbStats.mu.Unlock()
+	dbStats.generation++
+	generation := dbStats.generation
+	dbStats.source = source
+	return func() {
+		dbStats.mu.Lock()
+		defer dbStats.mu.Unlock()
+		if dbStats.generation == generation {
+			dbStats.source = nil
+		}
+	}
+}
+
+var (
+	dbUp                = dbDesc("up", "資料庫是否已連線（1 為已連線）")
+	dbMaxOpen           = dbDesc("max_open_connections", "連線池的最大連線數")
+	dbOpen              = dbDesc("open_connections", "目前開啟的連線數")
+	dbInUse             = dbDesc("in_use_connections", "使用中的連線數")
+	dbIdle              = dbDesc("idle_connections", "閒置的連線數")
+	dbWaitCount         = dbDesc("wait_count_total", "等待連線的總次數")
+	dbWaitDuration      = dbDesc("wait_duration_seconds_total", "等待連線的總時間（秒）")
+	dbMaxIdleClosed     = dbDesc("max_idle_closed_total", "因超過最大閒置連線數而關閉的連線數")
+	dbMaxIdleTimeClosed = dbDesc("max_idle_time_closed_total", "因超過最大閒置時間而關閉的連線數")
+	dbMaxLifetimeClosed = dbDesc("max_lifetime_closed_total", "因超過最大存活時間而關閉的連線數")
+)
+
+func dbDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db", name), help, []string{"tenant"}, nil)
+}
+
+// dbStatsCollector 在收集時讀取 sql.DBStats，輸出每個租戶的連線池指標；未設定來源時不輸出
+type dbStatsCollector struct {
+	mu         sync.Mutex
+	source     func() []DBStats
+	generation int // 每次設定來源時遞增，避免舊的 unregister 移除新的來源
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		dbUp, dbMaxOpen, dbOpen, dbInUse, dbIdle, dbWaitCount, dbWaitDuration,
+		dbMaxIdleClosed, dbMaxIdleTimeClosed, dbMaxLifetimeClosed,
+	} {
+		ch <- desc
+	}
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	source := c.source
+	c.mu.Unlock()
+	if source == nil {
+		return
+	}
+	for _, db := range source() {
+		up := 0.0
+		if db.Up {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(dbUp, prometheus.GaugeValue, up, db.Tenant)
+		if !db.Up {
+			continue
+		}
+		s := db.Stats
+		ch <- prometheus.MustNewConstMetric(dbMaxOpen, prometheus.GaugeValue, float64(s.MaxOpenConnections), db.Tenant)
+		ch <- prometheus.MustNewConstMetric(dbOpen, prometheus.GaugeValue, float64(s.OpenConnections), db.Tenant)
+		ch <- prometheus.MustNewConstMetric(dbInUse, prometheus.GaugeValue, float64(s.InUse), db.Tenant)
+		ch <- prometheus.MustNewConstMetric(dbIdle, prometheus.GaugeValue, float64(s.Idle), db.Tenant)
+		ch <- prometheus.MustNewConstMetric(dbWaitCount, prometheus.CounterValue, float64(s.WaitCount), db.Tenant)
+		ch <- prometheus.MustNewConstMetric(dbWaitDuration, prometheus.CounterValue, s.WaitDuration.Seconds(), db.Tenant)
+		ch <- prometheus.MustNewConstMetric(dbMaxIdleClosed, prometheus.CounterValue, float64(s.MaxIdleClosed), db.Tenant)
+		ch <- prometheus.MustNewConstMetric(dbMaxIdleTimeClosed, prometheus.CounterValue, float64(s.MaxIdleTimeClosed), db.Tenant)
+		ch <- prometheus.MustNewConstMetric(dbMaxLifetimeClosed, prometheus.CounterValue, float64(s.MaxLifetimeClosed), db.Tenant)
+	}
+}
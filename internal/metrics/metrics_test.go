@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveHTTPRequest(t *testing.T) {
+	before := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/available-slots/edit/:id", "200"))
+	ObserveHTTPRequest("GET", "/available-slots/edit/:id", "200", 20*time.Millisecond)
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/available-slots/edit/:id", "200")) - before; got != 1 {
+		t.Errorf("http requests = %v, want 1", got)
+	}
+}
+
+func TestDBStatsCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&dbStatsCollector{source: func() []DBStats {
+		return []DBStats{
+			{Tenant: "dtxcasemgnt", Up: true, Stats: sql.DBStats{MaxOpenConnections: 25, OpenConnections: 3, InUse: 1, Idle: 2}},
+			{Tenant: "dtxtraining", Up: false},
+		}
+	}})
+
+	expected := `
+# HELP dtx_db_open_connections 目前開啟的連線數
+# TYPE dtx_db_open_connections gauge
+dtx_db_open_connections{tenant="dtxcasemgnt"} 3
+# HELP dtx_db_up 資料庫是否已連線（1 為已連線）
+# TYPE dtx_db_up gauge
+dtx_db_up{tenant="dtxcasemgnt"} 1
+dtx_db_up{tenant="dtxtraining"} 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "dtx_db_up", "dtx_db_open_connections"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegisterDBStatsReplacesSource(t *testing.T) {
+	source := func(tenant string) func() []DBStats {
+		return func() []DBStats { return []DBStats{{Tenant: tenant, Up: true}} }
+	}
+	unregisterFirst := RegisterDBStats(source("first"))
+	unregisterSecond := RegisterDBStats(source("second"))
+	// 已被取代的來源移除時不應影響目前的來源
+	unregisterFirst()
+
+	expected := `
+# HELP dtx_db_up 資料庫是否已連線（1 為已連線）
+# TYPE dtx_db_up gauge
+dtx_db_up{tenant="second"} 1
+`
+	if err := testutil.GatherAndCompare(Registry, strings.NewReader(expected), "dtx_db_up"); err != nil {
+		t.Error(err)
+	}
+
+	unregisterSecond()
+	if count, err := testutil.GatherAndCount(Registry, "dtx_db_up"); err != nil || count != 0 {
+		t.Errorf("移除來源後 dtx_db_up 數量 = %d (%v), want 0", count, err)
+	}
+}
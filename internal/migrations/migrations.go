@@ -0,0 +1,397 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 每種資料庫方言各有一組版本相同的遷移檔，位於 sql/<方言>/ 目錄下
+//
+//go:embed sql/mysql/*.sql sql/sqlite/*.sql
+var migrationFiles embed.FS
+
+// 支援的資料庫方言，與 repository.Dialect 的值一致
+const (
+	DialectMySQL  = "mysql"
+	DialectSQLite = "sqlite"
+)
+
+// BaselineVersion 是既有業務資料表（user、role、patient 等）的基準版本。
+// 這些版本以 CREATE TABLE IF NOT EXISTS 接手既有的資料表，版本紀錄不代表資料表由遷移建立，
+// 因此不可回復；Down 只會回復之後由遷移建立的版本
+const BaselineVersion = 8
+
+// MySQL 具名鎖的名稱與等待時間，避免多個程序同時套用或回復遷移
+const (
+	mysqlLockName    = "golang_gin_app.schema_migrations"
+	mysqlLockTimeout = 60 // 秒
+)
+
+// 檔名格式：<版本>_<名稱>.<up|down>.sql，例如 0001_create_user.up.sql
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration 表示一個版本的資料庫結構變更
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus 表示某個版本的套用狀態
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrator 負責套用與回復內嵌的 SQL 遷移檔
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []*Migration
+}
+
+// NewMigrator 建立新的 Migrator，並載入指定方言的內嵌遷移檔
+func NewMigrator(db *sql.DB, dialect string) (*Migrator, error) {
+	if dialect != DialectMySQL && dialect != DialectSQLite {
+		return nil, fmt.Errorf("不支援的資料庫方言: %s", dialect)
+	}
+	migrations, err := loadMigrations(migrationFiles, path.Join("sql", dialect))
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// loadMigrations 讀取並依版本排序遷移檔，每個版本都必須同時有 up 與 down
+func loadMigrations(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("讀取遷移檔失敗: %v", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		matches := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("遷移檔名稱格式錯誤: %s", entry.Name())
+		}
+		version, _ := strconv.ParseInt(matches[1], 10, 64)
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("讀取遷移檔 %s 失敗: %v", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		} else if migration.Name != matches[2] {
+			return nil, fmt.Errorf("版本 %d 有多個不同名稱的遷移檔", version)
+		}
+		if matches[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("版本 %d 缺少 up 或 down 遷移檔", migration.Version)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// conn 是 *sql.DB、*sql.Conn 與 *sql.Tx 共同的查詢與執行方法
+type conn interface {
+	execer
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// ensureMigrationsTable 建立記錄已套用版本的資料表
+func ensureMigrationsTable(ctx context.Context, db conn) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("建立 schema_migrations 表失敗: %v", err)
+	}
+	return nil
+}
+
+// appliedVersions 獲取已套用的版本與套用時間
+func appliedVersions(ctx context.Context, db conn) (map[int64]time.Time, error) {
+	if err := ensureMigrationsTable(ctx, db); err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("獲取已套用版本失敗: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("掃描已套用版本失敗: %v", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Status 回傳所有遷移版本的套用狀態
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	applied, err := appliedVersions(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]*MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := &MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// CurrentVersion 回傳目前已套用的最高版本，尚未套用任何版本時為 0
+func (m *Migrator) CurrentVersion(ctx context.Context) (int64, error) {
+	applied, err := appliedVersions(ctx, m.db)
+	if err != nil {
+		return 0, err
+	}
+	var current int64
+	for version := range applied {
+		if version > current {
+			current = version
+		}
+	}
+	return current, nil
+}
+
+// AppliedVersion 回傳目前已套用的最高版本，不建立 schema_migrations 表，可用於健康檢查。
+// schema_migrations 表不存在（資料庫結構不是由遷移管理）時 managed 為 false。
+func (m *Migrator) AppliedVersion(ctx context.Context) (version int64, managed bool, err error) {
+	rows, err := m.db.QueryContext(ctx, `SELECT version FROM schema_migrations WHERE 1 = 0`)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, false, ctx.Err()
+		}
+		return 0, false, nil
+	}
+	rows.Close()
+
+	if err := m.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, true, fmt.Errorf("獲取已套用版本失敗: %v", err)
+	}
+	return version, true, nil
+}
+
+// LatestVersion 回傳內嵌遷移檔中的最新版本
+func (m *Migrator) LatestVersion() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up 依序套用所有尚未套用的版本，回傳本次套用的遷移。
+// 套用期間持有遷移鎖，多個程序同時啟動時只有一個會套用，其他程序等待後看到已套用的版本
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	done := make([]*Migration, 0)
+	err := m.locked(ctx, func(db conn) error {
+		applied, err := appliedVersions(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, db, migration.Version, func(conn execer) error {
+				if err := exec(ctx, conn, migration.Up); err != nil {
+					return fmt.Errorf("套用版本 %d_%s 失敗: %v", migration.Version, migration.Name, err)
+				}
+				_, err := conn.ExecContext(ctx,
+					`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+					migration.Version, migration.Name, time.Now())
+				if err != nil {
+					return fmt.Errorf("記錄版本 %d 失敗: %v", migration.Version, err)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down 依版本由新到舊回復 steps 個已套用的版本，回傳本次回復的遷移。
+// 不會回復 BaselineVersion 以前的版本：要回復的版本包含基準版本時不做任何修改並回傳錯誤
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("回復版本數必須大於0")
+	}
+	done := make([]*Migration, 0, steps)
+	err := m.locked(ctx, func(db conn) error {
+		applied, err := appliedVersions(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		pending := make([]*Migration, 0, steps)
+		for i := len(m.migrations) - 1; i >= 0 && len(pending) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Version <= BaselineVersion {
+				return fmt.Errorf("版本 %d 以前為既有資料表的基準版本，無法回復（最多可回復 %d 個版本）",
+					BaselineVersion, len(pending))
+			}
+			pending = append(pending, migration)
+		}
+
+		for _, migration := range pending {
+			if err := m.apply(ctx, db, migration.Version, func(conn execer) error {
+				if err := exec(ctx, conn, migration.Down); err != nil {
+					return fmt.Errorf("回復版本 %d_%s 失敗: %v", migration.Version, migration.Name, err)
+				}
+				_, err := conn.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, migration.Version)
+				if err != nil {
+					return fmt.Errorf("刪除版本 %d 紀錄失敗: %v", migration.Version, err)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// execer 是 *sql.DB 與 *sql.Tx 共同的執行方法
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// locked 取得遷移鎖後在同一個連線上執行 fn，讀取已套用版本與套用遷移都在鎖內完成。
+// MySQL 使用 GET_LOCK 具名鎖，鎖隨連線存在，程序中斷時會自動釋放；
+// SQLite 以 BEGIN IMMEDIATE 開始交易取得寫入鎖，其他連線會依 busy_timeout 等待，
+// 各版本在交易中以 SAVEPOINT 區隔，失敗的版本回滾後仍提交先前成功的版本
+func (m *Migrator) locked(ctx context.Context, fn func(db conn) error) error {
+	db, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("取得資料庫連線失敗: %v", err)
+	}
+	defer db.Close()
+
+	if m.dialect != DialectSQLite {
+		var acquired sql.NullInt64
+		if err := db.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, mysqlLockName, mysqlLockTimeout).Scan(&acquired); err != nil {
+			return fmt.Errorf("取得遷移鎖失敗: %v", err)
+		}
+		if acquired.Int64 != 1 {
+			return fmt.Errorf("等待遷移鎖逾時（%d 秒），可能有其他程序正在套用遷移", mysqlLockTimeout)
+		}
+		defer db.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, mysqlLockName)
+		return fn(db)
+	}
+
+	if _, err := db.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return fmt.Errorf("取得遷移鎖失敗: %v", err)
+	}
+	fnErr := fn(db)
+	if _, err := db.ExecContext(context.Background(), `COMMIT`); err != nil {
+		db.ExecContext(context.Background(), `ROLLBACK`)
+		if fnErr == nil {
+			fnErr = fmt.Errorf("提交交易失敗: %v", err)
+		}
+	}
+	return fnErr
+}
+
+// apply 執行一個版本的遷移與版本紀錄。SQLite 支援交易中的 DDL，兩者在同一個 SAVEPOINT 中完成，
+// 失敗時一起回滾；MySQL 的 DDL 會隱含提交交易，因此直接執行
+func (m *Migrator) apply(ctx context.Context, db conn, version int64, fn func(conn execer) error) error {
+	if m.dialect != DialectSQLite {
+		return fn(db)
+	}
+	savepoint := fmt.Sprintf("migration_%d", version)
+	if _, err := db.ExecContext(ctx, `SAVEPOINT `+savepoint); err != nil {
+		return fmt.Errorf("開始交易失敗: %v", err)
+	}
+	if err := fn(db); err != nil {
+		db.ExecContext(context.Background(), `ROLLBACK TO `+savepoint)
+		db.ExecContext(context.Background(), `RELEASE `+savepoint)
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `RELEASE `+savepoint); err != nil {
+		return fmt.Errorf("提交交易失敗: %v", err)
+	}
+	return nil
+}
+
+// exec 逐一執行遷移檔中以分號結尾的 SQL 語句
+func exec(ctx context.Context, conn execer, script string) error {
+	for _, statement := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements 將 SQL 腳本依行尾的分號拆成多個語句，並略過註解行
+func splitStatements(script string) []string {
+	statements := make([]string, 0)
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statement := strings.TrimSuffix(strings.TrimSpace(current.String()), ";")
+			statements = append(statements, statement)
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
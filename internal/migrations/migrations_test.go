@@ -0,0 +1,230 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file::memory:")
+	if err != nil {
+		t.Fatalf("開啟 SQLite 失敗: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestMigrator(t *testing.T, db *sql.DB, files fstest.MapFS) *Migrator {
+	t.Helper()
+	migrations, err := loadMigrations(files, "sql")
+	if err != nil {
+		t.Fatalf("載入遷移檔失敗: %v", err)
+	}
+	return &Migrator{db: db, dialect: DialectSQLite, migrations: migrations}
+}
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := "-- 註解\nCREATE TABLE a (\n  id INTEGER\n);\n\nINSERT INTO a VALUES (1);\nINSERT INTO a VALUES (2)"
+	got := splitStatements(script)
+	want := []string{"CREATE TABLE a (\n  id INTEGER\n)", "INSERT INTO a VALUES (1)", "INSERT INTO a VALUES (2)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("splitStatements = %q，預期 %q", got, want)
+	}
+	if got := splitStatements("-- 只有註解\n"); len(got) != 0 {
+		t.Fatalf("只有註解的腳本不應該有語句: %q", got)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(fstest.MapFS{
+		"sql/0002_b.up.sql":   file("CREATE TABLE b (id INTEGER);"),
+		"sql/0002_b.down.sql": file("DROP TABLE b;"),
+		"sql/0001_a.up.sql":   file("CREATE TABLE a (id INTEGER);"),
+		"sql/0001_a.down.sql": file("DROP TABLE a;"),
+	}, "sql")
+	if err != nil {
+		t.Fatalf("載入遷移檔失敗: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Name != "b" {
+		t.Fatalf("遷移未依版本排序: %+v", migrations)
+	}
+
+	if _, err := loadMigrations(fstest.MapFS{
+		"sql/0001_a.up.sql": file("CREATE TABLE a (id INTEGER);"),
+	}, "sql"); err == nil {
+		t.Fatalf("缺少 down 遷移檔應該失敗")
+	}
+	if _, err := loadMigrations(fstest.MapFS{
+		"sql/create_a.up.sql": file("CREATE TABLE a (id INTEGER);"),
+	}, "sql"); err == nil {
+		t.Fatalf("檔名格式錯誤應該失敗")
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	for _, dialect := range []string{DialectMySQL, DialectSQLite} {
+		migrator, err := NewMigrator(nil, dialect)
+		if err != nil {
+			t.Fatalf("載入 %s 遷移檔失敗: %v", dialect, err)
+		}
+		for _, migration := range migrator.migrations {
+			if migration.Version <= BaselineVersion && len(splitStatements(migration.Down)) != 0 {
+				t.Errorf("%s 基準版本 %d 的 down 不應該有語句", dialect, migration.Version)
+			}
+		}
+	}
+	if _, err := NewMigrator(nil, "postgres"); err == nil {
+		t.Fatalf("不支援的方言應該失敗")
+	}
+}
+
+func TestMigratorUpIsIdempotent(t *testing.T) {
+	db := openTestDB(t)
+	migrator, err := NewMigrator(db, DialectSQLite)
+	if err != nil {
+		t.Fatalf("載入遷移檔失敗: %v", err)
+	}
+	ctx := context.Background()
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("套用遷移失敗: %v", err)
+	}
+	if len(applied) != len(migrator.migrations) {
+		t.Fatalf("套用 %d 個版本，預期 %d", len(applied), len(migrator.migrations))
+	}
+	applied, err = migrator.Up(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("重複套用應該沒有變更: %d (%v)", len(applied), err)
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("獲取遷移狀態失敗: %v", err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.AppliedAt == nil {
+			t.Fatalf("版本 %d 應該已套用", status.Version)
+		}
+	}
+}
+
+func TestMigratorUpConcurrently(t *testing.T) {
+	// 模擬多個程序同時啟動：各自開啟同一個 SQLite 檔案並套用遷移
+	path := filepath.Join(t.TempDir(), "app.db")
+	const n = 8
+	start := make(chan struct{})
+	results := make([][]*Migration, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(10000)", path))
+		if err != nil {
+			t.Fatalf("開啟 SQLite 失敗: %v", err)
+		}
+		db.SetMaxOpenConns(1)
+		t.Cleanup(func() { db.Close() })
+		migrator, err := NewMigrator(db, DialectSQLite)
+		if err != nil {
+			t.Fatalf("載入遷移檔失敗: %v", err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			results[i], errs[i] = migrator.Up(context.Background())
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	total := 0
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("同時套用遷移失敗: %v", errs[i])
+		}
+		total += len(results[i])
+	}
+	migrator, _ := NewMigrator(nil, DialectSQLite)
+	if total != len(migrator.migrations) {
+		t.Fatalf("共套用 %d 個版本，預期每個版本只套用一次（%d 個）", total, len(migrator.migrations))
+	}
+}
+
+func TestMigratorDownStopsAtBaseline(t *testing.T) {
+	db := openTestDB(t)
+	migrator, err := NewMigrator(db, DialectSQLite)
+	if err != nil {
+		t.Fatalf("載入遷移檔失敗: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("套用遷移失敗: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO role (alias, description) VALUES ('keep', '保留')"); err != nil {
+		t.Fatalf("新增角色失敗: %v", err)
+	}
+
+	latest := migrator.LatestVersion()
+	if _, err := migrator.Down(ctx, int(latest)); err == nil {
+		t.Fatalf("回復超過基準版本應該失敗")
+	}
+	if current, _ := migrator.CurrentVersion(ctx); current != latest {
+		t.Fatalf("拒絕回復時不應該有任何變更，目前版本 = %d", current)
+	}
+
+	reverted, err := migrator.Down(ctx, int(latest-BaselineVersion))
+	if err != nil {
+		t.Fatalf("回復到基準版本失敗: %v", err)
+	}
+	if len(reverted) != int(latest-BaselineVersion) {
+		t.Fatalf("回復 %d 個版本，預期 %d", len(reverted), latest-BaselineVersion)
+	}
+	if current, _ := migrator.CurrentVersion(ctx); current != BaselineVersion {
+		t.Fatalf("目前版本 = %d，預期 %d", current, BaselineVersion)
+	}
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM role WHERE alias = 'keep'").Scan(&count); err != nil || count != 1 {
+		t.Fatalf("基準資料表的資料應該保留: %d (%v)", count, err)
+	}
+}
+
+func TestMigratorUpRollsBackFailedMigration(t *testing.T) {
+	db := openTestDB(t)
+	migrator := newTestMigrator(t, db, fstest.MapFS{
+		"sql/0001_a.up.sql":   file("CREATE TABLE a (id INTEGER);"),
+		"sql/0001_a.down.sql": file("DROP TABLE a;"),
+		"sql/0002_b.up.sql":   file("CREATE TABLE b (id INTEGER);\nINSERT INTO missing VALUES (1);"),
+		"sql/0002_b.down.sql": file("DROP TABLE b;"),
+	})
+	ctx := context.Background()
+
+	applied, err := migrator.Up(ctx)
+	if err == nil {
+		t.Fatalf("失敗的遷移應該回傳錯誤")
+	}
+	if len(applied) != 1 {
+		t.Fatalf("應該只套用版本 1，實際 %d 個", len(applied))
+	}
+	if current, _ := migrator.CurrentVersion(ctx); current != 1 {
+		t.Fatalf("目前版本 = %d，預期 1", current)
+	}
+	var count int
+	if err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'b'").Scan(&count); err != nil || count != 0 {
+		t.Fatalf("失敗版本建立的資料表應該回滾: %d (%v)", count, err)
+	}
+}
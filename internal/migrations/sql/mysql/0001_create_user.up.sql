@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS user (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    account VARCHAR(255) NOT NULL,
+    create_time DATETIME NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    last_login_date DATETIME NULL,
+    password VARCHAR(255) NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    steam_id VARCHAR(255) NULL,
+    tel_cell VARCHAR(32) NULL,
+    username VARCHAR(255) NULL,
+    UNIQUE KEY uk_user_account (account)
+) DEFAULT CHARSET = utf8mb4;
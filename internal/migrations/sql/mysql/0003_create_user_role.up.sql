@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS user_role (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    role_id BIGINT NOT NULL,
+    KEY idx_user_role_user (user_id),
+    KEY idx_user_role_role (role_id)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS history_disease (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    disease_name VARCHAR(255) NOT NULL,
+    UNIQUE KEY uk_history_disease_name (disease_name)
+) DEFAULT CHARSET = utf8mb4;
+
+INSERT IGNORE INTO history_disease (ID, disease_name) VALUES
+    (1, '中樞神經損傷'),
+    (2, '心血管疾病'),
+    (3, '呼吸方面疾病'),
+    (4, '肝臟疾病'),
+    (5, '糖尿病'),
+    (6, '腎臟病'),
+    (7, '癌症'),
+    (8, '免疫相關疾病');
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS patient (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NULL,
+    address VARCHAR(255) NULL,
+    age INT NULL,
+    birth DATETIME NULL,
+    city VARCHAR(32) NULL,
+    disease_id BIGINT NULL,
+    district VARCHAR(32) NULL,
+    emergency_contact VARCHAR(255) NULL,
+    emergency_phone VARCHAR(32) NULL,
+    emergency_relation VARCHAR(32) NULL,
+    gender VARCHAR(8) NULL,
+    idno VARCHAR(16) NULL,
+    mail VARCHAR(255) NULL,
+    name VARCHAR(255) NULL,
+    OTHERHISTORYDISEASE VARCHAR(255) NULL,
+    OTHERMEDICALHISTORY VARCHAR(255) NULL,
+    phone VARCHAR(32) NULL,
+    KEY idx_patient_user (user_id)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS patient_history_disease (
+    patient_id BIGINT NOT NULL,
+    history_disease VARCHAR(255) NOT NULL,
+    disease_id BIGINT NULL,
+    KEY idx_patient_history_disease_patient (patient_id)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS patient_medical_history (
+    patient_id BIGINT NOT NULL,
+    medical_history VARCHAR(255) NOT NULL,
+    KEY idx_patient_medical_history_patient (patient_id)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS wg_available_slots (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    doctor BIGINT NOT NULL,
+    is_booked TINYINT(1) NOT NULL DEFAULT 0,
+    slot_begin_time TIME NOT NULL,
+    slot_date DATE NOT NULL,
+    slot_end_time TIME NOT NULL,
+    KEY idx_wg_available_slots_doctor (doctor, slot_date)
+) DEFAULT CHARSET = utf8mb4;
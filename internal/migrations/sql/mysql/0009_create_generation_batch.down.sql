@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS generation_batch_item;
+DROP TABLE IF EXISTS generation_batch;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS generation_batch (
+    ID VARCHAR(64) NOT NULL PRIMARY KEY,
+    kind VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS generation_batch_item (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    batch_id VARCHAR(64) NOT NULL,
+    entity_type VARCHAR(32) NOT NULL,
+    entity_id BIGINT NOT NULL,
+    KEY idx_generation_batch_item_batch (batch_id, entity_type)
+) DEFAULT CHARSET = utf8mb4;
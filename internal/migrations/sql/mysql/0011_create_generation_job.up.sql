@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS generation_job (
+    ID VARCHAR(64) NOT NULL PRIMARY KEY,
+    kind VARCHAR(32) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    params TEXT NOT NULL,
+    result TEXT NULL,
+    error TEXT NULL,
+    progress_stage VARCHAR(32) NOT NULL DEFAULT '',
+    progress_done INT NOT NULL DEFAULT 0,
+    progress_total INT NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    started_at DATETIME NULL,
+    finished_at DATETIME NULL,
+    KEY idx_generation_job_status (status, created_at),
+    KEY idx_generation_job_created (created_at)
+) DEFAULT CHARSET = utf8mb4;
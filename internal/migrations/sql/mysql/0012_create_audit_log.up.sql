@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(6) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    entity_type VARCHAR(32) NOT NULL,
+    entity_id VARCHAR(64) NOT NULL,
+    before_data MEDIUMTEXT NULL,
+    after_data MEDIUMTEXT NULL,
+    changes MEDIUMTEXT NOT NULL,
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    method VARCHAR(16) NOT NULL DEFAULT '',
+    path VARCHAR(2048) NOT NULL DEFAULT '',
+    client_ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    KEY idx_audit_log_created (created_at),
+    KEY idx_audit_log_entity (entity_type, entity_id, created_at),
+    KEY idx_audit_log_actor (actor, created_at)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,2 @@
+-- 送出工作的請求（操作者、請求ID等），工作執行時的稽核紀錄沿用此操作者
+ALTER TABLE generation_job ADD COLUMN audit_request TEXT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE generation_job DROP COLUMN heartbeat_at;
+ALTER TABLE generation_job DROP COLUMN owner;
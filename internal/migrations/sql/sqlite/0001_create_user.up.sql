@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS user (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    account VARCHAR(255) NOT NULL,
+    create_time DATETIME NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    last_login_date DATETIME NULL,
+    password VARCHAR(255) NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    steam_id VARCHAR(255) NULL,
+    tel_cell VARCHAR(32) NULL,
+    username VARCHAR(255) NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uk_user_account ON user (account);
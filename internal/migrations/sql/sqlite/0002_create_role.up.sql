@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS role (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    alias VARCHAR(64) NOT NULL,
+    description VARCHAR(255) NULL
+);
+
+-- 角色ID需與 utils 中的角色ID常數一致
+INSERT OR IGNORE INTO role (ID, alias, description) VALUES
+    (1, 'USER', '一般使用者'),
+    (2, 'ADMIN', '管理員'),
+    (3, 'DOCTOR', '醫師'),
+    (4, 'DTX_PSY', '心理師'),
+    (5, 'DTX_ST', '語言治療師'),
+    (6, 'DTX_OT', '職能治療師'),
+    (7, 'DTX_PI', '計畫主持人');
@@ -0,0 +1 @@
+-- 基準版本：此資料表在遷移前即已存在，不可由遷移刪除（見 migrations.BaselineVersion）
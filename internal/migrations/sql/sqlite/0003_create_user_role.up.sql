@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS user_role (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id BIGINT NOT NULL,
+    role_id BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_role_user ON user_role (user_id);
+CREATE INDEX IF NOT EXISTS idx_user_role_role ON user_role (role_id);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS history_disease (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    disease_name VARCHAR(255) NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uk_history_disease_name ON history_disease (disease_name);
+
+INSERT OR IGNORE INTO history_disease (ID, disease_name) VALUES
+    (1, '中樞神經損傷'),
+    (2, '心血管疾病'),
+    (3, '呼吸方面疾病'),
+    (4, '肝臟疾病'),
+    (5, '糖尿病'),
+    (6, '腎臟病'),
+    (7, '癌症'),
+    (8, '免疫相關疾病');
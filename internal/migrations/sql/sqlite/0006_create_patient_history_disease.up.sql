@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS patient_history_disease (
+    patient_id BIGINT NOT NULL,
+    history_disease VARCHAR(255) NOT NULL,
+    disease_id BIGINT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_patient_history_disease_patient ON patient_history_disease (patient_id);
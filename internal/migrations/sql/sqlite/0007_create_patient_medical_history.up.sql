@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS patient_medical_history (
+    patient_id BIGINT NOT NULL,
+    medical_history VARCHAR(255) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_patient_medical_history_patient ON patient_medical_history (patient_id);
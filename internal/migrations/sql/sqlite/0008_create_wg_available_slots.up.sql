@@ -0,0 +1,11 @@
+-- 日期與時間以 TEXT 儲存（2006-01-02、15:04:05），與 MySQL 的 DATE/TIME 讀出格式一致
+CREATE TABLE IF NOT EXISTS wg_available_slots (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    doctor BIGINT NOT NULL,
+    is_booked BOOLEAN NOT NULL DEFAULT 0,
+    slot_begin_time TEXT NOT NULL,
+    slot_date TEXT NOT NULL,
+    slot_end_time TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_wg_available_slots_doctor ON wg_available_slots (doctor, slot_date);
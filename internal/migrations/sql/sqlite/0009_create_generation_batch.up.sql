@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS generation_batch (
+    ID VARCHAR(64) NOT NULL PRIMARY KEY,
+    kind VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS generation_batch_item (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    batch_id VARCHAR(64) NOT NULL,
+    entity_type VARCHAR(32) NOT NULL,
+    entity_id BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_generation_batch_item_batch ON generation_batch_item (batch_id, entity_type);
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS account_sequence (
+    prefix VARCHAR(32) NOT NULL PRIMARY KEY,
+    last_value BIGINT NOT NULL
+);
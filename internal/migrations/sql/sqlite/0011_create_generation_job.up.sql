@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS generation_job (
+    ID VARCHAR(64) NOT NULL PRIMARY KEY,
+    kind VARCHAR(32) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    params TEXT NOT NULL,
+    result TEXT NULL,
+    error TEXT NULL,
+    progress_stage VARCHAR(32) NOT NULL DEFAULT '',
+    progress_done INTEGER NOT NULL DEFAULT 0,
+    progress_total INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    started_at DATETIME NULL,
+    finished_at DATETIME NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_generation_job_status ON generation_job (status, created_at);
+CREATE INDEX IF NOT EXISTS idx_generation_job_created ON generation_job (created_at);
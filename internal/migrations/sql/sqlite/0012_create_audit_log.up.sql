@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    entity_type VARCHAR(32) NOT NULL,
+    entity_id VARCHAR(64) NOT NULL,
+    before_data TEXT NULL,
+    after_data TEXT NULL,
+    changes TEXT NOT NULL,
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    method VARCHAR(16) NOT NULL DEFAULT '',
+    path VARCHAR(2048) NOT NULL DEFAULT '',
+    client_ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log (entity_type, entity_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log (actor, created_at);
@@ -0,0 +1 @@
+ALTER TABLE generation_job DROP COLUMN audit_request;
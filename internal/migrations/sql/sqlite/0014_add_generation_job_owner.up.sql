@@ -0,0 +1,3 @@
+-- 執行工作的程序與其最後一次心跳，心跳逾時的工作才可由其他程序放回佇列
+ALTER TABLE generation_job ADD COLUMN owner VARCHAR(128) NULL;
+ALTER TABLE generation_job ADD COLUMN heartbeat_at DATETIME NULL;
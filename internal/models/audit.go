@@ -0,0 +1,77 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 稽核紀錄的動作
+const (
+	AuditActionCreate      = "create"
+	AuditActionUpdate      = "update"
+	AuditActionDelete      = "delete"
+	AuditActionAssignRoles = "assign_roles"
+	AuditActionGenerate    = "generate"
+	AuditActionPurge       = "purge"
+	AuditActionAnonymize   = "anonymize"
+	AuditActionCopy        = "copy"
+	AuditActionCancel      = "cancel"
+)
+
+// 稽核紀錄的實體類型
+const (
+	AuditEntityUser    = "user"
+	AuditEntityRole    = "role"
+	AuditEntitySlot    = "slot"
+	AuditEntityPatient = "patient"
+	AuditEntityBatch   = "batch"
+	AuditEntityJob     = "job"
+)
+
+// 無法識別使用者時記錄的操作者
+const (
+	AuditActorSystem    = "system"    // 不是由 HTTP 請求觸發的異動，例如命令列子命令
+	AuditActorAnonymous = "anonymous" // 請求未帶有識別使用者的標頭
+)
+
+// AuditRequest 表示進行異動的操作者與請求資訊
+type AuditRequest struct {
+	Actor     string `json:"actor"`
+	RequestID string `json:"request_id,omitempty"`
+	Method    string `json:"method,omitempty"`
+	Path      string `json:"path,omitempty"`
+	ClientIP  string `json:"client_ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// AuditEntry 表示一筆稽核紀錄。Before 與 After 為異動前後的 JSON 快照，
+// 新增時沒有 Before，刪除時沒有 After；Changes 為兩者不同的欄位
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Changes    []AuditChange   `json:"changes"`
+	AuditRequest
+}
+
+// AuditChange 表示單一欄位異動前後的值，新增或移除的欄位其中一側為空
+type AuditChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// AuditFilter 查詢稽核紀錄的條件，空字串與零值表示不限制；To 不包含在範圍內
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	EntityType string
+	EntityID   string
+	From       time.Time
+	To         time.Time
+	Limit      int
+}
@@ -0,0 +1,36 @@
+package models
+
+// CopyRequest 表示從來源資料庫複製資料到目標資料庫的請求
+type CopyRequest struct {
+	UserIDs      []int64 `json:"user_ids"`      // 要複製的使用者（含角色）
+	PatientIDs   []int64 `json:"patient_ids"`   // 要複製的病患（含病史與醫療史）
+	IncludeSlots bool    `json:"include_slots"` // 一併複製所選使用者的可預約時段
+	Anonymize    bool    `json:"anonymize"`     // 寫入前將病患個資去識別化
+	DryRun       bool    `json:"dry_run"`       // 只產生報告，不保留任何寫入
+}
+
+// CopyReport 表示複製的結果；DryRun 時的目標ID僅供預覽，交易已回滾
+type CopyReport struct {
+	BatchID      string         `json:"batch_id,omitempty"` // 目標資料庫中的生成批次，可用於清除複製的資料
+	DryRun       bool           `json:"dry_run"`
+	Users        []CopyMapping  `json:"users"`
+	Patients     []CopyMapping  `json:"patients"`
+	Slots        int            `json:"slots"`
+	CreatedRoles []string       `json:"created_roles,omitempty"` // 目標資料庫中原本沒有而新增的角色
+	Conflicts    []CopyConflict `json:"conflicts"`
+}
+
+// CopyMapping 表示來源資料列對應到目標資料庫的新ID
+type CopyMapping struct {
+	SourceID int64  `json:"source_id"`
+	TargetID int64  `json:"target_id"`
+	Label    string `json:"label"` // 帳號或病患姓名
+}
+
+// CopyConflict 表示複製時略過或調整的資料列及原因
+type CopyConflict struct {
+	Entity   string `json:"entity"` // user、patient 或 slot
+	SourceID int64  `json:"source_id"`
+	Label    string `json:"label"`
+	Reason   string `json:"reason"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 領域錯誤的種類，以 errors.Is 判斷；handler 依種類決定 HTTP 狀態碼
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrValidation  = errors.New("validation failed")
+	ErrForbidden   = errors.New("forbidden")
+	ErrUnavailable = errors.New("unavailable")
+	// ErrTooManyRequests 超過請求頻率或每日配額，由 ratelimit 套件回傳
+	ErrTooManyRequests = errors.New("too many requests")
+)
+
+// DomainError 帶有種類的領域錯誤，訊息維持原本的中文說明，
+// errors.Is 可同時比對種類與以 %w 包裝的底層錯誤
+type DomainError struct {
+	Kind error
+	Err  error
+}
+
+// Error 回傳錯誤訊息
+func (e *DomainError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap 回傳錯誤種類與底層錯誤
+func (e *DomainError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// newDomainError 依格式建立指定種類的領域錯誤
+func newDomainError(kind error, format string, args ...interface{}) error {
+	return &DomainError{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// NotFoundf 建立找不到資料的錯誤
+func NotFoundf(format string, args ...interface{}) error {
+	return newDomainError(ErrNotFound, format, args...)
+}
+
+// Conflictf 建立資料衝突（重複或狀態不允許）的錯誤
+func Conflictf(format string, args ...interface{}) error {
+	return newDomainError(ErrConflict, format, args...)
+}
+
+// Validationf 建立輸入驗證失敗的錯誤
+func Validationf(format string, args ...interface{}) error {
+	return newDomainError(ErrValidation, format, args...)
+}
+
+// Forbiddenf 建立操作不被允許的錯誤
+func Forbiddenf(format string, args ...interface{}) error {
+	return newDomainError(ErrForbidden, format, args...)
+}
+
+// Unavailablef 建立依賴的資源（例如租戶資料庫）暫時無法使用的錯誤
+func Unavailablef(format string, args ...interface{}) error {
+	return newDomainError(ErrUnavailable, format, args...)
+}
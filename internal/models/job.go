@@ -0,0 +1,89 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 背景生成工作的狀態
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// 生成作業回報進度時的步驟
+const (
+	JobStageUsers    = "users"
+	JobStageRoles    = "roles"
+	JobStagePatients = "patients"
+	JobStageSlots    = "slots"
+)
+
+// Job 表示一個背景生成工作，Kind 與生成批次的類型相同（users、patients 或 slots）。
+// 執行中的進度由 worker 即時回報，並定期寫入資料表；結束時寫入最後的進度。
+// Owner 是執行工作的程序，執行期間定期更新 HeartbeatAt；心跳逾時的工作才可由其他程序放回佇列。
+// AuditRequest 是送出工作的請求，工作執行時產生的稽核紀錄沿用其操作者，不在 API 中輸出。
+type Job struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	Status      string          `json:"status"`
+	Params      json.RawMessage `json:"params"`
+	Result      *JobResult      `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Progress    JobProgress     `json:"progress"`
+	CreatedAt   time.Time       `json:"created_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+	Owner       string          `json:"owner,omitempty"`
+	HeartbeatAt *time.Time      `json:"heartbeat_at,omitempty"`
+
+	AuditRequest *AuditRequest `json:"-"`
+}
+
+// Finished 判斷工作是否已結束（成功、失敗或已取消）
+func (j *Job) Finished() bool {
+	switch j.Status {
+	case JobStatusSucceeded, JobStatusFailed, JobStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// JobProgress 表示工作目前的進度，Stage 為目前的步驟（例如 users、roles）
+type JobProgress struct {
+	Stage string `json:"stage,omitempty"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// JobResult 表示工作成功後的結果
+type JobResult struct {
+	Created int      `json:"created"`
+	BatchID string   `json:"batch_id,omitempty"` // 可用於清除生成的資料
+	Skipped []string `json:"skipped,omitempty"`  // 被略過的資料及原因
+}
+
+// UserJobParams 生成假使用者工作的參數
+type UserJobParams struct {
+	Count    int     `json:"count"`
+	UserType string  `json:"user_type"`
+	RoleIDs  []int64 `json:"role_ids,omitempty"`
+}
+
+// PatientJobParams 生成假病患並寫入資料庫的工作參數
+type PatientJobParams struct {
+	Count   int    `json:"count"`
+	Profile string `json:"profile"`
+}
+
+// SlotJobParams 生成可預約時段工作的參數
+type SlotJobParams struct {
+	DoctorID     int64 `json:"doctor_id"`
+	Days         int   `json:"days"`
+	SlotsPerDay  int   `json:"slots_per_day"`
+	StartHour    int   `json:"start_hour"`
+	SlotDuration int   `json:"slot_duration"`
+}
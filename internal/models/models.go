@@ -43,3 +43,39 @@ type Comment struct {
 	UserID  int    `json:"user_id"`
 	Content string `json:"content"`
 }
+
+// 假資料生成批次類型
+const (
+	BatchKindUsers    = "users"
+	BatchKindPatients = "patients"
+	BatchKindSlots    = "slots"
+	BatchKindCopy     = "copy" // 從其他租戶複製的資料
+)
+
+// 批次紀錄的實體類型
+const (
+	BatchEntityUser    = "user"
+	BatchEntityPatient = "patient"
+	BatchEntitySlot    = "slot"
+)
+
+// GenerationBatch 表示一次假資料生成批次
+type GenerationBatch struct {
+	ID        string         `json:"id"`
+	Kind      string         `json:"kind"`
+	CreatedAt time.Time      `json:"created_at"`
+	Counts    map[string]int `json:"counts,omitempty"` // 各實體類型的紀錄筆數
+}
+
+// BatchPurgeResult 表示清除批次（或預覽）的結果
+type BatchPurgeResult struct {
+	BatchID string           `json:"batch_id"`
+	DryRun  bool             `json:"dry_run"`
+	Counts  []BatchPurgeStep `json:"counts"`
+}
+
+// BatchPurgeStep 表示清除批次時單一資料表受影響的筆數
+type BatchPurgeStep struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+}
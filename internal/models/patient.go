@@ -26,6 +26,20 @@ type Patient struct {
 	MedicalHistories    []string  `json:"medical_histories,omitempty"` // 用於顯示，實際儲存在 medicalHistory 欄位
 }
 
+// AnonymizeResult 表示就地去識別化病患的結果
+type AnonymizeResult struct {
+	DryRun  bool                `json:"dry_run"`
+	Count   int                 `json:"count"`
+	Samples []AnonymizedPatient `json:"samples"` // 前幾筆的對照，供預覽確認
+}
+
+// AnonymizedPatient 表示單一病患去識別化前後的姓名
+type AnonymizedPatient struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Pseudonym string `json:"pseudonym"`
+}
+
 // PatientHistoryDisease 定義患者病史資料模型
 type PatientHistoryDisease struct {
 	PatientID      int64  `json:"patient_id"`
@@ -106,3 +120,106 @@ const (
 	MedicalGout            MedicalHistoryEnum = "gout"
 	MedicalOther           MedicalHistoryEnum = "other"
 )
+
+// medicalHistoryEnums 依宣告順序列出所有醫療史枚舉值
+var medicalHistoryEnums = []MedicalHistoryEnum{
+	MedicalHypertension, MedicalDiabetes, MedicalHyperlipidemia, MedicalHeartDisease,
+	MedicalStroke, MedicalCancer, MedicalCopd, MedicalAsthma,
+	MedicalSleepApnea, MedicalOsteoporosis, MedicalArthritis, MedicalKidneyDisease,
+	MedicalLiverDisease, MedicalDepression, MedicalAnxiety, MedicalBipolarDisorder,
+	MedicalSchizophrenia, MedicalDementia, MedicalParkinsons, MedicalEpilepsy,
+	MedicalMigraine, MedicalThyroidDisease, MedicalGout, MedicalOther,
+}
+
+// historyDiseaseDisplayNames 疾病史枚舉對應的中文顯示名稱
+var historyDiseaseDisplayNames = map[HistoryDiseaseEnum]string{
+	HistoryHypertension:    "高血壓",
+	HistoryDiabetes:        "糖尿病",
+	HistoryHyperlipidemia:  "高血脂",
+	HistoryHeartDisease:    "心臟病",
+	HistoryStroke:          "中風",
+	HistoryCancer:          "癌症",
+	HistoryCopd:            "慢性阻塞性肺病",
+	HistoryAsthma:          "氣喘",
+	HistorySleepApnea:      "睡眠呼吸中止症",
+	HistoryOsteoporosis:    "骨質疏鬆",
+	HistoryArthritis:       "關節炎",
+	HistoryKidneyDisease:   "腎臟病",
+	HistoryLiverDisease:    "肝臟疾病",
+	HistoryDepression:      "憂鬱症",
+	HistoryAnxiety:         "焦慮症",
+	HistoryBipolarDisorder: "躁鬱症",
+	HistorySchizophrenia:   "思覺失調症",
+	HistoryDementia:        "失智症",
+	HistoryParkinsons:      "巴金森氏症",
+	HistoryEpilepsy:        "癲癇",
+	HistoryMigraine:        "偏頭痛",
+	HistoryThyroidDisease:  "甲狀腺疾病",
+	HistoryGout:            "痛風",
+	HistoryOther:           "其他",
+}
+
+// historyDiseaseCategories 疾病史枚舉對應到 history_disease 表中的疾病分類名稱
+var historyDiseaseCategories = map[HistoryDiseaseEnum]string{
+	HistoryHypertension:   "心血管疾病",
+	HistoryDiabetes:       "糖尿病",
+	HistoryHyperlipidemia: "心血管疾病",
+	HistoryHeartDisease:   "心血管疾病",
+	HistoryStroke:         "中樞神經損傷",
+	HistoryCancer:         "癌症",
+	HistoryCopd:           "呼吸方面疾病",
+	HistoryAsthma:         "呼吸方面疾病",
+	HistorySleepApnea:     "呼吸方面疾病",
+	HistoryArthritis:      "免疫相關疾病",
+	HistoryKidneyDisease:  "腎臟病",
+	HistoryLiverDisease:   "肝臟疾病",
+	HistoryDementia:       "中樞神經損傷",
+	HistoryParkinsons:     "中樞神經損傷",
+	HistoryEpilepsy:       "中樞神經損傷",
+}
+
+// DisplayName 回傳疾病史枚舉的中文顯示名稱，未定義時回傳原始代碼
+func (e HistoryDiseaseEnum) DisplayName() string {
+	if name, ok := historyDiseaseDisplayNames[e]; ok {
+		return name
+	}
+	return string(e)
+}
+
+// Category 回傳疾病史枚舉在 history_disease 表中對應的疾病分類名稱，沒有對應分類時回傳 false
+func (e HistoryDiseaseEnum) Category() (string, bool) {
+	category, ok := historyDiseaseCategories[e]
+	return category, ok
+}
+
+// DisplayName 回傳醫療史枚舉的中文顯示名稱，未定義時回傳原始代碼
+func (e MedicalHistoryEnum) DisplayName() string {
+	return HistoryDiseaseEnum(e).DisplayName()
+}
+
+// ParseHistoryDiseaseEnum 依代碼或中文顯示名稱解析疾病史枚舉
+func ParseHistoryDiseaseEnum(value string) (HistoryDiseaseEnum, bool) {
+	for code, name := range historyDiseaseDisplayNames {
+		if string(code) == value || name == value {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// MedicalHistoryEnums 回傳所有醫療史枚舉值，依宣告順序排列
+func MedicalHistoryEnums() []MedicalHistoryEnum {
+	enums := make([]MedicalHistoryEnum, len(medicalHistoryEnums))
+	copy(enums, medicalHistoryEnums)
+	return enums
+}
+
+// ParseMedicalHistoryEnum 依代碼或中文顯示名稱解析醫療史枚舉
+func ParseMedicalHistoryEnum(value string) (MedicalHistoryEnum, bool) {
+	for _, code := range medicalHistoryEnums {
+		if string(code) == value || code.DisplayName() == value {
+			return code, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,309 @@
+// Package ratelimit 限制生成端點的請求頻率與每日生成的資料筆數，
+// 分別依用戶端（IP）與使用者（由前端代理設定的標頭）計算
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"golang-gin-app/internal/metrics"
+	"golang-gin-app/internal/models"
+)
+
+// 限制對象的種類
+const (
+	KindClient = "client"
+	KindUser   = "user"
+)
+
+// 超過限制的原因
+const (
+	ReasonRate  = "rate"
+	ReasonQuota = "quota"
+)
+
+// Limit 請求頻率限制（token bucket）：平均每分鐘 PerMinute 個請求，最多可連續送出 Burst 個
+type Limit struct {
+	PerMinute int // 0 表示不限制
+	Burst     int // 小於 1 時視為 1
+}
+
+// Config 限制設定
+type Config struct {
+	Client           Limit
+	User             Limit
+	ClientDailyQuota int    // 每個用戶端每日可生成的資料筆數，0 表示不限制
+	UserDailyQuota   int    // 每個使用者每日可生成的資料筆數，0 表示不限制
+	UserHeader       string // 識別使用者的請求標頭；為空或請求未帶此標頭時只依用戶端限制
+}
+
+// Subject 限制的對象
+type Subject struct {
+	Kind string
+	ID   string
+}
+
+// ExceededError 超過請求頻率或每日配額，RetryAfter 為可再次嘗試前需等待的時間
+type ExceededError struct {
+	Subject    Subject
+	Reason     string
+	RetryAfter time.Duration
+	message    string
+}
+
+func (e *ExceededError) Error() string {
+	return e.message
+}
+
+// Unwrap 回傳 models.ErrTooManyRequests，handler 據此回傳 429
+func (e *ExceededError) Unwrap() error {
+	return models.ErrTooManyRequests
+}
+
+// Usage 單一對象今日的使用狀況
+type Usage struct {
+	Kind      string    `json:"kind"`
+	ID        string    `json:"id"`
+	Requests  int       `json:"requests"`  // 今日通過的請求數
+	Records   int       `json:"records"`   // 今日已預留的資料筆數
+	Rejected  int       `json:"rejected"`  // 今日被拒絕的請求數
+	Quota     int       `json:"quota"`     // 每日配額，0 表示不限制
+	Available float64   `json:"available"` // 目前可立即送出的請求數，不限制時為 -1
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// state 單一對象的 token bucket 與今日計數
+type state struct {
+	tokens   float64
+	updated  time.Time
+	requests int
+	records  int
+	rejected int
+	lastSeen time.Time
+}
+
+// Limiter 以記憶體保存每個對象的狀態，多個執行個體之間不共享；可同時由多個 goroutine 使用
+type Limiter struct {
+	config Config
+	now    func() time.Time
+
+	mu     sync.Mutex
+	day    time.Time // 目前計數所屬日期的開始時間
+	states map[Subject]*state
+}
+
+// New 建立 Limiter
+func New(config Config) *Limiter {
+	return &Limiter{config: config, now: time.Now, states: make(map[Subject]*state)}
+}
+
+// Config 回傳限制設定
+func (l *Limiter) Config() Config {
+	return l.config
+}
+
+// Subjects 回傳請求要計算限制的對象：用戶端 IP，以及請求帶有使用者標頭時的使用者
+func (l *Limiter) Subjects(clientIP string, header http.Header) []Subject {
+	subjects := []Subject{{Kind: KindClient, ID: clientIP}}
+	if l.config.UserHeader != "" {
+		if user := header.Get(l.config.UserHeader); user != "" {
+			subjects = append(subjects, Subject{Kind: KindUser, ID: user})
+		}
+	}
+	return subjects
+}
+
+// Reservation 已通過檢查並預留的資料筆數，生成失敗時以 Cancel 歸還
+type Reservation struct {
+	limiter  *Limiter
+	subjects []Subject
+	records  int
+}
+
+// Allow 檢查所有對象的請求頻率與每日配額，records 為這次要生成的資料筆數。
+// 全部通過時消耗每個對象的一個請求額度並預留 records 筆配額；任一對象超過限制時不消耗任何額度，回傳 *ExceededError。
+// l 為 nil 時不限制。
+func (l *Limiter) Allow(subjects []Subject, records int) (*Reservation, error) {
+	if l == nil {
+		return nil, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.rollover(now)
+
+	states := make([]*state, len(subjects))
+	for i, subject := range subjects {
+		st := l.stateFor(subject, now)
+		states[i] = st
+		if err := l.check(subject, st, records, now); err != nil {
+			st.rejected++
+			metrics.RateLimited.WithLabelValues(err.Subject.Kind, err.Reason).Inc()
+			return nil, err
+		}
+	}
+	for i, subject := range subjects {
+		st := states[i]
+		if l.limit(subject.Kind).PerMinute > 0 {
+			st.tokens--
+		}
+		st.requests++
+		st.records += records
+	}
+	return &Reservation{limiter: l, subjects: subjects, records: records}, nil
+}
+
+// Cancel 歸還預留的資料筆數（請求額度不歸還）；r 為 nil 時不做任何事
+func (r *Reservation) Cancel() {
+	if r == nil || r.records == 0 {
+		return
+	}
+	l := r.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, subject := range r.subjects {
+		if st, ok := l.states[subject]; ok {
+			st.records = max(st.records-r.records, 0)
+		}
+	}
+	r.records = 0
+}
+
+// check 檢查單一對象；呼叫時需持有 l.mu
+func (l *Limiter) check(subject Subject, st *state, records int, now time.Time) *ExceededError {
+	limit := l.limit(subject.Kind)
+	if limit.PerMinute > 0 {
+		l.refill(st, limit, now)
+		if st.tokens < 1 {
+			wait := time.Duration((1 - st.tokens) / float64(limit.PerMinute) * float64(time.Minute))
+			return &ExceededError{
+				Subject:    subject,
+				Reason:     ReasonRate,
+				RetryAfter: wait,
+				message:    fmt.Sprintf("%s %s 的請求過於頻繁（每分鐘 %d 次），請於 %d 秒後再試", subjectLabel(subject.Kind), subject.ID, limit.PerMinute, RetryAfterSeconds(wait)),
+			}
+		}
+	}
+	if quota := l.quota(subject.Kind); quota > 0 && st.records+records > quota {
+		wait := l.day.AddDate(0, 0, 1).Sub(now)
+		return &ExceededError{
+			Subject:    subject,
+			Reason:     ReasonQuota,
+			RetryAfter: wait,
+			message: fmt.Sprintf("%s %s 今日已生成 %d 筆資料，再生成 %d 筆將超過每日上限 %d 筆",
+				subjectLabel(subject.Kind), subject.ID, st.records, records, quota),
+		}
+	}
+	return nil
+}
+
+// Usage 回傳今日有請求的對象的使用狀況，依種類與已預留的筆數排序
+func (l *Limiter) Usage() []Usage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.rollover(now)
+	usage := make([]Usage, 0, len(l.states))
+	for subject, st := range l.states {
+		limit := l.limit(subject.Kind)
+		available := -1.0
+		if limit.PerMinute > 0 {
+			l.refill(st, limit, now)
+			available = math.Floor(st.tokens)
+		}
+		usage = append(usage, Usage{
+			Kind:      subject.Kind,
+			ID:        subject.ID,
+			Requests:  st.requests,
+			Records:   st.records,
+			Rejected:  st.rejected,
+			Quota:     l.quota(subject.Kind),
+			Available: available,
+			LastSeen:  st.lastSeen,
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Kind != usage[j].Kind {
+			return usage[i].Kind < usage[j].Kind
+		}
+		if usage[i].Records != usage[j].Records {
+			return usage[i].Records > usage[j].Records
+		}
+		return usage[i].ID < usage[j].ID
+	})
+	return usage
+}
+
+// rollover 換日時清除前一天的計數，並移除前一天沒有請求的對象；呼叫時需持有 l.mu
+func (l *Limiter) rollover(now time.Time) {
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	if today.Equal(l.day) {
+		return
+	}
+	previous := l.day
+	l.day = today
+	for subject, st := range l.states {
+		if st.lastSeen.Before(previous) {
+			delete(l.states, subject)
+			continue
+		}
+		st.requests, st.records, st.rejected = 0, 0, 0
+	}
+}
+
+// stateFor 取得或建立對象的狀態，新對象的 bucket 為滿；呼叫時需持有 l.mu
+func (l *Limiter) stateFor(subject Subject, now time.Time) *state {
+	st, ok := l.states[subject]
+	if !ok {
+		st = &state{tokens: float64(burst(l.limit(subject.Kind))), updated: now}
+		l.states[subject] = st
+	}
+	st.lastSeen = now
+	return st
+}
+
+// refill 依經過的時間補充 token，不超過 Burst
+func (l *Limiter) refill(st *state, limit Limit, now time.Time) {
+	elapsed := now.Sub(st.updated)
+	if elapsed > 0 {
+		st.tokens = math.Min(float64(burst(limit)), st.tokens+elapsed.Minutes()*float64(limit.PerMinute))
+		st.updated = now
+	}
+}
+
+func (l *Limiter) limit(kind string) Limit {
+	if kind == KindUser {
+		return l.config.User
+	}
+	return l.config.Client
+}
+
+func (l *Limiter) quota(kind string) int {
+	if kind == KindUser {
+		return l.config.UserDailyQuota
+	}
+	return l.config.ClientDailyQuota
+}
+
+func burst(limit Limit) int {
+	return max(limit.Burst, 1)
+}
+
+func subjectLabel(kind string) string {
+	if kind == KindUser {
+		return "使用者"
+	}
+	return "用戶端"
+}
+
+// RetryAfterSeconds 將等待時間無條件進位為 Retry-After 標頭使用的秒數，至少 1 秒
+func RetryAfterSeconds(d time.Duration) int {
+	return max(int(math.Ceil(d.Seconds())), 1)
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang-gin-app/internal/models"
+)
+
+// newTestLimiter 建立使用可控制時鐘的 Limiter
+func newTestLimiter(config Config) (*Limiter, *time.Time) {
+	now := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	l := New(config)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestRateLimitRefills(t *testing.T) {
+	l, now := newTestLimiter(Config{Client: Limit{PerMinute: 6, Burst: 2}})
+	client := []Subject{{Kind: KindClient, ID: "10.0.0.1"}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := l.Allow(client, 1); err != nil {
+			t.Fatalf("request %d within burst: %v", i+1, err)
+		}
+	}
+	_, err := l.Allow(client, 1)
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || exceeded.Reason != ReasonRate || !errors.Is(err, models.ErrTooManyRequests) {
+		t.Fatalf("third request: got %v, want rate limit error", err)
+	}
+	// 每分鐘 6 個，補充一個需要 10 秒
+	if exceeded.RetryAfter != 10*time.Second {
+		t.Errorf("RetryAfter = %v, want 10s", exceeded.RetryAfter)
+	}
+
+	// 其他用戶端不受影響
+	if _, err := l.Allow([]Subject{{Kind: KindClient, ID: "10.0.0.2"}}, 1); err != nil {
+		t.Errorf("other client: %v", err)
+	}
+
+	*now = now.Add(10 * time.Second)
+	if _, err := l.Allow(client, 1); err != nil {
+		t.Errorf("after refill: %v", err)
+	}
+}
+
+func TestDailyQuota(t *testing.T) {
+	l, now := newTestLimiter(Config{ClientDailyQuota: 100, UserDailyQuota: 50})
+	subjects := l.Subjects("10.0.0.1", http.Header{})
+	if len(subjects) != 1 {
+		t.Fatalf("subjects without user header = %v", subjects)
+	}
+
+	if _, err := l.Allow(subjects, 80); err != nil {
+		t.Fatalf("within quota: %v", err)
+	}
+	_, err := l.Allow(subjects, 30)
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || exceeded.Reason != ReasonQuota {
+		t.Fatalf("over quota: got %v, want quota error", err)
+	}
+	// 配額在午夜重新計算
+	if exceeded.RetryAfter != time.Hour {
+		t.Errorf("RetryAfter = %v, want 1h until midnight", exceeded.RetryAfter)
+	}
+
+	// 失敗的生成歸還配額
+	reservation, err := l.Allow(subjects, 20)
+	if err != nil {
+		t.Fatalf("fill quota: %v", err)
+	}
+	reservation.Cancel()
+	if usage := l.Usage(); usage[0].Records != 80 || usage[0].Requests != 2 || usage[0].Rejected != 1 {
+		t.Errorf("usage after cancel = %+v", usage[0])
+	}
+
+	*now = now.Add(time.Hour)
+	if _, err := l.Allow(subjects, 100); err != nil {
+		t.Errorf("next day: %v", err)
+	}
+}
+
+func TestUserLimitsApplyWithClientLimits(t *testing.T) {
+	l, _ := newTestLimiter(Config{
+		Client:         Limit{PerMinute: 60, Burst: 10},
+		User:           Limit{PerMinute: 1, Burst: 1},
+		UserDailyQuota: 10,
+		UserHeader:     "X-Forwarded-User",
+	})
+	header := http.Header{}
+	header.Set("X-Forwarded-User", "alice")
+	subjects := l.Subjects("10.0.0.1", header)
+	if len(subjects) != 2 || subjects[1] != (Subject{Kind: KindUser, ID: "alice"}) {
+		t.Fatalf("subjects = %v", subjects)
+	}
+
+	if _, err := l.Allow(subjects, 5); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	_, err := l.Allow(subjects, 5)
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || exceeded.Subject.Kind != KindUser {
+		t.Fatalf("second request: got %v, want user rate limit", err)
+	}
+
+	// 使用者被拒絕時不消耗用戶端的額度
+	for _, usage := range l.Usage() {
+		if usage.Kind == KindClient && (usage.Requests != 1 || usage.Available != 9) {
+			t.Errorf("client usage = %+v, want 1 request and 9 available", usage)
+		}
+	}
+}
+
+func TestNilLimiterAllowsEverything(t *testing.T) {
+	var l *Limiter
+	reservation, err := l.Allow(nil, 1000)
+	if err != nil {
+		t.Fatalf("nil limiter: %v", err)
+	}
+	reservation.Cancel()
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"golang-gin-app/internal/models"
+	"unicode/utf8"
+)
+
+// ReserveAccountNumbers 以資料庫序號保留 count 個連續的帳號編號，回傳第一個編號。
+// 序號存放在 account_sequence 表中，以單一 UPDATE 原子地遞增，
+// 因此多個應用程式實例同時生成帳號時也不會取得相同的編號。
+// 每次保留時都會與 user 表中既有帳號的最大編號比較，手動建立的帳號不會造成衝突；
+// 只有「前綴 + 純數字」的帳號會被比較，admin、doctor_wang 等帳號不影響序號。
+func (r *sqlUserRepository) ReserveAccountNumbers(ctx context.Context, prefix string, count int) (int64, error) {
+	if prefix == "" {
+		return 0, models.Validationf("帳號前綴不可為空")
+	}
+	if count <= 0 {
+		return 0, models.Validationf("保留數量必須大於0")
+	}
+
+	if r.dialect == DialectSQLite {
+		return r.reserveAccountNumbersSQLite(ctx, prefix, count)
+	}
+
+	// 帳號編號從前綴之後開始（SUBSTRING 位置從 1 起算）
+	prefixLength := utf8.RuneCountInString(prefix)
+	numberOffset := prefixLength + 1
+
+	// 第一次使用此前綴時建立序號列，已存在則忽略
+	_, err := r.db(ctx, "users.ReserveAccountNumbers").ExecContext(ctx,
+		`INSERT IGNORE INTO account_sequence (prefix, last_value) VALUES (?, 0)`, prefix)
+	if err != nil {
+		return 0, fmt.Errorf("初始化帳號序號失敗: %v", err)
+	}
+
+	// 原子地遞增序號；LAST_INSERT_ID(expr) 讓遞增後的值透過 LastInsertId 回傳。
+	// 以 LEFT 比對前綴（LIKE 會把前綴中的 _ 視為萬用字元），並只轉換純數字的編號，
+	// 避免嚴格模式下 CAST 非數字字串時的 1292 錯誤
+	res, err := r.db(ctx, "users.ReserveAccountNumbers").ExecContext(ctx, `
+		UPDATE account_sequence
+		SET last_value = LAST_INSERT_ID(GREATEST(last_value, (
+			SELECT COALESCE(MAX(CAST(SUBSTRING(account, ?) AS UNSIGNED)), 0)
+			FROM user
+			WHERE LEFT(account, ?) = ? AND SUBSTRING(account, ?) REGEXP '^[0-9]+$'
+		)) + ?)
+		WHERE prefix = ?`,
+		numberOffset, prefixLength, prefix, numberOffset, count, prefix)
+	if err != nil {
+		return 0, fmt.Errorf("保留帳號編號失敗: %v", err)
+	}
+
+	last, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("獲取帳號序號失敗: %v", err)
+	}
+	return last - int64(count) + 1, nil
+}
+
+// reserveAccountNumbersSQLite 是 ReserveAccountNumbers 的 SQLite 版本。
+// SQLite 的寫入會序列化，單一 UPDATE ... RETURNING 即可原子地遞增序號。
+func (r *sqlUserRepository) reserveAccountNumbersSQLite(ctx context.Context, prefix string, count int) (int64, error) {
+	// 帳號編號從前綴之後開始（substr 位置從 1 起算）
+	prefixLength := utf8.RuneCountInString(prefix)
+	numberOffset := prefixLength + 1
+
+	// 第一次使用此前綴時建立序號列，已存在則忽略
+	_, err := r.db(ctx, "users.reserveAccountNumbersSQLite").ExecContext(ctx,
+		`INSERT OR IGNORE INTO account_sequence (prefix, last_value) VALUES (?, 0)`, prefix)
+	if err != nil {
+		return 0, fmt.Errorf("初始化帳號序號失敗: %v", err)
+	}
+
+	// 以 substr 比對前綴並以 GLOB 排除含非數字字元的編號，與 MySQL 版本的篩選一致
+	var last int64
+	err = r.db(ctx, "users.reserveAccountNumbersSQLite").QueryRowContext(ctx, `
+		UPDATE account_sequence
+		SET last_value = max(last_value, (
+			SELECT COALESCE(MAX(CAST(substr(account, ?) AS INTEGER)), 0)
+			FROM user
+			WHERE substr(account, 1, ?) = ? AND substr(account, ?) <> ''
+				AND substr(account, ?) NOT GLOB '*[^0-9]*'
+		)) + ?
+		WHERE prefix = ?
+		RETURNING last_value`,
+		numberOffset, prefixLength, prefix, numberOffset, numberOffset, count, prefix).Scan(&last)
+	if err != nil {
+		return 0, fmt.Errorf("保留帳號編號失敗: %v", err)
+	}
+	return last - int64(count) + 1, nil
+}
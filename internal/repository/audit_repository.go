@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"golang-gin-app/internal/models"
+	"strings"
+)
+
+// auditColumns 查詢稽核紀錄時選取的欄位，順序與 scanAuditEntry 相同
+const auditColumns = `ID, created_at, actor, action, entity_type, entity_id, before_data, after_data, changes, request_id, method, path, client_ip, user_agent`
+
+// sqlAuditRepository 是 AuditRepository 的 SQL 實作
+type sqlAuditRepository struct {
+	*sqlConn
+}
+
+// RecordAudit 新增稽核紀錄並回填 entry.ID
+func (r *sqlAuditRepository) RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return fmt.Errorf("序列化稽核異動失敗: %v", err)
+	}
+	res, err := r.db(ctx, "audit.RecordAudit").ExecContext(ctx,
+		`INSERT INTO audit_log (created_at, actor, action, entity_type, entity_id, before_data, after_data, changes, request_id, method, path, client_ip, user_agent)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.CreatedAt, entry.Actor, entry.Action, entry.EntityType, entry.EntityID,
+		nullJSON(entry.Before), nullJSON(entry.After), string(changes),
+		entry.RequestID, entry.Method, entry.Path, entry.ClientIP, entry.UserAgent)
+	if err != nil {
+		return fmt.Errorf("新增稽核紀錄失敗: %v", err)
+	}
+	if entry.ID, err = res.LastInsertId(); err != nil {
+		return fmt.Errorf("獲取稽核紀錄ID失敗: %v", err)
+	}
+	return nil
+}
+
+// ListAudit 依條件由新到舊獲取稽核紀錄
+func (r *sqlAuditRepository) ListAudit(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+	var conditions []string
+	var args []interface{}
+	for _, eq := range []struct {
+		column string
+		value  string
+	}{
+		{"actor", filter.Actor},
+		{"action", filter.Action},
+		{"entity_type", filter.EntityType},
+		{"entity_id", filter.EntityID},
+	} {
+		if eq.value != "" {
+			conditions = append(conditions, eq.column+" = ?")
+			args = append(args, eq.value)
+		}
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.To)
+	}
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY created_at DESC, ID DESC LIMIT ?`
+	args = append(args, filter.Limit)
+
+	rows, err := r.db(ctx, "audit.ListAudit").QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("獲取稽核紀錄失敗: %v", err)
+	}
+	defer rows.Close()
+
+	entries := make([]*models.AuditEntry, 0)
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("掃描稽核紀錄失敗: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// nullJSON 將空的 JSON 快照存為 NULL
+func nullJSON(data json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(data), Valid: len(data) > 0}
+}
+
+// scanAuditEntry 掃描 auditColumns 選取的欄位
+func scanAuditEntry(row rowScanner) (*models.AuditEntry, error) {
+	entry := &models.AuditEntry{}
+	var before, after sql.NullString
+	var changes string
+	err := row.Scan(&entry.ID, &entry.CreatedAt, &entry.Actor, &entry.Action, &entry.EntityType, &entry.EntityID,
+		&before, &after, &changes, &entry.RequestID, &entry.Method, &entry.Path, &entry.ClientIP, &entry.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+	if before.Valid {
+		entry.Before = json.RawMessage(before.String)
+	}
+	if after.Valid {
+		entry.After = json.RawMessage(after.String)
+	}
+	if err := json.Unmarshal([]byte(changes), &entry.Changes); err != nil {
+		return nil, fmt.Errorf("解析稽核紀錄 %d 的異動失敗: %v", entry.ID, err)
+	}
+	return entry, nil
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"golang-gin-app/internal/models"
+)
+
+// batchPurgeSteps 清除批次時依相依順序處理的資料表，子表必須排在父表之前
+var batchPurgeSteps = []struct {
+	table string
+	where string
+	args  int // where 條件中 batch_id 參數的數量
+}{
+	{"user_role", "user_id IN (SELECT entity_id FROM generation_batch_item WHERE batch_id = ? AND entity_type = 'user')", 1},
+	{"patient_history_disease", "patient_id IN (SELECT entity_id FROM generation_batch_item WHERE batch_id = ? AND entity_type = 'patient')", 1},
+	{"patient_medical_history", "patient_id IN (SELECT entity_id FROM generation_batch_item WHERE batch_id = ? AND entity_type = 'patient')", 1},
+	{"patient", "ID IN (SELECT entity_id FROM generation_batch_item WHERE batch_id = ? AND entity_type = 'patient')", 1},
+	// 除了批次建立的時段外，也一併刪除批次中使用者名下的時段，避免留下孤兒資料
+	{"wg_available_slots", "ID IN (SELECT entity_id FROM generation_batch_item WHERE batch_id = ? AND entity_type = 'slot') OR doctor IN (SELECT entity_id FROM generation_batch_item WHERE batch_id = ? AND entity_type = 'user')", 2},
+	{"user", "ID IN (SELECT entity_id FROM generation_batch_item WHERE batch_id = ? AND entity_type = 'user')", 1},
+	{"generation_batch_item", "batch_id = ?", 1},
+	{"generation_batch", "ID = ?", 1},
+}
+
+// sqlBatchRepository 是 BatchRepository 的 SQL 實作
+type sqlBatchRepository struct {
+	*sqlConn
+}
+
+// RecordGenerationBatch 記錄一次生成批次及其建立的資料列
+func (r *sqlBatchRepository) RecordGenerationBatch(ctx context.Context, batch *models.GenerationBatch, entityType string, entityIDs []int64) error {
+	return r.withTx(ctx, "batches.RecordGenerationBatch", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO generation_batch (ID, kind, created_at) VALUES (?, ?, ?)`,
+			batch.ID, batch.Kind, batch.CreatedAt)
+		if isUniqueViolation(err) {
+			return models.Conflictf("建立批次紀錄失敗: 批次 %s 已存在", batch.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("建立批次紀錄失敗: %v", err)
+		}
+
+		stmt, err := tx.PrepareContext(ctx,
+			`INSERT INTO generation_batch_item (batch_id, entity_type, entity_id) VALUES (?, ?, ?)`)
+		if err != nil {
+			return fmt.Errorf("準備插入語句失敗: %v", err)
+		}
+		defer stmt.Close()
+
+		for _, id := range entityIDs {
+			if _, err := stmt.ExecContext(ctx, batch.ID, entityType, id); err != nil {
+				return fmt.Errorf("記錄批次資料 %s %d 失敗: %v", entityType, id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// AddGenerationBatchItems 為已存在的批次加入另一種實體類型的資料列
+func (r *sqlBatchRepository) AddGenerationBatchItems(ctx context.Context, batchID string, entityType string, entityIDs []int64) error {
+	if len(entityIDs) == 0 {
+		return nil
+	}
+	return r.withTx(ctx, "batches.AddGenerationBatchItems", func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx,
+			`INSERT INTO generation_batch_item (batch_id, entity_type, entity_id) VALUES (?, ?, ?)`)
+		if err != nil {
+			return fmt.Errorf("準備插入語句失敗: %v", err)
+		}
+		defer stmt.Close()
+
+		for _, id := range entityIDs {
+			if _, err := stmt.ExecContext(ctx, batchID, entityType, id); err != nil {
+				return fmt.Errorf("記錄批次資料 %s %d 失敗: %v", entityType, id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ListGenerationBatches 獲取最近的生成批次及各實體類型的筆數
+func (r *sqlBatchRepository) ListGenerationBatches(ctx context.Context, limit int) ([]*models.GenerationBatch, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := r.db(ctx, "batches.ListGenerationBatches").QueryContext(ctx,
+		`SELECT ID, kind, created_at FROM generation_batch ORDER BY created_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("獲取批次列表失敗: %v", err)
+	}
+	defer rows.Close()
+
+	batches := make([]*models.GenerationBatch, 0)
+	for rows.Next() {
+		batch := &models.GenerationBatch{Counts: make(map[string]int)}
+		if err := rows.Scan(&batch.ID, &batch.Kind, &batch.CreatedAt); err != nil {
+			return nil, fmt.Errorf("掃描批次數據失敗: %v", err)
+		}
+		batches = append(batches, batch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, batch := range batches {
+		counts, err := r.db(ctx, "batches.ListGenerationBatches").QueryContext(ctx,
+			`SELECT entity_type, COUNT(*) FROM generation_batch_item WHERE batch_id = ? GROUP BY entity_type`, batch.ID)
+		if err != nil {
+			return nil, fmt.Errorf("獲取批次 %s 筆數失敗: %v", batch.ID, err)
+		}
+		for counts.Next() {
+			var entityType string
+			var count int
+			if err := counts.Scan(&entityType, &count); err != nil {
+				counts.Close()
+				return nil, fmt.Errorf("掃描批次筆數失敗: %v", err)
+			}
+			batch.Counts[entityType] = count
+		}
+		counts.Close()
+	}
+	return batches, nil
+}
+
+// PurgeGenerationBatch 依相依順序在單一事務中刪除批次建立的資料；dryRun 時只計算筆數，不做任何修改
+func (r *sqlBatchRepository) PurgeGenerationBatch(ctx context.Context, batchID string, dryRun bool) (*models.BatchPurgeResult, error) {
+	result := &models.BatchPurgeResult{BatchID: batchID, DryRun: dryRun}
+	err := r.withTx(ctx, "batches.PurgeGenerationBatch", func(tx *sql.Tx) error {
+		var exists int
+		err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM generation_batch WHERE ID = ?`, batchID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("查詢批次失敗: %v", err)
+		}
+		if exists == 0 {
+			return models.NotFoundf("未找到ID為 %s 的批次", batchID)
+		}
+
+		for _, step := range batchPurgeSteps {
+			args := make([]interface{}, step.args)
+			for i := range args {
+				args[i] = batchID
+			}
+
+			var rows int64
+			if dryRun {
+				query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", step.table, step.where)
+				if err := tx.QueryRowContext(ctx, query, args...).Scan(&rows); err != nil {
+					return fmt.Errorf("計算 %s 筆數失敗: %v", step.table, err)
+				}
+			} else {
+				query := fmt.Sprintf("DELETE FROM %s WHERE %s", step.table, step.where)
+				res, err := tx.ExecContext(ctx, query, args...)
+				if err != nil {
+					return fmt.Errorf("刪除 %s 資料失敗: %v", step.table, err)
+				}
+				if rows, err = res.RowsAffected(); err != nil {
+					return fmt.Errorf("獲取影響行數失敗: %v", err)
+				}
+			}
+			result.Counts = append(result.Counts, models.BatchPurgeStep{Table: step.table, Rows: rows})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Dialect 表示資料庫方言，決定使用的 SQL 語法與遷移檔
+type Dialect string
+
+// 支援的資料庫方言
+const (
+	DialectMySQL  Dialect = "mysql"
+	DialectSQLite Dialect = "sqlite"
+)
+
+// ParseDialect 解析設定中的資料庫方言名稱，空字串視為 MySQL
+func ParseDialect(name string) (Dialect, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "mysql", "mariadb":
+		return DialectMySQL, nil
+	case "sqlite", "sqlite3":
+		return DialectSQLite, nil
+	default:
+		return "", fmt.Errorf("不支援的資料庫方言: %s", name)
+	}
+}
+
+// NewStore 依資料庫方言建立對應的 Store
+func NewStore(db *sql.DB, dialect Dialect) (Store, error) {
+	switch dialect {
+	case DialectMySQL, DialectSQLite:
+		return NewSQLStore(db, dialect), nil
+	default:
+		return nil, fmt.Errorf("不支援的資料庫方言: %s", dialect)
+	}
+}
+
+// RequiredTables 是 SQL Store 需要的資料表
+var RequiredTables = []string{
+	"user", "role", "user_role",
+	"history_disease", "patient", "patient_history_disease", "patient_medical_history",
+	"wg_available_slots", "generation_batch", "generation_batch_item", "account_sequence",
+	"generation_job", "audit_log",
+}
+
+// CheckTables 確認 RequiredTables 都存在，回傳的錯誤列出所有缺少的資料表
+func CheckTables(ctx context.Context, db *sql.DB) error {
+	missing := make([]string, 0)
+	for _, table := range RequiredTables {
+		rows, err := db.QueryContext(ctx, "SELECT 1 FROM "+table+" WHERE 1 = 0")
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			missing = append(missing, table)
+			continue
+		}
+		rows.Close()
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("缺少資料表: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"golang-gin-app/internal/models"
+	"strings"
+	"time"
+)
+
+// jobColumns 查詢工作時選取的欄位，順序與 scanJob 相同
+const jobColumns = `ID, kind, status, params, result, error, progress_stage, progress_done, progress_total, created_at, started_at, finished_at, audit_request, owner, heartbeat_at`
+
+// sqlJobRepository 是 JobRepository 的 SQL 實作
+type sqlJobRepository struct {
+	*sqlConn
+}
+
+// CreateJob 新增工作
+func (r *sqlJobRepository) CreateJob(ctx context.Context, job *models.Job) error {
+	var auditRequest sql.NullString
+	if job.AuditRequest != nil {
+		data, err := json.Marshal(job.AuditRequest)
+		if err != nil {
+			return fmt.Errorf("序列化工作的請求資訊失敗: %v", err)
+		}
+		auditRequest = sql.NullString{String: string(data), Valid: true}
+	}
+	_, err := r.db(ctx, "jobs.CreateJob").ExecContext(ctx,
+		`INSERT INTO generation_job (ID, kind, status, params, progress_total, created_at, audit_request) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		job.ID, job.Kind, job.Status, string(job.Params), job.Progress.Total, job.CreatedAt, auditRequest)
+	if isUniqueViolation(err) {
+		return models.Conflictf("建立工作失敗: 工作 %s 已存在", job.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("建立工作失敗: %v", err)
+	}
+	return nil
+}
+
+// GetJob 依ID取得工作
+func (r *sqlJobRepository) GetJob(ctx context.Context, id string) (*models.Job, error) {
+	row := r.db(ctx, "jobs.GetJob").QueryRowContext(ctx,
+		`SELECT `+jobColumns+` FROM generation_job WHERE ID = ?`, id)
+	job, err := scanJob(row)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFoundf("未找到ID為 %s 的工作", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查詢工作失敗: %v", err)
+	}
+	return job, nil
+}
+
+// ListJobs 獲取最近的工作
+func (r *sqlJobRepository) ListJobs(ctx context.Context, limit int) ([]*models.Job, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	return r.queryJobs(ctx, "jobs.ListJobs",
+		`SELECT `+jobColumns+` FROM generation_job ORDER BY created_at DESC, ID DESC LIMIT ?`, limit)
+}
+
+// ListJobsByStatus 依建立順序獲取指定狀態的工作
+func (r *sqlJobRepository) ListJobsByStatus(ctx context.Context, statuses ...string) ([]*models.Job, error) {
+	if len(statuses) == 0 {
+		return []*models.Job{}, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(statuses)), ", ")
+	args := make([]interface{}, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+	}
+	return r.queryJobs(ctx, "jobs.ListJobsByStatus",
+		`SELECT `+jobColumns+` FROM generation_job WHERE status IN (`+placeholders+`) ORDER BY created_at, ID`, args...)
+}
+
+// StartJob 將排隊中的工作標記為由 owner 執行中；工作不是排隊中（例如已取消）時回傳 models.ErrConflict
+func (r *sqlJobRepository) StartJob(ctx context.Context, id, owner string, startedAt time.Time) error {
+	res, err := r.db(ctx, "jobs.StartJob").ExecContext(ctx,
+		`UPDATE generation_job SET status = ?, started_at = ?, owner = ?, heartbeat_at = ? WHERE ID = ? AND status = ?`,
+		models.JobStatusRunning, startedAt, owner, startedAt, id, models.JobStatusQueued)
+	if err != nil {
+		return fmt.Errorf("更新工作狀態失敗: %v", err)
+	}
+	return r.requireUpdated(ctx, res, id, "工作 %s 不是排隊中，無法開始")
+}
+
+// UpdateJobProgress 記錄 owner 執行中工作的進度並更新心跳時間；工作不是由 owner 執行中時回傳 models.ErrConflict
+func (r *sqlJobRepository) UpdateJobProgress(ctx context.Context, id, owner string, progress models.JobProgress, heartbeatAt time.Time) error {
+	res, err := r.db(ctx, "jobs.UpdateJobProgress").ExecContext(ctx,
+		`UPDATE generation_job SET progress_stage = ?, progress_done = ?, progress_total = ?, heartbeat_at = ? WHERE ID = ? AND status = ? AND owner = ?`,
+		progress.Stage, progress.Done, progress.Total, heartbeatAt, id, models.JobStatusRunning, owner)
+	if err != nil {
+		return fmt.Errorf("更新工作進度失敗: %v", err)
+	}
+	return r.requireUpdated(ctx, res, id, "工作 %s 不是由此程序執行中，無法更新進度")
+}
+
+// FinishJob 記錄 job.Owner 執行的工作的結束狀態、結果、錯誤訊息與最後的進度
+func (r *sqlJobRepository) FinishJob(ctx context.Context, job *models.Job) error {
+	var result sql.NullString
+	if job.Result != nil {
+		data, err := json.Marshal(job.Result)
+		if err != nil {
+			return fmt.Errorf("序列化工作結果失敗: %v", err)
+		}
+		result = sql.NullString{String: string(data), Valid: true}
+	}
+	res, err := r.db(ctx, "jobs.FinishJob").ExecContext(ctx,
+		`UPDATE generation_job SET status = ?, result = ?, error = ?, progress_stage = ?, progress_done = ?, progress_total = ?, finished_at = ? WHERE ID = ? AND owner = ?`,
+		job.Status, result, sql.NullString{String: job.Error, Valid: job.Error != ""},
+		job.Progress.Stage, job.Progress.Done, job.Progress.Total, job.FinishedAt, job.ID, job.Owner)
+	if err != nil {
+		return fmt.Errorf("更新工作狀態失敗: %v", err)
+	}
+	return r.requireUpdated(ctx, res, job.ID, "工作 %s 已由其他程序接手，無法記錄結果")
+}
+
+// CancelQueuedJob 取消排隊中的工作；工作不是排隊中時回傳 models.ErrConflict
+func (r *sqlJobRepository) CancelQueuedJob(ctx context.Context, id string, finishedAt time.Time) error {
+	res, err := r.db(ctx, "jobs.CancelQueuedJob").ExecContext(ctx,
+		`UPDATE generation_job SET status = ?, finished_at = ? WHERE ID = ? AND status = ?`,
+		models.JobStatusCancelled, finishedAt, id, models.JobStatusQueued)
+	if err != nil {
+		return fmt.Errorf("取消工作失敗: %v", err)
+	}
+	return r.requireUpdated(ctx, res, id, "工作 %s 不是排隊中，無法直接取消")
+}
+
+// RequeueJob 將 owner 執行中的工作放回佇列（例如應用程式結束時中斷的工作），進度歸零
+func (r *sqlJobRepository) RequeueJob(ctx context.Context, id, owner string) error {
+	res, err := r.db(ctx, "jobs.RequeueJob").ExecContext(ctx,
+		`UPDATE generation_job SET `+requeueAssignments+` WHERE ID = ? AND status = ? AND owner = ?`,
+		models.JobStatusQueued, id, models.JobStatusRunning, owner)
+	if err != nil {
+		return fmt.Errorf("更新工作狀態失敗: %v", err)
+	}
+	return r.requireUpdated(ctx, res, id, "工作 %s 不是由此程序執行中，無法放回佇列")
+}
+
+// RequeueStaleJob 將心跳早於 staleBefore（或沒有心跳）的執行中工作放回佇列，進度歸零
+func (r *sqlJobRepository) RequeueStaleJob(ctx context.Context, id string, staleBefore time.Time) error {
+	res, err := r.db(ctx, "jobs.RequeueStaleJob").ExecContext(ctx,
+		`UPDATE generation_job SET `+requeueAssignments+` WHERE ID = ? AND status = ? AND (heartbeat_at IS NULL OR heartbeat_at < ?)`,
+		models.JobStatusQueued, id, models.JobStatusRunning, staleBefore)
+	if err != nil {
+		return fmt.Errorf("更新工作狀態失敗: %v", err)
+	}
+	return r.requireUpdated(ctx, res, id, "工作 %s 仍由其他程序執行中，無法放回佇列")
+}
+
+// requeueAssignments 放回佇列時更新的欄位，第一個參數為排隊中的狀態
+const requeueAssignments = `status = ?, started_at = NULL, owner = NULL, heartbeat_at = NULL, progress_stage = '', progress_done = 0`
+
+// requireUpdated 確認 UPDATE 有更新到資料列：工作不存在時回傳 models.ErrNotFound，
+// 存在但狀態不符時以 conflict 為訊息回傳 models.ErrConflict
+func (r *sqlJobRepository) requireUpdated(ctx context.Context, res sql.Result, id, conflict string) error {
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("獲取影響行數失敗: %v", err)
+	}
+	if rows > 0 {
+		return nil
+	}
+	if _, err := r.GetJob(ctx, id); err != nil {
+		return err
+	}
+	if conflict == "" {
+		return nil
+	}
+	return models.Conflictf(conflict, id)
+}
+
+func (r *sqlJobRepository) queryJobs(ctx context.Context, statement, query string, args ...interface{}) ([]*models.Job, error) {
+	rows, err := r.db(ctx, statement).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("獲取工作列表失敗: %v", err)
+	}
+	defer rows.Close()
+
+	jobs := make([]*models.Job, 0)
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("掃描工作數據失敗: %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// scanJob 掃描 jobColumns 選取的欄位
+func scanJob(row rowScanner) (*models.Job, error) {
+	job := &models.Job{}
+	var params string
+	var result, errMsg, auditRequest, owner sql.NullString
+	var startedAt, finishedAt, heartbeatAt sql.NullTime
+	err := row.Scan(&job.ID, &job.Kind, &job.Status, &params, &result, &errMsg,
+		&job.Progress.Stage, &job.Progress.Done, &job.Progress.Total,
+		&job.CreatedAt, &startedAt, &finishedAt, &auditRequest, &owner, &heartbeatAt)
+	if err != nil {
+		return nil, err
+	}
+	job.Params = json.RawMessage(params)
+	if result.Valid {
+		job.Result = &models.JobResult{}
+		if err := json.Unmarshal([]byte(result.String), job.Result); err != nil {
+			return nil, fmt.Errorf("解析工作 %s 的結果失敗: %v", job.ID, err)
+		}
+	}
+	job.Error = errMsg.String
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	job.Owner = owner.String
+	if heartbeatAt.Valid {
+		job.HeartbeatAt = &heartbeatAt.Time
+	}
+	if auditRequest.Valid {
+		job.AuditRequest = &models.AuditRequest{}
+		if err := json.Unmarshal([]byte(auditRequest.String), job.AuditRequest); err != nil {
+			return nil, fmt.Errorf("解析工作 %s 的請求資訊失敗: %v", job.ID, err)
+		}
+	}
+	return job, nil
+}
@@ -0,0 +1,1064 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang-gin-app/internal/models"
+)
+
+// memoryState 是 MemoryStore 的所有資料；保存的物件一律是副本且不在原處修改，
+// 因此交易快照只需複製各個 map 與 slice
+type memoryState struct {
+	users     map[int64]*models.User
+	roles     map[int64]*models.Role
+	userRoles map[int64][]int64 // user ID -> role IDs
+	slots     map[int64]*models.AvailableSlot
+	diseases  []*models.HistoryDisease
+
+	patients         map[int64]*models.Patient
+	historyDiseases  []models.PatientHistoryDisease
+	medicalHistories []models.PatientMedicalHistory
+
+	sequences  map[string]int64
+	batches    map[string]*models.GenerationBatch
+	batchItems map[string]map[string][]int64 // batch ID -> entity type -> entity IDs
+	jobs       map[string]*models.Job
+	audit      []*models.AuditEntry
+
+	nextUserID    int64
+	nextRoleID    int64
+	nextSlotID    int64
+	nextPatientID int64
+	nextAuditID   int64
+}
+
+// clone 複製目前狀態，用於交易失敗時還原
+func (s *memoryState) clone() *memoryState {
+	copied := *s
+	copied.users = make(map[int64]*models.User, len(s.users))
+	for id, user := range s.users {
+		copied.users[id] = user
+	}
+	copied.roles = make(map[int64]*models.Role, len(s.roles))
+	for id, role := range s.roles {
+		copied.roles[id] = role
+	}
+	copied.userRoles = make(map[int64][]int64, len(s.userRoles))
+	for id, roleIDs := range s.userRoles {
+		copied.userRoles[id] = roleIDs
+	}
+	copied.slots = make(map[int64]*models.AvailableSlot, len(s.slots))
+	for id, slot := range s.slots {
+		copied.slots[id] = slot
+	}
+	copied.patients = make(map[int64]*models.Patient, len(s.patients))
+	for id, patient := range s.patients {
+		copied.patients[id] = patient
+	}
+	copied.diseases = append([]*models.HistoryDisease(nil), s.diseases...)
+	copied.historyDiseases = append([]models.PatientHistoryDisease(nil), s.historyDiseases...)
+	copied.medicalHistories = append([]models.PatientMedicalHistory(nil), s.medicalHistories...)
+	copied.sequences = make(map[string]int64, len(s.sequences))
+	for prefix, last := range s.sequences {
+		copied.sequences[prefix] = last
+	}
+	copied.batches = make(map[string]*models.GenerationBatch, len(s.batches))
+	for id, batch := range s.batches {
+		copied.batches[id] = batch
+	}
+	copied.batchItems = make(map[string]map[string][]int64, len(s.batchItems))
+	for id, items := range s.batchItems {
+		copied.batchItems[id] = items
+	}
+	copied.jobs = make(map[string]*models.Job, len(s.jobs))
+	for id, job := range s.jobs {
+		copied.jobs[id] = job
+	}
+	copied.audit = append([]*models.AuditEntry(nil), s.audit...)
+	return &copied
+}
+
+// MemoryStore 是 Store 的記憶體實作，可同時被多個 goroutine 使用，供 service 單元測試使用。
+// 行為與 SQL 實作一致：預設角色與疾病目錄與遷移檔相同，帳號不可重複，找不到資料時回傳相同的錯誤。
+// WithTx 期間持有寫入鎖，其他 goroutine 的操作會等待交易結束；交易失敗時還原交易前的狀態。
+type MemoryStore struct {
+	mu    sync.RWMutex
+	state *memoryState
+}
+
+// memoryTxKey 是 context 中存放進行中記憶體交易的鍵，值為交易所屬 MemoryStore 的狀態
+type memoryTxKey struct{}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore 建立新的 MemoryStore，並載入與遷移檔相同的預設角色與疾病目錄
+func NewMemoryStore() *MemoryStore {
+	state := &memoryState{
+		users:      make(map[int64]*models.User),
+		roles:      make(map[int64]*models.Role),
+		userRoles:  make(map[int64][]int64),
+		slots:      make(map[int64]*models.AvailableSlot),
+		patients:   make(map[int64]*models.Patient),
+		sequences:  make(map[string]int64),
+		batches:    make(map[string]*models.GenerationBatch),
+		batchItems: make(map[string]map[string][]int64),
+		jobs:       make(map[string]*models.Job),
+	}
+
+	// 角色ID需與 utils 中的角色ID常數一致
+	defaultRoles := []struct {
+		alias       string
+		description string
+	}{
+		{"USER", "一般使用者"},
+		{"ADMIN", "管理員"},
+		{"DOCTOR", "醫師"},
+		{"DTX_PSY", "心理師"},
+		{"DTX_ST", "語言治療師"},
+		{"DTX_OT", "職能治療師"},
+		{"DTX_PI", "計畫主持人"},
+	}
+	for _, role := range defaultRoles {
+		description := role.description
+		state.nextRoleID++
+		state.roles[state.nextRoleID] = &models.Role{ID: state.nextRoleID, Alias: role.alias, Description: &description}
+	}
+
+	for i, name := range []string{"中樞神經損傷", "心血管疾病", "呼吸方面疾病", "肝臟疾病", "糖尿病", "腎臟病", "癌症", "免疫相關疾病"} {
+		state.diseases = append(state.diseases, &models.HistoryDisease{ID: int64(i + 1), DiseaseName: name})
+	}
+	return &MemoryStore{state: state}
+}
+
+// inTx 檢查 ctx 是否帶有此 MemoryStore 的交易（交易中已持有寫入鎖）
+func (s *MemoryStore) inTx(ctx context.Context) bool {
+	state, ok := ctx.Value(memoryTxKey{}).(*memoryState)
+	return ok && state == s.state
+}
+
+// lock 取得寫入鎖並回傳解鎖函式；交易中不重複加鎖
+func (s *MemoryStore) lock(ctx context.Context) func() {
+	if s.inTx(ctx) {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// rlock 取得讀取鎖並回傳解鎖函式；交易中不重複加鎖
+func (s *MemoryStore) rlock(ctx context.Context) func() {
+	if s.inTx(ctx) {
+		return func() {}
+	}
+	s.mu.RLock()
+	return s.mu.RUnlock
+}
+
+// Users 回傳使用者 Repository
+func (s *MemoryStore) Users() UserRepository {
+	return &memoryUserRepository{s}
+}
+
+// Roles 回傳角色 Repository
+func (s *MemoryStore) Roles() RoleRepository {
+	return &memoryRoleRepository{s}
+}
+
+// Slots 回傳可預約時段 Repository
+func (s *MemoryStore) Slots() SlotRepository {
+	return &memorySlotRepository{s}
+}
+
+// Patients 回傳病患 Repository
+func (s *MemoryStore) Patients() PatientRepository {
+	return &memoryPatientRepository{s}
+}
+
+// Batches 回傳生成批次 Repository
+func (s *MemoryStore) Batches() BatchRepository {
+	return &memoryBatchRepository{s}
+}
+
+// Jobs 回傳背景生成工作 Repository
+func (s *MemoryStore) Jobs() JobRepository {
+	return &memoryJobRepository{s}
+}
+
+// Audit 回傳稽核紀錄 Repository
+func (s *MemoryStore) Audit() AuditRepository {
+	return &memoryAuditRepository{s}
+}
+
+// WithTx 在持有寫入鎖的情況下執行 fn；fn 回傳錯誤、panic 或 ctx 被取消時還原交易前的狀態
+func (s *MemoryStore) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.inTx(ctx) {
+		return fn(ctx)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.state.clone()
+	committed := false
+	defer func() {
+		if !committed {
+			*s.state = *snapshot
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, s.state)); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("交易已取消: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+// copyUser 回傳使用者的副本，避免呼叫端修改內部資料
+func copyUser(user *models.User) *models.User {
+	copied := *user
+	copied.Roles = nil
+	return &copied
+}
+
+// normalizeSlot 與 SQL 實作一致，只保留日期與時段的時、分、秒
+func normalizeSlot(slot *models.AvailableSlot) *models.AvailableSlot {
+	date := slot.SlotDate
+	clock := func(t time.Time) time.Time {
+		return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+	}
+	return &models.AvailableSlot{
+		ID:            slot.ID,
+		Doctor:        slot.Doctor,
+		IsBooked:      slot.IsBooked,
+		SlotBeginTime: clock(slot.SlotBeginTime),
+		SlotDate:      time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local),
+		SlotEndTime:   clock(slot.SlotEndTime),
+	}
+}
+
+// memoryUserRepository 是 UserRepository 的記憶體實作
+type memoryUserRepository struct {
+	*MemoryStore
+}
+
+// accountExists 檢查帳號是否已存在，呼叫端需持有鎖
+func (r *memoryUserRepository) accountExists(account string) bool {
+	for _, user := range r.state.users {
+		if user.Account == account {
+			return true
+		}
+	}
+	return false
+}
+
+// Create 新增使用者
+func (r *memoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	defer r.lock(ctx)()
+	if r.accountExists(user.Account) {
+		return models.Conflictf("帳號 %s 已存在", user.Account)
+	}
+	r.state.nextUserID++
+	user.ID = r.state.nextUserID
+	r.state.users[user.ID] = copyUser(user)
+	return nil
+}
+
+// GetByID 依ID獲取使用者，找不到時回傳 models.ErrNotFound
+func (r *memoryUserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+	userID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, models.NotFoundf("未找到ID為 %s 的使用者", id)
+	}
+	defer r.rlock(ctx)()
+	user, ok := r.state.users[userID]
+	if !ok {
+		return nil, models.NotFoundf("未找到ID為 %s 的使用者", id)
+	}
+	return copyUser(user), nil
+}
+
+// GetByAccount 依帳號獲取使用者，找不到時回傳 models.ErrNotFound
+func (r *memoryUserRepository) GetByAccount(ctx context.Context, account string) (*models.User, error) {
+	defer r.rlock(ctx)()
+	for _, user := range r.state.users {
+		if user.Account == account {
+			return copyUser(user), nil
+		}
+	}
+	return nil, models.NotFoundf("未找到帳號為 %s 的使用者", account)
+}
+
+// Update 更新使用者，找不到時不做任何事
+func (r *memoryUserRepository) Update(ctx context.Context, user *models.User) error {
+	defer r.lock(ctx)()
+	if _, ok := r.state.users[user.ID]; ok {
+		r.state.users[user.ID] = copyUser(user)
+	}
+	return nil
+}
+
+// Delete 刪除使用者，找不到時不做任何事
+func (r *memoryUserRepository) Delete(ctx context.Context, id string) error {
+	userID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil
+	}
+	defer r.lock(ctx)()
+	delete(r.state.users, userID)
+	return nil
+}
+
+// BatchCreateUsers 批量新增使用者並回傳ID；任一帳號重複時不新增任何使用者
+func (r *memoryUserRepository) BatchCreateUsers(ctx context.Context, users []*models.User) ([]int64, error) {
+	defer r.lock(ctx)()
+
+	accounts := make(map[string]bool, len(users))
+	for _, user := range users {
+		if accounts[user.Account] || r.accountExists(user.Account) {
+			return nil, models.Conflictf("帳號 %s 已存在", user.Account)
+		}
+		accounts[user.Account] = true
+	}
+
+	userIDs := make([]int64, 0, len(users))
+	for _, user := range users {
+		r.state.nextUserID++
+		stored := copyUser(user)
+		stored.ID = r.state.nextUserID
+		r.state.users[stored.ID] = stored
+		userIDs = append(userIDs, stored.ID)
+	}
+	return userIDs, nil
+}
+
+// sortedUsers 依ID由新到舊排序使用者並附上角色，呼叫端需持有鎖
+func (r *memoryUserRepository) sortedUsers(filter func(*models.User) bool) []*models.User {
+	users := make([]*models.User, 0, len(r.state.users))
+	for _, user := range r.state.users {
+		if filter == nil || filter(user) {
+			users = append(users, copyUser(user))
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID > users[j].ID })
+	return users
+}
+
+// ListUsers 獲取最新的使用者列表
+func (r *memoryUserRepository) ListUsers(ctx context.Context, limit int) ([]*models.User, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	defer r.rlock(ctx)()
+	users := r.sortedUsers(nil)
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+// ListUsersWithRoles 獲取包含角色資訊的使用者列表
+func (r *memoryUserRepository) ListUsersWithRoles(ctx context.Context, limit int) ([]*models.User, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	defer r.rlock(ctx)()
+	users := r.sortedUsers(nil)
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	for _, user := range users {
+		user.Roles = memoryUserRoles(r.state, user.ID)
+	}
+	return users, nil
+}
+
+// GetUserByRoleID 獲取具有指定角色的使用者
+func (r *memoryUserRepository) GetUserByRoleID(ctx context.Context, roleID int64) ([]*models.User, error) {
+	defer r.rlock(ctx)()
+	users := r.sortedUsers(func(user *models.User) bool {
+		for _, id := range r.state.userRoles[user.ID] {
+			if id == roleID {
+				return true
+			}
+		}
+		return false
+	})
+	for _, user := range users {
+		user.Roles = memoryUserRoles(r.state, user.ID)
+	}
+	return users, nil
+}
+
+// ReserveAccountNumbers 保留 count 個連續的帳號編號，回傳第一個編號；
+// 與 SQL 實作相同，會略過 user 中已存在的最大編號
+func (r *memoryUserRepository) ReserveAccountNumbers(ctx context.Context, prefix string, count int) (int64, error) {
+	if prefix == "" {
+		return 0, models.Validationf("帳號前綴不可為空")
+	}
+	if count <= 0 {
+		return 0, models.Validationf("保留數量必須大於0")
+	}
+
+	defer r.lock(ctx)()
+
+	last := r.state.sequences[prefix]
+	for _, user := range r.state.users {
+		if !strings.HasPrefix(user.Account, prefix) {
+			continue
+		}
+		// 與 SQL 實作相同，只比較前綴後為純數字的帳號
+		digits := user.Account[len(prefix):]
+		if strings.Trim(digits, "0123456789") != "" {
+			continue
+		}
+		if number, err := strconv.ParseInt(digits, 10, 64); err == nil && number > last {
+			last = number
+		}
+	}
+	last += int64(count)
+	r.state.sequences[prefix] = last
+	return last - int64(count) + 1, nil
+}
+
+// memoryRoleRepository 是 RoleRepository 的記憶體實作
+type memoryRoleRepository struct {
+	*MemoryStore
+}
+
+// memoryUserRoles 獲取使用者的角色，呼叫端需持有鎖
+func memoryUserRoles(state *memoryState, userID int64) []*models.Role {
+	roles := make([]*models.Role, 0)
+	for _, roleID := range state.userRoles[userID] {
+		if role, ok := state.roles[roleID]; ok {
+			copied := *role
+			roles = append(roles, &copied)
+		}
+	}
+	return roles
+}
+
+// ListAllRoles 獲取所有角色
+func (r *memoryRoleRepository) ListAllRoles(ctx context.Context) ([]*models.Role, error) {
+	defer r.rlock(ctx)()
+	roles := make([]*models.Role, 0, len(r.state.roles))
+	for _, role := range r.state.roles {
+		copied := *role
+		roles = append(roles, &copied)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].ID < roles[j].ID })
+	return roles, nil
+}
+
+// AddRole 新增角色並回傳新角色的ID
+func (r *memoryRoleRepository) AddRole(ctx context.Context, alias, description string) (int64, error) {
+	defer r.lock(ctx)()
+	r.state.nextRoleID++
+	r.state.roles[r.state.nextRoleID] = &models.Role{ID: r.state.nextRoleID, Alias: alias, Description: &description}
+	return r.state.nextRoleID, nil
+}
+
+// DeleteRole 刪除角色與指派給使用者的該角色，找不到時回傳 models.ErrNotFound
+func (r *memoryRoleRepository) DeleteRole(ctx context.Context, roleID int64) error {
+	defer r.lock(ctx)()
+	if _, ok := r.state.roles[roleID]; !ok {
+		return models.NotFoundf("未找到ID為 %d 的角色", roleID)
+	}
+	delete(r.state.roles, roleID)
+	for userID, roleIDs := range r.state.userRoles {
+		kept := slices.DeleteFunc(append([]int64(nil), roleIDs...), func(id int64) bool { return id == roleID })
+		if len(kept) == 0 {
+			delete(r.state.userRoles, userID)
+		} else {
+			r.state.userRoles[userID] = kept
+		}
+	}
+	return nil
+}
+
+// AssignRoleToUser 以新的角色取代使用者現有的角色
+func (r *memoryRoleRepository) AssignRoleToUser(ctx context.Context, userID int64, roleIDs []int64) error {
+	defer r.lock(ctx)()
+	if len(roleIDs) == 0 {
+		delete(r.state.userRoles, userID)
+		return nil
+	}
+	r.state.userRoles[userID] = append([]int64(nil), roleIDs...)
+	return nil
+}
+
+// GetUserRoles 獲取使用者的角色
+func (r *memoryRoleRepository) GetUserRoles(ctx context.Context, userID int64) ([]*models.Role, error) {
+	defer r.rlock(ctx)()
+	return memoryUserRoles(r.state, userID), nil
+}
+
+// memorySlotRepository 是 SlotRepository 的記憶體實作
+type memorySlotRepository struct {
+	*MemoryStore
+}
+
+// BatchCreateAvailableSlots 批量新增可預約時段並回填ID
+func (r *memorySlotRepository) BatchCreateAvailableSlots(ctx context.Context, slots []*models.AvailableSlot) error {
+	defer r.lock(ctx)()
+	for _, slot := range slots {
+		r.state.nextSlotID++
+		slot.ID = r.state.nextSlotID
+		r.state.slots[slot.ID] = normalizeSlot(slot)
+	}
+	return nil
+}
+
+// GetAvailableSlotsByDoctor 獲取醫師的可預約時段，依日期與開始時間排序
+func (r *memorySlotRepository) GetAvailableSlotsByDoctor(ctx context.Context, doctorID int64) ([]*models.AvailableSlot, error) {
+	defer r.rlock(ctx)()
+	slots := make([]*models.AvailableSlot, 0)
+	for _, slot := range r.state.slots {
+		if slot.Doctor == doctorID {
+			copied := *slot
+			slots = append(slots, &copied)
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		if !slots[i].SlotBeginTime.Equal(slots[j].SlotBeginTime) {
+			return slots[i].SlotBeginTime.Before(slots[j].SlotBeginTime)
+		}
+		return slots[i].ID < slots[j].ID
+	})
+	return slots, nil
+}
+
+// GetAvailableSlotByID 通過ID獲取時段
+func (r *memorySlotRepository) GetAvailableSlotByID(ctx context.Context, slotID int64) (*models.AvailableSlot, error) {
+	defer r.rlock(ctx)()
+	slot, ok := r.state.slots[slotID]
+	if !ok {
+		return nil, models.NotFoundf("未找到ID為 %d 的時段", slotID)
+	}
+	copied := *slot
+	return &copied, nil
+}
+
+// UpdateAvailableSlot 更新可預約時段，找不到時不做任何事
+func (r *memorySlotRepository) UpdateAvailableSlot(ctx context.Context, slot *models.AvailableSlot) error {
+	defer r.lock(ctx)()
+	if _, ok := r.state.slots[slot.ID]; ok {
+		r.state.slots[slot.ID] = normalizeSlot(slot)
+	}
+	return nil
+}
+
+// DeleteAvailableSlot 刪除未被預約的可預約時段，已被預約時回傳 models.ErrConflict
+func (r *memorySlotRepository) DeleteAvailableSlot(ctx context.Context, slotID int64) error {
+	defer r.lock(ctx)()
+	slot, ok := r.state.slots[slotID]
+	if !ok {
+		return models.NotFoundf("未找到ID為 %d 的時段", slotID)
+	}
+	if slot.IsBooked {
+		return models.Conflictf("該時段已被預約，無法刪除")
+	}
+	delete(r.state.slots, slotID)
+	return nil
+}
+
+// memoryPatientRepository 是 PatientRepository 的記憶體實作
+type memoryPatientRepository struct {
+	*MemoryStore
+}
+
+// CreatePatient 新增病患並回填 patient.ID
+func (r *memoryPatientRepository) CreatePatient(ctx context.Context, patient *models.Patient) (int64, error) {
+	defer r.lock(ctx)()
+	r.state.nextPatientID++
+	patient.ID = r.state.nextPatientID
+	stored := *patient
+	stored.HistoryDiseases = nil
+	stored.MedicalHistories = nil
+	r.state.patients[stored.ID] = &stored
+	return patient.ID, nil
+}
+
+// AddHistoryDisease 新增病患的病史資料
+func (r *memoryPatientRepository) AddHistoryDisease(ctx context.Context, patientID int64, historyDisease string, diseaseID int64) error {
+	defer r.lock(ctx)()
+	r.state.historyDiseases = append(r.state.historyDiseases, models.PatientHistoryDisease{
+		PatientID: patientID, HistoryDisease: historyDisease, DiseaseID: diseaseID,
+	})
+	return nil
+}
+
+// AddMedicalHistory 新增病患的醫療史資料
+func (r *memoryPatientRepository) AddMedicalHistory(ctx context.Context, patientID int64, medicalHistory string) error {
+	defer r.lock(ctx)()
+	r.state.medicalHistories = append(r.state.medicalHistories, models.PatientMedicalHistory{
+		PatientID: patientID, MedicalHistory: medicalHistory,
+	})
+	return nil
+}
+
+// ListPatients 獲取最新的病患列表（不含病史與醫療史）
+func (r *memoryPatientRepository) ListPatients(ctx context.Context, limit int) ([]*models.Patient, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	defer r.rlock(ctx)()
+	patients := make([]*models.Patient, 0, len(r.state.patients))
+	for _, patient := range r.state.patients {
+		copied := *patient
+		patients = append(patients, &copied)
+	}
+	sort.Slice(patients, func(i, j int) bool { return patients[i].ID > patients[j].ID })
+	if len(patients) > limit {
+		patients = patients[:limit]
+	}
+	return patients, nil
+}
+
+// GetPatient 獲取病患及其病史、醫療史，找不到時回傳 models.ErrNotFound
+func (r *memoryPatientRepository) GetPatient(ctx context.Context, id int64) (*models.Patient, error) {
+	defer r.rlock(ctx)()
+	patient, ok := r.state.patients[id]
+	if !ok {
+		return nil, models.NotFoundf("未找到ID為 %d 的病患", id)
+	}
+	copied := *patient
+	copied.HistoryDiseases = make([]string, 0)
+	for _, row := range r.state.historyDiseases {
+		if row.PatientID == id {
+			copied.HistoryDiseases = append(copied.HistoryDiseases, row.HistoryDisease)
+		}
+	}
+	copied.MedicalHistories = make([]string, 0)
+	for _, row := range r.state.medicalHistories {
+		if row.PatientID == id {
+			copied.MedicalHistories = append(copied.MedicalHistories, row.MedicalHistory)
+		}
+	}
+	return &copied, nil
+}
+
+// PatientExistsByIDNo 檢查是否已有相同身分證字號的病患
+func (r *memoryPatientRepository) PatientExistsByIDNo(ctx context.Context, idno string) (bool, error) {
+	defer r.rlock(ctx)()
+	for _, patient := range r.state.patients {
+		if patient.IDNo == idno {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ListPatientIDs 依ID順序獲取所有病患的ID
+func (r *memoryPatientRepository) ListPatientIDs(ctx context.Context) ([]int64, error) {
+	defer r.rlock(ctx)()
+	ids := make([]int64, 0, len(r.state.patients))
+	for id := range r.state.patients {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// UpdatePatient 更新病患主資料（不含病史與醫療史），找不到時回傳 models.ErrNotFound
+func (r *memoryPatientRepository) UpdatePatient(ctx context.Context, patient *models.Patient) error {
+	defer r.lock(ctx)()
+	if _, ok := r.state.patients[patient.ID]; !ok {
+		return models.NotFoundf("未找到ID為 %d 的病患", patient.ID)
+	}
+	stored := *patient
+	stored.HistoryDiseases = nil
+	stored.MedicalHistories = nil
+	r.state.patients[stored.ID] = &stored
+	return nil
+}
+
+// ListHistoryDiseases 獲取疾病目錄
+func (r *memoryPatientRepository) ListHistoryDiseases(ctx context.Context) ([]*models.HistoryDisease, error) {
+	defer r.rlock(ctx)()
+	diseases := make([]*models.HistoryDisease, 0, len(r.state.diseases))
+	for _, disease := range r.state.diseases {
+		copied := *disease
+		diseases = append(diseases, &copied)
+	}
+	return diseases, nil
+}
+
+// memoryBatchRepository 是 BatchRepository 的記憶體實作
+type memoryBatchRepository struct {
+	*MemoryStore
+}
+
+// RecordGenerationBatch 記錄一次生成批次及其建立的資料
+func (r *memoryBatchRepository) RecordGenerationBatch(ctx context.Context, batch *models.GenerationBatch, entityType string, entityIDs []int64) error {
+	defer r.lock(ctx)()
+	if _, ok := r.state.batches[batch.ID]; ok {
+		return models.Conflictf("建立批次紀錄失敗: 批次 %s 已存在", batch.ID)
+	}
+	r.state.batches[batch.ID] = &models.GenerationBatch{ID: batch.ID, Kind: batch.Kind, CreatedAt: batch.CreatedAt}
+	r.state.batchItems[batch.ID] = map[string][]int64{entityType: append([]int64(nil), entityIDs...)}
+	return nil
+}
+
+// AddGenerationBatchItems 為已存在的批次加入另一種實體類型的資料
+func (r *memoryBatchRepository) AddGenerationBatchItems(ctx context.Context, batchID string, entityType string, entityIDs []int64) error {
+	if len(entityIDs) == 0 {
+		return nil
+	}
+	defer r.lock(ctx)()
+	// 交易快照與目前狀態共用內層 map，因此複製後再修改
+	items := make(map[string][]int64, len(r.state.batchItems[batchID])+1)
+	for existingType, ids := range r.state.batchItems[batchID] {
+		items[existingType] = ids
+	}
+	items[entityType] = append(append([]int64(nil), items[entityType]...), entityIDs...)
+	r.state.batchItems[batchID] = items
+	return nil
+}
+
+// ListGenerationBatches 獲取最近的生成批次及各實體類型的筆數
+func (r *memoryBatchRepository) ListGenerationBatches(ctx context.Context, limit int) ([]*models.GenerationBatch, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	defer r.rlock(ctx)()
+	batches := make([]*models.GenerationBatch, 0, len(r.state.batches))
+	for id, batch := range r.state.batches {
+		copied := &models.GenerationBatch{ID: batch.ID, Kind: batch.Kind, CreatedAt: batch.CreatedAt, Counts: make(map[string]int)}
+		for entityType, ids := range r.state.batchItems[id] {
+			copied.Counts[entityType] = len(ids)
+		}
+		batches = append(batches, copied)
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].CreatedAt.After(batches[j].CreatedAt) })
+	if len(batches) > limit {
+		batches = batches[:limit]
+	}
+	return batches, nil
+}
+
+// PurgeGenerationBatch 刪除批次建立的資料，步驟與 SQL 實作相同；dryRun 時只計算筆數
+func (r *memoryBatchRepository) PurgeGenerationBatch(ctx context.Context, batchID string, dryRun bool) (*models.BatchPurgeResult, error) {
+	defer r.lock(ctx)()
+	state := r.state
+	if _, ok := state.batches[batchID]; !ok {
+		return nil, models.NotFoundf("未找到ID為 %s 的批次", batchID)
+	}
+
+	items := state.batchItems[batchID]
+	inBatch := func(entityType string, id int64) bool {
+		for _, itemID := range items[entityType] {
+			if itemID == id {
+				return true
+			}
+		}
+		return false
+	}
+
+	var userRoles, historyDiseases, medicalHistories, patients, slots, users, batchItems int64
+	for userID, roleIDs := range state.userRoles {
+		if inBatch(models.BatchEntityUser, userID) {
+			userRoles += int64(len(roleIDs))
+			if !dryRun {
+				delete(state.userRoles, userID)
+			}
+		}
+	}
+
+	keptHistoryDiseases := make([]models.PatientHistoryDisease, 0, len(state.historyDiseases))
+	for _, row := range state.historyDiseases {
+		if inBatch(models.BatchEntityPatient, row.PatientID) {
+			historyDiseases++
+		} else {
+			keptHistoryDiseases = append(keptHistoryDiseases, row)
+		}
+	}
+	keptMedicalHistories := make([]models.PatientMedicalHistory, 0, len(state.medicalHistories))
+	for _, row := range state.medicalHistories {
+		if inBatch(models.BatchEntityPatient, row.PatientID) {
+			medicalHistories++
+		} else {
+			keptMedicalHistories = append(keptMedicalHistories, row)
+		}
+	}
+	if !dryRun {
+		state.historyDiseases = keptHistoryDiseases
+		state.medicalHistories = keptMedicalHistories
+	}
+
+	for patientID := range state.patients {
+		if inBatch(models.BatchEntityPatient, patientID) {
+			patients++
+			if !dryRun {
+				delete(state.patients, patientID)
+			}
+		}
+	}
+	for slotID, slot := range state.slots {
+		if inBatch(models.BatchEntitySlot, slotID) || inBatch(models.BatchEntityUser, slot.Doctor) {
+			slots++
+			if !dryRun {
+				delete(state.slots, slotID)
+			}
+		}
+	}
+	for userID := range state.users {
+		if inBatch(models.BatchEntityUser, userID) {
+			users++
+			if !dryRun {
+				delete(state.users, userID)
+			}
+		}
+	}
+	for _, ids := range items {
+		batchItems += int64(len(ids))
+	}
+	if !dryRun {
+		delete(state.batchItems, batchID)
+		delete(state.batches, batchID)
+	}
+
+	return &models.BatchPurgeResult{
+		BatchID: batchID,
+		DryRun:  dryRun,
+		Counts: []models.BatchPurgeStep{
+			{Table: "user_role", Rows: userRoles},
+			{Table: "patient_history_disease", Rows: historyDiseases},
+			{Table: "patient_medical_history", Rows: medicalHistories},
+			{Table: "patient", Rows: patients},
+			{Table: "wg_available_slots", Rows: slots},
+			{Table: "user", Rows: users},
+			{Table: "generation_batch_item", Rows: batchItems},
+			{Table: "generation_batch", Rows: 1},
+		},
+	}, nil
+}
+
+// memoryJobRepository 是 JobRepository 的記憶體實作
+type memoryJobRepository struct {
+	*MemoryStore
+}
+
+// copyJob 複製工作，指標欄位也一併複製
+func copyJob(job *models.Job) *models.Job {
+	copied := *job
+	copied.Params = append([]byte(nil), job.Params...)
+	if job.Result != nil {
+		result := *job.Result
+		result.Skipped = append([]string(nil), job.Result.Skipped...)
+		copied.Result = &result
+	}
+	if job.StartedAt != nil {
+		startedAt := *job.StartedAt
+		copied.StartedAt = &startedAt
+	}
+	if job.FinishedAt != nil {
+		finishedAt := *job.FinishedAt
+		copied.FinishedAt = &finishedAt
+	}
+	if job.HeartbeatAt != nil {
+		heartbeatAt := *job.HeartbeatAt
+		copied.HeartbeatAt = &heartbeatAt
+	}
+	if job.AuditRequest != nil {
+		auditRequest := *job.AuditRequest
+		copied.AuditRequest = &auditRequest
+	}
+	return &copied
+}
+
+// CreateJob 新增工作
+func (r *memoryJobRepository) CreateJob(ctx context.Context, job *models.Job) error {
+	defer r.lock(ctx)()
+	if _, ok := r.state.jobs[job.ID]; ok {
+		return models.Conflictf("建立工作失敗: 工作 %s 已存在", job.ID)
+	}
+	created := copyJob(job)
+	created.Result, created.Error, created.StartedAt, created.FinishedAt = nil, "", nil, nil
+	created.Owner, created.HeartbeatAt = "", nil
+	created.Progress = models.JobProgress{Total: job.Progress.Total}
+	r.state.jobs[job.ID] = created
+	return nil
+}
+
+// GetJob 依ID取得工作
+func (r *memoryJobRepository) GetJob(ctx context.Context, id string) (*models.Job, error) {
+	defer r.rlock(ctx)()
+	job, ok := r.state.jobs[id]
+	if !ok {
+		return nil, models.NotFoundf("未找到ID為 %s 的工作", id)
+	}
+	return copyJob(job), nil
+}
+
+// ListJobs 獲取最近的工作
+func (r *memoryJobRepository) ListJobs(ctx context.Context, limit int) ([]*models.Job, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	jobs := r.sortedJobs(ctx, func(*models.Job) bool { return true })
+	for i, j := 0, len(jobs)-1; i < j; i, j = i+1, j-1 {
+		jobs[i], jobs[j] = jobs[j], jobs[i]
+	}
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+// ListJobsByStatus 依建立順序獲取指定狀態的工作
+func (r *memoryJobRepository) ListJobsByStatus(ctx context.Context, statuses ...string) ([]*models.Job, error) {
+	return r.sortedJobs(ctx, func(job *models.Job) bool {
+		for _, status := range statuses {
+			if job.Status == status {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+// sortedJobs 依建立時間與ID排序回傳符合條件的工作副本
+func (r *memoryJobRepository) sortedJobs(ctx context.Context, match func(*models.Job) bool) []*models.Job {
+	defer r.rlock(ctx)()
+	jobs := make([]*models.Job, 0, len(r.state.jobs))
+	for _, job := range r.state.jobs {
+		if match(job) {
+			jobs = append(jobs, copyJob(job))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs
+}
+
+// updateJob 在工作狀態為 from（空字串表示不限）且 match 回傳 true（nil 表示不限）時以 update 修改工作副本後寫回
+func (r *memoryJobRepository) updateJob(ctx context.Context, id, from, conflict string, match func(job *models.Job) bool, update func(job *models.Job)) error {
+	defer r.lock(ctx)()
+	job, ok := r.state.jobs[id]
+	if !ok {
+		return models.NotFoundf("未找到ID為 %s 的工作", id)
+	}
+	if (from != "" && job.Status != from) || (match != nil && !match(job)) {
+		return models.Conflictf(conflict, id)
+	}
+	updated := copyJob(job)
+	update(updated)
+	r.state.jobs[id] = updated
+	return nil
+}
+
+// ownedBy 回傳判斷工作是否由 owner 執行的 match 函式
+func ownedBy(owner string) func(job *models.Job) bool {
+	return func(job *models.Job) bool { return job.Owner == owner }
+}
+
+// StartJob 將排隊中的工作標記為由 owner 執行中
+func (r *memoryJobRepository) StartJob(ctx context.Context, id, owner string, startedAt time.Time) error {
+	return r.updateJob(ctx, id, models.JobStatusQueued, "工作 %s 不是排隊中，無法開始", nil, func(job *models.Job) {
+		job.Status, job.StartedAt = models.JobStatusRunning, &startedAt
+		job.Owner, job.HeartbeatAt = owner, &startedAt
+	})
+}
+
+// UpdateJobProgress 記錄 owner 執行中工作的進度並更新心跳時間
+func (r *memoryJobRepository) UpdateJobProgress(ctx context.Context, id, owner string, progress models.JobProgress, heartbeatAt time.Time) error {
+	return r.updateJob(ctx, id, models.JobStatusRunning, "工作 %s 不是由此程序執行中，無法更新進度", ownedBy(owner), func(job *models.Job) {
+		job.Progress, job.HeartbeatAt = progress, &heartbeatAt
+	})
+}
+
+// FinishJob 記錄 job.Owner 執行的工作的結束狀態、結果、錯誤訊息與最後的進度
+func (r *memoryJobRepository) FinishJob(ctx context.Context, job *models.Job) error {
+	finished := copyJob(job)
+	return r.updateJob(ctx, job.ID, "", "工作 %s 已由其他程序接手，無法記錄結果", ownedBy(job.Owner), func(stored *models.Job) {
+		stored.Status, stored.Result, stored.Error = finished.Status, finished.Result, finished.Error
+		stored.Progress, stored.FinishedAt = finished.Progress, finished.FinishedAt
+	})
+}
+
+// CancelQueuedJob 取消排隊中的工作
+func (r *memoryJobRepository) CancelQueuedJob(ctx context.Context, id string, finishedAt time.Time) error {
+	return r.updateJob(ctx, id, models.JobStatusQueued, "工作 %s 不是排隊中，無法直接取消", nil, func(job *models.Job) {
+		job.Status, job.FinishedAt = models.JobStatusCancelled, &finishedAt
+	})
+}
+
+// RequeueJob 將 owner 執行中的工作放回佇列，進度歸零
+func (r *memoryJobRepository) RequeueJob(ctx context.Context, id, owner string) error {
+	return r.updateJob(ctx, id, models.JobStatusRunning, "工作 %s 不是由此程序執行中，無法放回佇列", ownedBy(owner), requeue)
+}
+
+// RequeueStaleJob 將心跳早於 staleBefore（或沒有心跳）的執行中工作放回佇列，進度歸零
+func (r *memoryJobRepository) RequeueStaleJob(ctx context.Context, id string, staleBefore time.Time) error {
+	stale := func(job *models.Job) bool {
+		return job.HeartbeatAt == nil || job.HeartbeatAt.Before(staleBefore)
+	}
+	return r.updateJob(ctx, id, models.JobStatusRunning, "工作 %s 仍由其他程序執行中，無法放回佇列", stale, requeue)
+}
+
+// requeue 將工作改回排隊中，清除執行的程序與進度
+func requeue(job *models.Job) {
+	job.Status, job.StartedAt = models.JobStatusQueued, nil
+	job.Owner, job.HeartbeatAt = "", nil
+	job.Progress = models.JobProgress{Total: job.Progress.Total}
+}
+
+// memoryAuditRepository 是 AuditRepository 的記憶體實作
+type memoryAuditRepository struct {
+	*MemoryStore
+}
+
+// copyAuditEntry 複製稽核紀錄，JSON 快照與異動也一併複製
+func copyAuditEntry(entry *models.AuditEntry) *models.AuditEntry {
+	copied := *entry
+	copied.Before = append(json.RawMessage(nil), entry.Before...)
+	copied.After = append(json.RawMessage(nil), entry.After...)
+	copied.Changes = append([]models.AuditChange(nil), entry.Changes...)
+	return &copied
+}
+
+// RecordAudit 新增稽核紀錄並回填 entry.ID
+func (r *memoryAuditRepository) RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	defer r.lock(ctx)()
+	r.state.nextAuditID++
+	entry.ID = r.state.nextAuditID
+	r.state.audit = append(r.state.audit, copyAuditEntry(entry))
+	return nil
+}
+
+// ListAudit 依條件由新到舊獲取稽核紀錄
+func (r *memoryAuditRepository) ListAudit(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	defer r.rlock(ctx)()
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+	entries := make([]*models.AuditEntry, 0)
+	for i := len(r.state.audit) - 1; i >= 0 && len(entries) < filter.Limit; i-- {
+		entry := r.state.audit[i]
+		switch {
+		case filter.Actor != "" && entry.Actor != filter.Actor,
+			filter.Action != "" && entry.Action != filter.Action,
+			filter.EntityType != "" && entry.EntityType != filter.EntityType,
+			filter.EntityID != "" && entry.EntityID != filter.EntityID,
+			!filter.From.IsZero() && entry.CreatedAt.Before(filter.From),
+			!filter.To.IsZero() && !entry.CreatedAt.Before(filter.To):
+			continue
+		}
+		entries = append(entries, copyAuditEntry(entry))
+	}
+	return entries, nil
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"golang-gin-app/internal/models"
+)
+
+// patientColumns 讀取病患時的欄位，順序與 scanPatient 一致
+const patientColumns = `ID, user_id, name, gender, idno, age, birth, address, city, district,
+	phone, mail, disease_id, emergency_contact, emergency_phone, emergency_relation,
+	OTHERHISTORYDISEASE, OTHERMEDICALHISTORY`
+
+// rowScanner 是 *sql.Row 與 *sql.Rows 共同的 Scan 方法
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPatient 讀取一筆病患資料，NULL 欄位視為零值
+func scanPatient(row rowScanner) (*models.Patient, error) {
+	var (
+		userID, age, diseaseID                       sql.NullInt64
+		birth                                        sql.NullTime
+		name, gender, idno, address, city, district  sql.NullString
+		phone, mail, contact, contactPhone, relation sql.NullString
+		otherHistoryDisease, otherMedicalHistory     sql.NullString
+	)
+	patient := &models.Patient{}
+	err := row.Scan(&patient.ID, &userID, &name, &gender, &idno, &age, &birth, &address, &city, &district,
+		&phone, &mail, &diseaseID, &contact, &contactPhone, &relation,
+		&otherHistoryDisease, &otherMedicalHistory)
+	if err != nil {
+		return nil, err
+	}
+	patient.UserID = userID.Int64
+	patient.Name = name.String
+	patient.Gender = gender.String
+	patient.IDNo = idno.String
+	patient.Age = int(age.Int64)
+	patient.Birth = birth.Time
+	patient.Address = address.String
+	patient.City = city.String
+	patient.District = district.String
+	patient.Phone = phone.String
+	patient.Mail = mail.String
+	patient.DiseaseID = diseaseID.Int64
+	patient.EmergencyContact = contact.String
+	patient.EmergencyPhone = contactPhone.String
+	patient.EmergencyRelation = relation.String
+	patient.OtherHistoryDisease = otherHistoryDisease.String
+	patient.OtherMedicalHistory = otherMedicalHistory.String
+	return patient, nil
+}
+
+// sqlPatientRepository 是 PatientRepository 的 SQL 實作
+type sqlPatientRepository struct {
+	*sqlConn
+}
+
+// CreatePatient 新增病患並回填 patient.ID
+func (r *sqlPatientRepository) CreatePatient(ctx context.Context, patient *models.Patient) (int64, error) {
+	res, err := r.db(ctx, "patients.CreatePatient").ExecContext(ctx, `
+		INSERT INTO patient (name, gender, idno, age, birth, address, city, district,
+			phone, mail, disease_id, emergency_contact, emergency_phone, emergency_relation,
+			OTHERHISTORYDISEASE, OTHERMEDICALHISTORY, user_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		patient.Name, patient.Gender, patient.IDNo, patient.Age, patient.Birth,
+		patient.Address, patient.City, patient.District, patient.Phone, patient.Mail,
+		patient.DiseaseID, patient.EmergencyContact, patient.EmergencyPhone,
+		patient.EmergencyRelation, patient.OtherHistoryDisease, patient.OtherMedicalHistory,
+		patient.UserID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("插入病患 %s 失敗: %v", patient.Name, err)
+	}
+
+	patient.ID, err = res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("獲取病患ID失敗: %v", err)
+	}
+	return patient.ID, nil
+}
+
+// AddHistoryDisease 新增病患的病史資料
+func (r *sqlPatientRepository) AddHistoryDisease(ctx context.Context, patientID int64, historyDisease string, diseaseID int64) error {
+	_, err := r.db(ctx, "patients.AddHistoryDisease").ExecContext(ctx, `
+		INSERT INTO patient_history_disease (patient_id, history_disease, disease_id)
+		VALUES (?, ?, ?)`,
+		patientID, historyDisease, diseaseID,
+	)
+	if err != nil {
+		return fmt.Errorf("插入病患 %d 的病史資料失敗: %v", patientID, err)
+	}
+	return nil
+}
+
+// AddMedicalHistory 新增病患的醫療史資料
+func (r *sqlPatientRepository) AddMedicalHistory(ctx context.Context, patientID int64, medicalHistory string) error {
+	_, err := r.db(ctx, "patients.AddMedicalHistory").ExecContext(ctx, `
+		INSERT INTO patient_medical_history (patient_id, medical_history)
+		VALUES (?, ?)`,
+		patientID, medicalHistory,
+	)
+	if err != nil {
+		return fmt.Errorf("插入病患 %d 的醫療史資料失敗: %v", patientID, err)
+	}
+	return nil
+}
+
+// ListPatients 獲取最新的病患列表（不含病史與醫療史）
+func (r *sqlPatientRepository) ListPatients(ctx context.Context, limit int) ([]*models.Patient, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	rows, err := r.db(ctx, "patients.ListPatients").QueryContext(ctx,
+		`SELECT `+patientColumns+` FROM patient ORDER BY ID DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("獲取病患列表失敗: %v", err)
+	}
+	defer rows.Close()
+
+	patients := make([]*models.Patient, 0)
+	for rows.Next() {
+		patient, err := scanPatient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("掃描病患數據失敗: %v", err)
+		}
+		patients = append(patients, patient)
+	}
+	return patients, rows.Err()
+}
+
+// GetPatient 取得病患及其病史、醫療史，找不到時回傳 models.ErrNotFound
+func (r *sqlPatientRepository) GetPatient(ctx context.Context, id int64) (*models.Patient, error) {
+	row := r.db(ctx, "patients.GetPatient").QueryRowContext(ctx, `SELECT `+patientColumns+` FROM patient WHERE ID = ?`, id)
+	patient, err := scanPatient(row)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFoundf("未找到ID為 %d 的病患", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("獲取病患 %d 失敗: %v", id, err)
+	}
+
+	patient.HistoryDiseases, err = r.queryStrings(ctx,
+		`SELECT history_disease FROM patient_history_disease WHERE patient_id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("獲取病患 %d 的病史資料失敗: %v", id, err)
+	}
+	patient.MedicalHistories, err = r.queryStrings(ctx,
+		`SELECT medical_history FROM patient_medical_history WHERE patient_id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("獲取病患 %d 的醫療史資料失敗: %v", id, err)
+	}
+	return patient, nil
+}
+
+// PatientExistsByIDNo 檢查是否已有相同身分證字號的病患
+func (r *sqlPatientRepository) PatientExistsByIDNo(ctx context.Context, idno string) (bool, error) {
+	var count int
+	err := r.db(ctx, "patients.PatientExistsByIDNo").QueryRowContext(ctx, `SELECT COUNT(*) FROM patient WHERE idno = ?`, idno).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("查詢身分證字號失敗: %v", err)
+	}
+	return count > 0, nil
+}
+
+// ListPatientIDs 依ID順序獲取所有病患的ID
+func (r *sqlPatientRepository) ListPatientIDs(ctx context.Context) ([]int64, error) {
+	rows, err := r.db(ctx, "patients.ListPatientIDs").QueryContext(ctx, `SELECT ID FROM patient ORDER BY ID`)
+	if err != nil {
+		return nil, fmt.Errorf("獲取病患ID失敗: %v", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("掃描病患ID失敗: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// UpdatePatient 更新病患主資料（不含病史與醫療史），找不到時回傳 models.ErrNotFound
+func (r *sqlPatientRepository) UpdatePatient(ctx context.Context, patient *models.Patient) error {
+	res, err := r.db(ctx, "patients.UpdatePatient").ExecContext(ctx, `
+		UPDATE patient SET name = ?, gender = ?, idno = ?, age = ?, birth = ?, address = ?, city = ?,
+			district = ?, phone = ?, mail = ?, disease_id = ?, emergency_contact = ?, emergency_phone = ?,
+			emergency_relation = ?, OTHERHISTORYDISEASE = ?, OTHERMEDICALHISTORY = ?, user_id = ?
+		WHERE ID = ?`,
+		patient.Name, patient.Gender, patient.IDNo, patient.Age, patient.Birth,
+		patient.Address, patient.City, patient.District, patient.Phone, patient.Mail,
+		patient.DiseaseID, patient.EmergencyContact, patient.EmergencyPhone,
+		patient.EmergencyRelation, patient.OtherHistoryDisease, patient.OtherMedicalHistory,
+		patient.UserID, patient.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("更新病患 %d 失敗: %v", patient.ID, err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("獲取影響行數失敗: %v", err)
+	}
+	if rows == 0 {
+		return models.NotFoundf("未找到ID為 %d 的病患", patient.ID)
+	}
+	return nil
+}
+
+// queryStrings 執行只回傳單一字串欄位的查詢
+func (r *sqlPatientRepository) queryStrings(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := r.db(ctx, "patients.queryStrings").QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make([]string, 0)
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// ListHistoryDiseases 獲取 history_disease 表中的所有疾病
+func (r *sqlPatientRepository) ListHistoryDiseases(ctx context.Context) ([]*models.HistoryDisease, error) {
+	query := `SELECT ID, disease_name FROM history_disease ORDER BY ID`
+	rows, err := r.db(ctx, "patients.ListHistoryDiseases").QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("獲取疾病目錄失敗: %v", err)
+	}
+	defer rows.Close()
+
+	diseases := make([]*models.HistoryDisease, 0)
+	for rows.Next() {
+		disease := &models.HistoryDisease{}
+		if err := rows.Scan(&disease.ID, &disease.DiseaseName); err != nil {
+			return nil, fmt.Errorf("掃描疾病數據失敗: %v", err)
+		}
+		diseases = append(diseases, disease)
+	}
+	return diseases, rows.Err()
+}
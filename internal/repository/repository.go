@@ -3,668 +3,209 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/tracing"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
-// Repository defines the methods for interacting with the database.
-type Repository interface {
+// UserRepository 使用者資料表操作
+type UserRepository interface {
 	Create(ctx context.Context, user *models.User) error
 	GetByID(ctx context.Context, id string) (*models.User, error)
+	// GetByAccount 依帳號取得使用者，找不到時回傳 models.ErrNotFound
+	GetByAccount(ctx context.Context, account string) (*models.User, error)
 	Update(ctx context.Context, user *models.User) error
 	Delete(ctx context.Context, id string) error
 	BatchCreateUsers(ctx context.Context, users []*models.User) ([]int64, error)
 	ListUsers(ctx context.Context, limit int) ([]*models.User, error)
-	// 新增角色相關方法
+	ListUsersWithRoles(ctx context.Context, limit int) ([]*models.User, error)
+	GetUserByRoleID(ctx context.Context, roleID int64) ([]*models.User, error)
+	// ReserveAccountNumbers 保留 count 個連續的帳號編號，回傳第一個編號
+	ReserveAccountNumbers(ctx context.Context, prefix string, count int) (int64, error)
+}
+
+// RoleRepository 角色與使用者角色資料表操作
+type RoleRepository interface {
 	ListAllRoles(ctx context.Context) ([]*models.Role, error)
+	AddRole(ctx context.Context, alias, description string) (int64, error)
+	// DeleteRole 刪除角色與指派給使用者的該角色，角色不存在時回傳 models.ErrNotFound
+	DeleteRole(ctx context.Context, roleID int64) error
 	AssignRoleToUser(ctx context.Context, userID int64, roleIDs []int64) error
 	GetUserRoles(ctx context.Context, userID int64) ([]*models.Role, error)
-	ListUsersWithRoles(ctx context.Context, limit int) ([]*models.User, error)
-	GetDB() *sql.DB // 新增方法以獲取資料庫連接
+}
 
-	// 可預約時段相關方法
+// SlotRepository 可預約時段資料表操作
+type SlotRepository interface {
 	BatchCreateAvailableSlots(ctx context.Context, slots []*models.AvailableSlot) error
 	GetAvailableSlotsByDoctor(ctx context.Context, doctorID int64) ([]*models.AvailableSlot, error)
-	GetUserByRoleID(ctx context.Context, roleID int64) ([]*models.User, error)
+	GetAvailableSlotByID(ctx context.Context, slotID int64) (*models.AvailableSlot, error)
 	UpdateAvailableSlot(ctx context.Context, slot *models.AvailableSlot) error
+	// DeleteAvailableSlot 刪除未被預約的時段，已被預約時回傳 models.ErrConflict
 	DeleteAvailableSlot(ctx context.Context, slotID int64) error
-	GetAvailableSlotByID(ctx context.Context, slotID int64) (*models.AvailableSlot, error)
-}
-
-// UserRepository is the implementation of the Repository interface.
-type UserRepository struct {
-	db *sql.DB
 }
 
-// NewUserRepository creates a new UserRepository.
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+// PatientRepository 病患、病史、醫療史與疾病目錄資料表操作
+type PatientRepository interface {
+	// CreatePatient 新增病患並回填 patient.ID
+	CreatePatient(ctx context.Context, patient *models.Patient) (int64, error)
+	AddHistoryDisease(ctx context.Context, patientID int64, historyDisease string, diseaseID int64) error
+	AddMedicalHistory(ctx context.Context, patientID int64, medicalHistory string) error
+	ListPatients(ctx context.Context, limit int) ([]*models.Patient, error)
+	// GetPatient 取得病患及其病史、醫療史，找不到時回傳 models.ErrNotFound
+	GetPatient(ctx context.Context, id int64) (*models.Patient, error)
+	PatientExistsByIDNo(ctx context.Context, idno string) (bool, error)
+	ListPatientIDs(ctx context.Context) ([]int64, error)
+	// UpdatePatient 更新病患主資料（不含病史與醫療史），找不到時回傳 models.ErrNotFound
+	UpdatePatient(ctx context.Context, patient *models.Patient) error
+	ListHistoryDiseases(ctx context.Context) ([]*models.HistoryDisease, error)
+}
+
+// BatchRepository 生成批次資料表操作
+type BatchRepository interface {
+	RecordGenerationBatch(ctx context.Context, batch *models.GenerationBatch, entityType string, entityIDs []int64) error
+	// AddGenerationBatchItems 為已存在的批次加入另一種實體類型的資料列
+	AddGenerationBatchItems(ctx context.Context, batchID string, entityType string, entityIDs []int64) error
+	ListGenerationBatches(ctx context.Context, limit int) ([]*models.GenerationBatch, error)
+	PurgeGenerationBatch(ctx context.Context, batchID string, dryRun bool) (*models.BatchPurgeResult, error)
+}
+
+// JobRepository 背景生成工作資料表操作
+type JobRepository interface {
+	CreateJob(ctx context.Context, job *models.Job) error
+	// GetJob 依ID取得工作，找不到時回傳 models.ErrNotFound
+	GetJob(ctx context.Context, id string) (*models.Job, error)
+	ListJobs(ctx context.Context, limit int) ([]*models.Job, error)
+	// ListJobsByStatus 依建立順序獲取指定狀態的工作
+	ListJobsByStatus(ctx context.Context, statuses ...string) ([]*models.Job, error)
+	// StartJob 將排隊中的工作標記為由 owner 執行中，並以 startedAt 作為第一次心跳；
+	// 工作不是排隊中時回傳 models.ErrConflict
+	StartJob(ctx context.Context, id, owner string, startedAt time.Time) error
+	// UpdateJobProgress 記錄 owner 執行中工作的進度並更新心跳時間，
+	// 工作不是執行中或已由其他程序接手時回傳 models.ErrConflict
+	UpdateJobProgress(ctx context.Context, id, owner string, progress models.JobProgress, heartbeatAt time.Time) error
+	// FinishJob 記錄 job.Owner 執行的工作的結束狀態、結果與進度，工作已由其他程序接手時回傳 models.ErrConflict
+	FinishJob(ctx context.Context, job *models.Job) error
+	// CancelQueuedJob 取消排隊中的工作，工作不是排隊中時回傳 models.ErrConflict
+	CancelQueuedJob(ctx context.Context, id string, finishedAt time.Time) error
+	// RequeueJob 將 owner 執行中的工作放回佇列，工作不是由 owner 執行中時回傳 models.ErrConflict
+	RequeueJob(ctx context.Context, id, owner string) error
+	// RequeueStaleJob 將心跳早於 staleBefore（或沒有心跳）的執行中工作放回佇列，
+	// 執行的程序仍在更新心跳時回傳 models.ErrConflict
+	RequeueStaleJob(ctx context.Context, id string, staleBefore time.Time) error
 }
 
-// GetDB returns the database connection
-func (r *UserRepository) GetDB() *sql.DB {
-	return r.db
+// AuditRepository 稽核紀錄資料表操作
+type AuditRepository interface {
+	// RecordAudit 新增稽核紀錄並回填 entry.ID
+	RecordAudit(ctx context.Context, entry *models.AuditEntry) error
+	// ListAudit 依條件由新到舊獲取稽核紀錄
+	ListAudit(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error)
 }
 
-// Create inserts a new user into the database.
-func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
-	query := `
-        INSERT INTO user (account, create_time, email, last_login_date, password, status, steam_id, tel_cell, username)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := r.db.ExecContext(ctx, query,
-		user.Account, user.CreateTime, user.Email, user.LastLoginDate,
-		user.Password, user.Status, user.SteamID, user.TelCell, user.Username)
-	return err
-}
-
-// GetByID retrieves a user by their ID from the database.
-func (r *UserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
-	query := `SELECT ID, account, create_time, email, last_login_date, password, status, steam_id, tel_cell, username
-              FROM user WHERE ID = ?`
-	row := r.db.QueryRowContext(ctx, query, id)
-	user := &models.User{}
-	var telCell sql.NullString
-	var username sql.NullString
-	err := row.Scan(&user.ID, &user.Account, &user.CreateTime, &user.Email, &user.LastLoginDate,
-		&user.Password, &user.Status, &user.SteamID, &telCell, &username)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	// 處理 tel_cell 的 NULL 值
-	if telCell.Valid {
-		user.TelCell = &telCell.String
-	} else {
-		user.TelCell = nil
-	}
-
-	// 處理 username 的 NULL 值
-	if username.Valid {
-		user.Username = &username.String
-	} else {
-		user.Username = nil
-	}
-
-	return user, nil
+// Store 提供各資料表的 Repository，並以 WithTx 讓 service 在單一交易中組合多個操作（unit of work）
+type Store interface {
+	Users() UserRepository
+	Roles() RoleRepository
+	Slots() SlotRepository
+	Patients() PatientRepository
+	Batches() BatchRepository
+	Jobs() JobRepository
+	Audit() AuditRepository
+	// WithTx 在單一交易中執行 fn。交易透過 fn 收到的 ctx 傳遞，
+	// 以該 ctx 呼叫的所有 Repository 方法（包含其他 service 方法）都在同一個交易中執行。
+	// fn 回傳錯誤、panic 或 ctx 被取消時回滾，否則提交；ctx 已帶有交易時沿用外層交易。
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
-// Update modifies an existing user in the database.
-func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
-	query := `
-        UPDATE user SET account = ?, create_time = ?, email = ?, last_login_date = ?,
-        password = ?, status = ?, steam_id = ?, tel_cell = ?, username = ?
-        WHERE ID = ?`
-	_, err := r.db.ExecContext(ctx, query,
-		user.Account, user.CreateTime, user.Email, user.LastLoginDate,
-		user.Password, user.Status, user.SteamID, user.TelCell, user.Username, user.ID)
-	return err
+// dbConn 是 *sql.DB 與 *sql.Tx 共同的查詢方法
+type dbConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
 }
 
-// Delete removes a user from the database by their ID.
-func (r *UserRepository) Delete(ctx context.Context, id string) error {
-	query := `DELETE FROM user WHERE ID = ?`
-	_, err := r.db.ExecContext(ctx, query, id)
-	return err
+// sqlConn 是各 SQL Repository 共用的連線
+type sqlConn struct {
+	sqlDB   *sql.DB
+	dialect Dialect
 }
 
-// BatchCreateUsers inserts multiple users into the database in a batch and returns their IDs.
-func (r *UserRepository) BatchCreateUsers(ctx context.Context, users []*models.User) ([]int64, error) {
-	if len(users) == 0 {
-		return []int64{}, nil
-	}
-	query := `
-        INSERT INTO user (account, create_time, email, last_login_date, password, status, steam_id, tel_cell, username)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, err
+// db 回傳目前應使用的連線：ctx 帶有此資料庫的交易時為 *sql.Tx，否則為 *sql.DB。
+// 每個語句都會建立名為 statement 的追蹤 span（例如 slots.GetAvailableSlotsByDoctor）。
+func (c *sqlConn) db(ctx context.Context, statement string) dbConn {
+	var conn dbConn = c.sqlDB
+	if tx := c.txFromContext(ctx); tx != nil {
+		conn = tx
 	}
-	stmt, err := tx.PrepareContext(ctx, query)
-	if err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-	defer stmt.Close()
-
-	userIDs := make([]int64, 0, len(users))
-	for _, user := range users {
-		res, err := stmt.ExecContext(ctx,
-			user.Account, user.CreateTime, user.Email, user.LastLoginDate,
-			user.Password, user.Status, user.SteamID, user.TelCell, user.Username)
-		if err != nil {
-			tx.Rollback()
-			return nil, err
-		}
-
-		// 獲取新插入用戶的 ID
-		id, err := res.LastInsertId()
-		if err != nil {
-			tx.Rollback()
-			return nil, err
-		}
-		userIDs = append(userIDs, id)
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return nil, err
-	}
-
-	return userIDs, nil
+	return tracedConn{conn: conn, system: string(c.dialect), statement: statement}
 }
 
-// ListUsers retrieves a list of users from the database, limited by the specified number.
-func (r *UserRepository) ListUsers(ctx context.Context, limit int) ([]*models.User, error) {
-	if limit <= 0 {
-		limit = 10 // Default limit if none specified
-	}
-	query := `SELECT ID, account, create_time, email, last_login_date, password, status, steam_id, tel_cell, username
-              FROM user ORDER BY ID DESC LIMIT ?`
-	rows, err := r.db.QueryContext(ctx, query, limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	users := make([]*models.User, 0)
-	for rows.Next() {
-		user := &models.User{}
-		var telCell sql.NullString
-		var username sql.NullString
-		err := rows.Scan(&user.ID, &user.Account, &user.CreateTime, &user.Email, &user.LastLoginDate,
-			&user.Password, &user.Status, &user.SteamID, &telCell, &username)
-		if err != nil {
-			return nil, err
-		}
-
-		// 處理 tel_cell 的 NULL 值
-		if telCell.Valid {
-			user.TelCell = &telCell.String
-		} else {
-			user.TelCell = nil
-		}
-
-		// 處理 username 的 NULL 值
-		if username.Valid {
-			user.Username = &username.String
-		} else {
-			user.Username = nil
-		}
+// withTx 在交易中執行 fn，供需要多個語句的單一 Repository 方法使用；
+// ctx 已帶有交易時沿用該交易，由外層負責提交或回滾。整個 fn 記錄為一個名為 statement 的 span。
+func (c *sqlConn) withTx(ctx context.Context, statement string, fn func(tx *sql.Tx) error) (err error) {
+	ctx, span := tracing.StartSQL(ctx, string(c.dialect), statement, "")
+	defer func() { tracing.End(span, err) }()
 
-		users = append(users, user)
+	if tx := c.txFromContext(ctx); tx != nil {
+		return fn(tx)
 	}
-	return users, nil
+	return c.runTx(ctx, func(ctx context.Context) error {
+		return fn(c.txFromContext(ctx))
+	})
 }
 
-// ListAllRoles 獲取所有角色
-func (r *UserRepository) ListAllRoles(ctx context.Context) ([]*models.Role, error) {
-	query := `SELECT ID, alias, description FROM role`
-	rows, err := r.db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	roles := make([]*models.Role, 0)
-	for rows.Next() {
-		role := &models.Role{}
-		err := rows.Scan(&role.ID, &role.Alias, &role.Description)
-		if err != nil {
-			return nil, err
-		}
-		roles = append(roles, role)
-	}
-	return roles, nil
+// SQLStore 是以 database/sql 實作的 Store，支援 MySQL 與 SQLite
+type SQLStore struct {
+	conn *sqlConn
 }
 
-// AssignRoleToUser 為用戶指派角色
-func (r *UserRepository) AssignRoleToUser(ctx context.Context, userID int64, roleIDs []int64) error {
-	// 檢查用戶角色表是否存在
-	checkTableQuery := `SHOW TABLES LIKE 'user_role'`
-	rows, err := r.db.QueryContext(ctx, checkTableQuery)
-	if err != nil {
-		return fmt.Errorf("檢查 user_role 表失敗: %v", err)
-	}
-
-	tableExists := false
-	for rows.Next() {
-		tableExists = true
-		break
-	}
-	rows.Close()
-
-	if !tableExists {
-		return fmt.Errorf("user_role 表不存在，請創建表")
-	}
-
-	// 打印詳細信息
-	fmt.Printf("正在為用戶 ID %d 分配角色: %v\n", userID, roleIDs)
-
-	// 開始事務
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("開始事務失敗: %v", err)
-	}
-	defer func() {
-		if err != nil {
-			// 發生錯誤時回滾事務
-			tx.Rollback()
-			fmt.Printf("事務回滾，用戶 ID: %d, 錯誤: %v\n", userID, err)
-		}
-	}()
-
-	// 先刪除該用戶現有的所有角色
-	deleteQuery := `DELETE FROM user_role WHERE user_id = ?`
-	_, err = tx.ExecContext(ctx, deleteQuery, userID)
-	if err != nil {
-		return fmt.Errorf("刪除用戶舊角色失敗: %v", err)
-	}
-
-	// 如果沒有要添加的角色，直接提交事務
-	if len(roleIDs) == 0 {
-		if err = tx.Commit(); err != nil {
-			return fmt.Errorf("提交空角色事務失敗: %v", err)
-		}
-		return nil
-	}
-
-	// 添加新角色 - 根據實際表結構修改 (移除 created_at 欄位)
-	insertQuery := `INSERT INTO user_role (user_id, role_id) VALUES (?, ?)`
-	stmt, err := tx.PrepareContext(ctx, insertQuery)
-	if err != nil {
-		return fmt.Errorf("準備插入語句失敗: %v", err)
-	}
-	defer stmt.Close()
-
-	for _, roleID := range roleIDs {
-		_, err = stmt.ExecContext(ctx, userID, roleID)
-		if err != nil {
-			return fmt.Errorf("為用戶 %d 插入角色 %d 失敗: %v", userID, roleID, err)
-		}
-		fmt.Printf("成功為用戶 %d 添加角色 %d\n", userID, roleID)
-	}
-
-	// 提交事務
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("提交事務失敗: %v", err)
-	}
-
-	fmt.Printf("成功完成用戶 %d 的角色分配\n", userID)
-	return nil
+// NewSQLStore 建立新的 SQLStore
+func NewSQLStore(db *sql.DB, dialect Dialect) *SQLStore {
+	return &SQLStore{conn: &sqlConn{sqlDB: db, dialect: dialect}}
 }
 
-// GetUserRoles 獲取用戶角色
-func (r *UserRepository) GetUserRoles(ctx context.Context, userID int64) ([]*models.Role, error) {
-	query := `
-		SELECT r.ID, r.alias, r.description
-		FROM role r
-		JOIN user_role ur ON r.ID = ur.role_id
-		WHERE ur.user_id = ?
-	`
-	rows, err := r.db.QueryContext(ctx, query, userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	roles := make([]*models.Role, 0)
-	for rows.Next() {
-		role := &models.Role{}
-		err := rows.Scan(&role.ID, &role.Alias, &role.Description)
-		if err != nil {
-			return nil, err
-		}
-		roles = append(roles, role)
-	}
-	return roles, nil
+// Users 回傳使用者 Repository
+func (s *SQLStore) Users() UserRepository {
+	return &sqlUserRepository{s.conn}
 }
 
-// ListUsersWithRoles 獲取包含角色資訊的用戶列表
-func (r *UserRepository) ListUsersWithRoles(ctx context.Context, limit int) ([]*models.User, error) {
-	if limit <= 0 {
-		limit = 10 // Default limit if none specified
-	}
-
-	// 先獲取用戶基本資訊
-	users, err := r.ListUsers(ctx, limit)
-	if err != nil {
-		return nil, err
-	}
-
-	// 為每個用戶添加角色資訊
-	for _, user := range users {
-		roles, err := r.GetUserRoles(ctx, user.ID)
-		if err != nil {
-			return nil, err
-		}
-		user.Roles = roles
-	}
-
-	return users, nil
+// Roles 回傳角色 Repository
+func (s *SQLStore) Roles() RoleRepository {
+	return &sqlRoleRepository{s.conn}
 }
 
-// GetUserByRoleID 獲取特定角色的用戶列表
-func (r *UserRepository) GetUserByRoleID(ctx context.Context, roleID int64) ([]*models.User, error) {
-	query := `
-		SELECT u.ID, u.account, u.create_time, u.email, u.last_login_date, 
-		       u.password, u.status, u.steam_id, u.tel_cell, u.username
-		FROM user u
-		JOIN user_role ur ON u.ID = ur.user_id
-		WHERE ur.role_id = ?
-		ORDER BY u.ID DESC
-	`
-	rows, err := r.db.QueryContext(ctx, query, roleID)
-	if err != nil {
-		return nil, fmt.Errorf("獲取角色用戶列表失敗: %v", err)
-	}
-	defer rows.Close()
-
-	users := make([]*models.User, 0)
-	for rows.Next() {
-		user := &models.User{}
-		var telCell sql.NullString
-		var username sql.NullString
-		err := rows.Scan(&user.ID, &user.Account, &user.CreateTime, &user.Email, &user.LastLoginDate,
-			&user.Password, &user.Status, &user.SteamID, &telCell, &username)
-		if err != nil {
-			return nil, fmt.Errorf("掃描用戶數據失敗: %v", err)
-		}
-
-		// 處理 tel_cell 的 NULL 值
-		if telCell.Valid {
-			user.TelCell = &telCell.String
-		} else {
-			user.TelCell = nil
-		}
-
-		// 處理 username 的 NULL 值
-		if username.Valid {
-			user.Username = &username.String
-		} else {
-			user.Username = nil
-		}
-
-		// 獲取該用戶的角色
-		roles, err := r.GetUserRoles(ctx, user.ID)
-		if err != nil {
-			return nil, fmt.Errorf("獲取用戶 %d 的角色失敗: %v", user.ID, err)
-		}
-		user.Roles = roles
-
-		users = append(users, user)
-	}
-	return users, nil
+// Slots 回傳可預約時段 Repository
+func (s *SQLStore) Slots() SlotRepository {
+	return &sqlSlotRepository{s.conn}
 }
 
-// BatchCreateAvailableSlots 批量創建可預約時段
-func (r *UserRepository) BatchCreateAvailableSlots(ctx context.Context, slots []*models.AvailableSlot) error {
-	if len(slots) == 0 {
-		return nil
-	}
-
-	// 開始事務
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("開始事務失敗: %v", err)
-	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-			fmt.Printf("事務回滾，錯誤: %v\n", err)
-		}
-	}()
-
-	// 檢查表是否存在
-	checkTableQuery := `SHOW TABLES LIKE 'wg_available_slots'`
-	rows, err := r.db.QueryContext(ctx, checkTableQuery)
-	if err != nil {
-		return fmt.Errorf("檢查 wg_available_slots 表失敗: %v", err)
-	}
-
-	tableExists := false
-	for rows.Next() {
-		tableExists = true
-		break
-	}
-	rows.Close()
-
-	if !tableExists {
-		return fmt.Errorf("wg_available_slots 表不存在，請創建表")
-	}
-
-	// 批量插入時段
-	insertQuery := `INSERT INTO wg_available_slots 
-                   (doctor, is_booked, slot_begin_time, slot_date, slot_end_time) 
-                   VALUES (?, ?, ?, ?, ?)`
-	stmt, err := tx.PrepareContext(ctx, insertQuery)
-	if err != nil {
-		return fmt.Errorf("準備插入語句失敗: %v", err)
-	}
-	defer stmt.Close()
-
-	for _, slot := range slots {
-		_, err = stmt.ExecContext(ctx,
-			slot.Doctor,
-			slot.IsBooked,
-			slot.SlotBeginTime.Format("15:04:05"),
-			slot.SlotDate.Format("2006-01-02"),
-			slot.SlotEndTime.Format("15:04:05"))
-		if err != nil {
-			return fmt.Errorf("插入時段失敗: %v", err)
-		}
-	}
-
-	// 提交事務
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("提交事務失敗: %v", err)
-	}
-
-	return nil
+// Patients 回傳病患 Repository
+func (s *SQLStore) Patients() PatientRepository {
+	return &sqlPatientRepository{s.conn}
 }
 
-// GetAvailableSlotsByDoctor 獲取醫師的可預約時段
-func (r *UserRepository) GetAvailableSlotsByDoctor(ctx context.Context, doctorID int64) ([]*models.AvailableSlot, error) {
-	query := `
-		SELECT ID, doctor, is_booked, slot_begin_time, slot_date, slot_end_time
-		FROM wg_available_slots
-		WHERE doctor = ?
-		ORDER BY slot_date, slot_begin_time
-	`
-	rows, err := r.db.QueryContext(ctx, query, doctorID)
-	if err != nil {
-		return nil, fmt.Errorf("獲取醫師時段失敗: %v", err)
-	}
-	defer rows.Close()
-
-	slots := make([]*models.AvailableSlot, 0)
-	for rows.Next() {
-		slot := &models.AvailableSlot{}
-		var beginTime, endTime, slotDate string
-		err := rows.Scan(
-			&slot.ID,
-			&slot.Doctor,
-			&slot.IsBooked,
-			&beginTime,
-			&slotDate,
-			&endTime)
-		if err != nil {
-			return nil, fmt.Errorf("掃描時段數據失敗: %v", err)
-		}
-
-		// 解析日期時間 - 嘗試多種格式
-		// 首先嘗試標準日期格式
-		date, err := time.Parse("2006-01-02", slotDate)
-		if err != nil {
-			// 如果標準格式解析失敗，嘗試 ISO 格式
-			date, err = time.Parse(time.RFC3339, slotDate)
-			if err != nil {
-				// 嘗試不帶時區的格式
-				date, err = time.Parse("2006-01-02T15:04:05", slotDate)
-				if err != nil {
-					return nil, fmt.Errorf("解析日期失敗 %s: %v", slotDate, err)
-				}
-			}
-		}
-		// 只保留日期部分，去除時間
-		slot.SlotDate = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
-
-		// 解析開始時間
-		beginTimeParsed, err := time.Parse("15:04:05", beginTime)
-		if err != nil {
-			return nil, fmt.Errorf("解析開始時間失敗 %s: %v", beginTime, err)
-		}
-		slot.SlotBeginTime = time.Date(
-			date.Year(), date.Month(), date.Day(),
-			beginTimeParsed.Hour(), beginTimeParsed.Minute(), beginTimeParsed.Second(),
-			0, time.Local)
-
-		// 解析結束時間
-		endTimeParsed, err := time.Parse("15:04:05", endTime)
-		if err != nil {
-			return nil, fmt.Errorf("解析結束時間失敗 %s: %v", endTime, err)
-		}
-		slot.SlotEndTime = time.Date(
-			date.Year(), date.Month(), date.Day(),
-			endTimeParsed.Hour(), endTimeParsed.Minute(), endTimeParsed.Second(),
-			0, time.Local)
-
-		slots = append(slots, slot)
-	}
-	return slots, nil
+// Batches 回傳生成批次 Repository
+func (s *SQLStore) Batches() BatchRepository {
+	return &sqlBatchRepository{s.conn}
 }
 
-// UpdateAvailableSlot 更新可預約時段
-func (r *UserRepository) UpdateAvailableSlot(ctx context.Context, slot *models.AvailableSlot) error {
-	query := `
-		UPDATE wg_available_slots
-		SET doctor = ?, is_booked = ?, slot_begin_time = ?, slot_date = ?, slot_end_time = ?
-		WHERE ID = ?
-	`
-	_, err := r.db.ExecContext(ctx, query,
-		slot.Doctor,
-		slot.IsBooked,
-		slot.SlotBeginTime.Format("15:04:05"),
-		slot.SlotDate.Format("2006-01-02"),
-		slot.SlotEndTime.Format("15:04:05"),
-		slot.ID)
-
-	if err != nil {
-		return fmt.Errorf("更新時段失敗: %v", err)
-	}
-	return nil
+// Jobs 回傳背景工作 Repository
+func (s *SQLStore) Jobs() JobRepository {
+	return &sqlJobRepository{s.conn}
 }
 
-// DeleteAvailableSlot 刪除可預約時段
-func (r *UserRepository) DeleteAvailableSlot(ctx context.Context, slotID int64) error {
-	query := `DELETE FROM wg_available_slots WHERE ID = ?`
-	result, err := r.db.ExecContext(ctx, query, slotID)
-	if err != nil {
-		return fmt.Errorf("刪除時段失敗: %v", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("獲取影響行數失敗: %v", err)
-	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("未找到ID為 %d 的時段", slotID)
-	}
-
-	return nil
+// Audit 回傳稽核紀錄 Repository
+func (s *SQLStore) Audit() AuditRepository {
+	return &sqlAuditRepository{s.conn}
 }
 
-// GetAvailableSlotByID 通過ID獲取時段
-func (r *UserRepository) GetAvailableSlotByID(ctx context.Context, slotID int64) (*models.AvailableSlot, error) {
-	query := `
-		SELECT ID, doctor, is_booked, slot_begin_time, slot_date, slot_end_time
-		FROM wg_available_slots
-		WHERE ID = ?
-	`
-	slot := &models.AvailableSlot{}
-	var beginTime, endTime, slotDate string
-
-	err := r.db.QueryRowContext(ctx, query, slotID).Scan(
-		&slot.ID,
-		&slot.Doctor,
-		&slot.IsBooked,
-		&beginTime,
-		&slotDate,
-		&endTime)
-
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("未找到ID為 %d 的時段", slotID)
-	}
-
-	if err != nil {
-		return nil, fmt.Errorf("獲取時段數據失敗: %v", err)
+// WithTx 在單一資料庫交易中執行 fn
+func (s *SQLStore) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.conn.txFromContext(ctx) != nil {
+		return fn(ctx)
 	}
-
-	// 解析日期時間 - 嘗試多種格式
-	// 首先嘗試標準日期格式
-	date, err := time.Parse("2006-01-02", slotDate)
-	if err != nil {
-		// 如果標準格式解析失敗，嘗試 ISO 格式
-		date, err = time.Parse(time.RFC3339, slotDate)
-		if err != nil {
-			// 嘗試不帶時區的格式
-			date, err = time.Parse("2006-01-02T15:04:05", slotDate)
-			if err != nil {
-				return nil, fmt.Errorf("解析日期失敗 %s: %v", slotDate, err)
-			}
-		}
-	}
-	// 只保留日期部分，去除時間
-	slot.SlotDate = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
-
-	// 解析開始時間
-	beginTimeParsed, err := time.Parse("15:04:05", beginTime)
-	if err != nil {
-		return nil, fmt.Errorf("解析開始時間失敗 %s: %v", beginTime, err)
-	}
-	slot.SlotBeginTime = time.Date(
-		date.Year(), date.Month(), date.Day(),
-		beginTimeParsed.Hour(), beginTimeParsed.Minute(), beginTimeParsed.Second(),
-		0, time.Local)
-
-	// 解析結束時間
-	endTimeParsed, err := time.Parse("15:04:05", endTime)
-	if err != nil {
-		return nil, fmt.Errorf("解析結束時間失敗 %s: %v", endTime, err)
-	}
-	slot.SlotEndTime = time.Date(
-		date.Year(), date.Month(), date.Day(),
-		endTimeParsed.Hour(), endTimeParsed.Minute(), endTimeParsed.Second(),
-		0, time.Local)
-
-	return slot, nil
-}
-
-// RoleRepository 角色資料庫操作
-type RoleRepository struct {
-	DB *sql.DB
-}
-
-// NewRoleRepository 建立新的 RoleRepository
-func NewRoleRepository(db *sql.DB) *RoleRepository {
-	return &RoleRepository{DB: db}
-}
-
-// AddRole 新增角色
-func (r *RoleRepository) AddRole(alias, description string) error {
-	query := "INSERT INTO role (alias, description) VALUES (?, ?)"
-	_, err := r.DB.Exec(query, alias, description)
-	return err
-}
-
-// DeleteRole 刪除角色
-func (r *RoleRepository) DeleteRole(roleID string) error {
-	query := "DELETE FROM role WHERE ID = ?"
-	_, err := r.DB.Exec(query, roleID)
-	return err
+	return s.conn.runTx(ctx, fn)
 }
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/models"
+)
+
+// sqlRoleRepository 是 RoleRepository 的 SQL 實作
+type sqlRoleRepository struct {
+	*sqlConn
+}
+
+// ListAllRoles 獲取所有角色
+func (r *sqlRoleRepository) ListAllRoles(ctx context.Context) ([]*models.Role, error) {
+	query := `SELECT ID, alias, description FROM role`
+	rows, err := r.db(ctx, "roles.ListAllRoles").QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := make([]*models.Role, 0)
+	for rows.Next() {
+		role := &models.Role{}
+		err := rows.Scan(&role.ID, &role.Alias, &role.Description)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// AssignRoleToUser 為用戶指派角色
+func (r *sqlRoleRepository) AssignRoleToUser(ctx context.Context, userID int64, roleIDs []int64) error {
+	logger := logging.FromContext(ctx).With(logging.KeyUser, userID)
+	logger.Debug("正在分配角色", "role_ids", roleIDs)
+
+	err := r.withTx(ctx, "roles.AssignRoleToUser", func(tx *sql.Tx) error {
+		// 先刪除該用戶現有的所有角色
+		deleteQuery := `DELETE FROM user_role WHERE user_id = ?`
+		if _, err := tx.ExecContext(ctx, deleteQuery, userID); err != nil {
+			return fmt.Errorf("刪除用戶舊角色失敗: %v", err)
+		}
+
+		// 如果沒有要添加的角色，直接提交事務
+		if len(roleIDs) == 0 {
+			return nil
+		}
+
+		// 添加新角色 - 根據實際表結構修改 (移除 created_at 欄位)
+		insertQuery := `INSERT INTO user_role (user_id, role_id) VALUES (?, ?)`
+		stmt, err := tx.PrepareContext(ctx, insertQuery)
+		if err != nil {
+			return fmt.Errorf("準備插入語句失敗: %v", err)
+		}
+		defer stmt.Close()
+
+		for _, roleID := range roleIDs {
+			if _, err := stmt.ExecContext(ctx, userID, roleID); err != nil {
+				return fmt.Errorf("為用戶 %d 插入角色 %d 失敗: %v", userID, roleID, err)
+			}
+			logger.Debug("已添加角色", "role_id", roleID)
+		}
+		return nil
+	})
+	if err != nil {
+		// 發生錯誤時事務已回滾
+		logger.Error("分配角色失敗", logging.KeyError, err.Error())
+		return err
+	}
+
+	logger.Debug("已完成角色分配", "role_count", len(roleIDs))
+	return nil
+}
+
+// GetUserRoles 獲取用戶角色
+func (r *sqlRoleRepository) GetUserRoles(ctx context.Context, userID int64) ([]*models.Role, error) {
+	return queryUserRoles(ctx, r.db(ctx, "roles.GetUserRoles"), userID)
+}
+
+// queryUserRoles 獲取用戶角色，供角色與使用者 Repository 共用
+func queryUserRoles(ctx context.Context, db dbConn, userID int64) ([]*models.Role, error) {
+	query := `
+		SELECT r.ID, r.alias, r.description
+		FROM role r
+		JOIN user_role ur ON r.ID = ur.role_id
+		WHERE ur.user_id = ?
+	`
+	rows, err := db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := make([]*models.Role, 0)
+	for rows.Next() {
+		role := &models.Role{}
+		err := rows.Scan(&role.ID, &role.Alias, &role.Description)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// AddRole 新增角色並回傳新角色的ID
+func (r *sqlRoleRepository) AddRole(ctx context.Context, alias, description string) (int64, error) {
+	query := "INSERT INTO role (alias, description) VALUES (?, ?)"
+	res, err := r.db(ctx, "roles.AddRole").ExecContext(ctx, query, alias, description)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// DeleteRole 在同一個交易中刪除角色與指派給使用者的該角色，角色不存在時回傳 models.ErrNotFound
+func (r *sqlRoleRepository) DeleteRole(ctx context.Context, roleID int64) error {
+	return r.withTx(ctx, "roles.DeleteRole", func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM user_role WHERE role_id = ?`, roleID); err != nil {
+			return fmt.Errorf("刪除用戶角色失敗: %v", err)
+		}
+		res, err := tx.ExecContext(ctx, `DELETE FROM role WHERE ID = ?`, roleID)
+		if err != nil {
+			return fmt.Errorf("刪除角色失敗: %v", err)
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("獲取刪除筆數失敗: %v", err)
+		}
+		if affected == 0 {
+			return models.NotFoundf("未找到ID為 %d 的角色", roleID)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"golang-gin-app/internal/models"
+	"time"
+)
+
+// sqlSlotRepository 是 SlotRepository 的 SQL 實作
+type sqlSlotRepository struct {
+	*sqlConn
+}
+
+// BatchCreateAvailableSlots 批量創建可預約時段
+func (r *sqlSlotRepository) BatchCreateAvailableSlots(ctx context.Context, slots []*models.AvailableSlot) error {
+	if len(slots) == 0 {
+		return nil
+	}
+
+	return r.withTx(ctx, "slots.BatchCreateAvailableSlots", func(tx *sql.Tx) error {
+		// 批量插入時段
+		insertQuery := `INSERT INTO wg_available_slots 
+                   (doctor, is_booked, slot_begin_time, slot_date, slot_end_time) 
+                   VALUES (?, ?, ?, ?, ?)`
+		stmt, err := tx.PrepareContext(ctx, insertQuery)
+		if err != nil {
+			return fmt.Errorf("準備插入語句失敗: %v", err)
+		}
+		defer stmt.Close()
+
+		for _, slot := range slots {
+			res, err := stmt.ExecContext(ctx,
+				slot.Doctor,
+				slot.IsBooked,
+				slot.SlotBeginTime.Format("15:04:05"),
+				slot.SlotDate.Format("2006-01-02"),
+				slot.SlotEndTime.Format("15:04:05"))
+			if err != nil {
+				return fmt.Errorf("插入時段失敗: %v", err)
+			}
+
+			// 回填新插入時段的 ID
+			slot.ID, err = res.LastInsertId()
+			if err != nil {
+				return fmt.Errorf("獲取時段ID失敗: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetAvailableSlotsByDoctor 獲取醫師的可預約時段
+func (r *sqlSlotRepository) GetAvailableSlotsByDoctor(ctx context.Context, doctorID int64) ([]*models.AvailableSlot, error) {
+	query := `
+		SELECT ID, doctor, is_booked, slot_begin_time, slot_date, slot_end_time
+		FROM wg_available_slots
+		WHERE doctor = ?
+		ORDER BY slot_date, slot_begin_time
+	`
+	rows, err := r.db(ctx, "slots.GetAvailableSlotsByDoctor").QueryContext(ctx, query, doctorID)
+	if err != nil {
+		return nil, fmt.Errorf("獲取醫師時段失敗: %v", err)
+	}
+	defer rows.Close()
+
+	slots := make([]*models.AvailableSlot, 0)
+	for rows.Next() {
+		slot := &models.AvailableSlot{}
+		var beginTime, endTime, slotDate string
+		err := rows.Scan(
+			&slot.ID,
+			&slot.Doctor,
+			&slot.IsBooked,
+			&beginTime,
+			&slotDate,
+			&endTime)
+		if err != nil {
+			return nil, fmt.Errorf("掃描時段數據失敗: %v", err)
+		}
+
+		// 解析日期時間 - 嘗試多種格式
+		// 首先嘗試標準日期格式
+		date, err := time.Parse("2006-01-02", slotDate)
+		if err != nil {
+			// 如果標準格式解析失敗，嘗試 ISO 格式
+			date, err = time.Parse(time.RFC3339, slotDate)
+			if err != nil {
+				// 嘗試不帶時區的格式
+				date, err = time.Parse("2006-01-02T15:04:05", slotDate)
+				if err != nil {
+					return nil, fmt.Errorf("解析日期失敗 %s: %v", slotDate, err)
+				}
+			}
+		}
+		// 只保留日期部分，去除時間
+		slot.SlotDate = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+
+		// 解析開始時間
+		beginTimeParsed, err := time.Parse("15:04:05", beginTime)
+		if err != nil {
+			return nil, fmt.Errorf("解析開始時間失敗 %s: %v", beginTime, err)
+		}
+		slot.SlotBeginTime = time.Date(
+			date.Year(), date.Month(), date.Day(),
+			beginTimeParsed.Hour(), beginTimeParsed.Minute(), beginTimeParsed.Second(),
+			0, time.Local)
+
+		// 解析結束時間
+		endTimeParsed, err := time.Parse("15:04:05", endTime)
+		if err != nil {
+			return nil, fmt.Errorf("解析結束時間失敗 %s: %v", endTime, err)
+		}
+		slot.SlotEndTime = time.Date(
+			date.Year(), date.Month(), date.Day(),
+			endTimeParsed.Hour(), endTimeParsed.Minute(), endTimeParsed.Second(),
+			0, time.Local)
+
+		slots = append(slots, slot)
+	}
+	return slots, nil
+}
+
+// UpdateAvailableSlot 更新可預約時段
+func (r *sqlSlotRepository) UpdateAvailableSlot(ctx context.Context, slot *models.AvailableSlot) error {
+	query := `
+		UPDATE wg_available_slots
+		SET doctor = ?, is_booked = ?, slot_begin_time = ?, slot_date = ?, slot_end_time = ?
+		WHERE ID = ?
+	`
+	_, err := r.db(ctx, "slots.UpdateAvailableSlot").ExecContext(ctx, query,
+		slot.Doctor,
+		slot.IsBooked,
+		slot.SlotBeginTime.Format("15:04:05"),
+		slot.SlotDate.Format("2006-01-02"),
+		slot.SlotEndTime.Format("15:04:05"),
+		slot.ID)
+
+	if err != nil {
+		return fmt.Errorf("更新時段失敗: %v", err)
+	}
+	return nil
+}
+
+// DeleteAvailableSlot 刪除未被預約的可預約時段。是否已預約的判斷在同一個 DELETE 中完成，
+// 不受其他連線在讀取後才預約時段影響；時段已被預約時回傳 models.ErrConflict
+func (r *sqlSlotRepository) DeleteAvailableSlot(ctx context.Context, slotID int64) error {
+	query := `DELETE FROM wg_available_slots WHERE ID = ? AND is_booked = 0`
+	result, err := r.db(ctx, "slots.DeleteAvailableSlot").ExecContext(ctx, query, slotID)
+	if err != nil {
+		return fmt.Errorf("刪除時段失敗: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("獲取影響行數失敗: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		// 沒有刪除任何資料時，區分時段不存在與已被預約
+		if _, err := r.GetAvailableSlotByID(ctx, slotID); err != nil {
+			return err
+		}
+		return models.Conflictf("該時段已被預約，無法刪除")
+	}
+
+	return nil
+}
+
+// GetAvailableSlotByID 通過ID獲取時段
+func (r *sqlSlotRepository) GetAvailableSlotByID(ctx context.Context, slotID int64) (*models.AvailableSlot, error) {
+	query := `
+		SELECT ID, doctor, is_booked, slot_begin_time, slot_date, slot_end_time
+		FROM wg_available_slots
+		WHERE ID = ?
+	`
+	slot := &models.AvailableSlot{}
+	var beginTime, endTime, slotDate string
+
+	err := r.db(ctx, "slots.GetAvailableSlotByID").QueryRowContext(ctx, query, slotID).Scan(
+		&slot.ID,
+		&slot.Doctor,
+		&slot.IsBooked,
+		&beginTime,
+		&slotDate,
+		&endTime)
+
+	if err == sql.ErrNoRows {
+		return nil, models.NotFoundf("未找到ID為 %d 的時段", slotID)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("獲取時段數據失敗: %v", err)
+	}
+
+	// 解析日期時間 - 嘗試多種格式
+	// 首先嘗試標準日期格式
+	date, err := time.Parse("2006-01-02", slotDate)
+	if err != nil {
+		// 如果標準格式解析失敗，嘗試 ISO 格式
+		date, err = time.Parse(time.RFC3339, slotDate)
+		if err != nil {
+			// 嘗試不帶時區的格式
+			date, err = time.Parse("2006-01-02T15:04:05", slotDate)
+			if err != nil {
+				return nil, fmt.Errorf("解析日期失敗 %s: %v", slotDate, err)
+			}
+		}
+	}
+	// 只保留日期部分，去除時間
+	slot.SlotDate = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+
+	// 解析開始時間
+	beginTimeParsed, err := time.Parse("15:04:05", beginTime)
+	if err != nil {
+		return nil, fmt.Errorf("解析開始時間失敗 %s: %v", beginTime, err)
+	}
+	slot.SlotBeginTime = time.Date(
+		date.Year(), date.Month(), date.Day(),
+		beginTimeParsed.Hour(), beginTimeParsed.Minute(), beginTimeParsed.Second(),
+		0, time.Local)
+
+	// 解析結束時間
+	endTimeParsed, err := time.Parse("15:04:05", endTime)
+	if err != nil {
+		return nil, fmt.Errorf("解析結束時間失敗 %s: %v", endTime, err)
+	}
+	slot.SlotEndTime = time.Date(
+		date.Year(), date.Month(), date.Day(),
+		endTimeParsed.Hour(), endTimeParsed.Minute(), endTimeParsed.Second(),
+		0, time.Local)
+
+	return slot, nil
+}
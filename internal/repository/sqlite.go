@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteMemory 作為 SQLite 路徑時使用記憶體資料庫，程式結束後資料即消失
+const SQLiteMemory = ":memory:"
+
+// OpenSQLite 開啟 SQLite 資料庫，path 為檔案路徑或 SQLiteMemory。
+// SQLite 同一時間只允許一個寫入者，因此連線池限制為單一連線，
+// 記憶體資料庫也因此在整個連線池中共用同一份資料。
+func OpenSQLite(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,675 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang-gin-app/internal/migrations"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/repository"
+)
+
+// newSQLiteStore 建立已套用所有遷移的記憶體 SQLite Store
+func newSQLiteStore(t *testing.T) repository.Store {
+	t.Helper()
+	db, err := repository.OpenSQLite(repository.SQLiteMemory)
+	if err != nil {
+		t.Fatalf("開啟 SQLite 失敗: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.NewMigrator(db, migrations.DialectSQLite)
+	if err != nil {
+		t.Fatalf("載入遷移檔失敗: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("套用遷移失敗: %v", err)
+	}
+
+	store, err := repository.NewStore(db, repository.DialectSQLite)
+	if err != nil {
+		t.Fatalf("建立 Store 失敗: %v", err)
+	}
+	return store
+}
+
+func newUsers(prefix string, first, count int) []*models.User {
+	users := make([]*models.User, 0, count)
+	for i := 0; i < count; i++ {
+		users = append(users, &models.User{
+			Account:    fmt.Sprintf("%s%04d", prefix, first+i),
+			CreateTime: time.Now().Truncate(time.Second),
+			Email:      fmt.Sprintf("%s%04d@example.com", prefix, first+i),
+			Password:   "secret",
+			Status:     "APPROVED",
+		})
+	}
+	return users
+}
+
+func TestSQLiteMigrationsUpAndDown(t *testing.T) {
+	db, err := repository.OpenSQLite(repository.SQLiteMemory)
+	if err != nil {
+		t.Fatalf("開啟 SQLite 失敗: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	migrator, err := migrations.NewMigrator(db, migrations.DialectSQLite)
+	if err != nil {
+		t.Fatalf("載入遷移檔失敗: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("套用遷移失敗: %v", err)
+	}
+	current, err := migrator.CurrentVersion(ctx)
+	if err != nil || current != migrator.LatestVersion() {
+		t.Fatalf("目前版本 = %d (%v)，預期 %d", current, err, migrator.LatestVersion())
+	}
+
+	steps := int(migrator.LatestVersion() - migrations.BaselineVersion)
+	if _, err := migrator.Down(ctx, steps); err != nil {
+		t.Fatalf("回復遷移失敗: %v", err)
+	}
+	if current, _ := migrator.CurrentVersion(ctx); current != migrations.BaselineVersion {
+		t.Fatalf("回復到基準後目前版本 = %d，預期 %d", current, migrations.BaselineVersion)
+	}
+	if _, err := migrator.Down(ctx, 1); err == nil {
+		t.Fatalf("回復基準版本應該失敗")
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("重新套用遷移失敗: %v", err)
+	}
+}
+
+func TestSQLiteUsersAndRoles(t *testing.T) {
+	store := newSQLiteStore(t)
+	ctx := context.Background()
+
+	ids, err := store.Users().BatchCreateUsers(ctx, newUsers("doctor", 1, 3))
+	if err != nil {
+		t.Fatalf("批量建立使用者失敗: %v", err)
+	}
+	if len(ids) != 3 {
+		t.Fatalf("建立 %d 位使用者，預期 3", len(ids))
+	}
+	for _, id := range ids {
+		if err := store.Roles().AssignRoleToUser(ctx, id, []int64{3}); err != nil {
+			t.Fatalf("指派角色失敗: %v", err)
+		}
+	}
+
+	user, err := store.Users().GetByID(ctx, fmt.Sprint(ids[0]))
+	if err != nil || user == nil {
+		t.Fatalf("獲取使用者失敗: %v", err)
+	}
+	if user.Account != "doctor0001" || user.CreateTime.IsZero() {
+		t.Fatalf("使用者資料不符: %+v", user)
+	}
+
+	doctors, err := store.Users().GetUserByRoleID(ctx, 3)
+	if err != nil {
+		t.Fatalf("依角色獲取使用者失敗: %v", err)
+	}
+	if len(doctors) != 3 || len(doctors[0].Roles) != 1 || doctors[0].Roles[0].Alias != "DOCTOR" {
+		t.Fatalf("依角色獲取的使用者不符: %d 位", len(doctors))
+	}
+
+	roleID, err := store.Roles().AddRole(ctx, "AUDITOR", "稽核員")
+	if err != nil {
+		t.Fatalf("新增角色失敗: %v", err)
+	}
+	roles, err := store.Roles().ListAllRoles(ctx)
+	if err != nil || roles[len(roles)-1].ID != roleID || roles[len(roles)-1].Alias != "AUDITOR" {
+		t.Fatalf("新增角色應回傳新角色的ID %d: %v (%v)", roleID, roles, err)
+	}
+	if err := store.Roles().AssignRoleToUser(ctx, ids[0], []int64{3, roleID}); err != nil {
+		t.Fatalf("指派角色失敗: %v", err)
+	}
+	if err := store.Roles().DeleteRole(ctx, roleID); err != nil {
+		t.Fatalf("刪除角色失敗: %v", err)
+	}
+	if roles, _ := store.Roles().GetUserRoles(ctx, ids[0]); len(roles) != 1 || roles[0].ID != 3 {
+		t.Fatalf("刪除角色後應移除使用者的該角色: %+v", roles)
+	}
+	if orphaned, err := store.Users().GetUserByRoleID(ctx, roleID); err != nil || len(orphaned) != 0 {
+		t.Fatalf("user_role 不應留下已刪除角色的資料: %d 位 (%v)", len(orphaned), err)
+	}
+	if err := store.Roles().DeleteRole(ctx, roleID); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("刪除不存在的角色應回傳 ErrNotFound，實際為 %v", err)
+	}
+
+	diseases, err := store.Patients().ListHistoryDiseases(ctx)
+	if err != nil || len(diseases) == 0 {
+		t.Fatalf("疾病目錄應有預設資料: %d 筆 (%v)", len(diseases), err)
+	}
+}
+
+func TestSQLiteReserveAccountNumbers(t *testing.T) {
+	store := newSQLiteStore(t)
+	ctx := context.Background()
+
+	// 手動建立的帳號編號也應被略過
+	if _, err := store.Users().BatchCreateUsers(ctx, newUsers("doctor", 7, 1)); err != nil {
+		t.Fatalf("建立使用者失敗: %v", err)
+	}
+
+	first, err := store.Users().ReserveAccountNumbers(ctx, "doctor", 5)
+	if err != nil {
+		t.Fatalf("保留帳號編號失敗: %v", err)
+	}
+	if first != 8 {
+		t.Fatalf("第一個編號 = %d，預期 8", first)
+	}
+	next, err := store.Users().ReserveAccountNumbers(ctx, "doctor", 2)
+	if err != nil {
+		t.Fatalf("保留帳號編號失敗: %v", err)
+	}
+	if next != 13 {
+		t.Fatalf("下一個編號 = %d，預期 13", next)
+	}
+}
+
+func TestSQLiteReserveAccountNumbersIgnoresNonNumericSuffix(t *testing.T) {
+	store := newSQLiteStore(t)
+	ctx := context.Background()
+
+	// 非純數字的編號、以及 LIKE 會誤判為符合 "pi_" 前綴的帳號都不應影響序號
+	users := newUsers("pi", 3, 1)
+	for _, account := range []string{"pinky", "pi_9999", "pix42", "pi12abc", "doctor_wang", "admin"} {
+		user := newUsers(account, 0, 1)[0]
+		user.Account = account
+		users = append(users, user)
+	}
+	if _, err := store.Users().BatchCreateUsers(ctx, users); err != nil {
+		t.Fatalf("建立使用者失敗: %v", err)
+	}
+
+	first, err := store.Users().ReserveAccountNumbers(ctx, "pi", 1)
+	if err != nil {
+		t.Fatalf("保留帳號編號失敗: %v", err)
+	}
+	if first != 4 {
+		t.Fatalf("第一個編號 = %d，預期 4", first)
+	}
+	first, err = store.Users().ReserveAccountNumbers(ctx, "pi_", 1)
+	if err != nil {
+		t.Fatalf("保留帳號編號失敗: %v", err)
+	}
+	if first != 10000 {
+		t.Fatalf("pi_ 前綴的第一個編號 = %d，預期 10000", first)
+	}
+	first, err = store.Users().ReserveAccountNumbers(ctx, "p_", 1)
+	if err != nil {
+		t.Fatalf("保留帳號編號失敗: %v", err)
+	}
+	if first != 1 {
+		t.Fatalf("p_ 前綴不應比對到 pi 開頭的帳號，第一個編號 = %d", first)
+	}
+}
+
+func TestSQLiteDeleteAvailableSlotKeepsBookedSlots(t *testing.T) {
+	store := newSQLiteStore(t)
+	ctx := context.Background()
+
+	ids, err := store.Users().BatchCreateUsers(ctx, newUsers("doctor", 1, 1))
+	if err != nil {
+		t.Fatalf("建立使用者失敗: %v", err)
+	}
+	day := time.Date(2026, 1, 5, 0, 0, 0, 0, time.Local)
+	slots := []*models.AvailableSlot{
+		{Doctor: ids[0], SlotDate: day, SlotBeginTime: day.Add(9 * time.Hour), SlotEndTime: day.Add(10 * time.Hour), IsBooked: true},
+		{Doctor: ids[0], SlotDate: day, SlotBeginTime: day.Add(10 * time.Hour), SlotEndTime: day.Add(11 * time.Hour)},
+	}
+	if err := store.Slots().BatchCreateAvailableSlots(ctx, slots); err != nil {
+		t.Fatalf("建立時段失敗: %v", err)
+	}
+
+	// 刪除條件包含 is_booked，已預約的時段不會被刪除
+	if err := store.Slots().DeleteAvailableSlot(ctx, slots[0].ID); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("刪除已預約的時段應回傳 ErrConflict，實際為 %v", err)
+	}
+	if _, err := store.Slots().GetAvailableSlotByID(ctx, slots[0].ID); err != nil {
+		t.Fatalf("已預約的時段應保留: %v", err)
+	}
+	if err := store.Slots().DeleteAvailableSlot(ctx, slots[1].ID); err != nil {
+		t.Fatalf("刪除時段失敗: %v", err)
+	}
+	if err := store.Slots().DeleteAvailableSlot(ctx, slots[1].ID); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("刪除不存在的時段應回傳 ErrNotFound，實際為 %v", err)
+	}
+}
+
+func TestSQLiteSlotsAndBatchPurge(t *testing.T) {
+	store := newSQLiteStore(t)
+	ctx := context.Background()
+
+	ids, err := store.Users().BatchCreateUsers(ctx, newUsers("doctor", 1, 1))
+	if err != nil {
+		t.Fatalf("建立使用者失敗: %v", err)
+	}
+	day := time.Date(2026, 1, 5, 0, 0, 0, 0, time.Local)
+	slots := []*models.AvailableSlot{{
+		Doctor:        ids[0],
+		SlotDate:      day,
+		SlotBeginTime: day.Add(9 * time.Hour),
+		SlotEndTime:   day.Add(10 * time.Hour),
+	}}
+	if err := store.Slots().BatchCreateAvailableSlots(ctx, slots); err != nil {
+		t.Fatalf("建立時段失敗: %v", err)
+	}
+	got, err := store.Slots().GetAvailableSlotByID(ctx, slots[0].ID)
+	if err != nil {
+		t.Fatalf("獲取時段失敗: %v", err)
+	}
+	if !got.SlotBeginTime.Equal(slots[0].SlotBeginTime) || !got.SlotDate.Equal(day) {
+		t.Fatalf("時段資料不符: %+v", got)
+	}
+
+	batch := &models.GenerationBatch{ID: "test-batch", Kind: models.BatchKindUsers, CreatedAt: time.Now()}
+	if err := store.Batches().RecordGenerationBatch(ctx, batch, models.BatchEntityUser, ids); err != nil {
+		t.Fatalf("記錄批次失敗: %v", err)
+	}
+	batches, err := store.Batches().ListGenerationBatches(ctx, 10)
+	if err != nil || len(batches) != 1 || batches[0].Counts[models.BatchEntityUser] != 1 {
+		t.Fatalf("批次列表不符: %v (%v)", batches, err)
+	}
+
+	preview, err := store.Batches().PurgeGenerationBatch(ctx, batch.ID, true)
+	if err != nil {
+		t.Fatalf("預覽清除批次失敗: %v", err)
+	}
+	if user, _ := store.Users().GetByID(ctx, fmt.Sprint(ids[0])); user == nil {
+		t.Fatalf("預覽不應刪除資料: %+v", preview)
+	}
+
+	if _, err := store.Batches().PurgeGenerationBatch(ctx, batch.ID, false); err != nil {
+		t.Fatalf("清除批次失敗: %v", err)
+	}
+	if user, _ := store.Users().GetByID(ctx, fmt.Sprint(ids[0])); user != nil {
+		t.Fatalf("清除批次後使用者仍存在")
+	}
+	if remaining, _ := store.Slots().GetAvailableSlotsByDoctor(ctx, ids[0]); len(remaining) != 0 {
+		t.Fatalf("清除批次後仍有 %d 個時段", len(remaining))
+	}
+}
+
+// assertNoUsers 確認交易回滾後沒有留下使用者與角色
+func assertNoUsers(t *testing.T, store repository.Store) {
+	t.Helper()
+	ctx := context.Background()
+	if users, _ := store.Users().ListUsers(ctx, 10); len(users) != 0 {
+		t.Fatalf("交易回滾後仍有 %d 位使用者", len(users))
+	}
+	if doctors, _ := store.Users().GetUserByRoleID(ctx, 3); len(doctors) != 0 {
+		t.Fatalf("交易回滾後仍有 %d 位使用者具有角色", len(doctors))
+	}
+}
+
+// createDoctors 在 ctx 中建立使用者並指派醫師角色，模擬跨多個 Repository 的流程
+func createDoctors(ctx context.Context, store repository.Store) error {
+	ids, err := store.Users().BatchCreateUsers(ctx, newUsers("doctor", 1, 2))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := store.Roles().AssignRoleToUser(ctx, id, []int64{3}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestSQLiteWithTxCommits(t *testing.T) {
+	store := newSQLiteStore(t)
+	ctx := context.Background()
+
+	err := store.WithTx(ctx, func(ctx context.Context) error {
+		// 巢狀 WithTx 沿用外層交易
+		return store.WithTx(ctx, func(ctx context.Context) error {
+			return createDoctors(ctx, store)
+		})
+	})
+	if err != nil {
+		t.Fatalf("WithTx 失敗: %v", err)
+	}
+	if doctors, _ := store.Users().GetUserByRoleID(ctx, 3); len(doctors) != 2 {
+		t.Fatalf("提交後應有 2 位醫師，實際 %d 位", len(doctors))
+	}
+}
+
+func TestSQLiteWithTxRollsBackOnError(t *testing.T) {
+	store := newSQLiteStore(t)
+
+	wantErr := fmt.Errorf("中途失敗")
+	err := store.WithTx(context.Background(), func(ctx context.Context) error {
+		if err := createDoctors(ctx, store); err != nil {
+			return err
+		}
+		return wantErr
+	})
+	if err != wantErr {
+		t.Fatalf("WithTx 回傳 %v，預期 %v", err, wantErr)
+	}
+	assertNoUsers(t, store)
+}
+
+func TestSQLiteWithTxRollsBackOnPanic(t *testing.T) {
+	store := newSQLiteStore(t)
+
+	func() {
+		defer func() {
+			if p := recover(); p == nil {
+				t.Fatal("WithTx 應繼續拋出 panic")
+			}
+		}()
+		store.WithTx(context.Background(), func(ctx context.Context) error {
+			if err := createDoctors(ctx, store); err != nil {
+				return err
+			}
+			panic("中途 panic")
+		})
+	}()
+	assertNoUsers(t, store)
+}
+
+func TestSQLiteWithTxRollsBackOnCancel(t *testing.T) {
+	store := newSQLiteStore(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := store.WithTx(ctx, func(ctx context.Context) error {
+		if err := createDoctors(ctx, store); err != nil {
+			return err
+		}
+		cancel()
+		return nil
+	})
+	if err == nil {
+		t.Fatal("ctx 取消後 WithTx 應回傳錯誤")
+	}
+	assertNoUsers(t, store)
+}
+
+func TestSQLiteTypedErrors(t *testing.T) {
+	store := newSQLiteStore(t)
+	ctx := context.Background()
+
+	if _, err := store.Users().BatchCreateUsers(ctx, newUsers("doctor", 1, 1)); err != nil {
+		t.Fatalf("建立使用者失敗: %v", err)
+	}
+	if _, err := store.Users().BatchCreateUsers(ctx, newUsers("doctor", 1, 1)); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("重複帳號應回傳 ErrConflict，實際為 %v", err)
+	}
+	if err := store.Users().Create(ctx, newUsers("doctor", 1, 1)[0]); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("重複帳號應回傳 ErrConflict，實際為 %v", err)
+	}
+	if _, err := store.Users().GetByID(ctx, "999"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("不存在的使用者應回傳 ErrNotFound，實際為 %v", err)
+	}
+	if _, err := store.Slots().GetAvailableSlotByID(ctx, 999); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("不存在的時段應回傳 ErrNotFound，實際為 %v", err)
+	}
+	if _, err := store.Batches().PurgeGenerationBatch(ctx, "missing", true); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("不存在的批次應回傳 ErrNotFound，實際為 %v", err)
+	}
+}
+
+func TestSQLitePatientsAndLookups(t *testing.T) {
+	store := newSQLiteStore(t)
+	ctx := context.Background()
+
+	if _, err := store.Users().BatchCreateUsers(ctx, newUsers("doctor", 1, 1)); err != nil {
+		t.Fatalf("BatchCreateUsers 失敗: %v", err)
+	}
+	user, err := store.Users().GetByAccount(ctx, "doctor0001")
+	if err != nil || user.Email != "doctor0001@example.com" {
+		t.Fatalf("GetByAccount = %+v, %v", user, err)
+	}
+	if _, err := store.Users().GetByAccount(ctx, "nobody"); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("GetByAccount 找不到時應回傳 ErrNotFound，得到 %v", err)
+	}
+
+	birth := time.Date(1980, 5, 17, 0, 0, 0, 0, time.Local)
+	patient := &models.Patient{UserID: user.ID, Name: "王小明", Gender: "M", IDNo: "A123456789", Age: 44, Birth: birth, City: "臺北市"}
+	patientID, err := store.Patients().CreatePatient(ctx, patient)
+	if err != nil {
+		t.Fatalf("CreatePatient 失敗: %v", err)
+	}
+	if err := store.Patients().AddHistoryDisease(ctx, patientID, "糖尿病", 5); err != nil {
+		t.Fatalf("AddHistoryDisease 失敗: %v", err)
+	}
+	if err := store.Patients().AddMedicalHistory(ctx, patientID, "高血壓"); err != nil {
+		t.Fatalf("AddMedicalHistory 失敗: %v", err)
+	}
+
+	got, err := store.Patients().GetPatient(ctx, patientID)
+	if err != nil {
+		t.Fatalf("GetPatient 失敗: %v", err)
+	}
+	if got.Name != patient.Name || got.UserID != user.ID || !got.Birth.Equal(birth) {
+		t.Errorf("GetPatient = %+v", got)
+	}
+	if len(got.HistoryDiseases) != 1 || len(got.MedicalHistories) != 1 {
+		t.Errorf("病史或醫療史不正確: %v, %v", got.HistoryDiseases, got.MedicalHistories)
+	}
+	if _, err := store.Patients().GetPatient(ctx, patientID+1); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("GetPatient 找不到時應回傳 ErrNotFound，得到 %v", err)
+	}
+	if exists, err := store.Patients().PatientExistsByIDNo(ctx, "A123456789"); err != nil || !exists {
+		t.Errorf("PatientExistsByIDNo = %v, %v", exists, err)
+	}
+	if patients, err := store.Patients().ListPatients(ctx, 10); err != nil || len(patients) != 1 {
+		t.Errorf("ListPatients = %d, %v", len(patients), err)
+	}
+
+	// 同一個批次可記錄多種實體類型
+	batch := &models.GenerationBatch{ID: "copy-1", Kind: models.BatchKindCopy, CreatedAt: time.Now()}
+	if err := store.Batches().RecordGenerationBatch(ctx, batch, models.BatchEntityUser, []int64{user.ID}); err != nil {
+		t.Fatalf("RecordGenerationBatch 失敗: %v", err)
+	}
+	if err := store.Batches().AddGenerationBatchItems(ctx, batch.ID, models.BatchEntityPatient, []int64{patientID}); err != nil {
+		t.Fatalf("AddGenerationBatchItems 失敗: %v", err)
+	}
+	result, err := store.Batches().PurgeGenerationBatch(ctx, batch.ID, false)
+	if err != nil {
+		t.Fatalf("PurgeGenerationBatch 失敗: %v", err)
+	}
+	for _, step := range result.Counts {
+		if (step.Table == "patient" || step.Table == "user") && step.Rows != 1 {
+			t.Errorf("%s 應刪除 1 筆，實際 %d 筆", step.Table, step.Rows)
+		}
+	}
+}
+
+func TestSQLiteJobLifecycle(t *testing.T) {
+	store := newSQLiteStore(t)
+	ctx := context.Background()
+	jobs := store.Jobs()
+
+	created := time.Now().Truncate(time.Second)
+	job := &models.Job{
+		ID:        "job-1",
+		Kind:      models.BatchKindUsers,
+		Status:    models.JobStatusQueued,
+		Params:    []byte(`{"count":5}`),
+		Progress:  models.JobProgress{Total: 5},
+		CreatedAt: created,
+
+		AuditRequest: &models.AuditRequest{Actor: "alice", RequestID: "req-1"},
+	}
+	if err := jobs.CreateJob(ctx, job); err != nil {
+		t.Fatalf("建立工作失敗: %v", err)
+	}
+	if err := jobs.CreateJob(ctx, job); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("重複建立工作應回傳 ErrConflict，實際為 %v", err)
+	}
+	if _, err := jobs.GetJob(ctx, "missing"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("找不到工作應回傳 ErrNotFound，實際為 %v", err)
+	}
+
+	if err := jobs.StartJob(ctx, job.ID, "worker-a", created.Add(time.Second)); err != nil {
+		t.Fatalf("開始工作失敗: %v", err)
+	}
+	if err := jobs.StartJob(ctx, job.ID, "worker-b", created); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("重複開始工作應回傳 ErrConflict，實際為 %v", err)
+	}
+	if err := jobs.CancelQueuedJob(ctx, job.ID, created); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("取消執行中的工作應回傳 ErrConflict，實際為 %v", err)
+	}
+	progress := models.JobProgress{Stage: models.JobStageUsers, Done: 2, Total: 5}
+	heartbeat := created.Add(30 * time.Second)
+	if err := jobs.UpdateJobProgress(ctx, job.ID, "worker-a", progress, heartbeat); err != nil {
+		t.Fatalf("更新工作進度失敗: %v", err)
+	}
+	if err := jobs.UpdateJobProgress(ctx, job.ID, "worker-b", progress, heartbeat); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("其他程序更新進度應回傳 ErrConflict，實際為 %v", err)
+	}
+	if got, err := jobs.GetJob(ctx, job.ID); err != nil || got.Progress != progress ||
+		got.Owner != "worker-a" || got.HeartbeatAt == nil || !got.HeartbeatAt.Equal(heartbeat) {
+		t.Fatalf("工作進度 = %+v (%v)，預期 %+v", got, err, progress)
+	}
+	running, err := jobs.ListJobsByStatus(ctx, models.JobStatusRunning)
+	if err != nil || len(running) != 1 || running[0].StartedAt == nil {
+		t.Fatalf("執行中的工作不符: %v (%v)", running, err)
+	}
+
+	// 其他程序只能放回心跳逾時的工作
+	if err := jobs.RequeueJob(ctx, job.ID, "worker-b"); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("其他程序放回工作應回傳 ErrConflict，實際為 %v", err)
+	}
+	if err := jobs.RequeueStaleJob(ctx, job.ID, heartbeat); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("放回仍有心跳的工作應回傳 ErrConflict，實際為 %v", err)
+	}
+	if err := jobs.RequeueStaleJob(ctx, job.ID, heartbeat.Add(time.Second)); err != nil {
+		t.Fatalf("放回心跳逾時的工作失敗: %v", err)
+	}
+	if got, _ := jobs.GetJob(ctx, job.ID); got.Status != models.JobStatusQueued || got.Owner != "" || got.HeartbeatAt != nil {
+		t.Fatalf("放回佇列的工作應清除執行的程序: %+v", got)
+	}
+	if err := jobs.StartJob(ctx, job.ID, "worker-a", created.Add(time.Second)); err != nil {
+		t.Fatalf("重新開始工作失敗: %v", err)
+	}
+	if err := jobs.RequeueJob(ctx, job.ID, "worker-a"); err != nil {
+		t.Fatalf("放回佇列失敗: %v", err)
+	}
+	if err := jobs.StartJob(ctx, job.ID, "worker-b", created.Add(2*time.Second)); err != nil {
+		t.Fatalf("重新開始工作失敗: %v", err)
+	}
+
+	finished := created.Add(3 * time.Second)
+	job.Status = models.JobStatusSucceeded
+	job.Result = &models.JobResult{Created: 5, BatchID: "batch-1", Skipped: []string{"略過"}}
+	job.Progress = models.JobProgress{Stage: models.JobStageRoles, Done: 5, Total: 5}
+	job.FinishedAt = &finished
+	job.Owner = "worker-a"
+	if err := jobs.FinishJob(ctx, job); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("已由其他程序接手的工作不應記錄結果，實際為 %v", err)
+	}
+	job.Owner = "worker-b"
+	if err := jobs.FinishJob(ctx, job); err != nil {
+		t.Fatalf("記錄工作結果失敗: %v", err)
+	}
+
+	got, err := jobs.GetJob(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("獲取工作失敗: %v", err)
+	}
+	if got.Status != models.JobStatusSucceeded || got.Result == nil || got.Result.BatchID != "batch-1" ||
+		len(got.Result.Skipped) != 1 || got.Progress != job.Progress || got.FinishedAt == nil ||
+		string(got.Params) != `{"count":5}` || !got.CreatedAt.Equal(created) {
+		t.Fatalf("工作資料不符: %+v", got)
+	}
+	if got.AuditRequest == nil || *got.AuditRequest != *job.AuditRequest {
+		t.Fatalf("工作的請求資訊 = %+v，預期 %+v", got.AuditRequest, job.AuditRequest)
+	}
+
+	queued := &models.Job{ID: "job-2", Kind: models.BatchKindSlots, Status: models.JobStatusQueued, Params: []byte(`{}`), CreatedAt: created.Add(time.Minute)}
+	if err := jobs.CreateJob(ctx, queued); err != nil {
+		t.Fatalf("建立工作失敗: %v", err)
+	}
+	if err := jobs.UpdateJobProgress(ctx, queued.ID, "worker-a", progress, heartbeat); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("更新排隊中工作的進度應回傳 ErrConflict，實際為 %v", err)
+	}
+	if err := jobs.CancelQueuedJob(ctx, queued.ID, finished); err != nil {
+		t.Fatalf("取消排隊中的工作失敗: %v", err)
+	}
+	list, err := jobs.ListJobs(ctx, 10)
+	if err != nil || len(list) != 2 || list[0].ID != queued.ID || list[0].Status != models.JobStatusCancelled {
+		t.Fatalf("工作列表應依建立時間由新到舊排列: %v (%v)", list, err)
+	}
+	if list[0].AuditRequest != nil {
+		t.Fatalf("未帶請求資訊的工作不應有 AuditRequest: %+v", list[0].AuditRequest)
+	}
+}
+
+func TestSQLiteAuditLog(t *testing.T) {
+	store := newSQLiteStore(t)
+	ctx := context.Background()
+	audit := store.Audit()
+
+	day := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+	entries := []*models.AuditEntry{
+		{CreatedAt: day, Action: models.AuditActionCreate, EntityType: models.AuditEntityRole, EntityID: "8",
+			After:        []byte(`{"alias":"AUDITOR"}`),
+			Changes:      []models.AuditChange{{Field: "alias", After: []byte(`"AUDITOR"`)}},
+			AuditRequest: models.AuditRequest{Actor: "alice", RequestID: "req-1", Method: "POST", Path: "/roles/add"}},
+		{CreatedAt: day.Add(time.Hour), Action: models.AuditActionDelete, EntityType: models.AuditEntityRole, EntityID: "8",
+			Before:       []byte(`{"alias":"AUDITOR"}`),
+			Changes:      []models.AuditChange{{Field: "alias", Before: []byte(`"AUDITOR"`)}},
+			AuditRequest: models.AuditRequest{Actor: "bob"}},
+		{CreatedAt: day.AddDate(0, 0, 1), Action: models.AuditActionUpdate, EntityType: models.AuditEntitySlot, EntityID: "3",
+			Before: []byte(`{"is_booked":false}`), After: []byte(`{"is_booked":true}`),
+			Changes:      []models.AuditChange{{Field: "is_booked", Before: []byte(`false`), After: []byte(`true`)}},
+			AuditRequest: models.AuditRequest{Actor: "alice"}},
+	}
+	for _, entry := range entries {
+		if err := audit.RecordAudit(ctx, entry); err != nil {
+			t.Fatalf("新增稽核紀錄失敗: %v", err)
+		}
+		if entry.ID == 0 {
+			t.Fatal("新增稽核紀錄後應回填ID")
+		}
+	}
+
+	all, err := audit.ListAudit(ctx, models.AuditFilter{})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("ListAudit = %d 筆 (%v)，預期 3 筆", len(all), err)
+	}
+	if all[0].ID != entries[2].ID || all[2].ID != entries[0].ID {
+		t.Errorf("稽核紀錄應由新到舊排序: %d, %d, %d", all[0].ID, all[1].ID, all[2].ID)
+	}
+	first := all[2]
+	if first.Before != nil || string(first.After) != `{"alias":"AUDITOR"}` || first.RequestID != "req-1" || first.Path != "/roles/add" {
+		t.Errorf("稽核紀錄未正確保存: %+v", first)
+	}
+	if len(first.Changes) != 1 || first.Changes[0].Before != nil || string(first.Changes[0].After) != `"AUDITOR"` {
+		t.Errorf("異動未正確保存: %+v", first.Changes)
+	}
+
+	cases := []struct {
+		name   string
+		filter models.AuditFilter
+		want   []int64
+	}{
+		{"actor", models.AuditFilter{Actor: "alice"}, []int64{entries[2].ID, entries[0].ID}},
+		{"entity", models.AuditFilter{EntityType: models.AuditEntityRole, EntityID: "8", Action: models.AuditActionDelete}, []int64{entries[1].ID}},
+		{"date range", models.AuditFilter{From: day, To: day.AddDate(0, 0, 1)}, []int64{entries[1].ID, entries[0].ID}},
+		{"limit", models.AuditFilter{Limit: 1}, []int64{entries[2].ID}},
+	}
+	for _, tc := range cases {
+		got, err := audit.ListAudit(ctx, tc.filter)
+		if err != nil {
+			t.Fatalf("%s: ListAudit returned error: %v", tc.name, err)
+		}
+		ids := make([]int64, 0, len(got))
+		for _, entry := range got {
+			ids = append(ids, entry.ID)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: IDs = %v, want %v", tc.name, ids, tc.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"golang-gin-app/internal/tracing"
+)
+
+// tracedConn 包裝 dbConn，為每個語句建立名為 statement 的追蹤 span 並記錄錯誤。
+// 查詢的 span 只涵蓋執行查詢，不含之後讀取結果列的時間。
+type tracedConn struct {
+	conn      dbConn
+	system    string
+	statement string
+}
+
+func (c tracedConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := tracing.StartSQL(ctx, c.system, c.statement, query)
+	result, err := c.conn.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (c tracedConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := tracing.StartSQL(ctx, c.system, c.statement, query)
+	rows, err := c.conn.QueryContext(ctx, query, args...)
+	tracing.End(span, err)
+	return rows, err
+}
+
+func (c tracedConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := tracing.StartSQL(ctx, c.system, c.statement, query)
+	row := c.conn.QueryRowContext(ctx, query, args...)
+	// sql.ErrNoRows 要到 Scan 時才知道，不視為錯誤
+	tracing.End(span, row.Err())
+	return row
+}
+
+func (c tracedConn) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := tracing.StartSQL(ctx, c.system, c.statement, query)
+	stmt, err := c.conn.PrepareContext(ctx, query)
+	tracing.End(span, err)
+	return stmt, err
+}
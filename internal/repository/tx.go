@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"golang-gin-app/internal/tracing"
+)
+
+// sqlTxKey 是 context 中存放進行中 SQL 交易的鍵
+type sqlTxKey struct{}
+
+// sqlTxState 記錄交易所屬的資料庫，避免同一個 ctx 被用於其他資料庫時誤用交易
+type sqlTxState struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+// txFromContext 取得 ctx 中屬於此資料庫的交易，沒有時回傳 nil
+func (c *sqlConn) txFromContext(ctx context.Context) *sql.Tx {
+	state, ok := ctx.Value(sqlTxKey{}).(*sqlTxState)
+	if !ok || state.db != c.sqlDB {
+		return nil
+	}
+	return state.tx
+}
+
+// runTx 開始新的交易並將其放入 ctx 後執行 fn。
+// fn 回傳錯誤或 panic 時回滾（panic 會在回滾後繼續往上拋出）；
+// ctx 在 fn 結束前被取消時也會回滾並回傳 ctx 的錯誤，否則提交。
+func (c *sqlConn) runTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	ctx, span := tracing.StartSQL(ctx, string(c.dialect), "transaction", "")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := c.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("開始事務失敗: %v", err)
+	}
+
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		// 回滾失敗（例如 ctx 取消時 database/sql 已自動回滾）不影響回傳的錯誤
+		tx.Rollback()
+		if p := recover(); p != nil {
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, sqlTxKey{}, &sqlTxState{db: c.sqlDB, tx: tx})); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("交易已取消: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事務失敗: %v", err)
+	}
+	committed = true
+	return nil
+}
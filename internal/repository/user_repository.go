@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"golang-gin-app/internal/models"
+)
+
+// sqlUserRepository 是 UserRepository 的 SQL 實作
+type sqlUserRepository struct {
+	*sqlConn
+}
+
+// Create inserts a new user into the database and sets user.ID.
+func (r *sqlUserRepository) Create(ctx context.Context, user *models.User) error {
+	query := `
+        INSERT INTO user (account, create_time, email, last_login_date, password, status, steam_id, tel_cell, username)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := r.db(ctx, "users.Create").ExecContext(ctx, query,
+		user.Account, user.CreateTime, user.Email, user.LastLoginDate,
+		user.Password, user.Status, user.SteamID, user.TelCell, user.Username)
+	if isUniqueViolation(err) {
+		return models.Conflictf("帳號 %s 已存在", user.Account)
+	}
+	if err != nil {
+		return err
+	}
+	user.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetByID retrieves a user by their ID from the database; returns models.ErrNotFound when missing.
+func (r *sqlUserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+	query := `SELECT ID, account, create_time, email, last_login_date, password, status, steam_id, tel_cell, username
+              FROM user WHERE ID = ?`
+	row := r.db(ctx, "users.GetByID").QueryRowContext(ctx, query, id)
+	user := &models.User{}
+	var telCell sql.NullString
+	var username sql.NullString
+	err := row.Scan(&user.ID, &user.Account, &user.CreateTime, &user.Email, &user.LastLoginDate,
+		&user.Password, &user.Status, &user.SteamID, &telCell, &username)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFoundf("未找到ID為 %s 的使用者", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 處理 tel_cell 的 NULL 值
+	if telCell.Valid {
+		user.TelCell = &telCell.String
+	} else {
+		user.TelCell = nil
+	}
+
+	// 處理 username 的 NULL 值
+	if username.Valid {
+		user.Username = &username.String
+	} else {
+		user.Username = nil
+	}
+
+	return user, nil
+}
+
+// GetByAccount 依帳號取得使用者，找不到時回傳 models.ErrNotFound
+func (r *sqlUserRepository) GetByAccount(ctx context.Context, account string) (*models.User, error) {
+	query := `SELECT ID, account, create_time, email, last_login_date, password, status, steam_id, tel_cell, username
+              FROM user WHERE account = ?`
+	row := r.db(ctx, "users.GetByAccount").QueryRowContext(ctx, query, account)
+	user := &models.User{}
+	var telCell sql.NullString
+	var username sql.NullString
+	err := row.Scan(&user.ID, &user.Account, &user.CreateTime, &user.Email, &user.LastLoginDate,
+		&user.Password, &user.Status, &user.SteamID, &telCell, &username)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFoundf("未找到帳號為 %s 的使用者", account)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if telCell.Valid {
+		user.TelCell = &telCell.String
+	}
+	if username.Valid {
+		user.Username = &username.String
+	}
+	return user, nil
+}
+
+// Update modifies an existing user in the database.
+func (r *sqlUserRepository) Update(ctx context.Context, user *models.User) error {
+	query := `
+        UPDATE user SET account = ?, create_time = ?, email = ?, last_login_date = ?,
+        password = ?, status = ?, steam_id = ?, tel_cell = ?, username = ?
+        WHERE ID = ?`
+	_, err := r.db(ctx, "users.Update").ExecContext(ctx, query,
+		user.Account, user.CreateTime, user.Email, user.LastLoginDate,
+		user.Password, user.Status, user.SteamID, user.TelCell, user.Username, user.ID)
+	return err
+}
+
+// Delete removes a user from the database by their ID.
+func (r *sqlUserRepository) Delete(ctx context.Context, id string) error {
+	query := `DELETE FROM user WHERE ID = ?`
+	_, err := r.db(ctx, "users.Delete").ExecContext(ctx, query, id)
+	return err
+}
+
+// BatchCreateUsers inserts multiple users into the database in a batch and returns their IDs.
+func (r *sqlUserRepository) BatchCreateUsers(ctx context.Context, users []*models.User) ([]int64, error) {
+	if len(users) == 0 {
+		return []int64{}, nil
+	}
+	query := `
+        INSERT INTO user (account, create_time, email, last_login_date, password, status, steam_id, tel_cell, username)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	userIDs := make([]int64, 0, len(users))
+	err := r.withTx(ctx, "users.BatchCreateUsers", func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, user := range users {
+			res, err := stmt.ExecContext(ctx,
+				user.Account, user.CreateTime, user.Email, user.LastLoginDate,
+				user.Password, user.Status, user.SteamID, user.TelCell, user.Username)
+			if isUniqueViolation(err) {
+				return models.Conflictf("帳號 %s 已存在", user.Account)
+			}
+			if err != nil {
+				return err
+			}
+
+			// 獲取新插入用戶的 ID
+			id, err := res.LastInsertId()
+			if err != nil {
+				return err
+			}
+			userIDs = append(userIDs, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// ListUsers retrieves a list of users from the database, limited by the specified number.
+func (r *sqlUserRepository) ListUsers(ctx context.Context, limit int) ([]*models.User, error) {
+	if limit <= 0 {
+		limit = 10 // Default limit if none specified
+	}
+	query := `SELECT ID, account, create_time, email, last_login_date, password, status, steam_id, tel_cell, username
+              FROM user ORDER BY ID DESC LIMIT ?`
+	rows, err := r.db(ctx, "users.ListUsers").QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := make([]*models.User, 0)
+	for rows.Next() {
+		user := &models.User{}
+		var telCell sql.NullString
+		var username sql.NullString
+		err := rows.Scan(&user.ID, &user.Account, &user.CreateTime, &user.Email, &user.LastLoginDate,
+			&user.Password, &user.Status, &user.SteamID, &telCell, &username)
+		if err != nil {
+			return nil, err
+		}
+
+		// 處理 tel_cell 的 NULL 值
+		if telCell.Valid {
+			user.TelCell = &telCell.String
+		} else {
+			user.TelCell = nil
+		}
+
+		// 處理 username 的 NULL 值
+		if username.Valid {
+			user.Username = &username.String
+		} else {
+			user.Username = nil
+		}
+
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// ListUsersWithRoles 獲取包含角色資訊的用戶列表
+func (r *sqlUserRepository) ListUsersWithRoles(ctx context.Context, limit int) ([]*models.User, error) {
+	if limit <= 0 {
+		limit = 10 // Default limit if none specified
+	}
+
+	// 先獲取用戶基本資訊
+	users, err := r.ListUsers(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// 為每個用戶添加角色資訊
+	for _, user := range users {
+		roles, err := queryUserRoles(ctx, r.db(ctx, "users.ListUsersWithRoles"), user.ID)
+		if err != nil {
+			return nil, err
+		}
+		user.Roles = roles
+	}
+
+	return users, nil
+}
+
+// GetUserByRoleID 獲取特定角色的用戶列表
+func (r *sqlUserRepository) GetUserByRoleID(ctx context.Context, roleID int64) ([]*models.User, error) {
+	query := `
+		SELECT u.ID, u.account, u.create_time, u.email, u.last_login_date, 
+		       u.password, u.status, u.steam_id, u.tel_cell, u.username
+		FROM user u
+		JOIN user_role ur ON u.ID = ur.user_id
+		WHERE ur.role_id = ?
+		ORDER BY u.ID DESC
+	`
+	rows, err := r.db(ctx, "users.GetUserByRoleID").QueryContext(ctx, query, roleID)
+	if err != nil {
+		return nil, fmt.Errorf("獲取角色用戶列表失敗: %v", err)
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0)
+	for rows.Next() {
+		user := &models.User{}
+		var telCell sql.NullString
+		var username sql.NullString
+		err := rows.Scan(&user.ID, &user.Account, &user.CreateTime, &user.Email, &user.LastLoginDate,
+			&user.Password, &user.Status, &user.SteamID, &telCell, &username)
+		if err != nil {
+			return nil, fmt.Errorf("掃描用戶數據失敗: %v", err)
+		}
+
+		// 處理 tel_cell 的 NULL 值
+		if telCell.Valid {
+			user.TelCell = &telCell.String
+		} else {
+			user.TelCell = nil
+		}
+
+		// 處理 username 的 NULL 值
+		if username.Valid {
+			user.Username = &username.String
+		} else {
+			user.Username = nil
+		}
+
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("讀取用戶數據失敗: %v", err)
+	}
+	rows.Close()
+
+	// 讀完用戶列表後再查詢角色，避免在單一連線的資料庫（如 SQLite）上同時佔用兩個查詢
+	for _, user := range users {
+		roles, err := queryUserRoles(ctx, r.db(ctx, "users.GetUserByRoleID"), user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("獲取用戶 %d 的角色失敗: %v", user.ID, err)
+		}
+		user.Roles = roles
+	}
+	return users, nil
+}
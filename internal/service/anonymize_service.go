@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/tracing"
+)
+
+// anonymizeSampleSize 去識別化結果中保留的對照筆數
+const anonymizeSampleSize = 20
+
+// AnonymizePatients 就地將病患個資替換為假名，病史與醫療史維持不變。
+// patientIDs 為空時處理所有病患；所有更新在同一個交易中完成。
+// dryRun 時不寫入，只回傳筆數與前幾筆的對照。
+func (s *Service) AnonymizePatients(ctx context.Context, patientIDs []int64, dryRun bool) (*models.AnonymizeResult, error) {
+	ctx, span := tracing.Start(ctx, "Service.AnonymizePatients")
+	defer span.End()
+
+	if s.anonymizer == nil {
+		return nil, models.Validationf("未設定去識別化金鑰")
+	}
+
+	result := &models.AnonymizeResult{DryRun: dryRun, Samples: make([]models.AnonymizedPatient, 0)}
+	err := s.store.WithTx(ctx, func(ctx context.Context) error {
+		ids := patientIDs
+		if len(ids) == 0 {
+			var err error
+			if ids, err = s.store.Patients().ListPatientIDs(ctx); err != nil {
+				return err
+			}
+		}
+
+		for _, id := range ids {
+			patient, err := s.store.Patients().GetPatient(ctx, id)
+			if err != nil {
+				return err
+			}
+			anonymized := s.anonymizer.Patient(patient)
+			if !dryRun {
+				if err := s.store.Patients().UpdatePatient(ctx, anonymized); err != nil {
+					return fmt.Errorf("去識別化病患 %d 失敗: %w", id, err)
+				}
+			}
+
+			result.Count++
+			if len(result.Samples) < anonymizeSampleSize {
+				result.Samples = append(result.Samples, models.AnonymizedPatient{
+					ID: id, Name: patient.Name, Pseudonym: anonymized.Name,
+				})
+			}
+		}
+		if dryRun {
+			return nil
+		}
+		// 只記錄病患ID，記錄個資會違背去識別化的目的
+		return s.recordAudit(ctx, models.AuditActionAnonymize, models.AuditEntityPatient, "", nil, map[string]interface{}{
+			"count": result.Count, "patient_ids": ids,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		logging.FromContext(ctx).Info("已去識別化病患", "count", result.Count)
+	}
+	return result, nil
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/tracing"
+	"sort"
+	"time"
+)
+
+// MaxAuditEntries 單次查詢或匯出稽核紀錄的最大筆數
+const MaxAuditEntries = 10000
+
+type auditRequestKey struct{}
+
+// WithAuditRequest 回傳帶有操作者與請求資訊的 context，之後以該 context 進行的異動都以此記錄稽核紀錄
+func WithAuditRequest(ctx context.Context, req models.AuditRequest) context.Context {
+	return context.WithValue(ctx, auditRequestKey{}, req)
+}
+
+// auditRequestFromContext 取得 context 中的操作者與請求資訊，沒有時操作者為 models.AuditActorSystem
+func auditRequestFromContext(ctx context.Context) models.AuditRequest {
+	req, _ := ctx.Value(auditRequestKey{}).(models.AuditRequest)
+	if req.Actor == "" {
+		req.Actor = models.AuditActorSystem
+	}
+	return req
+}
+
+// recordAudit 記錄一筆稽核紀錄，before 與 after 為異動前後的快照，新增時 before 為 nil，刪除時 after 為 nil。
+// 應在異動所在的 WithTx 中呼叫，讓稽核紀錄與異動一起提交或回滾
+func (s *Service) recordAudit(ctx context.Context, action, entityType, entityID string, before, after interface{}) error {
+	entry := &models.AuditEntry{
+		CreatedAt:    time.Now(),
+		Action:       action,
+		EntityType:   entityType,
+		EntityID:     entityID,
+		AuditRequest: auditRequestFromContext(ctx),
+	}
+	var err error
+	if entry.Before, err = auditSnapshot(before); err != nil {
+		return err
+	}
+	if entry.After, err = auditSnapshot(after); err != nil {
+		return err
+	}
+	entry.Changes = diffAudit(entry.Before, entry.After)
+	if err := s.store.Audit().RecordAudit(ctx, entry); err != nil {
+		return fmt.Errorf("記錄稽核紀錄失敗: %w", err)
+	}
+	return nil
+}
+
+// auditSnapshot 將快照序列化為 JSON，nil 表示沒有快照
+func auditSnapshot(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("序列化稽核快照失敗: %v", err)
+	}
+	return data, nil
+}
+
+// diffAudit 比較兩個快照最上層的欄位，依欄位名稱排序回傳不同的欄位
+func diffAudit(before, after json.RawMessage) []models.AuditChange {
+	beforeFields, afterFields := auditFields(before), auditFields(after)
+	names := make([]string, 0, len(beforeFields)+len(afterFields))
+	for name := range beforeFields {
+		names = append(names, name)
+	}
+	for name := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := make([]models.AuditChange, 0)
+	for _, name := range names {
+		if !bytes.Equal(beforeFields[name], afterFields[name]) {
+			changes = append(changes, models.AuditChange{Field: name, Before: beforeFields[name], After: afterFields[name]})
+		}
+	}
+	return changes
+}
+
+// auditFields 將 JSON 物件快照拆成各欄位，快照為空時回傳空的 map
+func auditFields(snapshot json.RawMessage) map[string]json.RawMessage {
+	fields := make(map[string]json.RawMessage)
+	if len(snapshot) > 0 {
+		// 快照都由 auditSnapshot 以結構或 map 序列化，一定是 JSON 物件
+		_ = json.Unmarshal(snapshot, &fields)
+	}
+	return fields
+}
+
+// ListAuditEntries 依條件由新到舊獲取稽核紀錄，未指定筆數時為 100 筆
+func (s *Service) ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListAuditEntries")
+	defer span.End()
+
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+	if filter.Limit > MaxAuditEntries {
+		return nil, models.Validationf("筆數不可超過 %d", MaxAuditEntries)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.To.After(filter.From) {
+		return nil, models.Validationf("結束日期必須晚於開始日期")
+	}
+	return s.store.Audit().ListAudit(ctx, filter)
+}
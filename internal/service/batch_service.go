@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/tracing"
+	"time"
+)
+
+// newSortableID 產生以時間開頭、可排序的ID，用於生成批次與背景工作
+func newSortableID(now time.Time) string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return now.Format("20060102150405.000000")
+	}
+	return now.Format("20060102150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// recordBatch 為本次生成建立批次紀錄，並回傳批次ID；在 WithTx 中呼叫時與其他寫入一起提交
+func (s *Service) recordBatch(ctx context.Context, kind, entityType string, entityIDs []int64) (string, error) {
+	now := time.Now()
+	batch := &models.GenerationBatch{
+		ID:        newSortableID(now),
+		Kind:      kind,
+		CreatedAt: now,
+	}
+	if err := s.store.Batches().RecordGenerationBatch(ctx, batch, entityType, entityIDs); err != nil {
+		return "", fmt.Errorf("記錄生成批次失敗: %w", err)
+	}
+	return batch.ID, nil
+}
+
+// ListGenerationBatches 獲取最近的生成批次
+func (s *Service) ListGenerationBatches(ctx context.Context) ([]*models.GenerationBatch, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListGenerationBatches")
+	defer span.End()
+
+	return s.store.Batches().ListGenerationBatches(ctx, 100)
+}
+
+// PurgeGenerationBatch 清除批次建立的所有資料；dryRun 時只回傳將刪除的筆數
+func (s *Service) PurgeGenerationBatch(ctx context.Context, batchID string, dryRun bool) (*models.BatchPurgeResult, error) {
+	ctx, span := tracing.Start(ctx, "Service.PurgeGenerationBatch")
+	defer span.End()
+
+	if batchID == "" {
+		return nil, models.Validationf("請提供批次ID")
+	}
+	var result *models.BatchPurgeResult
+	err := s.store.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if result, err = s.store.Batches().PurgeGenerationBatch(ctx, batchID, dryRun); err != nil || dryRun {
+			return err
+		}
+		return s.recordAudit(ctx, models.AuditActionPurge, models.AuditEntityBatch, batchID,
+			map[string]interface{}{"counts": result.Counts}, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		logging.FromContext(ctx).Info("已清除生成批次", "batch_id", batchID, "counts", result.Counts)
+	}
+	return result, nil
+}
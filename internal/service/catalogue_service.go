@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/tracing"
+	"golang-gin-app/internal/utils"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Catalogue 快取從資料庫載入的疾病史與醫療史選項
+type Catalogue struct {
+	mu               sync.RWMutex
+	historyDiseases  []*models.HistoryDisease
+	diseaseIDs       map[string]int64
+	medicalHistories []string // 預設醫療史選項與醫療史枚舉的中文顯示名稱
+	loadedAt         time.Time
+}
+
+// CatalogueSnapshot 疾病目錄的唯讀快照，供頁面與 API 顯示
+type CatalogueSnapshot struct {
+	HistoryDiseases  []*models.HistoryDisease `json:"history_diseases"`
+	MedicalHistories []string                 `json:"medical_histories"`
+	LoadedAt         time.Time                `json:"loaded_at"`
+}
+
+// RefreshCatalogue 從資料庫重新載入疾病目錄
+func (s *Service) RefreshCatalogue(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "Service.RefreshCatalogue")
+	defer span.End()
+
+	diseases, err := s.store.Patients().ListHistoryDiseases(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 醫療史選項是固定的清單：預設選項加上醫療史枚舉的中文顯示名稱。
+	// 資料庫中已有的值不一定通過驗證（例如舊資料或直接寫入的值），因此不列入選項
+	medicalHistories := utils.DefaultMedicalHistories()
+	for _, code := range models.MedicalHistoryEnums() {
+		if name := code.DisplayName(); !slices.Contains(medicalHistories, name) {
+			medicalHistories = append(medicalHistories, name)
+		}
+	}
+
+	diseaseIDs := make(map[string]int64, len(diseases))
+	for _, disease := range diseases {
+		diseaseIDs[disease.DiseaseName] = disease.ID
+	}
+
+	s.catalogue.mu.Lock()
+	defer s.catalogue.mu.Unlock()
+	s.catalogue.historyDiseases = diseases
+	s.catalogue.diseaseIDs = diseaseIDs
+	s.catalogue.medicalHistories = medicalHistories
+	s.catalogue.loadedAt = time.Now()
+	return nil
+}
+
+// ensureCatalogue 疾病目錄尚未載入時從資料庫載入
+func (s *Service) ensureCatalogue(ctx context.Context) error {
+	s.catalogue.mu.RLock()
+	loaded := !s.catalogue.loadedAt.IsZero()
+	s.catalogue.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+	return s.RefreshCatalogue(ctx)
+}
+
+// GetCatalogue 取得目前的疾病目錄快照
+func (s *Service) GetCatalogue(ctx context.Context) (*CatalogueSnapshot, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetCatalogue")
+	defer span.End()
+
+	if err := s.ensureCatalogue(ctx); err != nil {
+		return nil, err
+	}
+	s.catalogue.mu.RLock()
+	defer s.catalogue.mu.RUnlock()
+	diseases := make([]*models.HistoryDisease, len(s.catalogue.historyDiseases))
+	copy(diseases, s.catalogue.historyDiseases)
+	medicalHistories := make([]string, len(s.catalogue.medicalHistories))
+	copy(medicalHistories, s.catalogue.medicalHistories)
+	return &CatalogueSnapshot{
+		HistoryDiseases:  diseases,
+		MedicalHistories: medicalHistories,
+		LoadedAt:         s.catalogue.loadedAt,
+	}, nil
+}
+
+// GetPatientCatalogue 取得供假病患生成器使用的病史目錄
+func (s *Service) GetPatientCatalogue(ctx context.Context) (*utils.PatientCatalogue, error) {
+	snapshot, err := s.GetCatalogue(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshot.HistoryDiseases) == 0 {
+		return nil, fmt.Errorf("history_disease 表中沒有任何疾病資料")
+	}
+	names := make([]string, 0, len(snapshot.HistoryDiseases))
+	for _, disease := range snapshot.HistoryDiseases {
+		names = append(names, disease.DiseaseName)
+	}
+	return &utils.PatientCatalogue{
+		HistoryDiseases:  names,
+		MedicalHistories: snapshot.MedicalHistories,
+	}, nil
+}
+
+// LookupHistoryDiseaseID 依疾病名稱取得 history_disease 的 ID
+func (s *Service) LookupHistoryDiseaseID(ctx context.Context, name string) (int64, bool, error) {
+	if err := s.ensureCatalogue(ctx); err != nil {
+		return 0, false, err
+	}
+	s.catalogue.mu.RLock()
+	defer s.catalogue.mu.RUnlock()
+	id, ok := s.catalogue.diseaseIDs[name]
+	return id, ok, nil
+}
+
+// resolveHistoryDisease 回傳病史在疾病目錄中的名稱。目錄中沒有時以 models.ParseHistoryDiseaseEnum
+// 解析疾病史枚舉代碼或中文顯示名稱（例如 diabetes、中風），依序以顯示名稱與疾病分類比對目錄。
+// 呼叫者需持有 catalogue.mu 的讀取鎖
+func (c *Catalogue) resolveHistoryDisease(value string) (string, bool) {
+	if _, ok := c.diseaseIDs[value]; ok {
+		return value, true
+	}
+	code, ok := models.ParseHistoryDiseaseEnum(value)
+	if !ok {
+		return "", false
+	}
+	candidates := []string{code.DisplayName()}
+	if category, ok := code.Category(); ok {
+		candidates = append(candidates, category)
+	}
+	for _, name := range candidates {
+		if _, ok := c.diseaseIDs[name]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// resolveMedicalHistory 回傳醫療史在選項中的名稱。選項中沒有時以 models.ParseMedicalHistoryEnum
+// 解析醫療史枚舉代碼（例如 diabetes），換成中文顯示名稱。呼叫者需持有 catalogue.mu 的讀取鎖
+func (c *Catalogue) resolveMedicalHistory(value string) (string, bool) {
+	if slices.Contains(c.medicalHistories, value) {
+		return value, true
+	}
+	code, ok := models.ParseMedicalHistoryEnum(value)
+	if !ok {
+		return "", false
+	}
+	return code.DisplayName(), true
+}
+
+// ValidatePatientHistories 驗證病患的病史與醫療史是否都在疾病目錄中。
+// 病史與醫療史可以是枚舉代碼或中文顯示名稱，驗證通過時會換成疾病目錄與醫療史選項中的名稱
+func (s *Service) ValidatePatientHistories(ctx context.Context, patient *models.Patient) error {
+	if err := s.ensureCatalogue(ctx); err != nil {
+		return err
+	}
+	s.catalogue.mu.RLock()
+	defer s.catalogue.mu.RUnlock()
+
+	unknown := make([]string, 0)
+	resolved := make([]string, 0, len(patient.HistoryDiseases))
+	for _, disease := range patient.HistoryDiseases {
+		name, ok := s.catalogue.resolveHistoryDisease(disease)
+		if !ok {
+			unknown = append(unknown, disease)
+			continue
+		}
+		// 不同代碼可能對應到同一個疾病分類（例如 hypertension 與 heartDisease），只保留一次
+		if !slices.Contains(resolved, name) {
+			resolved = append(resolved, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return models.Validationf("病患 %s 的病史不在疾病目錄中: %s", patient.Name, strings.Join(unknown, ", "))
+	}
+
+	resolvedMedical := make([]string, 0, len(patient.MedicalHistories))
+	for _, history := range patient.MedicalHistories {
+		name, ok := s.catalogue.resolveMedicalHistory(history)
+		if !ok {
+			unknown = append(unknown, history)
+			continue
+		}
+		if !slices.Contains(resolvedMedical, name) {
+			resolvedMedical = append(resolvedMedical, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return models.Validationf("病患 %s 的醫療史不在選項中: %s", patient.Name, strings.Join(unknown, ", "))
+	}
+	patient.HistoryDiseases = resolved
+	patient.MedicalHistories = resolvedMedical
+	return nil
+}
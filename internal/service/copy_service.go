@@ -0,0 +1,507 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/tracing"
+	"golang-gin-app/internal/utils"
+	"strconv"
+)
+
+// errCopyDryRun 用於預覽複製時回滾目標資料庫的交易
+var errCopyDryRun = errors.New("預覽複製，回滾交易")
+
+// copySource 從來源資料庫讀出、準備寫入目標資料庫的資料
+type copySource struct {
+	users        []*models.User
+	roles        map[int64][]*models.Role          // 來源使用者ID -> 角色
+	slots        map[int64][]*models.AvailableSlot // 來源使用者ID -> 時段
+	patients     []*models.Patient
+	patientUsers map[int64]string  // 病患關聯的來源使用者ID -> 帳號（未在本次複製的使用者中）
+	diseaseNames map[int64]string  // 來源疾病目錄 ID -> 疾病名稱
+	anonymizer   *utils.Anonymizer // 不為 nil 時寫入前將病患去識別化
+}
+
+// ListPatients 獲取最新的病患列表
+func (s *Service) ListPatients(ctx context.Context) ([]*models.Patient, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListPatients")
+	defer span.End()
+
+	return s.store.Patients().ListPatients(ctx, 50)
+}
+
+// CopyTo 將來源（s）中選取的使用者（含角色與可預約時段）及病患（含病史與醫療史）複製到 target。
+// 新資料列會取得目標資料庫的新ID，使用者角色依角色代碼對應，目標缺少的角色會自動新增。
+// 目標已有相同帳號的使用者時沿用該使用者；已有相同身分證字號的病患、相同時間的時段則略過，
+// 並記錄在報告的 Conflicts 中。所有寫入在目標資料庫的單一交易中完成，並記錄為 copy 生成批次，
+// 可透過批次管理清除。req.DryRun 時回滾交易，只回傳報告。
+func (s *Service) CopyTo(ctx context.Context, target *Service, req models.CopyRequest) (*models.CopyReport, error) {
+	ctx, span := tracing.Start(ctx, "Service.CopyTo")
+	defer span.End()
+
+	if target == nil || target == s {
+		return nil, models.Validationf("來源與目標資料庫不可相同")
+	}
+	if len(req.UserIDs) == 0 && len(req.PatientIDs) == 0 {
+		return nil, models.Validationf("請選擇要複製的使用者或病患")
+	}
+	if req.Anonymize && s.anonymizer == nil {
+		return nil, models.Validationf("未設定去識別化金鑰")
+	}
+
+	source, err := s.loadCopySource(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Anonymize {
+		source.anonymizer = s.anonymizer
+	}
+
+	report := &models.CopyReport{
+		DryRun:    req.DryRun,
+		Users:     make([]models.CopyMapping, 0),
+		Patients:  make([]models.CopyMapping, 0),
+		Conflicts: make([]models.CopyConflict, 0),
+	}
+	err = target.store.WithTx(ctx, func(ctx context.Context) error {
+		if err := target.copyInto(ctx, source, req, report); err != nil {
+			return err
+		}
+		if req.DryRun {
+			return errCopyDryRun
+		}
+		return target.recordAudit(ctx, models.AuditActionCopy, models.AuditEntityBatch, report.BatchID, nil, map[string]interface{}{
+			"users": len(report.Users), "patients": len(report.Patients), "slots": report.Slots,
+			"created_roles": report.CreatedRoles, "anonymize": req.Anonymize,
+		})
+	})
+	if errors.Is(err, errCopyDryRun) {
+		report.BatchID = ""
+		return report, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("複製資料失敗: %w", err)
+	}
+
+	// 複製的醫療史可能不在目標原本的選項中，重新載入疾病目錄
+	if len(report.Patients) > 0 {
+		if err := target.RefreshCatalogue(ctx); err != nil {
+			return report, fmt.Errorf("資料已複製，但重新載入疾病目錄失敗: %w", err)
+		}
+	}
+	logging.FromContext(ctx).Info("已複製資料到其他租戶",
+		"users", len(report.Users), "patients", len(report.Patients), "conflicts", len(report.Conflicts),
+		"anonymize", req.Anonymize, "batch_id", report.BatchID)
+	return report, nil
+}
+
+// loadCopySource 從來源資料庫讀取要複製的資料；選取的資料不存在時回傳 models.ErrNotFound
+func (s *Service) loadCopySource(ctx context.Context, req models.CopyRequest) (*copySource, error) {
+	source := &copySource{
+		roles:        make(map[int64][]*models.Role),
+		slots:        make(map[int64][]*models.AvailableSlot),
+		patientUsers: make(map[int64]string),
+		diseaseNames: make(map[int64]string),
+	}
+
+	selectedUsers := make(map[int64]bool, len(req.UserIDs))
+	for _, userID := range req.UserIDs {
+		if selectedUsers[userID] {
+			continue
+		}
+		selectedUsers[userID] = true
+
+		user, err := s.store.Users().GetByID(ctx, strconv.FormatInt(userID, 10))
+		if err != nil {
+			return nil, err
+		}
+		roles, err := s.store.Roles().GetUserRoles(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("獲取使用者 %d 的角色失敗: %w", userID, err)
+		}
+		source.users = append(source.users, user)
+		source.roles[userID] = roles
+
+		if req.IncludeSlots {
+			slots, err := s.store.Slots().GetAvailableSlotsByDoctor(ctx, userID)
+			if err != nil {
+				return nil, fmt.Errorf("獲取使用者 %d 的時段失敗: %w", userID, err)
+			}
+			source.slots[userID] = slots
+		}
+	}
+
+	if len(req.PatientIDs) == 0 {
+		return source, nil
+	}
+
+	diseases, err := s.store.Patients().ListHistoryDiseases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, disease := range diseases {
+		source.diseaseNames[disease.ID] = disease.DiseaseName
+	}
+
+	selectedPatients := make(map[int64]bool, len(req.PatientIDs))
+	for _, patientID := range req.PatientIDs {
+		if selectedPatients[patientID] {
+			continue
+		}
+		selectedPatients[patientID] = true
+
+		patient, err := s.store.Patients().GetPatient(ctx, patientID)
+		if err != nil {
+			return nil, err
+		}
+		source.patients = append(source.patients, patient)
+
+		// 病患關聯的使用者未一起複製時，記下帳號以便在目標資料庫中對應
+		if patient.UserID == 0 || selectedUsers[patient.UserID] {
+			continue
+		}
+		if _, ok := source.patientUsers[patient.UserID]; ok {
+			continue
+		}
+		user, err := s.store.Users().GetByID(ctx, strconv.FormatInt(patient.UserID, 10))
+		if errors.Is(err, models.ErrNotFound) {
+			source.patientUsers[patient.UserID] = ""
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		source.patientUsers[patient.UserID] = user.Account
+	}
+	return source, nil
+}
+
+// copyInto 在目標資料庫（s）的交易中寫入來源資料並填寫報告
+func (s *Service) copyInto(ctx context.Context, source *copySource, req models.CopyRequest, report *models.CopyReport) error {
+	userIDs, err := s.copyUsers(ctx, source, report)
+	if err != nil {
+		return err
+	}
+
+	newUserIDs := make([]int64, 0, len(report.Users))
+	for _, mapping := range report.Users {
+		newUserIDs = append(newUserIDs, mapping.TargetID)
+	}
+	newSlotIDs, err := s.copySlots(ctx, source, userIDs, report)
+	if err != nil {
+		return err
+	}
+	newPatientIDs, err := s.copyPatients(ctx, source, req, userIDs, report)
+	if err != nil {
+		return err
+	}
+
+	if len(newUserIDs) == 0 && len(newSlotIDs) == 0 && len(newPatientIDs) == 0 {
+		return nil
+	}
+
+	// 記錄為同一個生成批次，以便之後清除複製的資料
+	report.BatchID, err = s.recordBatch(ctx, models.BatchKindCopy, models.BatchEntityUser, newUserIDs)
+	if err != nil {
+		return err
+	}
+	if err := s.store.Batches().AddGenerationBatchItems(ctx, report.BatchID, models.BatchEntityPatient, newPatientIDs); err != nil {
+		return err
+	}
+	return s.store.Batches().AddGenerationBatchItems(ctx, report.BatchID, models.BatchEntitySlot, newSlotIDs)
+}
+
+// copyUsers 寫入使用者與角色，回傳來源使用者ID對應的目標使用者ID（包含沿用的既有使用者）
+func (s *Service) copyUsers(ctx context.Context, source *copySource, report *models.CopyReport) (map[int64]int64, error) {
+	userIDs := make(map[int64]int64, len(source.users))
+	if len(source.users) == 0 {
+		return userIDs, nil
+	}
+
+	roleIDs, err := s.ensureRoles(ctx, source, report)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range source.users {
+		existing, err := s.store.Users().GetByAccount(ctx, user.Account)
+		if err == nil {
+			userIDs[user.ID] = existing.ID
+			report.Conflicts = append(report.Conflicts, models.CopyConflict{
+				Entity:   models.BatchEntityUser,
+				SourceID: user.ID,
+				Label:    user.Account,
+				Reason:   fmt.Sprintf("帳號已存在於目標資料庫，沿用ID為 %d 的使用者，角色未變更", existing.ID),
+			})
+			continue
+		}
+		if !errors.Is(err, models.ErrNotFound) {
+			return nil, err
+		}
+
+		copied := *user
+		copied.ID = 0
+		copied.Roles = nil
+		ids, err := s.store.Users().BatchCreateUsers(ctx, []*models.User{&copied})
+		if err != nil {
+			return nil, fmt.Errorf("複製使用者 %s 失敗: %w", user.Account, err)
+		}
+		userIDs[user.ID] = ids[0]
+		report.Users = append(report.Users, models.CopyMapping{SourceID: user.ID, TargetID: ids[0], Label: user.Account})
+
+		targetRoleIDs := make([]int64, 0, len(source.roles[user.ID]))
+		for _, role := range source.roles[user.ID] {
+			targetRoleIDs = append(targetRoleIDs, roleIDs[role.Alias])
+		}
+		if len(targetRoleIDs) > 0 {
+			if err := s.assignRoles(ctx, ids[0], targetRoleIDs); err != nil {
+				return nil, fmt.Errorf("為用戶 %d 分配角色失敗: %w", ids[0], err)
+			}
+		}
+	}
+	return userIDs, nil
+}
+
+// ensureRoles 依角色代碼對應目標資料庫的角色ID，目標缺少的角色會以來源的說明新增
+func (s *Service) ensureRoles(ctx context.Context, source *copySource, report *models.CopyReport) (map[string]int64, error) {
+	roleIDs, err := s.roleIDsByAlias(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range source.users {
+		for _, role := range source.roles[user.ID] {
+			if _, ok := roleIDs[role.Alias]; ok {
+				continue
+			}
+			description := ""
+			if role.Description != nil {
+				description = *role.Description
+			}
+			roleID, err := s.store.Roles().AddRole(ctx, role.Alias, description)
+			if err != nil {
+				return nil, fmt.Errorf("新增角色 %s 失敗: %w", role.Alias, err)
+			}
+			roleIDs[role.Alias] = roleID
+			report.CreatedRoles = append(report.CreatedRoles, role.Alias)
+		}
+	}
+	return roleIDs, nil
+}
+
+// roleIDsByAlias 回傳角色代碼對應的角色ID
+func (s *Service) roleIDsByAlias(ctx context.Context) (map[string]int64, error) {
+	roles, err := s.store.Roles().ListAllRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roleIDs := make(map[string]int64, len(roles))
+	for _, role := range roles {
+		roleIDs[role.Alias] = role.ID
+	}
+	return roleIDs, nil
+}
+
+// copySlots 將時段寫到對應的目標使用者名下，略過目標使用者已有的相同時段；
+// 沒有複製預約資料，因此時段一律設為未預約
+func (s *Service) copySlots(ctx context.Context, source *copySource, userIDs map[int64]int64, report *models.CopyReport) ([]int64, error) {
+	newSlots := make([]*models.AvailableSlot, 0)
+	for _, user := range source.users {
+		slots := source.slots[user.ID]
+		if len(slots) == 0 {
+			continue
+		}
+		targetUserID := userIDs[user.ID]
+
+		existing, err := s.store.Slots().GetAvailableSlotsByDoctor(ctx, targetUserID)
+		if err != nil {
+			return nil, err
+		}
+		taken := make(map[string]bool, len(existing))
+		for _, slot := range existing {
+			taken[slotKey(slot)] = true
+		}
+
+		for _, slot := range slots {
+			if taken[slotKey(slot)] {
+				report.Conflicts = append(report.Conflicts, models.CopyConflict{
+					Entity:   models.BatchEntitySlot,
+					SourceID: slot.ID,
+					Label:    user.Account + " " + slotKey(slot),
+					Reason:   "目標使用者已有相同時間的時段",
+				})
+				continue
+			}
+			copied := *slot
+			copied.ID = 0
+			copied.Doctor = targetUserID
+			copied.IsBooked = false
+			newSlots = append(newSlots, &copied)
+		}
+	}
+
+	if len(newSlots) == 0 {
+		return nil, nil
+	}
+	if err := s.store.Slots().BatchCreateAvailableSlots(ctx, newSlots); err != nil {
+		return nil, fmt.Errorf("複製時段失敗: %w", err)
+	}
+	slotIDs := make([]int64, 0, len(newSlots))
+	for _, slot := range newSlots {
+		slotIDs = append(slotIDs, slot.ID)
+	}
+	report.Slots = len(slotIDs)
+	return slotIDs, nil
+}
+
+// slotKey 以日期與開始時間識別時段
+func slotKey(slot *models.AvailableSlot) string {
+	return slot.SlotDate.Format("2006-01-02") + " " + slot.SlotBeginTime.Format("15:04")
+}
+
+// copyPatients 寫入病患、病史與醫療史。病史依疾病名稱對應目標的疾病目錄，
+// 目錄中沒有的病患會被略過；主要疾病或關聯的使用者在目標資料庫中找不到時設為 0。
+// 報告與錯誤訊息中的病患名稱使用寫入目標的資料，匿名化時不會洩漏來源的姓名
+func (s *Service) copyPatients(ctx context.Context, source *copySource, req models.CopyRequest, userIDs map[int64]int64, report *models.CopyReport) ([]int64, error) {
+	patientIDs := make([]int64, 0, len(source.patients))
+	for _, original := range source.patients {
+		patient := original
+		if source.anonymizer != nil {
+			patient = source.anonymizer.Patient(original)
+		}
+		label := patient.Name
+		// 假名由金鑰決定，重複複製同一病患時去識別化後的身分證字號也會相同
+		if patient.IDNo != "" {
+			exists, err := s.store.Patients().PatientExistsByIDNo(ctx, patient.IDNo)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				report.Conflicts = append(report.Conflicts, models.CopyConflict{
+					Entity:   models.BatchEntityPatient,
+					SourceID: original.ID,
+					Label:    label,
+					Reason:   "目標資料庫已有相同身分證字號的病患",
+				})
+				continue
+			}
+		}
+
+		diseaseIDs, missing, err := s.targetDiseaseIDs(ctx, patient.HistoryDiseases)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) > 0 {
+			report.Conflicts = append(report.Conflicts, models.CopyConflict{
+				Entity:   models.BatchEntityPatient,
+				SourceID: original.ID,
+				Label:    label,
+				Reason:   fmt.Sprintf("病史不在目標的疾病目錄中: %v", missing),
+			})
+			continue
+		}
+
+		copied := *patient
+		copied.ID = 0
+		copied.DiseaseID, err = s.mapDiseaseID(ctx, source, original, label, report)
+		if err != nil {
+			return nil, err
+		}
+		copied.UserID, err = s.mapPatientUser(ctx, source, userIDs, original, label, report)
+		if err != nil {
+			return nil, err
+		}
+
+		patientID, err := s.store.Patients().CreatePatient(ctx, &copied)
+		if err != nil {
+			return nil, fmt.Errorf("複製病患 %s 失敗: %w", label, err)
+		}
+		for i, historyDisease := range patient.HistoryDiseases {
+			if err := s.store.Patients().AddHistoryDisease(ctx, patientID, historyDisease, diseaseIDs[i]); err != nil {
+				return nil, fmt.Errorf("複製病患 %s 的病史資料失敗: %w", label, err)
+			}
+		}
+		for _, medicalHistory := range patient.MedicalHistories {
+			if err := s.store.Patients().AddMedicalHistory(ctx, patientID, medicalHistory); err != nil {
+				return nil, fmt.Errorf("複製病患 %s 的醫療史資料失敗: %w", label, err)
+			}
+		}
+
+		patientIDs = append(patientIDs, patientID)
+		report.Patients = append(report.Patients, models.CopyMapping{SourceID: original.ID, TargetID: patientID, Label: copied.Name})
+	}
+	return patientIDs, nil
+}
+
+// targetDiseaseIDs 依疾病名稱查詢目標疾病目錄中的ID，並回傳目錄中沒有的疾病
+func (s *Service) targetDiseaseIDs(ctx context.Context, names []string) ([]int64, []string, error) {
+	ids := make([]int64, 0, len(names))
+	missing := make([]string, 0)
+	for _, name := range names {
+		id, ok, err := s.LookupHistoryDiseaseID(ctx, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, missing, nil
+}
+
+// mapDiseaseID 依疾病名稱將來源的 disease_id 對應到目標疾病目錄。
+// 來源ID在目標中可能指向不同的疾病，因此無法對應時設為 0 並記錄在報告中，不沿用原值
+func (s *Service) mapDiseaseID(ctx context.Context, source *copySource, patient *models.Patient, label string, report *models.CopyReport) (int64, error) {
+	if patient.DiseaseID == 0 {
+		return 0, nil
+	}
+	name, ok := source.diseaseNames[patient.DiseaseID]
+	if ok {
+		id, found, err := s.LookupHistoryDiseaseID(ctx, name)
+		if err != nil {
+			return 0, err
+		}
+		if found {
+			return id, nil
+		}
+	}
+	report.Conflicts = append(report.Conflicts, models.CopyConflict{
+		Entity:   models.BatchEntityPatient,
+		SourceID: patient.ID,
+		Label:    label,
+		Reason:   fmt.Sprintf("主要疾病 %d 不在目標的疾病目錄中，disease_id 設為 0", patient.DiseaseID),
+	})
+	return 0, nil
+}
+
+// mapPatientUser 將病患的 user_id 對應到目標資料庫：優先使用本次複製的使用者，
+// 其次依帳號尋找目標中的使用者，都找不到時設為 0 並記錄在報告中
+func (s *Service) mapPatientUser(ctx context.Context, source *copySource, userIDs map[int64]int64, patient *models.Patient, label string, report *models.CopyReport) (int64, error) {
+	if patient.UserID == 0 {
+		return 0, nil
+	}
+	if id, ok := userIDs[patient.UserID]; ok {
+		return id, nil
+	}
+	if account := source.patientUsers[patient.UserID]; account != "" {
+		user, err := s.store.Users().GetByAccount(ctx, account)
+		if err == nil {
+			return user.ID, nil
+		}
+		if !errors.Is(err, models.ErrNotFound) {
+			return 0, err
+		}
+	}
+	report.Conflicts = append(report.Conflicts, models.CopyConflict{
+		Entity:   models.BatchEntityPatient,
+		SourceID: patient.ID,
+		Label:    label,
+		Reason:   fmt.Sprintf("關聯的使用者 %d 不在目標資料庫中，user_id 設為 0", patient.UserID),
+	})
+	return 0, nil
+}
@@ -0,0 +1,420 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang-gin-app/internal/jobs"
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/tracing"
+	"golang-gin-app/internal/utils"
+	"os"
+	"sync"
+	"time"
+)
+
+// MaxPatientJobCount 背景工作一次最多生成的病患數量
+const MaxPatientJobCount = 1000
+
+// JobHeartbeatTimeout 執行中工作的心跳超過此時間未更新時，視為執行的程序已結束，工作可由其他程序放回佇列
+const JobHeartbeatTimeout = time.Minute
+
+// jobHeartbeatInterval 執行中工作沒有新進度時更新心跳的間隔，需遠小於 JobHeartbeatTimeout
+var jobHeartbeatInterval = 10 * time.Second
+
+// processInstanceID 識別本程序，記錄為執行中工作的 owner；每次啟動都不同
+var processInstanceID = newInstanceID()
+
+// newInstanceID 以主機名稱、程序ID與亂數產生程序的識別
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	if len(host) > 64 {
+		host = host[:64]
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// ValidateJob 驗證工作類型與參數，並回傳工作將建立的資料筆數（用於配額與進度）
+func ValidateJob(kind string, params json.RawMessage) (int, error) {
+	decode := func(v interface{}) error {
+		if len(params) == 0 {
+			return models.Validationf("請提供工作參數")
+		}
+		if err := json.Unmarshal(params, v); err != nil {
+			return models.Validationf("工作參數格式錯誤: %v", err)
+		}
+		return nil
+	}
+
+	switch kind {
+	case models.BatchKindUsers:
+		var p models.UserJobParams
+		if err := decode(&p); err != nil {
+			return 0, err
+		}
+		if p.Count < 1 || p.Count > 1000 {
+			return 0, models.Validationf("count must be between 1 and 1000")
+		}
+		if p.UserType != "" {
+			if _, ok := utils.GetFakeUserType(p.UserType); !ok {
+				return 0, models.Validationf("unknown user type: %s", p.UserType)
+			}
+		}
+		return p.Count, nil
+	case models.BatchKindPatients:
+		var p models.PatientJobParams
+		if err := decode(&p); err != nil {
+			return 0, err
+		}
+		if p.Count < 1 || p.Count > MaxPatientJobCount {
+			return 0, models.Validationf("病患數量必須在1到%d之間", MaxPatientJobCount)
+		}
+		if _, ok := utils.GetPatientProfile(p.Profile); !ok {
+			return 0, models.Validationf("未知的擬真設定檔: %s", p.Profile)
+		}
+		return p.Count, nil
+	case models.BatchKindSlots:
+		var p models.SlotJobParams
+		if err := decode(&p); err != nil {
+			return 0, err
+		}
+		switch {
+		case p.DoctorID <= 0:
+			return 0, models.Validationf("醫師/治療師ID必須大於0")
+		case p.Days <= 0 || p.Days > 365:
+			return 0, models.Validationf("天數必須在1到365之間")
+		case p.SlotsPerDay <= 0 || p.SlotsPerDay > 24:
+			return 0, models.Validationf("每天時段數必須在1到24之間")
+		case p.StartHour < 0 || p.StartHour > 23:
+			return 0, models.Validationf("開始時間必須在0到23之間")
+		case p.SlotDuration <= 0 || p.SlotDuration > 240:
+			return 0, models.Validationf("每個時段的持續時間必須在1到240分鐘之間")
+		}
+		return p.Days * p.SlotsPerDay, nil
+	}
+	return 0, models.Validationf("未知的工作類型: %s", kind)
+}
+
+// SubmitJob 驗證參數並建立排隊中的工作；工作由 jobs.Runner 呼叫 RunJob 執行
+func (s *Service) SubmitJob(ctx context.Context, kind string, params json.RawMessage) (*models.Job, error) {
+	ctx, span := tracing.Start(ctx, "Service.SubmitJob")
+	defer span.End()
+
+	total, err := ValidateJob(kind, params)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	auditRequest := auditRequestFromContext(ctx)
+	job := &models.Job{
+		ID:           newSortableID(now),
+		Kind:         kind,
+		Status:       models.JobStatusQueued,
+		Params:       params,
+		Progress:     models.JobProgress{Total: total},
+		CreatedAt:    now,
+		AuditRequest: &auditRequest,
+	}
+	err = s.store.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.store.Jobs().CreateJob(ctx, job); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, models.AuditActionCreate, models.AuditEntityJob, job.ID, nil, map[string]interface{}{
+			"kind": kind, "params": params,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("已建立背景工作", "job_id", job.ID, "kind", kind)
+	return job, nil
+}
+
+// GetJob 依ID取得工作
+func (s *Service) GetJob(ctx context.Context, id string) (*models.Job, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetJob")
+	defer span.End()
+
+	return s.store.Jobs().GetJob(ctx, id)
+}
+
+// ListJobs 獲取最近的工作
+func (s *Service) ListJobs(ctx context.Context) ([]*models.Job, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListJobs")
+	defer span.End()
+
+	return s.store.Jobs().ListJobs(ctx, 100)
+}
+
+// CancelJob 取消工作：排隊中的工作直接標記為已取消；執行中的工作以 cancelRunning 請求本程序的
+// jobs.Runner 取消，RunJob 結束時會標記為已取消。cancelRunning 回傳 false（工作不在本程序執行）時
+// 回傳 models.ErrConflict 且不記錄稽核紀錄；已結束的工作同樣回傳 models.ErrConflict
+func (s *Service) CancelJob(ctx context.Context, id string, cancelRunning func() bool) (*models.Job, error) {
+	ctx, span := tracing.Start(ctx, "Service.CancelJob")
+	defer span.End()
+
+	job, err := s.store.Jobs().GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch job.Status {
+	case models.JobStatusQueued:
+		err := s.store.WithTx(ctx, func(ctx context.Context) error {
+			if err := s.store.Jobs().CancelQueuedJob(ctx, id, time.Now()); err != nil {
+				return err
+			}
+			return s.recordAudit(ctx, models.AuditActionCancel, models.AuditEntityJob, id,
+				jobStatusSnapshot(models.JobStatusQueued, false), jobStatusSnapshot(models.JobStatusCancelled, false))
+		})
+		if errors.Is(err, models.ErrConflict) {
+			// 工作剛好開始執行，改為取消執行中的工作
+			return s.CancelJob(ctx, id, cancelRunning)
+		}
+		if err != nil {
+			return nil, err
+		}
+		logging.FromContext(ctx).Info("已取消背景工作", "job_id", id)
+		return s.store.Jobs().GetJob(ctx, id)
+	case models.JobStatusRunning:
+		if !cancelRunning() {
+			return nil, models.Conflictf("工作 %s 由其他程序（%s）執行，無法在此程序取消", id, job.Owner)
+		}
+		err := s.recordAudit(ctx, models.AuditActionCancel, models.AuditEntityJob, id,
+			jobStatusSnapshot(models.JobStatusRunning, false), jobStatusSnapshot(models.JobStatusRunning, true))
+		if err != nil {
+			return nil, err
+		}
+		return job, nil
+	}
+	return nil, models.Conflictf("工作 %s 已結束，無法取消", id)
+}
+
+// jobStatusSnapshot 回傳記錄於稽核紀錄的工作狀態快照；執行中的工作在取消請求後才會結束
+func jobStatusSnapshot(status string, cancelRequested bool) map[string]interface{} {
+	return map[string]interface{}{"status": status, "cancel_requested": cancelRequested}
+}
+
+// ResumeJobs 將心跳逾時（執行的程序已結束）的執行中工作放回佇列，並依建立順序回傳所有排隊中的工作ID。
+// 應在啟動時及之後定期呼叫，並將回傳的工作排入 jobs.Runner；其他程序仍在執行的工作不受影響，
+// 排隊中的工作由最先開始執行的程序執行
+func (s *Service) ResumeJobs(ctx context.Context) ([]string, error) {
+	running, err := s.store.Jobs().ListJobsByStatus(ctx, models.JobStatusRunning)
+	if err != nil {
+		return nil, err
+	}
+	staleBefore := time.Now().Add(-JobHeartbeatTimeout)
+	for _, job := range running {
+		// 本程序的工作仍在執行；SQLite 在工作的交易期間無法更新心跳，因此不依心跳判斷
+		if job.Owner == s.instanceID {
+			continue
+		}
+		err := s.store.Jobs().RequeueStaleJob(ctx, job.ID, staleBefore)
+		if errors.Is(err, models.ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		logging.FromContext(ctx).Warn("執行工作的程序已無心跳，工作放回佇列", "job_id", job.ID, "owner", job.Owner)
+	}
+
+	queued, err := s.store.Jobs().ListJobsByStatus(ctx, models.JobStatusQueued)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(queued))
+	for _, job := range queued {
+		ids = append(ids, job.ID)
+	}
+	return ids, nil
+}
+
+// RunJob 執行排隊中的工作並記錄結果；工作已被取消或已由其他 worker 開始時不做任何事。
+// 每次狀態或進度改變時以工作的副本呼叫 observe。ctx 被取消時依 context.Cause：
+// jobs.ErrShutdown 將工作放回佇列，其他原因標記為已取消
+func (s *Service) RunJob(ctx context.Context, id string, observe func(models.Job)) error {
+	ctx, span := tracing.Start(ctx, "Service.RunJob")
+	defer span.End()
+
+	startedAt := time.Now()
+	err := s.store.Jobs().StartJob(ctx, id, s.instanceID, startedAt)
+	if errors.Is(err, models.ErrConflict) || errors.Is(err, models.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	job, err := s.store.Jobs().GetJob(ctx, id)
+	if err != nil {
+		return err
+	}
+	observe(*job)
+
+	logger := logging.FromContext(ctx).With("job_id", id, "kind", job.Kind)
+	logger.Info("開始執行背景工作")
+	writer := s.startProgressWriter(ctx, id, job.Progress)
+	// 工作產生的稽核紀錄沿用送出工作的操作者與請求ID，與建立工作的稽核紀錄相同
+	if job.AuditRequest != nil {
+		ctx = WithAuditRequest(ctx, *job.AuditRequest)
+	}
+	progressCtx := jobs.WithProgress(ctx, func(progress models.JobProgress) {
+		job.Progress = progress
+		writer.report(progress)
+		observe(*job)
+	})
+	result, runErr := s.runJob(progressCtx, job)
+	writer.stop()
+
+	// 工作結束後的狀態更新不受取消影響
+	ctx = context.WithoutCancel(ctx)
+	if cause := context.Cause(progressCtx); runErr != nil && errors.Is(cause, jobs.ErrShutdown) {
+		logger.Warn("應用程式結束，背景工作放回佇列")
+		return s.store.Jobs().RequeueJob(ctx, id, s.instanceID)
+	}
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	switch {
+	case runErr == nil:
+		job.Status, job.Result = models.JobStatusSucceeded, result
+		if job.Progress.Total > 0 {
+			job.Progress.Done = job.Progress.Total
+		}
+		logger.Info("背景工作完成", "created", result.Created, "batch_id", result.BatchID)
+	case errors.Is(context.Cause(progressCtx), jobs.ErrCancelled):
+		job.Status = models.JobStatusCancelled
+		logger.Info("背景工作已取消")
+	default:
+		job.Status, job.Error = models.JobStatusFailed, runErr.Error()
+		logger.Error("背景工作失敗", logging.KeyError, runErr.Error())
+	}
+	observe(*job)
+	return s.store.Jobs().FinishJob(ctx, job)
+}
+
+// jobProgressInterval 執行中工作的進度寫入資料表的最短間隔
+var jobProgressInterval = time.Second
+
+// progressWriter 在背景定期將執行中工作的最新進度與心跳寫入資料表。
+// 進度在生成作業的交易中回報，回報時不能直接寫入：SQLite 只有一個連線，
+// 記憶體 Store 在交易期間持有鎖，交易外的寫入會等待回報所在的交易而卡住。
+// 因此回報只記下最新的進度，由另一個 goroutine 寫入；在這兩種 Store 上寫入會等到交易結束，
+// 工作結束時停止，最後的進度由 FinishJob 寫入
+type progressWriter struct {
+	mu      sync.Mutex
+	latest  models.JobProgress
+	changed bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startProgressWriter 開始定期寫入工作 id 的進度：進度有變化時最多每 jobProgressInterval 寫入一次，
+// 沒有變化時每 jobHeartbeatInterval 寫入一次作為心跳。progress 為工作目前的進度，ctx 不可帶有交易
+func (s *Service) startProgressWriter(ctx context.Context, id string, progress models.JobProgress) *progressWriter {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &progressWriter{latest: progress, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(jobProgressInterval)
+		defer ticker.Stop()
+		lastWrite := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			progress, changed := w.take()
+			if !changed && time.Since(lastWrite) < jobHeartbeatInterval {
+				continue
+			}
+			lastWrite = time.Now()
+			err := s.store.Jobs().UpdateJobProgress(ctx, id, s.instanceID, progress, lastWrite)
+			if err != nil && ctx.Err() == nil && !errors.Is(err, models.ErrConflict) {
+				logging.FromContext(ctx).Warn("記錄工作進度失敗", "job_id", id, logging.KeyError, err.Error())
+			}
+		}
+	}()
+	return w
+}
+
+// report 記下最新的進度，不會等待寫入
+func (w *progressWriter) report(progress models.JobProgress) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.latest, w.changed = progress, true
+}
+
+// take 取出最新的進度，並回傳上次取出後是否有變化
+func (w *progressWriter) take() (models.JobProgress, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	changed := w.changed
+	w.changed = false
+	return w.latest, changed
+}
+
+// stop 停止寫入並等待背景的 goroutine 結束，尚未寫入的進度直接捨棄
+func (w *progressWriter) stop() {
+	w.cancel()
+	<-w.done
+}
+
+// runJob 依工作類型解析參數並執行對應的生成作業
+func (s *Service) runJob(ctx context.Context, job *models.Job) (*models.JobResult, error) {
+	switch job.Kind {
+	case models.BatchKindUsers:
+		var p models.UserJobParams
+		if err := json.Unmarshal(job.Params, &p); err != nil {
+			return nil, fmt.Errorf("解析工作參數失敗: %v", err)
+		}
+		created, batchID, err := s.GenerateFakeUsers(ctx, p.Count, p.UserType, p.RoleIDs)
+		if err != nil {
+			return nil, err
+		}
+		return &models.JobResult{Created: created, BatchID: batchID}, nil
+	case models.BatchKindPatients:
+		var p models.PatientJobParams
+		if err := json.Unmarshal(job.Params, &p); err != nil {
+			return nil, fmt.Errorf("解析工作參數失敗: %v", err)
+		}
+		profile, ok := utils.GetPatientProfile(p.Profile)
+		if !ok {
+			return nil, models.Validationf("未知的擬真設定檔: %s", p.Profile)
+		}
+		catalogue, err := s.GetPatientCatalogue(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("載入疾病目錄失敗: %w", err)
+		}
+		patients, err := utils.GenerateFakePatientsWithProfile(p.Count, profile, catalogue)
+		if err != nil {
+			return nil, fmt.Errorf("生成假病患資料失敗: %w", err)
+		}
+		created, batchID, skipped, err := s.SaveFakePatients(ctx, patients)
+		if err != nil {
+			return nil, err
+		}
+		return &models.JobResult{Created: created, BatchID: batchID, Skipped: skipped}, nil
+	case models.BatchKindSlots:
+		var p models.SlotJobParams
+		if err := json.Unmarshal(job.Params, &p); err != nil {
+			return nil, fmt.Errorf("解析工作參數失敗: %v", err)
+		}
+		slots, batchID, err := s.GenerateAvailableSlots(ctx, p.DoctorID, p.Days, p.SlotsPerDay, p.StartHour, p.SlotDuration)
+		if err != nil {
+			return nil, err
+		}
+		return &models.JobResult{Created: len(slots), BatchID: batchID}, nil
+	}
+	return nil, models.Validationf("未知的工作類型: %s", job.Kind)
+}
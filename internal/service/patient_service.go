@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"golang-gin-app/internal/jobs"
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/metrics"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/tracing"
+	"time"
+)
+
+// SaveFakePatients 將假病患寫入資料庫，並回傳成功筆數、生成批次ID與被略過的病患錯誤訊息。
+// 病史可使用疾病史枚舉代碼（例如 diabetes），寫入時換成疾病目錄中的名稱；
+// 病史或醫療史不在疾病目錄中的病患會被略過；其餘病患、病史、醫療史與批次紀錄
+// 在同一個交易中寫入，任一步驟失敗時全部回滾。
+func (s *Service) SaveFakePatients(ctx context.Context, patients []*models.Patient) (int, string, []string, error) {
+	ctx, span := tracing.Start(ctx, "Service.SaveFakePatients")
+	defer span.End()
+
+	skipped := make([]string, 0)
+	valid := make([]*models.Patient, 0, len(patients))
+	diseaseIDs := make(map[*models.Patient][]int64, len(patients))
+
+	// 在交易外先驗證並查詢疾病ID，交易中只進行寫入
+	for _, patient := range patients {
+		if err := s.ValidatePatientHistories(ctx, patient); err != nil {
+			skipped = append(skipped, err.Error())
+			continue
+		}
+		ids := make([]int64, 0, len(patient.HistoryDiseases))
+		for _, historyDisease := range patient.HistoryDiseases {
+			diseaseID, ok, err := s.LookupHistoryDiseaseID(ctx, historyDisease)
+			if err != nil {
+				return 0, "", skipped, fmt.Errorf("查詢疾病 %s 的ID失敗: %w", historyDisease, err)
+			}
+			if !ok {
+				return 0, "", skipped, models.Validationf("疾病 %s 不在疾病目錄中", historyDisease)
+			}
+			ids = append(ids, diseaseID)
+		}
+		diseaseIDs[patient] = ids
+		valid = append(valid, patient)
+	}
+
+	if len(valid) == 0 {
+		return 0, "", skipped, nil
+	}
+
+	start := time.Now()
+	var batchID string
+	err := s.store.WithTx(ctx, func(ctx context.Context) error {
+		patientIDs := make([]int64, 0, len(valid))
+		for i, patient := range valid {
+			// 插入主病患資料，並回填 ID 以便在前端顯示
+			patientID, err := s.store.Patients().CreatePatient(ctx, patient)
+			if err != nil {
+				return fmt.Errorf("插入病患 %s 失敗: %w", patient.Name, err)
+			}
+			patientIDs = append(patientIDs, patientID)
+
+			// 插入病史資料
+			for i, historyDisease := range patient.HistoryDiseases {
+				if err := s.store.Patients().AddHistoryDisease(ctx, patientID, historyDisease, diseaseIDs[patient][i]); err != nil {
+					return fmt.Errorf("插入病患 %s 的病史資料失敗: %w", patient.Name, err)
+				}
+			}
+
+			// 插入醫療史資料
+			for _, medicalHistory := range patient.MedicalHistories {
+				if err := s.store.Patients().AddMedicalHistory(ctx, patientID, medicalHistory); err != nil {
+					return fmt.Errorf("插入病患 %s 的醫療史資料失敗: %w", patient.Name, err)
+				}
+			}
+			jobs.ReportProgress(ctx, models.JobStagePatients, i+1, len(valid))
+		}
+
+		// 記錄生成批次，以便之後清除
+		var err error
+		batchID, err = s.recordBatch(ctx, models.BatchKindPatients, models.BatchEntityPatient, patientIDs)
+		if err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, models.AuditActionGenerate, models.AuditEntityBatch, batchID, nil, map[string]interface{}{
+			"kind": models.BatchKindPatients, "count": len(patientIDs),
+		})
+	})
+	metrics.ObserveGeneration("patients", start, err)
+	if err != nil {
+		// 交易已回滾，清除回填的ID
+		for _, patient := range valid {
+			patient.ID = 0
+		}
+		return 0, "", skipped, err
+	}
+
+	metrics.PatientsInserted.Add(float64(len(valid)))
+	logging.FromContext(ctx).Info("已儲存假病患", "count", len(valid), "skipped", len(skipped), "batch_id", batchID)
+	return len(valid), batchID, skipped, nil
+}
@@ -2,108 +2,288 @@ package service
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
+	"golang-gin-app/internal/jobs"
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/metrics"
 	"golang-gin-app/internal/models"
 	"golang-gin-app/internal/repository"
+	"golang-gin-app/internal/tracing"
 	"golang-gin-app/internal/utils"
+	"strconv"
+	"time"
 )
 
 type Service struct {
-	repo repository.Repository
-	db   *sql.DB // 添加數據庫連接以便初始化計數器
+	store      repository.Store
+	catalogue  *Catalogue        // 疾病目錄快取
+	anonymizer *utils.Anonymizer // 病患去識別化，未設定時無法去識別化
+	instanceID string            // 記錄為本程序執行中工作的 owner
 }
 
-func NewService(repo repository.Repository) *Service {
-	var db *sql.DB
-	db = repo.GetDB() // 通過 repository 獲取資料庫連接
-	return &Service{repo: repo, db: db}
+func NewService(store repository.Store) *Service {
+	return &Service{store: store, catalogue: &Catalogue{}, instanceID: processInstanceID}
 }
 
-// GenerateFakeUsers generates a specified number of fake users and saves them to the database
-func (s *Service) GenerateFakeUsers(ctx context.Context, count int, userType string, roleIDs []int64) (int, error) {
+// SetAnonymizer 設定病患去識別化使用的 Anonymizer，應在啟動時設定
+func (s *Service) SetAnonymizer(anonymizer *utils.Anonymizer) {
+	s.anonymizer = anonymizer
+}
+
+// GenerateFakeUsers generates a specified number of fake users, saves them to the database
+// and returns the number of users created together with the generation batch ID.
+// The batch is all-or-nothing: if any role assignment fails, no user is kept
+// (earlier versions kept the users and returned only the role error)
+func (s *Service) GenerateFakeUsers(ctx context.Context, count int, userType string, roleIDs []int64) (int, string, error) {
+	ctx, span := tracing.Start(ctx, "Service.GenerateFakeUsers")
+	defer span.End()
+
 	if count < 1 || count > 1000 {
-		return 0, fmt.Errorf("count must be between 1 and 1000")
+		return 0, "", models.Validationf("count must be between 1 and 1000")
 	}
 
 	// 設置預設使用者類型為 "doctor" 如果未提供
 	if userType == "" {
-		userType = "doctor"
+		userType = utils.UserTypeDoctor
+	}
+	if _, ok := utils.GetFakeUserType(userType); !ok {
+		return 0, "", models.Validationf("unknown user type: %s", userType)
 	}
 
-	// 初始化計數器，防止重複帳號
-	if s.db != nil {
-		if err := utils.InitializeCounters(s.db); err != nil {
-			return 0, fmt.Errorf("failed to initialize counters: %v", err)
-		}
+	// 從資料庫保留連續的帳號編號，確保多個程序同時生成時不會重複。
+	// 保留編號不放在交易中，交易回滾時跳過的編號不會被重複使用
+	prefix := utils.AccountPrefixForUserType(userType)
+	firstNumber, err := s.store.Users().ReserveAccountNumbers(ctx, prefix, count)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to reserve account numbers: %w", err)
 	}
 
 	// 生成假使用者
-	users := utils.GenerateFakeUsers(count, userType)
+	start := time.Now()
+	users := utils.GenerateFakeUsers(count, userType, firstNumber)
 
-	// 批量創建使用者並獲取創建後的使用者 ID
-	userIDs, err := s.repo.BatchCreateUsers(ctx, users)
-	if err != nil {
-		return 0, fmt.Errorf("批量創建使用者失敗: %v", err)
-	}
+	// 建立使用者、批次紀錄與角色在同一個交易中完成，任一步驟失敗時全部回滾
+	var batchID string
+	err = s.store.WithTx(ctx, func(ctx context.Context) error {
+		// 批量創建使用者並獲取創建後的使用者 ID
+		userIDs, err := s.store.Users().BatchCreateUsers(ctx, users)
+		if err != nil {
+			return fmt.Errorf("批量創建使用者失敗: %w", err)
+		}
+		jobs.ReportProgress(ctx, models.JobStageUsers, len(userIDs), len(userIDs))
 
-	// 如果沒有創建任何使用者，直接返回
-	if len(userIDs) == 0 {
-		return 0, nil
-	}
+		// 如果沒有創建任何使用者，直接返回
+		if len(userIDs) == 0 {
+			return nil
+		}
 
-	// 如果提供了角色 ID，為每個用戶分配這些角色
-	if len(roleIDs) > 0 {
-		// 統計角色分配失敗的用戶數
-		failCount := 0
-		// 直接為剛剛創建的用戶分配角色
-		for _, userID := range userIDs {
-			// 嘗試分配角色
-			if err := s.repo.AssignRoleToUser(ctx, userID, roleIDs); err != nil {
-				failCount++
-				fmt.Printf("Error assigning roles to user %d: %v\n", userID, err)
-				// 繼續處理下一個用戶，不中斷整個流程
-			}
+		// 記錄生成批次，以便之後清除
+		batchID, err = s.recordBatch(ctx, models.BatchKindUsers, models.BatchEntityUser, userIDs)
+		if err != nil {
+			return err
+		}
+		err = s.recordAudit(ctx, models.AuditActionGenerate, models.AuditEntityBatch, batchID, nil, map[string]interface{}{
+			"kind": models.BatchKindUsers, "user_type": userType, "count": len(userIDs), "role_ids": roleIDs,
+		})
+		if err != nil {
+			return err
 		}
 
-		// 如果所有角色分配都失敗，返回錯誤
-		if failCount == len(userIDs) {
-			return len(users), fmt.Errorf("所有用戶角色分配失敗，請檢查 user_role 表和權限設置")
-		} else if failCount > 0 {
-			// 部分失敗，繼續處理但記錄警告
-			fmt.Printf("警告: %d/%d 個用戶角色分配失敗\n", failCount, len(userIDs))
+		// 為每個用戶分配角色；未提供角色 ID 時使用該使用者類型的預設角色（可能每人隨機不同）
+		useDefaultRoles := len(roleIDs) == 0
+		if useDefaultRoles && len(utils.GetDefaultRoleIDsForUserType(userType)) == 0 {
+			return nil
+		}
+		for i, userID := range userIDs {
+			userRoleIDs := roleIDs
+			if useDefaultRoles {
+				userRoleIDs = utils.GetDefaultRoleIDsForUserType(userType)
+			}
+			if err := s.assignRoles(ctx, userID, userRoleIDs); err != nil {
+				return fmt.Errorf("為用戶 %d 分配角色失敗: %w", userID, err)
+			}
+			jobs.ReportProgress(ctx, models.JobStageRoles, i+1, len(userIDs))
 		}
+		return nil
+	})
+	metrics.ObserveGeneration("users", start, err)
+	if err != nil {
+		return 0, "", err
 	}
 
-	return len(users), nil
+	metrics.UsersGenerated.WithLabelValues(userType).Add(float64(len(users)))
+	logging.FromContext(ctx).Info("已生成假使用者", "user_type", userType, "count", len(users), "batch_id", batchID)
+	return len(users), batchID, nil
 }
 
 // CreateUser creates a single user in the database
 func (s *Service) CreateUser(ctx context.Context, user *models.User) error {
-	return s.repo.Create(ctx, user)
+	ctx, span := tracing.Start(ctx, "Service.CreateUser")
+	defer span.End()
+
+	return s.store.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.store.Users().Create(ctx, user); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, models.AuditActionCreate, models.AuditEntityUser, strconv.FormatInt(user.ID, 10), nil, userSnapshot(user))
+	})
+}
+
+// userSnapshot 回傳記錄於稽核紀錄的使用者快照，不含密碼
+func userSnapshot(user *models.User) *models.User {
+	copied := *user
+	copied.Password = ""
+	copied.Roles = nil
+	return &copied
 }
 
 // GetUserByID retrieves a user by ID from the database
 func (s *Service) GetUserByID(ctx context.Context, id string) (*models.User, error) {
-	return s.repo.GetByID(ctx, id)
+	ctx, span := tracing.Start(ctx, "Service.GetUserByID")
+	defer span.End()
+
+	return s.store.Users().GetByID(ctx, id)
 }
 
 // ListUsers retrieves a list of users from the database
 func (s *Service) ListUsers(ctx context.Context) ([]*models.User, error) {
-	return s.repo.ListUsersWithRoles(ctx, 50) // Limiting to 50 users for display purposes
+	ctx, span := tracing.Start(ctx, "Service.ListUsers")
+	defer span.End()
+
+	return s.store.Users().ListUsersWithRoles(ctx, 50) // Limiting to 50 users for display purposes
 }
 
 // ListAllRoles 獲取所有角色
 func (s *Service) ListAllRoles(ctx context.Context) ([]*models.Role, error) {
-	return s.repo.ListAllRoles(ctx)
+	ctx, span := tracing.Start(ctx, "Service.ListAllRoles")
+	defer span.End()
+
+	return s.store.Roles().ListAllRoles(ctx)
 }
 
-// AssignRolesToUser 為用戶分配角色
+// AssignRolesToUser 以 roleIDs 取代用戶現有的角色，並記錄異動前後的角色
 func (s *Service) AssignRolesToUser(ctx context.Context, userID int64, roleIDs []int64) error {
-	return s.repo.AssignRoleToUser(ctx, userID, roleIDs)
+	ctx, span := tracing.Start(ctx, "Service.AssignRolesToUser")
+	defer span.End()
+
+	return s.store.WithTx(ctx, func(ctx context.Context) error {
+		before, err := s.store.Roles().GetUserRoles(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if err := s.assignRoles(ctx, userID, roleIDs); err != nil {
+			return err
+		}
+		after, err := s.store.Roles().GetUserRoles(ctx, userID)
+		if err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, models.AuditActionAssignRoles, models.AuditEntityUser, strconv.FormatInt(userID, 10),
+			userRolesSnapshot(before), userRolesSnapshot(after))
+	})
+}
+
+// assignRoles 為用戶分配角色，不記錄稽核紀錄；生成與複製資料時由批次的稽核紀錄涵蓋
+func (s *Service) assignRoles(ctx context.Context, userID int64, roleIDs []int64) error {
+	if err := s.store.Roles().AssignRoleToUser(ctx, userID, roleIDs); err != nil {
+		metrics.RoleAssignmentFailures.Inc()
+		return err
+	}
+	return nil
+}
+
+// userRolesSnapshot 回傳記錄於稽核紀錄的用戶角色快照
+func userRolesSnapshot(roles []*models.Role) map[string]interface{} {
+	ids := make([]int64, 0, len(roles))
+	aliases := make([]string, 0, len(roles))
+	for _, role := range roles {
+		ids = append(ids, role.ID)
+		aliases = append(aliases, role.Alias)
+	}
+	return map[string]interface{}{"role_ids": ids, "roles": aliases}
 }
 
 // GetUserRoles 獲取用戶角色
 func (s *Service) GetUserRoles(ctx context.Context, userID int64) ([]*models.Role, error) {
-	return s.repo.GetUserRoles(ctx, userID)
+	ctx, span := tracing.Start(ctx, "Service.GetUserRoles")
+	defer span.End()
+
+	return s.store.Roles().GetUserRoles(ctx, userID)
+}
+
+// AddRole 新增角色
+func (s *Service) AddRole(ctx context.Context, alias, description string) error {
+	ctx, span := tracing.Start(ctx, "Service.AddRole")
+	defer span.End()
+
+	if alias == "" {
+		return models.Validationf("角色代碼不可為空")
+	}
+	return s.store.WithTx(ctx, func(ctx context.Context) error {
+		roleID, err := s.store.Roles().AddRole(ctx, alias, description)
+		if err != nil {
+			return err
+		}
+		role := &models.Role{ID: roleID, Alias: alias, Description: &description}
+		return s.recordAudit(ctx, models.AuditActionCreate, models.AuditEntityRole, strconv.FormatInt(roleID, 10), nil, role)
+	})
+}
+
+// DeleteRole 刪除角色，並在同一個交易中移除使用者的該角色，角色不存在時回傳 models.ErrNotFound。
+// 每個受影響的使用者都記錄一筆角色分配的稽核紀錄
+func (s *Service) DeleteRole(ctx context.Context, roleID int64) error {
+	ctx, span := tracing.Start(ctx, "Service.DeleteRole")
+	defer span.End()
+
+	if roleID <= 0 {
+		return models.Validationf("無效的角色ID")
+	}
+	return s.store.WithTx(ctx, func(ctx context.Context) error {
+		role, err := s.findRole(ctx, roleID)
+		if err != nil {
+			return err
+		}
+		if role == nil {
+			return models.NotFoundf("未找到ID為 %d 的角色", roleID)
+		}
+		users, err := s.store.Users().GetUserByRoleID(ctx, roleID)
+		if err != nil {
+			return err
+		}
+		before := make(map[int64][]*models.Role, len(users))
+		for _, user := range users {
+			if before[user.ID], err = s.store.Roles().GetUserRoles(ctx, user.ID); err != nil {
+				return err
+			}
+		}
+
+		if err := s.store.Roles().DeleteRole(ctx, roleID); err != nil {
+			return err
+		}
+		for _, user := range users {
+			after, err := s.store.Roles().GetUserRoles(ctx, user.ID)
+			if err != nil {
+				return err
+			}
+			if err := s.recordAudit(ctx, models.AuditActionAssignRoles, models.AuditEntityUser, strconv.FormatInt(user.ID, 10),
+				userRolesSnapshot(before[user.ID]), userRolesSnapshot(after)); err != nil {
+				return err
+			}
+		}
+		return s.recordAudit(ctx, models.AuditActionDelete, models.AuditEntityRole, strconv.FormatInt(roleID, 10), role, nil)
+	})
+}
+
+// findRole 回傳指定ID的角色，沒有時回傳 nil
+func (s *Service) findRole(ctx context.Context, roleID int64) (*models.Role, error) {
+	roles, err := s.store.Roles().ListAllRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range roles {
+		if role.ID == roleID {
+			return role, nil
+		}
+	}
+	return nil, nil
 }
@@ -0,0 +1,1314 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang-gin-app/internal/jobs"
+	"golang-gin-app/internal/metrics"
+	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/repository"
+	"golang-gin-app/internal/utils"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestService 建立使用記憶體 Store 的 Service
+func newTestService() (*Service, *repository.MemoryStore) {
+	store := repository.NewMemoryStore()
+	svc := NewService(store)
+	anonymizer, _ := utils.NewAnonymizer([]byte("test-anonymization-key"))
+	svc.SetAnonymizer(anonymizer)
+	return svc, store
+}
+
+func roleIDsOf(roles []*models.Role) []int64 {
+	ids := make([]int64, 0, len(roles))
+	for _, role := range roles {
+		ids = append(ids, role.ID)
+	}
+	return ids
+}
+
+func TestGenerateFakeUsersConcurrentAccountsAreUnique(t *testing.T) {
+	// 多個 Service 共用同一個 Repository，模擬多個應用程式實例共用同一個資料庫
+	store := repository.NewMemoryStore()
+
+	const instances = 8
+	const perInstance = 25
+	userTypes := []string{"doctor", "therapy"}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, instances*len(userTypes))
+	for i := 0; i < instances; i++ {
+		// 每個 Service 代表一個獨立的應用程式實例
+		svc := NewService(store)
+		for _, userType := range userTypes {
+			wg.Add(1)
+			go func(userType string) {
+				defer wg.Done()
+				if _, _, err := svc.GenerateFakeUsers(context.Background(), perInstance, userType, nil); err != nil {
+					errs <- err
+				}
+			}(userType)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("GenerateFakeUsers returned error: %v", err)
+	}
+
+	want := instances * perInstance * len(userTypes)
+	users, err := store.Users().ListUsers(context.Background(), want+1)
+	if err != nil {
+		t.Fatalf("ListUsers returned error: %v", err)
+	}
+	if len(users) != want {
+		t.Fatalf("expected %d users, got %d", want, len(users))
+	}
+	seen := make(map[string]bool, len(users))
+	for _, user := range users {
+		if seen[user.Account] {
+			t.Fatalf("duplicate account %s", user.Account)
+		}
+		seen[user.Account] = true
+	}
+	for _, userType := range userTypes {
+		for n := 1; n <= instances*perInstance; n++ {
+			if account := fmt.Sprintf("%s%d", userType, n); !seen[account] {
+				t.Errorf("expected account %s to be generated", account)
+			}
+		}
+	}
+}
+
+func TestGenerateFakeUsersValidatesInput(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	cases := []struct {
+		name     string
+		count    int
+		userType string
+	}{
+		{"zero count", 0, "doctor"},
+		{"too many", 1001, "doctor"},
+		{"unknown type", 1, "nurse"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, _, err := svc.GenerateFakeUsers(ctx, tc.count, tc.userType, nil); !errors.Is(err, models.ErrValidation) {
+				t.Fatalf("expected validation error for count=%d userType=%q, got %v", tc.count, tc.userType, err)
+			}
+		})
+	}
+}
+
+func TestGenerateFakeUsersDefaultsToDoctor(t *testing.T) {
+	svc, store := newTestService()
+	ctx := context.Background()
+
+	created, batchID, err := svc.GenerateFakeUsers(ctx, 2, "", nil)
+	if err != nil {
+		t.Fatalf("GenerateFakeUsers returned error: %v", err)
+	}
+	if created != 2 || batchID == "" {
+		t.Fatalf("expected 2 users with a batch ID, got %d users and batch %q", created, batchID)
+	}
+
+	users, _ := store.Users().ListUsers(ctx, 10)
+	for _, user := range users {
+		if !strings.HasPrefix(user.Account, "doctor") {
+			t.Errorf("expected doctor account, got %s", user.Account)
+		}
+		if !strings.HasSuffix(user.Email, "@example.com") {
+			t.Errorf("expected example.com email, got %s", user.Email)
+		}
+	}
+}
+
+func TestGenerateFakeUsersSkipsExistingAccountNumbers(t *testing.T) {
+	svc, store := newTestService()
+	ctx := context.Background()
+
+	// 手動建立的帳號不應與生成的帳號衝突
+	manual := &models.User{Account: "doctor5", Email: "manual@example.com", Status: "APPROVED", CreateTime: time.Now()}
+	if err := store.Users().Create(ctx, manual); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+
+	if _, _, err := svc.GenerateFakeUsers(ctx, 2, "doctor", nil); err != nil {
+		t.Fatalf("GenerateFakeUsers returned error: %v", err)
+	}
+	for _, id := range []string{"2", "3"} {
+		user, _ := store.Users().GetByID(ctx, id)
+		if user == nil {
+			t.Fatalf("expected user %s to exist", id)
+		}
+		if user.Account != "doctor6" && user.Account != "doctor7" {
+			t.Errorf("expected account after doctor5, got %s", user.Account)
+		}
+	}
+}
+
+func TestGenerateFakeUsersAssignsSelectedRoles(t *testing.T) {
+	svc, store := newTestService()
+	ctx := context.Background()
+
+	selected := []int64{utils.ADMIN_ROLE_ID, utils.DTX_PI_ROLE_ID}
+	if _, _, err := svc.GenerateFakeUsers(ctx, 3, "doctor", selected); err != nil {
+		t.Fatalf("GenerateFakeUsers returned error: %v", err)
+	}
+
+	users, _ := store.Users().ListUsersWithRoles(ctx, 10)
+	if len(users) != 3 {
+		t.Fatalf("expected 3 users, got %d", len(users))
+	}
+	for _, user := range users {
+		got := roleIDsOf(user.Roles)
+		if len(got) != 2 || got[0] != selected[0] || got[1] != selected[1] {
+			t.Errorf("user %s: expected roles %v, got %v", user.Account, selected, got)
+		}
+	}
+}
+
+func TestGenerateFakeUsersAssignsDefaultRoles(t *testing.T) {
+	cases := []struct {
+		userType string
+		allowed  []int64
+	}{
+		{utils.UserTypeDoctor, []int64{utils.DOCTOR_ROLE_ID}},
+		{utils.UserTypePatient, []int64{utils.USER_ROLE_ID}},
+		{utils.UserTypeAdmin, []int64{utils.ADMIN_ROLE_ID}},
+		{utils.UserTypeTherapy, []int64{utils.DTX_PSY_ROLE_ID, utils.DTX_ST_ROLE_ID, utils.DTX_OT_ROLE_ID, utils.DTX_PI_ROLE_ID}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.userType, func(t *testing.T) {
+			svc, store := newTestService()
+			ctx := context.Background()
+
+			if _, _, err := svc.GenerateFakeUsers(ctx, 10, tc.userType, nil); err != nil {
+				t.Fatalf("GenerateFakeUsers returned error: %v", err)
+			}
+			users, _ := store.Users().ListUsersWithRoles(ctx, 20)
+			for _, user := range users {
+				got := roleIDsOf(user.Roles)
+				if len(got) != 1 {
+					t.Fatalf("user %s: expected exactly one default role, got %v", user.Account, got)
+				}
+				found := false
+				for _, id := range tc.allowed {
+					if got[0] == id {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("user %s: role %d not in %v", user.Account, got[0], tc.allowed)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateAvailableSlots(t *testing.T) {
+	svc, store := newTestService()
+	ctx := context.Background()
+
+	slots, batchID, err := svc.GenerateAvailableSlots(ctx, 1, 2, 3, 9, 30)
+	if err != nil {
+		t.Fatalf("GenerateAvailableSlots returned error: %v", err)
+	}
+	if len(slots) != 6 || batchID == "" {
+		t.Fatalf("expected 6 slots with a batch ID, got %d slots and batch %q", len(slots), batchID)
+	}
+
+	stored, _ := store.Slots().GetAvailableSlotsByDoctor(ctx, 1)
+	if len(stored) != 6 {
+		t.Fatalf("expected 6 stored slots, got %d", len(stored))
+	}
+	wantStarts := []string{"09:00", "09:30", "10:00"}
+	for i, slot := range stored[:3] {
+		if got := slot.SlotBeginTime.Format("15:04"); got != wantStarts[i] {
+			t.Errorf("slot %d: expected start %s, got %s", i, wantStarts[i], got)
+		}
+		if got := slot.SlotEndTime.Sub(slot.SlotBeginTime); got != 30*time.Minute {
+			t.Errorf("slot %d: expected 30 minute duration, got %v", i, got)
+		}
+		if slot.IsBooked {
+			t.Errorf("slot %d: new slots must not be booked", i)
+		}
+	}
+
+	batches, _ := svc.ListGenerationBatches(ctx)
+	if len(batches) != 1 || batches[0].Counts[models.BatchEntitySlot] != 6 {
+		t.Fatalf("expected one slot batch with 6 slots, got %+v", batches)
+	}
+}
+
+func TestGenerateAvailableSlotsStopsAtMidnight(t *testing.T) {
+	svc, _ := newTestService()
+
+	slots, _, err := svc.GenerateAvailableSlots(context.Background(), 1, 1, 4, 22, 60)
+	if err != nil {
+		t.Fatalf("GenerateAvailableSlots returned error: %v", err)
+	}
+	if len(slots) != 2 {
+		t.Fatalf("expected slots at 22:00 and 23:00 only, got %d", len(slots))
+	}
+	last := slots[len(slots)-1]
+	if got := last.SlotEndTime.Format("15:04"); got != "23:59" {
+		t.Errorf("expected last slot to end at 23:59, got %s", got)
+	}
+}
+
+func TestGenerateAvailableSlotsValidatesInput(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	cases := []struct {
+		name                                       string
+		doctorID                                   int64
+		days, slotsPerDay, startHour, slotDuration int
+	}{
+		{"doctor", 0, 1, 1, 9, 30},
+		{"days", 1, 0, 1, 9, 30},
+		{"too many days", 1, 366, 1, 9, 30},
+		{"slots per day", 1, 1, 25, 9, 30},
+		{"start hour", 1, 1, 1, 24, 30},
+		{"duration", 1, 1, 1, 9, 241},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, _, err := svc.GenerateAvailableSlots(ctx, tc.doctorID, tc.days, tc.slotsPerDay, tc.startHour, tc.slotDuration); !errors.Is(err, models.ErrValidation) {
+				t.Fatalf("expected validation error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateAvailableSlotRules(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	slots, _, err := svc.GenerateAvailableSlots(ctx, 1, 1, 1, 9, 60)
+	if err != nil {
+		t.Fatalf("GenerateAvailableSlots returned error: %v", err)
+	}
+	slot := *slots[0]
+
+	invalid := slot
+	invalid.ID = 0
+	if err := svc.UpdateAvailableSlot(ctx, &invalid); err == nil {
+		t.Error("expected error for missing slot ID")
+	}
+
+	invalid = slot
+	invalid.Doctor = 0
+	if err := svc.UpdateAvailableSlot(ctx, &invalid); err == nil {
+		t.Error("expected error for missing doctor")
+	}
+
+	invalid = slot
+	invalid.SlotBeginTime, invalid.SlotEndTime = slot.SlotEndTime, slot.SlotBeginTime
+	if err := svc.UpdateAvailableSlot(ctx, &invalid); err == nil {
+		t.Error("expected error when begin time is after end time")
+	}
+
+	updated := slot
+	updated.IsBooked = true
+	updated.SlotEndTime = slot.SlotBeginTime.Add(90 * time.Minute)
+	if err := svc.UpdateAvailableSlot(ctx, &updated); err != nil {
+		t.Fatalf("UpdateAvailableSlot returned error: %v", err)
+	}
+	got, err := svc.GetAvailableSlotByID(ctx, slot.ID)
+	if err != nil {
+		t.Fatalf("GetAvailableSlotByID returned error: %v", err)
+	}
+	if !got.IsBooked || !got.SlotEndTime.Equal(updated.SlotEndTime) {
+		t.Errorf("slot was not updated: %+v", got)
+	}
+}
+
+func TestDeleteAvailableSlotRules(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	slots, _, err := svc.GenerateAvailableSlots(ctx, 1, 1, 2, 9, 60)
+	if err != nil {
+		t.Fatalf("GenerateAvailableSlots returned error: %v", err)
+	}
+	free, booked := slots[0], *slots[1]
+
+	if err := svc.DeleteAvailableSlot(ctx, 0); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("expected validation error for invalid slot ID, got %v", err)
+	}
+	if err := svc.DeleteAvailableSlot(ctx, 999); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected not found error for missing slot, got %v", err)
+	}
+
+	booked.IsBooked = true
+	if err := svc.UpdateAvailableSlot(ctx, &booked); err != nil {
+		t.Fatalf("UpdateAvailableSlot returned error: %v", err)
+	}
+	if err := svc.DeleteAvailableSlot(ctx, booked.ID); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("expected conflict when deleting a booked slot, got %v", err)
+	}
+	if _, err := svc.GetAvailableSlotByID(ctx, booked.ID); err != nil {
+		t.Errorf("booked slot must not be deleted: %v", err)
+	}
+
+	if err := svc.DeleteAvailableSlot(ctx, free.ID); err != nil {
+		t.Fatalf("DeleteAvailableSlot returned error: %v", err)
+	}
+	if _, err := svc.GetAvailableSlotByID(ctx, free.ID); err == nil {
+		t.Error("expected free slot to be deleted")
+	}
+}
+
+func TestSlotMetrics(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+	generated, booked, deleted := testutil.ToFloat64(metrics.SlotsGenerated), testutil.ToFloat64(metrics.SlotsBooked), testutil.ToFloat64(metrics.SlotsDeleted)
+
+	slots, _, err := svc.GenerateAvailableSlots(ctx, 1, 1, 3, 9, 60)
+	if err != nil {
+		t.Fatalf("GenerateAvailableSlots returned error: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.SlotsGenerated) - generated; got != 3 {
+		t.Errorf("slots generated = %v, want 3", got)
+	}
+
+	// 只有由未預約改為已預約時才計入，再次更新已預約的時段不重複計算
+	slot := *slots[0]
+	slot.IsBooked = true
+	for i := 0; i < 2; i++ {
+		if err := svc.UpdateAvailableSlot(ctx, &slot); err != nil {
+			t.Fatalf("UpdateAvailableSlot returned error: %v", err)
+		}
+	}
+	if got := testutil.ToFloat64(metrics.SlotsBooked) - booked; got != 1 {
+		t.Errorf("slots booked = %v, want 1", got)
+	}
+
+	// 刪除失敗（已預約）不計入
+	svc.DeleteAvailableSlot(ctx, slot.ID)
+	if err := svc.DeleteAvailableSlot(ctx, slots[1].ID); err != nil {
+		t.Fatalf("DeleteAvailableSlot returned error: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.SlotsDeleted) - deleted; got != 1 {
+		t.Errorf("slots deleted = %v, want 1", got)
+	}
+}
+
+func TestPurgeGenerationBatchRemovesUsersRolesAndSlots(t *testing.T) {
+	svc, store := newTestService()
+	ctx := context.Background()
+
+	_, batchID, err := svc.GenerateFakeUsers(ctx, 2, "doctor", nil)
+	if err != nil {
+		t.Fatalf("GenerateFakeUsers returned error: %v", err)
+	}
+	doctors, _ := svc.GetDoctorUsers(ctx)
+	if len(doctors) != 2 {
+		t.Fatalf("expected 2 doctors, got %d", len(doctors))
+	}
+	if _, _, err := svc.GenerateAvailableSlots(ctx, doctors[0].ID, 1, 2, 9, 60); err != nil {
+		t.Fatalf("GenerateAvailableSlots returned error: %v", err)
+	}
+
+	preview, err := svc.PurgeGenerationBatch(ctx, batchID, true)
+	if err != nil {
+		t.Fatalf("dry run returned error: %v", err)
+	}
+	if users, _ := store.Users().ListUsers(ctx, 10); len(users) != 2 {
+		t.Fatalf("dry run must not delete users: %+v", preview)
+	}
+
+	if _, err := svc.PurgeGenerationBatch(ctx, batchID, false); err != nil {
+		t.Fatalf("PurgeGenerationBatch returned error: %v", err)
+	}
+	if users, _ := store.Users().ListUsers(ctx, 10); len(users) != 0 {
+		t.Errorf("expected users to be purged, %d left", len(users))
+	}
+	if slots, _ := svc.GetAvailableSlotsByDoctor(ctx, doctors[0].ID); len(slots) != 0 {
+		t.Errorf("expected the doctor's slots to be purged, %d left", len(slots))
+	}
+	if _, err := svc.PurgeGenerationBatch(ctx, batchID, false); err == nil {
+		t.Error("expected error when purging an unknown batch")
+	}
+}
+
+func TestSaveFakePatientsSkipsInvalidAndRecordsBatch(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	catalogue, err := svc.GetPatientCatalogue(ctx)
+	if err != nil {
+		t.Fatalf("GetPatientCatalogue returned error: %v", err)
+	}
+	patients, err := utils.GenerateFakePatients(3, catalogue)
+	if err != nil {
+		t.Fatalf("GenerateFakePatients returned error: %v", err)
+	}
+	patients[2].HistoryDiseases = append(patients[2].HistoryDiseases, "不存在的疾病")
+
+	saved, batchID, skipped, err := svc.SaveFakePatients(ctx, patients)
+	if err != nil {
+		t.Fatalf("SaveFakePatients returned error: %v", err)
+	}
+	if saved != 2 || len(skipped) != 1 || batchID == "" {
+		t.Fatalf("saved = %d, skipped = %v, batch = %q; want 2 saved and 1 skipped", saved, skipped, batchID)
+	}
+
+	preview, err := svc.PurgeGenerationBatch(ctx, batchID, true)
+	if err != nil {
+		t.Fatalf("dry run returned error: %v", err)
+	}
+	for _, step := range preview.Counts {
+		if step.Table == "patient" && step.Rows != 2 {
+			t.Errorf("expected 2 patients in batch, got %d", step.Rows)
+		}
+	}
+}
+
+func TestWithTxRollsBackOnErrorPanicAndCancel(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(store *repository.MemoryStore, create func(ctx context.Context) error) error
+	}{
+		{"error", func(store *repository.MemoryStore, create func(ctx context.Context) error) error {
+			return store.WithTx(context.Background(), func(ctx context.Context) error {
+				if err := create(ctx); err != nil {
+					return err
+				}
+				return fmt.Errorf("boom")
+			})
+		}},
+		{"panic", func(store *repository.MemoryStore, create func(ctx context.Context) error) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					err = fmt.Errorf("panic: %v", p)
+				}
+			}()
+			return store.WithTx(context.Background(), func(ctx context.Context) error {
+				if err := create(ctx); err != nil {
+					return err
+				}
+				panic("boom")
+			})
+		}},
+		{"cancel", func(store *repository.MemoryStore, create func(ctx context.Context) error) error {
+			ctx, cancel := context.WithCancel(context.Background())
+			return store.WithTx(ctx, func(ctx context.Context) error {
+				if err := create(ctx); err != nil {
+					return err
+				}
+				cancel()
+				return nil
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, store := newTestService()
+			err := tt.run(store, func(ctx context.Context) error {
+				// 交易中呼叫其他 service 方法，應沿用同一個交易
+				_, _, err := svc.GenerateFakeUsers(ctx, 2, utils.UserTypeDoctor, nil)
+				return err
+			})
+			if err == nil {
+				t.Fatal("expected WithTx to fail")
+			}
+			if users, _ := store.Users().ListUsers(context.Background(), 10); len(users) != 0 {
+				t.Errorf("expected rollback to remove users, %d left", len(users))
+			}
+			if batches, _ := svc.ListGenerationBatches(context.Background()); len(batches) != 0 {
+				t.Errorf("expected rollback to remove batches, %d left", len(batches))
+			}
+		})
+	}
+}
+
+func TestCopyToRemapsIDsAndReportsConflicts(t *testing.T) {
+	source, _ := newTestService()
+	target, targetStore := newTestService()
+	ctx := context.Background()
+
+	// 目標資料庫先有一個使用者，使來源與目標的ID錯開
+	if _, _, err := target.GenerateFakeUsers(ctx, 1, "therapy", nil); err != nil {
+		t.Fatalf("GenerateFakeUsers returned error: %v", err)
+	}
+	if _, _, err := source.GenerateFakeUsers(ctx, 2, "doctor", nil); err != nil {
+		t.Fatalf("GenerateFakeUsers returned error: %v", err)
+	}
+	doctors, _ := source.GetDoctorUsers(ctx)
+	if _, _, err := source.GenerateAvailableSlots(ctx, doctors[0].ID, 1, 2, 9, 60); err != nil {
+		t.Fatalf("GenerateAvailableSlots returned error: %v", err)
+	}
+	catalogue, _ := source.GetPatientCatalogue(ctx)
+	patients, _ := utils.GenerateFakePatients(1, catalogue)
+	patients[0].UserID = doctors[1].ID
+	patients[0].DiseaseID = 1 // 生成器的 disease_id 可能不在疾病目錄中，會被回報為衝突
+	if _, _, _, err := source.SaveFakePatients(ctx, patients); err != nil {
+		t.Fatalf("SaveFakePatients returned error: %v", err)
+	}
+
+	req := models.CopyRequest{
+		UserIDs:      []int64{doctors[0].ID, doctors[1].ID},
+		PatientIDs:   []int64{patients[0].ID},
+		IncludeSlots: true,
+	}
+
+	preview, err := source.CopyTo(ctx, target, models.CopyRequest{UserIDs: req.UserIDs, DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run returned error: %v", err)
+	}
+	if len(preview.Users) != 2 || preview.BatchID != "" {
+		t.Fatalf("unexpected dry run report: %+v", preview)
+	}
+	if users, _ := targetStore.Users().ListUsers(ctx, 10); len(users) != 1 {
+		t.Fatalf("dry run must not keep users, got %d", len(users))
+	}
+
+	report, err := source.CopyTo(ctx, target, req)
+	if err != nil {
+		t.Fatalf("CopyTo returned error: %v", err)
+	}
+	if len(report.Users) != 2 || len(report.Patients) != 1 || report.Slots != 2 || len(report.Conflicts) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	mapped := make(map[int64]int64)
+	for _, mapping := range report.Users {
+		mapped[mapping.SourceID] = mapping.TargetID
+		if user, err := target.GetUserByID(ctx, fmt.Sprint(mapping.TargetID)); err != nil || user.Account != mapping.Label {
+			t.Errorf("expected target user %d to be %s, got %+v (%v)", mapping.TargetID, mapping.Label, user, err)
+		}
+		roles, _ := target.GetUserRoles(ctx, mapping.TargetID)
+		if len(roles) == 0 || roles[0].Alias != "DOCTOR" {
+			t.Errorf("expected copied user %d to keep the DOCTOR role, got %+v", mapping.TargetID, roles)
+		}
+	}
+	if slots, _ := target.GetAvailableSlotsByDoctor(ctx, mapped[doctors[0].ID]); len(slots) != 2 {
+		t.Errorf("expected 2 slots for the copied doctor, got %d", len(slots))
+	}
+	copied, err := targetStore.Patients().GetPatient(ctx, report.Patients[0].TargetID)
+	if err != nil {
+		t.Fatalf("GetPatient returned error: %v", err)
+	}
+	if copied.UserID != mapped[doctors[1].ID] || copied.IDNo != patients[0].IDNo {
+		t.Errorf("unexpected copied patient: %+v", copied)
+	}
+	if len(copied.HistoryDiseases) != len(patients[0].HistoryDiseases) {
+		t.Errorf("expected %d history diseases, got %d", len(patients[0].HistoryDiseases), len(copied.HistoryDiseases))
+	}
+
+	// 再次複製時帳號、時段與身分證字號都已存在
+	again, err := source.CopyTo(ctx, target, req)
+	if err != nil {
+		t.Fatalf("second CopyTo returned error: %v", err)
+	}
+	if len(again.Users) != 0 || len(again.Patients) != 0 || again.Slots != 0 || len(again.Conflicts) != 5 {
+		t.Fatalf("expected only conflicts on the second copy: %+v", again)
+	}
+
+	// 匿名化的病患不比對身分證字號
+	anonymized, err := source.CopyTo(ctx, target, models.CopyRequest{PatientIDs: req.PatientIDs, Anonymize: true})
+	if err != nil {
+		t.Fatalf("anonymized CopyTo returned error: %v", err)
+	}
+	if len(anonymized.Patients) != 1 {
+		t.Fatalf("expected an anonymized copy: %+v", anonymized)
+	}
+	if copied, _ := targetStore.Patients().GetPatient(ctx, anonymized.Patients[0].TargetID); copied.IDNo == patients[0].IDNo {
+		t.Errorf("expected the anonymized copy to get a new ID number, got %s", copied.IDNo)
+	}
+
+	// 假名由金鑰決定，再次複製同一病患時視為衝突
+	again, err = source.CopyTo(ctx, target, models.CopyRequest{PatientIDs: req.PatientIDs, Anonymize: true})
+	if err != nil {
+		t.Fatalf("second anonymized CopyTo returned error: %v", err)
+	}
+	if len(again.Patients) != 0 || len(again.Conflicts) != 1 {
+		t.Fatalf("expected the anonymized patient to conflict on the second copy: %+v", again)
+	}
+	if label := again.Conflicts[0].Label; label == patients[0].Name || label != anonymized.Patients[0].Label {
+		t.Errorf("expected the conflict to be labelled with the anonymized name, got %q", label)
+	}
+
+	// 複製的資料記錄為批次，可一併清除
+	if _, err := target.PurgeGenerationBatch(ctx, report.BatchID, false); err != nil {
+		t.Fatalf("PurgeGenerationBatch returned error: %v", err)
+	}
+	if users, _ := targetStore.Users().ListUsers(ctx, 10); len(users) != 1 {
+		t.Errorf("expected copied users to be purged, %d users left", len(users))
+	}
+}
+
+func TestCopyToClearsUnmappedDiseaseID(t *testing.T) {
+	source, _ := newTestService()
+	target, targetStore := newTestService()
+	ctx := context.Background()
+
+	catalogue, _ := source.GetPatientCatalogue(ctx)
+	patients, _ := utils.GenerateFakePatients(2, catalogue)
+	patients[0].UserID, patients[1].UserID = 0, 0
+	patients[0].DiseaseID = 1
+	patients[1].DiseaseID = 99999 // 不在來源疾病目錄中的ID
+	if _, _, _, err := source.SaveFakePatients(ctx, patients); err != nil {
+		t.Fatalf("SaveFakePatients returned error: %v", err)
+	}
+
+	report, err := source.CopyTo(ctx, target, models.CopyRequest{PatientIDs: []int64{patients[0].ID, patients[1].ID}})
+	if err != nil {
+		t.Fatalf("CopyTo returned error: %v", err)
+	}
+	if len(report.Patients) != 2 || len(report.Conflicts) != 1 || report.Conflicts[0].SourceID != patients[1].ID {
+		t.Fatalf("expected one disease conflict: %+v", report)
+	}
+	for i, mapping := range report.Patients {
+		copied, err := targetStore.Patients().GetPatient(ctx, mapping.TargetID)
+		if err != nil {
+			t.Fatalf("GetPatient returned error: %v", err)
+		}
+		want := patients[0].DiseaseID
+		if i == 1 {
+			want = 0
+		}
+		if copied.DiseaseID != want {
+			t.Errorf("patient %d: disease_id = %d, want %d", i, copied.DiseaseID, want)
+		}
+	}
+}
+
+func TestCopyToValidatesRequest(t *testing.T) {
+	source, _ := newTestService()
+	target, _ := newTestService()
+	ctx := context.Background()
+
+	if _, err := source.CopyTo(ctx, source, models.CopyRequest{UserIDs: []int64{1}}); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("expected validation error when copying into the same database, got %v", err)
+	}
+	if _, err := source.CopyTo(ctx, target, models.CopyRequest{}); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("expected validation error for an empty request, got %v", err)
+	}
+	if _, err := source.CopyTo(ctx, target, models.CopyRequest{UserIDs: []int64{99}}); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected not found error for an unknown user, got %v", err)
+	}
+}
+
+func TestAnonymizePatients(t *testing.T) {
+	svc, store := newTestService()
+	ctx := context.Background()
+
+	catalogue, _ := svc.GetPatientCatalogue(ctx)
+	patients, _ := utils.GenerateFakePatients(3, catalogue)
+	if _, _, _, err := svc.SaveFakePatients(ctx, patients); err != nil {
+		t.Fatalf("SaveFakePatients returned error: %v", err)
+	}
+
+	preview, err := svc.AnonymizePatients(ctx, nil, true)
+	if err != nil {
+		t.Fatalf("dry run returned error: %v", err)
+	}
+	if preview.Count != 3 || len(preview.Samples) != 3 {
+		t.Fatalf("unexpected dry run result: %+v", preview)
+	}
+	if unchanged, _ := store.Patients().GetPatient(ctx, patients[0].ID); unchanged.IDNo != patients[0].IDNo {
+		t.Fatal("dry run must not modify patients")
+	}
+
+	result, err := svc.AnonymizePatients(ctx, []int64{patients[0].ID}, false)
+	if err != nil {
+		t.Fatalf("AnonymizePatients returned error: %v", err)
+	}
+	anonymized, _ := store.Patients().GetPatient(ctx, patients[0].ID)
+	if result.Count != 1 || anonymized.IDNo == patients[0].IDNo || anonymized.Name != result.Samples[0].Pseudonym {
+		t.Errorf("unexpected anonymized patient %+v for result %+v", anonymized, result)
+	}
+	if len(anonymized.HistoryDiseases) != len(patients[0].HistoryDiseases) || anonymized.City != patients[0].City {
+		t.Errorf("expected histories and city to be kept: %+v", anonymized)
+	}
+	if untouched, _ := store.Patients().GetPatient(ctx, patients[1].ID); untouched.IDNo != patients[1].IDNo {
+		t.Error("expected other patients to be left unchanged")
+	}
+
+	if _, err := NewService(store).AnonymizePatients(ctx, nil, true); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("expected validation error without an anonymizer, got %v", err)
+	}
+}
+
+// submitTestJob 以 params 建立工作，失敗時中止測試
+func submitTestJob(t *testing.T, svc *Service, kind string, params string) *models.Job {
+	t.Helper()
+	job, err := svc.SubmitJob(context.Background(), kind, json.RawMessage(params))
+	if err != nil {
+		t.Fatalf("建立工作失敗: %v", err)
+	}
+	return job
+}
+
+func TestRunJobRecordsResultAndProgress(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	job := submitTestJob(t, svc, models.BatchKindUsers, `{"count":3,"user_type":"doctor"}`)
+	if job.Status != models.JobStatusQueued || job.Progress.Total != 3 {
+		t.Fatalf("新建立的工作不符: %+v", job)
+	}
+
+	var observed []models.Job
+	if err := svc.RunJob(ctx, job.ID, func(job models.Job) { observed = append(observed, job) }); err != nil {
+		t.Fatalf("執行工作失敗: %v", err)
+	}
+
+	got, err := svc.GetJob(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("獲取工作失敗: %v", err)
+	}
+	if got.Status != models.JobStatusSucceeded || got.Result == nil || got.Result.Created != 3 || got.Result.BatchID == "" {
+		t.Fatalf("工作結果不符: %+v", got)
+	}
+	if got.StartedAt == nil || got.FinishedAt == nil {
+		t.Fatalf("應記錄開始與結束時間: %+v", got)
+	}
+
+	stages := make(map[string]bool)
+	for _, snapshot := range observed {
+		stages[snapshot.Progress.Stage] = true
+	}
+	if !stages[models.JobStageUsers] || !stages[models.JobStageRoles] {
+		t.Fatalf("應回報使用者與角色的進度，實際為 %v", stages)
+	}
+	if first, last := observed[0], observed[len(observed)-1]; first.Status != models.JobStatusRunning || last.Status != models.JobStatusSucceeded {
+		t.Fatalf("回報的狀態應由執行中變為成功: %s -> %s", first.Status, last.Status)
+	}
+
+	// 已結束的工作不會再次執行，也無法取消
+	if err := svc.RunJob(ctx, job.ID, func(models.Job) { t.Fatal("已結束的工作不應再次執行") }); err != nil {
+		t.Fatalf("重複執行工作應直接結束: %v", err)
+	}
+	if _, err := svc.CancelJob(ctx, job.ID, func() bool { return true }); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("取消已結束的工作應回傳 ErrConflict，實際為 %v", err)
+	}
+}
+
+func TestRunJobPersistsProgress(t *testing.T) {
+	interval := jobProgressInterval
+	jobProgressInterval = time.Millisecond
+	defer func() { jobProgressInterval = interval }()
+
+	svc, store := newTestService()
+	ctx := context.Background()
+
+	job := submitTestJob(t, svc, models.BatchKindUsers, `{"count":1,"user_type":"doctor"}`)
+	if err := store.Jobs().StartJob(ctx, job.ID, svc.instanceID, time.Now()); err != nil {
+		t.Fatalf("開始工作失敗: %v", err)
+	}
+	writer := svc.startProgressWriter(ctx, job.ID, job.Progress)
+	writer.report(models.JobProgress{Stage: models.JobStageUsers, Done: 1, Total: 3})
+	writer.report(models.JobProgress{Stage: models.JobStageUsers, Done: 2, Total: 3})
+	want := models.JobProgress{Stage: models.JobStageUsers, Done: 2, Total: 3}
+	deadline := time.Now().Add(time.Second)
+	for {
+		got, err := svc.GetJob(ctx, job.ID)
+		if err != nil {
+			t.Fatalf("獲取工作失敗: %v", err)
+		}
+		if got.Progress == want {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("進度應定期寫入資料表: %+v", got.Progress)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	writer.stop()
+	writer.report(models.JobProgress{Stage: models.JobStageRoles, Done: 3, Total: 3})
+	time.Sleep(5 * time.Millisecond)
+	if got, _ := svc.GetJob(ctx, job.ID); got.Progress != want {
+		t.Fatalf("停止後不應再寫入進度: %+v", got.Progress)
+	}
+
+	// 生成作業在交易中回報進度，寫入不能讓工作卡住
+	job = submitTestJob(t, svc, models.BatchKindUsers, `{"count":200,"user_type":"doctor"}`)
+	done := make(chan error, 1)
+	go func() { done <- svc.RunJob(ctx, job.ID, func(models.Job) { time.Sleep(10 * time.Microsecond) }) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("執行工作失敗: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("寫入進度時工作不應卡住")
+	}
+	got, _ := svc.GetJob(ctx, job.ID)
+	if got.Status != models.JobStatusSucceeded || got.Progress.Done != got.Progress.Total || got.Progress.Total != 200 {
+		t.Fatalf("工作結束時應寫入最後的進度: %+v", got)
+	}
+}
+
+func TestProgressWriterHeartbeats(t *testing.T) {
+	progressInterval, heartbeatInterval := jobProgressInterval, jobHeartbeatInterval
+	jobProgressInterval, jobHeartbeatInterval = time.Millisecond, 5*time.Millisecond
+	defer func() { jobProgressInterval, jobHeartbeatInterval = progressInterval, heartbeatInterval }()
+
+	svc, store := newTestService()
+	ctx := context.Background()
+	job := submitTestJob(t, svc, models.BatchKindUsers, `{"count":3,"user_type":"doctor"}`)
+	startedAt := time.Now().Add(-time.Hour)
+	if err := store.Jobs().StartJob(ctx, job.ID, svc.instanceID, startedAt); err != nil {
+		t.Fatalf("開始工作失敗: %v", err)
+	}
+
+	// 沒有新進度時仍定期更新心跳，且不改變進度
+	writer := svc.startProgressWriter(ctx, job.ID, job.Progress)
+	defer writer.stop()
+	deadline := time.Now().Add(time.Second)
+	for {
+		got, err := svc.GetJob(ctx, job.ID)
+		if err != nil {
+			t.Fatalf("獲取工作失敗: %v", err)
+		}
+		if got.HeartbeatAt != nil && got.HeartbeatAt.After(startedAt) {
+			if got.Progress != job.Progress {
+				t.Fatalf("心跳不應改變進度: %+v", got.Progress)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("應定期更新心跳: %+v", got)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSubmitJobValidatesParams(t *testing.T) {
+	svc, _ := newTestService()
+	cases := []struct {
+		kind   string
+		params string
+	}{
+		{"unknown", `{}`},
+		{models.BatchKindUsers, ``},
+		{models.BatchKindUsers, `{"count":0}`},
+		{models.BatchKindUsers, `{"count":1,"user_type":"nobody"}`},
+		{models.BatchKindPatients, `{"count":1001}`},
+		{models.BatchKindPatients, `{"count":1,"profile":"unknown"}`},
+		{models.BatchKindSlots, `{"doctor_id":1,"days":0,"slots_per_day":1,"slot_duration":30}`},
+		{models.BatchKindSlots, `not json`},
+	}
+	for _, tc := range cases {
+		if _, err := svc.SubmitJob(context.Background(), tc.kind, json.RawMessage(tc.params)); !errors.Is(err, models.ErrValidation) {
+			t.Errorf("%s %s: 應回傳 ErrValidation，實際為 %v", tc.kind, tc.params, err)
+		}
+	}
+}
+
+func TestRunJobCancelledOrInterrupted(t *testing.T) {
+	svc, store := newTestService()
+
+	run := func(cause error) *models.Job {
+		t.Helper()
+		job := submitTestJob(t, svc, models.BatchKindSlots, `{"doctor_id":1,"days":2,"slots_per_day":4,"start_hour":8,"slot_duration":30}`)
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cancel(cause)
+		if err := svc.RunJob(ctx, job.ID, func(models.Job) {}); err != nil {
+			t.Fatalf("執行工作失敗: %v", err)
+		}
+		got, err := svc.GetJob(context.Background(), job.ID)
+		if err != nil {
+			t.Fatalf("獲取工作失敗: %v", err)
+		}
+		return got
+	}
+
+	if got := run(jobs.ErrCancelled); got.Status != models.JobStatusCancelled || got.FinishedAt == nil {
+		t.Fatalf("取消的工作應標記為已取消: %+v", got)
+	}
+	if got := run(jobs.ErrShutdown); got.Status != models.JobStatusQueued || got.StartedAt != nil {
+		t.Fatalf("應用程式結束時中斷的工作應放回佇列: %+v", got)
+	}
+	if slots, _ := store.Slots().GetAvailableSlotsByDoctor(context.Background(), 1); len(slots) != 0 {
+		t.Fatalf("中斷的工作應回滾，實際留下 %d 個時段", len(slots))
+	}
+}
+
+func TestCancelAndResumeJobs(t *testing.T) {
+	svc, store := newTestService()
+	ctx := context.Background()
+
+	cancelled := submitTestJob(t, svc, models.BatchKindPatients, `{"count":2}`)
+	interrupted := submitTestJob(t, svc, models.BatchKindPatients, `{"count":2}`)
+	queued := submitTestJob(t, svc, models.BatchKindPatients, `{"count":2}`)
+
+	got, err := svc.CancelJob(ctx, cancelled.ID, func() bool { t.Fatal("排隊中的工作不需要 Runner 取消"); return false })
+	if err != nil || got.Status != models.JobStatusCancelled {
+		t.Fatalf("取消排隊中的工作失敗: %+v (%v)", got, err)
+	}
+	if err := svc.RunJob(ctx, cancelled.ID, func(models.Job) { t.Fatal("已取消的工作不應執行") }); err != nil {
+		t.Fatalf("執行已取消的工作應直接結束: %v", err)
+	}
+
+	// 模擬已結束的程序留下的執行中工作：心跳已逾時
+	if err := store.Jobs().StartJob(ctx, interrupted.ID, "stopped-instance", time.Now().Add(-2*JobHeartbeatTimeout)); err != nil {
+		t.Fatalf("開始工作失敗: %v", err)
+	}
+	// Runner 沒有接受取消（工作不在本程序執行）時回傳 ErrConflict，且不記錄稽核紀錄
+	if _, err := svc.CancelJob(ctx, interrupted.ID, func() bool { return false }); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("不在本程序執行的工作應回傳 ErrConflict，實際為 %v", err)
+	}
+	if entries, _ := svc.ListAuditEntries(ctx, models.AuditFilter{EntityID: interrupted.ID, Action: models.AuditActionCancel}); len(entries) != 0 {
+		t.Fatalf("取消失敗時不應記錄稽核紀錄: %+v", entries)
+	}
+	if got, err := svc.CancelJob(ctx, interrupted.ID, func() bool { return true }); err != nil || got.Status != models.JobStatusRunning {
+		t.Fatalf("執行中的工作應交由 Runner 取消: %+v (%v)", got, err)
+	}
+	entries, _ := svc.ListAuditEntries(ctx, models.AuditFilter{EntityID: interrupted.ID, Action: models.AuditActionCancel})
+	if len(entries) != 1 {
+		t.Fatalf("Runner 接受取消後應記錄一筆稽核紀錄: %+v", entries)
+	}
+	// 其他程序仍在執行的工作與本程序執行中的工作都不應放回佇列
+	other := submitTestJob(t, svc, models.BatchKindPatients, `{"count":2}`)
+	if err := store.Jobs().StartJob(ctx, other.ID, "live-instance", time.Now()); err != nil {
+		t.Fatalf("開始工作失敗: %v", err)
+	}
+	own := submitTestJob(t, svc, models.BatchKindPatients, `{"count":2}`)
+	if err := store.Jobs().StartJob(ctx, own.ID, svc.instanceID, time.Now().Add(-2*JobHeartbeatTimeout)); err != nil {
+		t.Fatalf("開始工作失敗: %v", err)
+	}
+
+	ids, err := svc.ResumeJobs(ctx)
+	if err != nil {
+		t.Fatalf("恢復工作失敗: %v", err)
+	}
+	if fmt.Sprint(ids) != fmt.Sprint([]string{interrupted.ID, queued.ID}) {
+		t.Fatalf("應依建立順序回傳排隊中的工作，實際為 %v", ids)
+	}
+	for _, id := range []string{other.ID, own.ID} {
+		if got, _ := svc.GetJob(ctx, id); got.Status != models.JobStatusRunning {
+			t.Fatalf("仍在執行的工作 %s 不應放回佇列: %+v", id, got)
+		}
+	}
+}
+
+func TestAuditRecordsMutations(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := WithAuditRequest(context.Background(), models.AuditRequest{Actor: "alice", RequestID: "req-1", Method: "POST", Path: "/test"})
+
+	if err := svc.AddRole(ctx, "AUDITOR", "稽核員"); err != nil {
+		t.Fatalf("AddRole returned error: %v", err)
+	}
+	roles, _ := svc.ListAllRoles(ctx)
+	auditor := roles[len(roles)-1]
+	if auditor.Alias != "AUDITOR" {
+		t.Fatalf("最後一個角色 = %+v，預期 AUDITOR", auditor)
+	}
+	if err := svc.DeleteRole(ctx, auditor.ID); err != nil {
+		t.Fatalf("DeleteRole returned error: %v", err)
+	}
+	// 不存在的角色回傳 ErrNotFound，沒有刪除任何資料，不記錄
+	if err := svc.DeleteRole(ctx, auditor.ID); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("刪除不存在的角色應回傳 ErrNotFound，實際為 %v", err)
+	}
+
+	// 生成資料未帶操作者，角色分配由批次的稽核紀錄涵蓋
+	_, batchID, err := svc.GenerateFakeUsers(context.Background(), 1, utils.UserTypeDoctor, []int64{utils.USER_ROLE_ID})
+	if err != nil {
+		t.Fatalf("GenerateFakeUsers returned error: %v", err)
+	}
+	users, _ := svc.ListUsers(ctx)
+	if err := svc.AssignRolesToUser(ctx, users[0].ID, []int64{utils.USER_ROLE_ID, utils.DOCTOR_ROLE_ID}); err != nil {
+		t.Fatalf("AssignRolesToUser returned error: %v", err)
+	}
+
+	slots, _, err := svc.GenerateAvailableSlots(ctx, users[0].ID, 1, 2, 9, 60)
+	if err != nil {
+		t.Fatalf("GenerateAvailableSlots returned error: %v", err)
+	}
+	booked := *slots[0]
+	booked.IsBooked = true
+	if err := svc.UpdateAvailableSlot(ctx, &booked); err != nil {
+		t.Fatalf("UpdateAvailableSlot returned error: %v", err)
+	}
+	// 失敗的異動隨交易回滾，不留下稽核紀錄
+	if err := svc.DeleteAvailableSlot(ctx, booked.ID); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("刪除已預約的時段應回傳 ErrConflict，實際為 %v", err)
+	}
+	if err := svc.DeleteAvailableSlot(ctx, slots[1].ID); err != nil {
+		t.Fatalf("DeleteAvailableSlot returned error: %v", err)
+	}
+
+	entries, err := svc.ListAuditEntries(ctx, models.AuditFilter{})
+	if err != nil {
+		t.Fatalf("ListAuditEntries returned error: %v", err)
+	}
+	var got []string
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		got = append(got, entry.Actor+" "+entry.Action+" "+entry.EntityType)
+	}
+	want := []string{
+		"alice create role",
+		"alice delete role",
+		"system generate batch",
+		"alice assign_roles user",
+		"alice generate batch",
+		"alice update slot",
+		"alice delete slot",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("稽核紀錄 = %q, want %q", got, want)
+	}
+	if entries[4].EntityID != batchID {
+		t.Errorf("生成的稽核紀錄應以批次ID為資料ID: %+v", entries[4])
+	}
+	if created := entries[6]; created.EntityID != fmt.Sprint(auditor.ID) {
+		t.Errorf("新增角色的稽核紀錄應以新角色ID為資料ID: %+v", created)
+	}
+
+	update := entries[1]
+	if update.RequestID != "req-1" || update.Path != "/test" || update.EntityID != fmt.Sprint(booked.ID) {
+		t.Errorf("稽核紀錄應帶有請求資訊: %+v", update)
+	}
+	if len(update.Changes) != 1 || update.Changes[0].Field != "is_booked" ||
+		string(update.Changes[0].Before) != "false" || string(update.Changes[0].After) != "true" {
+		t.Errorf("更新時段的異動 = %+v, want is_booked false → true", update.Changes)
+	}
+	if deleted := entries[0]; deleted.After != nil || len(deleted.Before) == 0 || len(deleted.Changes) == 0 {
+		t.Errorf("刪除時段應只有異動前的快照: %+v", deleted)
+	}
+	var assigned struct{ Roles []string }
+	if err := json.Unmarshal(entries[3].After, &assigned); err != nil || strings.Join(assigned.Roles, ",") != "USER,DOCTOR" {
+		t.Errorf("分配角色後的快照 = %s", entries[3].After)
+	}
+
+	filtered, err := svc.ListAuditEntries(ctx, models.AuditFilter{EntityType: models.AuditEntityRole, Action: models.AuditActionDelete})
+	if err != nil || len(filtered) != 1 || filtered[0].EntityID != fmt.Sprint(auditor.ID) {
+		t.Errorf("依條件篩選 = %+v (%v)", filtered, err)
+	}
+	if _, err := svc.ListAuditEntries(ctx, models.AuditFilter{Limit: MaxAuditEntries + 1}); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("超過筆數上限應回傳 ErrValidation，實際為 %v", err)
+	}
+}
+
+func TestDeleteRoleRemovesUserRoles(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := WithAuditRequest(context.Background(), models.AuditRequest{Actor: "alice"})
+
+	if err := svc.AddRole(ctx, "AUDITOR", "稽核員"); err != nil {
+		t.Fatalf("AddRole returned error: %v", err)
+	}
+	roles, _ := svc.ListAllRoles(ctx)
+	auditor := roles[len(roles)-1]
+	if _, _, err := svc.GenerateFakeUsers(context.Background(), 2, utils.UserTypeDoctor, []int64{utils.USER_ROLE_ID}); err != nil {
+		t.Fatalf("GenerateFakeUsers returned error: %v", err)
+	}
+	users, _ := svc.ListUsers(ctx)
+	if err := svc.AssignRolesToUser(ctx, users[0].ID, []int64{utils.USER_ROLE_ID, auditor.ID}); err != nil {
+		t.Fatalf("AssignRolesToUser returned error: %v", err)
+	}
+
+	if err := svc.DeleteRole(ctx, auditor.ID); err != nil {
+		t.Fatalf("DeleteRole returned error: %v", err)
+	}
+	if got, _ := svc.GetUserRoles(ctx, users[0].ID); fmt.Sprint(roleIDsOf(got)) != fmt.Sprint([]int64{utils.USER_ROLE_ID}) {
+		t.Fatalf("刪除角色後使用者的角色 = %v", roleIDsOf(got))
+	}
+	if got, _ := svc.GetUserRoles(ctx, users[1].ID); fmt.Sprint(roleIDsOf(got)) != fmt.Sprint([]int64{utils.USER_ROLE_ID}) {
+		t.Fatalf("沒有該角色的使用者不受影響: %v", roleIDsOf(got))
+	}
+
+	// 受影響的使用者記錄一筆角色分配，接著才是刪除角色
+	entries, err := svc.ListAuditEntries(ctx, models.AuditFilter{Actor: "alice"})
+	if err != nil || len(entries) < 3 {
+		t.Fatalf("稽核紀錄 = %+v (%v)", entries, err)
+	}
+	deleted, removed := entries[0], entries[1]
+	if deleted.Action != models.AuditActionDelete || deleted.EntityType != models.AuditEntityRole {
+		t.Fatalf("最後一筆應為刪除角色: %+v", deleted)
+	}
+	if removed.Action != models.AuditActionAssignRoles || removed.EntityID != fmt.Sprint(users[0].ID) {
+		t.Fatalf("應記錄使用者 %d 的角色異動: %+v", users[0].ID, removed)
+	}
+	var before, after struct{ Roles []string }
+	json.Unmarshal(removed.Before, &before)
+	json.Unmarshal(removed.After, &after)
+	if strings.Join(before.Roles, ",") != "USER,AUDITOR" || strings.Join(after.Roles, ",") != "USER" {
+		t.Errorf("角色異動 = %v → %v", before.Roles, after.Roles)
+	}
+	if entries[2].Action == models.AuditActionAssignRoles && entries[2].EntityID == fmt.Sprint(users[1].ID) {
+		t.Errorf("沒有該角色的使用者不應記錄: %+v", entries[2])
+	}
+}
+
+func TestAuditJobKeepsSubmitter(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := WithAuditRequest(context.Background(), models.AuditRequest{Actor: "alice", RequestID: "req-1"})
+
+	job, err := svc.SubmitJob(ctx, models.BatchKindUsers, json.RawMessage(`{"count":2,"user_type":"doctor"}`))
+	if err != nil {
+		t.Fatalf("SubmitJob returned error: %v", err)
+	}
+	// 工作由 runner 在另一個 context 中執行，操作者由工作本身還原
+	if err := svc.RunJob(context.Background(), job.ID, func(models.Job) {}); err != nil {
+		t.Fatalf("RunJob returned error: %v", err)
+	}
+
+	entries, err := svc.ListAuditEntries(context.Background(), models.AuditFilter{Action: models.AuditActionGenerate})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("應有一筆生成的稽核紀錄: %+v (%v)", entries, err)
+	}
+	if entries[0].Actor != "alice" || entries[0].RequestID != "req-1" {
+		t.Errorf("工作產生的稽核紀錄應沿用送出工作的請求: %+v", entries[0].AuditRequest)
+	}
+}
+
+func TestAuditOmitsPersonalData(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := WithAuditRequest(context.Background(), models.AuditRequest{Actor: "alice"})
+
+	user := &models.User{Account: "audit0001", Email: "audit@example.com", Password: "secret", Status: "APPROVED"}
+	if err := svc.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser returned error: %v", err)
+	}
+	catalogue, err := svc.GetPatientCatalogue(ctx)
+	if err != nil {
+		t.Fatalf("GetPatientCatalogue returned error: %v", err)
+	}
+	patients, err := utils.GenerateFakePatients(2, catalogue)
+	if err != nil {
+		t.Fatalf("GenerateFakePatients returned error: %v", err)
+	}
+	if _, _, _, err := svc.SaveFakePatients(ctx, patients); err != nil {
+		t.Fatalf("SaveFakePatients returned error: %v", err)
+	}
+	if _, err := svc.AnonymizePatients(ctx, nil, false); err != nil {
+		t.Fatalf("AnonymizePatients returned error: %v", err)
+	}
+
+	entries, err := svc.ListAuditEntries(ctx, models.AuditFilter{})
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("ListAuditEntries = %d entries (%v), want 3", len(entries), err)
+	}
+	for _, entry := range entries {
+		data := string(entry.Before) + string(entry.After)
+		for _, secret := range []string{"secret", patients[0].Name, patients[0].IDNo} {
+			if strings.Contains(data, secret) {
+				t.Errorf("%s %s 的稽核紀錄不應包含 %q: %s", entry.Action, entry.EntityType, secret, data)
+			}
+		}
+	}
+	if entries[2].EntityID != fmt.Sprint(user.ID) || entries[0].Action != models.AuditActionAnonymize {
+		t.Errorf("稽核紀錄 = %+v", entries)
+	}
+}
+
+func TestCatalogueCachesUntilRefresh(t *testing.T) {
+	svc, store := newTestService()
+	ctx := context.Background()
+
+	first, err := svc.GetCatalogue(ctx)
+	if err != nil {
+		t.Fatalf("GetCatalogue returned error: %v", err)
+	}
+	if len(first.HistoryDiseases) == 0 || len(first.MedicalHistories) == 0 || first.LoadedAt.IsZero() {
+		t.Fatalf("疾病目錄應有預設資料: %+v", first)
+	}
+	// 快照是副本，修改不影響快取
+	first.MedicalHistories[0] = "已修改"
+
+	patientID, err := store.Patients().CreatePatient(ctx, &models.Patient{Name: "測試"})
+	if err != nil {
+		t.Fatalf("CreatePatient returned error: %v", err)
+	}
+	if err := store.Patients().AddMedicalHistory(ctx, patientID, "新醫療史"); err != nil {
+		t.Fatalf("AddMedicalHistory returned error: %v", err)
+	}
+
+	cached, _ := svc.GetCatalogue(ctx)
+	if slices.Contains(cached.MedicalHistories, "新醫療史") || !cached.LoadedAt.Equal(first.LoadedAt) {
+		t.Fatalf("重新載入前應使用快取的目錄: %v", cached.MedicalHistories)
+	}
+	if cached.MedicalHistories[0] == "已修改" {
+		t.Fatalf("修改快照不應影響快取")
+	}
+	patient := &models.Patient{Name: "測試", MedicalHistories: []string{"新醫療史"}}
+	if err := svc.ValidatePatientHistories(ctx, patient); !errors.Is(err, models.ErrValidation) {
+		t.Fatalf("快取中沒有的醫療史應回傳 ErrValidation，實際為 %v", err)
+	}
+
+	if err := svc.RefreshCatalogue(ctx); err != nil {
+		t.Fatalf("RefreshCatalogue returned error: %v", err)
+	}
+	refreshed, _ := svc.GetCatalogue(ctx)
+	if refreshed.LoadedAt.Before(first.LoadedAt) {
+		t.Fatalf("重新載入後載入時間應更新: %s", refreshed.LoadedAt)
+	}
+	// 醫療史選項是固定清單，只存在於資料庫中的值不會成為選項，也不會通過驗證
+	if slices.Contains(refreshed.MedicalHistories, "新醫療史") || len(refreshed.MedicalHistories) != len(first.MedicalHistories) {
+		t.Fatalf("資料庫中已使用的醫療史不應加入選項: %v", refreshed.MedicalHistories)
+	}
+	if err := svc.ValidatePatientHistories(ctx, patient); !errors.Is(err, models.ErrValidation) {
+		t.Fatalf("只存在於資料庫中的醫療史應回傳 ErrValidation，實際為 %v", err)
+	}
+}
+
+func TestValidatePatientHistoriesResolvesEnumCodes(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	// 代碼與中文顯示名稱都會換成目錄中的名稱，對應到同一分類的只保留一次
+	patient := &models.Patient{Name: "測試", HistoryDiseases: []string{"diabetes", "中風", "hypertension", "heartDisease", "癌症"}}
+	if err := svc.ValidatePatientHistories(ctx, patient); err != nil {
+		t.Fatalf("ValidatePatientHistories returned error: %v", err)
+	}
+	want := []string{"糖尿病", "中樞神經損傷", "心血管疾病", "癌症"}
+	if !slices.Equal(patient.HistoryDiseases, want) {
+		t.Fatalf("病史 = %v, want %v", patient.HistoryDiseases, want)
+	}
+
+	// 沒有對應分類的代碼與未知的名稱仍會被拒絕，且不修改病患資料
+	for _, value := range []string{"depression", "不存在的疾病"} {
+		patient := &models.Patient{Name: "測試", HistoryDiseases: []string{"diabetes", value}}
+		if err := svc.ValidatePatientHistories(ctx, patient); !errors.Is(err, models.ErrValidation) {
+			t.Errorf("%s: 應回傳 ErrValidation，實際為 %v", value, err)
+		}
+		if patient.HistoryDiseases[0] != "diabetes" {
+			t.Errorf("驗證失敗時不應修改病史: %v", patient.HistoryDiseases)
+		}
+	}
+
+	// 醫療史枚舉代碼會換成中文顯示名稱，與預設選項一樣可以通過驗證
+	patient = &models.Patient{Name: "測試", MedicalHistories: []string{"diabetes", "骨折", "糖尿病", "stroke"}}
+	if err := svc.ValidatePatientHistories(ctx, patient); err != nil {
+		t.Fatalf("ValidatePatientHistories returned error: %v", err)
+	}
+	if want := []string{"糖尿病", "骨折", "中風"}; !slices.Equal(patient.MedicalHistories, want) {
+		t.Fatalf("醫療史 = %v, want %v", patient.MedicalHistories, want)
+	}
+	patient = &models.Patient{Name: "測試", MedicalHistories: []string{"diabetes", "notAnEnum"}}
+	if err := svc.ValidatePatientHistories(ctx, patient); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("未知的醫療史代碼應回傳 ErrValidation，實際為 %v", err)
+	}
+	if patient.MedicalHistories[0] != "diabetes" {
+		t.Errorf("驗證失敗時不應修改醫療史: %v", patient.MedicalHistories)
+	}
+
+	patients := []*models.Patient{{Name: "代碼", IDNo: "A123456789", HistoryDiseases: []string{"kidneyDisease"}}}
+	created, _, skipped, err := svc.SaveFakePatients(ctx, patients)
+	if err != nil || created != 1 || len(skipped) != 0 {
+		t.Fatalf("以代碼填寫病史的病患應可寫入: %d %v (%v)", created, skipped, err)
+	}
+	if patients[0].HistoryDiseases[0] != "腎臟病" {
+		t.Errorf("寫入的病史 = %v, want 腎臟病", patients[0].HistoryDiseases)
+	}
+}
@@ -3,27 +3,35 @@ package service
 import (
 	"context"
 	"fmt"
+	"golang-gin-app/internal/jobs"
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/metrics"
 	"golang-gin-app/internal/models"
+	"golang-gin-app/internal/tracing"
+	"strconv"
 	"time"
 )
 
-// GenerateAvailableSlots 根據指定條件生成可預約時段
-func (s *Service) GenerateAvailableSlots(ctx context.Context, doctorID int64, days int, slotsPerDay int, startHour int, slotDuration int) ([]*models.AvailableSlot, error) {
+// GenerateAvailableSlots 根據指定條件生成可預約時段，並回傳生成批次ID
+func (s *Service) GenerateAvailableSlots(ctx context.Context, doctorID int64, days int, slotsPerDay int, startHour int, slotDuration int) ([]*models.AvailableSlot, string, error) {
+	ctx, span := tracing.Start(ctx, "Service.GenerateAvailableSlots")
+	defer span.End()
+
 	// 基本參數驗證
 	if doctorID <= 0 {
-		return nil, fmt.Errorf("醫師/治療師ID必須大於0")
+		return nil, "", models.Validationf("醫師/治療師ID必須大於0")
 	}
 	if days <= 0 || days > 365 {
-		return nil, fmt.Errorf("天數必須在1到365之間")
+		return nil, "", models.Validationf("天數必須在1到365之間")
 	}
 	if slotsPerDay <= 0 || slotsPerDay > 24 {
-		return nil, fmt.Errorf("每天時段數必須在1到24之間")
+		return nil, "", models.Validationf("每天時段數必須在1到24之間")
 	}
 	if startHour < 0 || startHour > 23 {
-		return nil, fmt.Errorf("開始時間必須在0到23之間")
+		return nil, "", models.Validationf("開始時間必須在0到23之間")
 	}
 	if slotDuration <= 0 || slotDuration > 240 {
-		return nil, fmt.Errorf("每個時段的持續時間必須在1到240分鐘之間")
+		return nil, "", models.Validationf("每個時段的持續時間必須在1到240分鐘之間")
 	}
 
 	// 生成預約時段
@@ -81,27 +89,66 @@ func (s *Service) GenerateAvailableSlots(ctx context.Context, doctorID int64, da
 		}
 	}
 
-	// 批量保存到數據庫
-	if err := s.repo.BatchCreateAvailableSlots(ctx, slots); err != nil {
-		return nil, fmt.Errorf("保存預約時段失敗: %v", err)
+	if len(slots) == 0 {
+		return slots, "", nil
+	}
+
+	// 保存時段與記錄生成批次在同一個交易中完成
+	start := time.Now()
+	var batchID string
+	err := s.store.WithTx(ctx, func(ctx context.Context) error {
+		// 批量保存到數據庫
+		if err := s.store.Slots().BatchCreateAvailableSlots(ctx, slots); err != nil {
+			return fmt.Errorf("保存預約時段失敗: %w", err)
+		}
+		jobs.ReportProgress(ctx, models.JobStageSlots, len(slots), len(slots))
+
+		// 記錄生成批次，以便之後清除
+		slotIDs := make([]int64, 0, len(slots))
+		for _, slot := range slots {
+			slotIDs = append(slotIDs, slot.ID)
+		}
+		var err error
+		batchID, err = s.recordBatch(ctx, models.BatchKindSlots, models.BatchEntitySlot, slotIDs)
+		if err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, models.AuditActionGenerate, models.AuditEntityBatch, batchID, nil, map[string]interface{}{
+			"kind": models.BatchKindSlots, "doctor_id": doctorID, "count": len(slots),
+		})
+	})
+	metrics.ObserveGeneration("slots", start, err)
+	if err != nil {
+		return nil, "", err
 	}
 
-	return slots, nil
+	metrics.SlotsGenerated.Add(float64(len(slots)))
+	logging.FromContext(ctx).Info("已生成可預約時段", "doctor_id", doctorID, "count", len(slots), "batch_id", batchID)
+	return slots, batchID, nil
 }
 
 // GetAvailableSlotsByDoctor 獲取指定醫師的可預約時段
 func (s *Service) GetAvailableSlotsByDoctor(ctx context.Context, doctorID int64) ([]*models.AvailableSlot, error) {
-	return s.repo.GetAvailableSlotsByDoctor(ctx, doctorID)
+	ctx, span := tracing.Start(ctx, "Service.GetAvailableSlotsByDoctor")
+	defer span.End()
+
+	return s.store.Slots().GetAvailableSlotsByDoctor(ctx, doctorID)
 }
 
 // GetDoctorUsers 獲取具有醫師角色的用戶
 func (s *Service) GetDoctorUsers(ctx context.Context) ([]*models.User, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetDoctorUsers")
+	defer span.End()
+
 	// 醫師角色ID為3，根據utils.go中的常數
-	return s.repo.GetUserByRoleID(ctx, 3) // DOCTOR_ROLE_ID = 3
+	return s.store.Users().GetUserByRoleID(ctx, 3) // DOCTOR_ROLE_ID = 3
 }
 
 // GetTherapistUsers 獲取具有治療師角色的用戶
 func (s *Service) GetTherapistUsers(ctx context.Context) ([]*models.User, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetTherapistUsers")
+	defer span.End()
+
 	// 合併所有治療師角色的用戶
 	// 治療師角色ID為4,5,6,7，根據utils.go中的常數
 	therapistRoles := []int64{4, 5, 6, 7} // DTX_PSY_ROLE_ID = 4, DTX_ST_ROLE_ID = 5, DTX_OT_ROLE_ID = 6, DTX_PI_ROLE_ID = 7
@@ -110,9 +157,9 @@ func (s *Service) GetTherapistUsers(ctx context.Context) ([]*models.User, error)
 	uniqueIDs := make(map[int64]bool)
 
 	for _, roleID := range therapistRoles {
-		users, err := s.repo.GetUserByRoleID(ctx, roleID)
+		users, err := s.store.Users().GetUserByRoleID(ctx, roleID)
 		if err != nil {
-			return nil, fmt.Errorf("獲取角色ID %d 的用戶失敗: %v", roleID, err)
+			return nil, fmt.Errorf("獲取角色ID %d 的用戶失敗: %w", roleID, err)
 		}
 
 		// 避免重複用戶
@@ -129,43 +176,83 @@ func (s *Service) GetTherapistUsers(ctx context.Context) ([]*models.User, error)
 
 // UpdateAvailableSlot 更新可預約時段
 func (s *Service) UpdateAvailableSlot(ctx context.Context, slot *models.AvailableSlot) error {
+	ctx, span := tracing.Start(ctx, "Service.UpdateAvailableSlot")
+	defer span.End()
+
 	// 驗證必要字段
 	if slot.ID <= 0 {
-		return fmt.Errorf("無效的時段ID")
+		return models.Validationf("無效的時段ID")
 	}
 	if slot.Doctor <= 0 {
-		return fmt.Errorf("無效的醫師/治療師ID")
+		return models.Validationf("無效的醫師/治療師ID")
 	}
 
 	// 檢查時間格式
 	if slot.SlotBeginTime.After(slot.SlotEndTime) {
-		return fmt.Errorf("開始時間不能晚於結束時間")
+		return models.Validationf("開始時間不能晚於結束時間")
 	}
 
-	return s.repo.UpdateAvailableSlot(ctx, slot)
+	// 讀取原本的預約狀態與更新在同一個交易中完成，以統計新預約的時段
+	booked := false
+	err := s.store.WithTx(ctx, func(ctx context.Context) error {
+		current, err := s.GetAvailableSlotByID(ctx, slot.ID)
+		if err != nil {
+			return err
+		}
+		booked = slot.IsBooked && !current.IsBooked
+		if err := s.store.Slots().UpdateAvailableSlot(ctx, slot); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, models.AuditActionUpdate, models.AuditEntitySlot, strconv.FormatInt(slot.ID, 10), current, slot)
+	})
+	if err != nil {
+		return err
+	}
+	if booked {
+		metrics.SlotsBooked.Inc()
+	}
+	return nil
 }
 
 // DeleteAvailableSlot 刪除可預約時段
 func (s *Service) DeleteAvailableSlot(ctx context.Context, slotID int64) error {
+	ctx, span := tracing.Start(ctx, "Service.DeleteAvailableSlot")
+	defer span.End()
+
 	if slotID <= 0 {
-		return fmt.Errorf("無效的時段ID")
+		return models.Validationf("無效的時段ID")
 	}
 
-	// 先檢查時段是否存在
-	slot, err := s.repo.GetAvailableSlotByID(ctx, slotID)
+	// 交易讓刪除與稽核紀錄一起提交；讀取的時段只用於提早回報與稽核快照，
+	// 讀取後才被預約的情況由 DeleteAvailableSlot 的條件式刪除回傳 models.ErrConflict
+	err := s.store.WithTx(ctx, func(ctx context.Context) error {
+		// 先檢查時段是否存在
+		slot, err := s.GetAvailableSlotByID(ctx, slotID)
+		if err != nil {
+			return err
+		}
+
+		// 如果時段已被預約，不允許刪除
+		if slot.IsBooked {
+			return models.Conflictf("該時段已被預約，無法刪除")
+		}
+
+		if err := s.store.Slots().DeleteAvailableSlot(ctx, slotID); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, models.AuditActionDelete, models.AuditEntitySlot, strconv.FormatInt(slotID, 10), slot, nil)
+	})
 	if err != nil {
 		return err
 	}
-
-	// 如果時段已被預約，不允許刪除
-	if slot.IsBooked {
-		return fmt.Errorf("該時段已被預約，無法刪除")
-	}
-
-	return s.repo.DeleteAvailableSlot(ctx, slotID)
+	metrics.SlotsDeleted.Inc()
+	return nil
 }
 
 // GetAvailableSlotByID 通過ID獲取時段
 func (s *Service) GetAvailableSlotByID(ctx context.Context, slotID int64) (*models.AvailableSlot, error) {
-	return s.repo.GetAvailableSlotByID(ctx, slotID)
+	ctx, span := tracing.Start(ctx, "Service.GetAvailableSlotByID")
+	defer span.End()
+
+	return s.store.Slots().GetAvailableSlotByID(ctx, slotID)
 }
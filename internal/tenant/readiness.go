@@ -0,0 +1,77 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+
+	"golang-gin-app/internal/migrations"
+	"golang-gin-app/internal/repository"
+)
+
+// Readiness 租戶資料庫的就緒檢查結果
+type Readiness struct {
+	Name          string `json:"name"`
+	Default       bool   `json:"default"`
+	Ready         bool   `json:"ready"`
+	SchemaVersion int64  `json:"schema_version,omitempty"`
+	LatestVersion int64  `json:"latest_version,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// CheckReadiness 依順序檢查所有租戶：尚未連線的租戶視為未就緒，
+// 已連線的租戶需能 ping 通、具備所需資料表，且沒有尚未套用的遷移
+func (r *Registry) CheckReadiness(ctx context.Context) []Readiness {
+	r.mu.RLock()
+	names := append([]string(nil), r.names...)
+	r.mu.RUnlock()
+
+	results := make([]Readiness, 0, len(names))
+	for _, name := range names {
+		result := Readiness{Name: name, Default: name == r.defaultName}
+		if t, ok := r.Get(name); ok {
+			result.Ready = true
+			if err := t.check(ctx, &result); err != nil {
+				result.Ready = false
+				result.Error = err.Error()
+			}
+		} else {
+			result.Error = "資料庫尚未連線"
+			if err := r.Unavailable(name); err != nil {
+				result.Error += ": " + err.Error()
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// check 檢查租戶資料庫的連線、資料表與遷移版本；沒有資料庫連線（例如記憶體 Store）時不檢查
+func (t *Tenant) check(ctx context.Context, result *Readiness) error {
+	if t.DB == nil {
+		return nil
+	}
+	if err := t.DB.PingContext(ctx); err != nil {
+		return fmt.Errorf("資料庫連線失敗: %v", err)
+	}
+	if err := repository.CheckTables(ctx, t.DB); err != nil {
+		return err
+	}
+
+	migrator, err := migrations.NewMigrator(t.DB, string(t.Dialect))
+	if err != nil {
+		return err
+	}
+	version, managed, err := migrator.AppliedVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if !managed {
+		// 資料表齊全但結構不由遷移管理，視為就緒
+		return nil
+	}
+	result.SchemaVersion, result.LatestVersion = version, migrator.LatestVersion()
+	if version < result.LatestVersion {
+		return fmt.Errorf("資料庫結構版本 %d 落後於 %d，請執行 migrate up", version, result.LatestVersion)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package tenant
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"golang-gin-app/internal/repository"
+	"golang-gin-app/internal/service"
+)
+
+// Tenant 表示一個具名的目標資料庫（例如 dtxcasemgnt、dtxtraining）及其 Service
+type Tenant struct {
+	Name    string
+	DB      *sql.DB
+	Dialect repository.Dialect
+	Service *service.Service
+}
+
+// PoolStats 租戶資料庫連線池的統計，取自 sql.DB.Stats()
+type PoolStats struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationMS     int64 `json:"wait_duration_ms"`
+	MaxIdleClosed      int64 `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64 `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+}
+
+// Status 租戶的連線狀態；尚未連線的租戶帶有最近一次的連線錯誤
+type Status struct {
+	Name      string     `json:"name"`
+	Default   bool       `json:"default"`
+	Available bool       `json:"available"`
+	Error     string     `json:"error,omitempty"`
+	Pool      *PoolStats `json:"pool,omitempty"`
+}
+
+// Registry 依名稱管理所有租戶資料庫，名稱依首次註冊或標記的順序排列。
+// 連線失敗的租戶以 MarkUnavailable 標記，背景重新連線成功後再以 Register 啟用。
+type Registry struct {
+	mu          sync.RWMutex
+	tenants     map[string]*Tenant
+	unavailable map[string]error
+	names       []string
+	defaultName string
+	closed      bool
+}
+
+// NewRegistry 建立新的 Registry，defaultName 為未指定租戶時使用的租戶
+func NewRegistry(defaultName string) *Registry {
+	return &Registry{
+		tenants:     make(map[string]*Tenant),
+		unavailable: make(map[string]error),
+		defaultName: defaultName,
+	}
+}
+
+// Register 註冊已連線的租戶，名稱重複或 Registry 已關閉時回傳錯誤。
+// 先前標記為無法使用的租戶會在註冊後啟用，並保留原本的排列順序。
+func (r *Registry) Register(t *Tenant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return fmt.Errorf("租戶 %s 註冊失敗: Registry 已關閉", t.Name)
+	}
+	if _, exists := r.tenants[t.Name]; exists {
+		return fmt.Errorf("租戶 %s 已註冊", t.Name)
+	}
+	if _, pending := r.unavailable[t.Name]; pending {
+		delete(r.unavailable, t.Name)
+	} else {
+		r.names = append(r.names, t.Name)
+	}
+	r.tenants[t.Name] = t
+	return nil
+}
+
+// MarkUnavailable 記錄尚未連線的租戶與最近一次的連線錯誤，已連線的租戶不受影響
+func (r *Registry) MarkUnavailable(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tenants[name]; exists {
+		return
+	}
+	if _, pending := r.unavailable[name]; !pending {
+		r.names = append(r.names, name)
+	}
+	r.unavailable[name] = err
+}
+
+// Get 依名稱取得已連線的租戶
+func (r *Registry) Get(name string) (*Tenant, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tenants[name]
+	return t, ok
+}
+
+// Unavailable 回傳尚未連線的租戶最近一次的連線錯誤，租戶已連線或不存在時回傳 nil
+func (r *Registry) Unavailable(name string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.unavailable[name]
+}
+
+// Default 取得預設租戶，未註冊時回傳 nil
+func (r *Registry) Default() *Tenant {
+	t, _ := r.Get(r.defaultName)
+	return t
+}
+
+// DefaultName 回傳預設租戶名稱
+func (r *Registry) DefaultName() string {
+	return r.defaultName
+}
+
+// Names 依順序回傳所有已連線的租戶名稱
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.tenants))
+	for _, name := range r.names {
+		if _, ok := r.tenants[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// List 依順序回傳所有已連線的租戶
+func (r *Registry) List() []*Tenant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tenants := make([]*Tenant, 0, len(r.tenants))
+	for _, name := range r.names {
+		if t, ok := r.tenants[name]; ok {
+			tenants = append(tenants, t)
+		}
+	}
+	return tenants
+}
+
+// Statuses 依順序回傳所有租戶（含尚未連線者）的連線狀態與連線池統計
+func (r *Registry) Statuses() []Status {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	statuses := make([]Status, 0, len(r.names))
+	for _, name := range r.names {
+		status := Status{Name: name, Default: name == r.defaultName}
+		if t, ok := r.tenants[name]; ok {
+			status.Available = true
+			if t.DB != nil {
+				status.Pool = poolStats(t.DB.Stats())
+			}
+		} else if err := r.unavailable[name]; err != nil {
+			status.Error = err.Error()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// poolStats 將 sql.DBStats 轉為可輸出為 JSON 的統計
+func poolStats(stats sql.DBStats) *PoolStats {
+	return &PoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMS:     stats.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+}
+
+// Close 關閉所有租戶的資料庫連線，之後不再接受註冊
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	var firstErr error
+	for _, t := range r.List() {
+		if t.DB == nil {
+			continue
+		}
+		if err := t.DB.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("關閉租戶 %s 的資料庫失敗: %v", t.Name, err)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,109 @@
+// Package tracing 設定 OpenTelemetry 追蹤，並提供建立 span 的輔助函式
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName 本應用程式建立的 span 所屬的 tracer 名稱
+const instrumentationName = "golang-gin-app"
+
+// 支援的匯出方式
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Config 追蹤設定；OTLP 的端點、TLS 與標頭由匯出器自行讀取標準的 OTEL_EXPORTER_OTLP_* 環境變數
+type Config struct {
+	Exporter    string  // none、stdout 或 otlp
+	ServiceName string  // 回報的服務名稱
+	SampleRatio float64 // 取樣比例，0 到 1；上游請求已取樣時一律取樣
+}
+
+// Setup 依設定建立全域的 TracerProvider 與 W3C trace context 傳遞方式，
+// 回傳的函式在結束時送出尚未匯出的 span。Exporter 為 none 時不建立 span。
+// stdout 匯出寫入 w。
+func Setup(ctx context.Context, config Config, w io.Writer) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(config.Exporter) {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case ExporterOTLP:
+		// OTEL_EXPORTER_OTLP_ENDPOINT 是完整的 URL（例如 http://collector:4318），由匯出器解析
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("無效的追蹤匯出方式 %q（可用: none, stdout, otlp）", config.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("建立追蹤匯出器失敗: %v", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(config.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("建立追蹤資源失敗: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start 以本應用程式的 tracer 建立 span
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End 結束 span，err 不為 nil 時記錄錯誤並將狀態設為 Error
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// StartSQL 為一個 SQL 語句建立 client span，name 為語句名稱（例如 slots.GetAvailableSlotsByDoctor），
+// query 為空時不記錄 SQL 內容
+func StartSQL(ctx context.Context, system, name, query string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", system),
+		attribute.String("db.operation.name", name),
+	}
+	if query != "" {
+		attrs = append(attrs, attribute.String("db.query.text", query))
+	}
+	return Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// TraceID 回傳 ctx 中已取樣的 span 的 trace ID，沒有時回傳空字串
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsSampled() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
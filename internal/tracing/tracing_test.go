@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetupOTLPUsesEndpointURL(t *testing.T) {
+	paths := make(chan string, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case paths <- r.URL.Path:
+		default:
+		}
+	}))
+	defer collector.Close()
+	// 標準的環境變數是完整的 URL，包含 http:// 時不使用 TLS
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL)
+
+	ctx := context.Background()
+	shutdown, err := Setup(ctx, Config{Exporter: ExporterOTLP, ServiceName: "test", SampleRatio: 1}, io.Discard)
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	_, span := Start(ctx, "test")
+	End(span, nil)
+	if err := shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	select {
+	case path := <-paths:
+		if path != "/v1/traces" {
+			t.Errorf("exported to %s, want /v1/traces", path)
+		}
+	default:
+		t.Fatal("no spans exported to the collector")
+	}
+}
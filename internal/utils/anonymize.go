@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"golang-gin-app/internal/models"
+	"math/rand"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+// MinAnonymizationKeyLength 去識別化金鑰的最小長度（位元組）
+const MinAnonymizationKeyLength = 16
+
+// ageBandWidth 去識別化時保留的年齡區間寬度，年齡只在同一個區間內調整
+const ageBandWidth = 10
+
+// Anonymizer 以 HMAC-SHA256 金鑰將病患個資替換為假名。相同金鑰下相同的原始值一律對應到
+// 相同的假名，因此同一人在不同資料列、不同資料庫中仍可對應；沒有金鑰則無法由原始值推得假名。
+// 性別、年齡區間、縣市與病史維持不變，以保留資料的分佈。
+type Anonymizer struct {
+	key []byte
+}
+
+// NewAnonymizer 以金鑰建立 Anonymizer，金鑰至少需 MinAnonymizationKeyLength 位元組
+func NewAnonymizer(key []byte) (*Anonymizer, error) {
+	if len(key) < MinAnonymizationKeyLength {
+		return nil, fmt.Errorf("去識別化金鑰至少需要 %d 位元組", MinAnonymizationKeyLength)
+	}
+	return &Anonymizer{key: append([]byte(nil), key...)}, nil
+}
+
+// seed 以金鑰計算欄位與原始值的 HMAC，作為假名生成的亂數種子
+func (a *Anonymizer) seed(field, value string) uint64 {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	// gofakeit 以 0 作為種子時會改用隨機種子，因此保留最低位元為 1
+	return binary.BigEndian.Uint64(mac.Sum(nil)) | 1
+}
+
+// rand 回傳由欄位與原始值決定的亂數產生器
+func (a *Anonymizer) rand(field, value string) *rand.Rand {
+	return rand.New(rand.NewSource(int64(a.seed(field, value))))
+}
+
+// Name 將姓名替換為假的中文姓名
+func (a *Anonymizer) Name(name string) string {
+	if name == "" {
+		return ""
+	}
+	return generateChineseName(a.rand("name", name))
+}
+
+// IDNo 將身分證字號替換為相同性別的假身分證字號
+func (a *Anonymizer) IDNo(idno, gender string) string {
+	if idno == "" {
+		return ""
+	}
+	// 原始字號的第二碼即代表性別，優先沿用
+	if len(idno) > 1 && idno[1] == '2' {
+		gender = "F"
+	} else if len(idno) > 1 && idno[1] == '1' {
+		gender = "M"
+	}
+	return generateTaiwanID(a.rand("idno", idno), gender)
+}
+
+// Phone 將電話號碼替換為假的台灣手機號碼
+func (a *Anonymizer) Phone(phone string) string {
+	if phone == "" {
+		return ""
+	}
+	return generateTaiwanPhone(a.rand("phone", phone))
+}
+
+// Email 將電子郵件替換為假的電子郵件
+func (a *Anonymizer) Email(mail string) string {
+	if mail == "" {
+		return ""
+	}
+	return gofakeit.New(a.seed("mail", mail)).Email()
+}
+
+// Address 將地址替換為假的路名與門牌，並在同一縣市中重新選擇區域
+func (a *Anonymizer) Address(city, district, address string) (string, string) {
+	if address == "" {
+		return district, ""
+	}
+	r := a.rand("address", city+district+address)
+	if districts := taiwanCityDistricts[city]; len(districts) > 0 && district != "" {
+		district = districts[r.Intn(len(districts))]
+	}
+	return district, generateAddress(r)
+}
+
+// Age 在同一個年齡區間內調整年齡，並產生相符的出生日期；identity 用於決定調整量
+func (a *Anonymizer) Age(identity string, age int, now time.Time) (int, time.Time) {
+	r := a.rand("age", identity)
+	bandStart := age - age%ageBandWidth
+	age = bandStart + r.Intn(ageBandWidth)
+	birth := time.Date(now.Year()-age, time.Month(r.Intn(12)+1), r.Intn(28)+1, 0, 0, 0, 0, time.Local)
+	// 今年生日尚未到時實際年齡少一歲
+	if birth.AddDate(age, 0, 0).After(now) {
+		birth = birth.AddDate(-1, 0, 0)
+	}
+	return age, birth
+}
+
+// Patient 回傳去識別化的病患副本：姓名、身分證字號、電話、電子郵件、地址、出生日期與緊急聯絡人
+// 替換為假名，性別、年齡區間、縣市與病史維持不變
+func (a *Anonymizer) Patient(patient *models.Patient) *models.Patient {
+	anonymized := *patient
+	anonymized.Name = a.Name(patient.Name)
+	anonymized.IDNo = a.IDNo(patient.IDNo, patient.Gender)
+	anonymized.Phone = a.Phone(patient.Phone)
+	anonymized.Mail = a.Email(patient.Mail)
+	anonymized.District, anonymized.Address = a.Address(patient.City, patient.District, patient.Address)
+	anonymized.EmergencyContact = a.Name(patient.EmergencyContact)
+	anonymized.EmergencyPhone = a.Phone(patient.EmergencyPhone)
+
+	// 以身分證字號識別同一人，沒有時改用姓名與出生日期
+	identity := patient.IDNo
+	if identity == "" {
+		identity = patient.Name + "|" + patient.Birth.Format("2006-01-02")
+	}
+	anonymized.Age, anonymized.Birth = a.Age(identity, patient.Age, time.Now())
+
+	anonymized.HistoryDiseases = append([]string(nil), patient.HistoryDiseases...)
+	anonymized.MedicalHistories = append([]string(nil), patient.MedicalHistories...)
+	return &anonymized
+}
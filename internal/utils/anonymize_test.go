@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang-gin-app/internal/models"
+)
+
+func newTestAnonymizer(t *testing.T, key string) *Anonymizer {
+	t.Helper()
+	anonymizer, err := NewAnonymizer([]byte(key))
+	if err != nil {
+		t.Fatalf("NewAnonymizer returned error: %v", err)
+	}
+	return anonymizer
+}
+
+func testPatient() *models.Patient {
+	return &models.Patient{
+		ID:                1,
+		Name:              "王小明",
+		Gender:            "F",
+		IDNo:              "A223456789",
+		Age:               47,
+		Birth:             time.Date(1979, 3, 2, 0, 0, 0, 0, time.Local),
+		City:              "台北市",
+		District:          "大安區",
+		Address:           "復興南路一段1號",
+		Phone:             "0912345678",
+		Mail:              "ming@example.com",
+		EmergencyContact:  "王大明",
+		EmergencyPhone:    "0987654321",
+		EmergencyRelation: "父親",
+		HistoryDiseases:   []string{"糖尿病"},
+		MedicalHistories:  []string{"開刀"},
+	}
+}
+
+func TestNewAnonymizerRequiresKey(t *testing.T) {
+	if _, err := NewAnonymizer([]byte("short")); err == nil {
+		t.Error("expected error for a short key")
+	}
+}
+
+func TestAnonymizerIsConsistentPerKey(t *testing.T) {
+	a := newTestAnonymizer(t, "0123456789abcdef")
+	b := newTestAnonymizer(t, "fedcba9876543210")
+	patient := testPatient()
+
+	first, second := a.Patient(patient), a.Patient(patient)
+	if first.Name != second.Name || first.IDNo != second.IDNo || first.Phone != second.Phone ||
+		first.Mail != second.Mail || first.Address != second.Address || !first.Birth.Equal(second.Birth) {
+		t.Errorf("same key must give the same pseudonyms: %+v vs %+v", first, second)
+	}
+	if other := b.Patient(patient); other.IDNo == first.IDNo && other.Phone == first.Phone {
+		t.Errorf("different keys should give different pseudonyms, got %s / %s", other.IDNo, other.Phone)
+	}
+
+	// 相同的原始值在不同欄位、不同病患中對應到相同的假名
+	if a.Name(patient.Name) != first.Name || a.Phone(patient.EmergencyPhone) != first.EmergencyPhone {
+		t.Error("expected field pseudonyms to match the patient pseudonyms")
+	}
+}
+
+func TestAnonymizerReplacesIdentifiersAndKeepsDistributions(t *testing.T) {
+	a := newTestAnonymizer(t, "0123456789abcdef")
+	patient := testPatient()
+	anonymized := a.Patient(patient)
+
+	for field, pair := range map[string][2]string{
+		"name":              {patient.Name, anonymized.Name},
+		"idno":              {patient.IDNo, anonymized.IDNo},
+		"phone":             {patient.Phone, anonymized.Phone},
+		"mail":              {patient.Mail, anonymized.Mail},
+		"address":           {patient.Address, anonymized.Address},
+		"emergency_contact": {patient.EmergencyContact, anonymized.EmergencyContact},
+		"emergency_phone":   {patient.EmergencyPhone, anonymized.EmergencyPhone},
+	} {
+		if pair[1] == "" || pair[0] == pair[1] {
+			t.Errorf("%s was not replaced: %q -> %q", field, pair[0], pair[1])
+		}
+	}
+
+	if anonymized.IDNo[1] != '2' || anonymized.Gender != patient.Gender {
+		t.Errorf("expected gender to be kept, got %s / %s", anonymized.IDNo, anonymized.Gender)
+	}
+	if anonymized.Age/ageBandWidth != patient.Age/ageBandWidth {
+		t.Errorf("expected age %d to stay in the band of %d", anonymized.Age, patient.Age)
+	}
+	if years := time.Now().Year() - anonymized.Birth.Year(); years != anonymized.Age && years != anonymized.Age+1 {
+		t.Errorf("birth %s does not match age %d", anonymized.Birth.Format("2006-01-02"), anonymized.Age)
+	}
+	inCity := false
+	for _, district := range taiwanCityDistricts[patient.City] {
+		inCity = inCity || district == anonymized.District
+	}
+	if anonymized.City != patient.City || !inCity {
+		t.Errorf("expected city to be kept and district to stay in the city, got %s %s", anonymized.City, anonymized.District)
+	}
+	if strings.Join(anonymized.HistoryDiseases, ",") != "糖尿病" || strings.Join(anonymized.MedicalHistories, ",") != "開刀" {
+		t.Errorf("expected histories to be kept, got %v / %v", anonymized.HistoryDiseases, anonymized.MedicalHistories)
+	}
+	if patient.Name != "王小明" {
+		t.Error("Patient must not modify its argument")
+	}
+	if a.Phone("") != "" || a.Name("") != "" {
+		t.Error("empty values must stay empty")
+	}
+}
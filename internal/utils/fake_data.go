@@ -1,12 +1,9 @@
 package utils
 
 import (
-	"database/sql"
 	"fmt"
 	"golang-gin-app/internal/models"
 	"math/rand"
-	"strings"
-	"sync"
 	"time"
 
 	"github.com/brianvoe/gofakeit/v7"
@@ -29,15 +26,6 @@ var chineseNameRunes = []rune(
 		"雯琪凱安宸瑋語嫣詩涵雅庭睿哲梓子宜萱彥廷啟航詠晴知淇奕辰晉銘遠瑞昕曉彤弘嘉祺瑤軒靜凡筱宇霖念慈萍思源雨薇芷若依蔓惜霏煌洛旭筠羿恆孟心昌逸飛毅",
 )
 
-// 用於生成醫師和治療師的唯一編號
-var (
-	doctorNumberMutex  sync.Mutex
-	therapyNumberMutex sync.Mutex
-	lastDoctorNumber   = 0
-	lastTherapyNumber  = 0
-	isInitialized      = false
-)
-
 // 角色ID常數，對應資料庫中的角色ID
 const (
 	USER_ROLE_ID    = 1
@@ -94,80 +82,23 @@ func init() {
 	})
 }
 
-// InitializeCounters 從數據庫初始化計數器，防止重複帳號
-func InitializeCounters(db *sql.DB) error {
-	if isInitialized {
-		return nil
-	}
-
-	// 獲取當前醫師編號的最大值
-	doctorQuery := `SELECT MAX(CAST(SUBSTRING(account, 7) AS UNSIGNED)) FROM user WHERE account LIKE 'doctor%'`
-	var maxDoctorNum sql.NullInt64
-	err := db.QueryRow(doctorQuery).Scan(&maxDoctorNum)
-	if err != nil && err != sql.ErrNoRows {
-		return fmt.Errorf("failed to get max doctor number: %v", err)
-	}
-
-	// 獲取當前治療師編號的最大值
-	therapyQuery := `SELECT MAX(CAST(SUBSTRING(account, 8) AS UNSIGNED)) FROM user WHERE account LIKE 'therapy%'`
-	var maxTherapyNum sql.NullInt64
-	err = db.QueryRow(therapyQuery).Scan(&maxTherapyNum)
-	if err != nil && err != sql.ErrNoRows {
-		return fmt.Errorf("failed to get max therapy number: %v", err)
-	}
-
-	// 設置計數器初始值
-	doctorNumberMutex.Lock()
-	if maxDoctorNum.Valid {
-		lastDoctorNumber = int(maxDoctorNum.Int64)
-	}
-	doctorNumberMutex.Unlock()
-
-	therapyNumberMutex.Lock()
-	if maxTherapyNum.Valid {
-		lastTherapyNumber = int(maxTherapyNum.Int64)
-	}
-	therapyNumberMutex.Unlock()
-
-	isInitialized = true
-	return nil
+// AccountPrefixForUserType 根據使用者類型取得帳號前綴，帳號格式為「前綴+編號」
+func AccountPrefixForUserType(userType string) string {
+	return fakeUserTypeOrDefault(userType).AccountPrefix
 }
 
-// getNextDoctorNumber 獲取下一個醫師編號
-func getNextDoctorNumber() int {
-	doctorNumberMutex.Lock()
-	defer doctorNumberMutex.Unlock()
-	lastDoctorNumber++
-	return lastDoctorNumber
-}
-
-// getNextTherapyNumber 獲取下一個治療師編號
-func getNextTherapyNumber() int {
-	therapyNumberMutex.Lock()
-	defer therapyNumberMutex.Unlock()
-	lastTherapyNumber++
-	return lastTherapyNumber
-}
-
-// GenerateFakeUser creates a fake user with realistic data
-func GenerateFakeUser(userType string) *models.User {
+// GenerateFakeUser creates a fake user with realistic data and the given account number
+func GenerateFakeUser(userType string, number int64) *models.User {
 	now := time.Now()
 	pastDate := gofakeit.DateRange(now.AddDate(-5, 0, 0), now)
 	steamID := gofakeit.UUID()
 	username, _ := gofakeit.Generate("{chinese_name}")
 
-	var account, email string
-
-	// 根據使用者類型產生不同的 account 和 email
-	if strings.ToLower(userType) == "doctor" {
-		number := getNextDoctorNumber()
-		account = fmt.Sprintf("doctor%d", number)
-		email = fmt.Sprintf("%s@example.com", account)
-	} else { // therapy
-		number := getNextTherapyNumber()
-		account = fmt.Sprintf("therapy%d", number)
-		email = fmt.Sprintf("%s@example.com", account)
-	}
+	// 根據使用者類型產生不同的 account、email 和狀態
+	config := fakeUserTypeOrDefault(userType)
+	account := fmt.Sprintf("%s%d", config.AccountPrefix, number)
+	email := fmt.Sprintf("%s@%s", account, config.EmailDomain)
+
 	// 使用我們自定義的 taiwan_phone 生成器
 	phoneNumber, _ := gofakeit.Generate("{taiwan_phone}")
 	return &models.User{
@@ -176,7 +107,7 @@ func GenerateFakeUser(userType string) *models.User {
 		Email:         email,
 		LastLoginDate: &pastDate,
 		Password:      "$2a$12$qDECDR.WBiP2Xueb5ftW3.LKslm6.Gs7oeTH1T3SmnUxpucvUm8sW",
-		Status:        "APPROVED",
+		Status:        config.randomStatus(),
 		SteamID:       &steamID,
 		TelCell:       &phoneNumber,
 		// 產生像「張偉」「李欣怡」這樣的中文姓名
@@ -186,11 +117,12 @@ func GenerateFakeUser(userType string) *models.User {
 	}
 }
 
-// GenerateFakeUsers creates a slice of fake users
-func GenerateFakeUsers(count int, userType string) []*models.User {
+// GenerateFakeUsers creates a slice of fake users numbered consecutively from firstNumber;
+// the numbers must be reserved beforehand (see Repository.ReserveAccountNumbers)
+func GenerateFakeUsers(count int, userType string, firstNumber int64) []*models.User {
 	users := make([]*models.User, count)
 	for i := 0; i < count; i++ {
-		users[i] = GenerateFakeUser(userType)
+		users[i] = GenerateFakeUser(userType, firstNumber+int64(i))
 	}
 	return users
 }
@@ -198,17 +130,11 @@ func GenerateFakeUsers(count int, userType string) []*models.User {
 // GetDefaultRoleIDsForUserType 根據使用者類型獲取預設角色 ID 列表
 // 此函數可在沒有前端角色選擇時提供預設值
 func GetDefaultRoleIDsForUserType(userType string) []int64 {
-	switch strings.ToLower(userType) {
-	case "doctor":
-		return []int64{DOCTOR_ROLE_ID} // 醫師只需要醫師角色
-	case "therapy":
-		// 對於治療師，隨機選擇一種治療師角色，不含 USER 角色
-		therapistRoles := []int64{DTX_PSY_ROLE_ID, DTX_ST_ROLE_ID, DTX_OT_ROLE_ID, DTX_PI_ROLE_ID}
-		randomIndex := rand.Intn(len(therapistRoles))
-		return []int64{therapistRoles[randomIndex]}
-	default:
+	config, ok := GetFakeUserType(userType)
+	if !ok {
 		return []int64{} // 不自動分配任何角色
 	}
+	return config.DefaultRoleIDs()
 }
 
 // 台灣城市和區域對應表
@@ -237,19 +163,26 @@ var taiwanCityDistricts = map[string][]string{
 	"連江縣": {"南竿鄉", "北竿鄉", "莒光鄉", "東引鄉"},
 }
 
-// 疾病史選項（從資料庫 history_disease 表獲取）
-var historyDiseases = []string{
-	"中樞神經損傷", "心血管疾病", "呼吸方面疾病", "肝臟疾病",
-	"糖尿病", "腎臟病", "癌症", "免疫相關疾病",
-}
-
-// 醫療史選項
-var medicalHistories = []string{
+// 預設醫療史選項，資料庫中尚無醫療史資料時使用
+var defaultMedicalHistories = []string{
 	"骨折", "開刀", "住院治療", "重大傷病", "藥物過敏",
 	"食物過敏", "輸血", "化療", "放射治療", "洗腎治療",
 	"器官移植", "心導管", "心律不整裝置", "人工關節", "牙科手術",
 }
 
+// DefaultMedicalHistories 回傳預設醫療史選項的副本
+func DefaultMedicalHistories() []string {
+	options := make([]string, len(defaultMedicalHistories))
+	copy(options, defaultMedicalHistories)
+	return options
+}
+
+// PatientCatalogue 生成假病患時可選用的病史與醫療史選項，通常由資料庫目錄載入
+type PatientCatalogue struct {
+	HistoryDiseases  []string
+	MedicalHistories []string
+}
+
 // 緊急聯絡人關係選項
 var emergencyRelations = []string{
 	"父親", "母親", "配偶", "兒子", "女兒",
@@ -257,61 +190,62 @@ var emergencyRelations = []string{
 	"阿姨", "表親", "朋友", "同事", "監護人",
 }
 
-// GenerateFakePatients 生成指定數量的假病患資料
-func GenerateFakePatients(count int) ([]*models.Patient, error) {
+// GenerateFakePatients 生成指定數量的假病患資料（使用預設擬真設定檔）
+func GenerateFakePatients(count int, catalogue *PatientCatalogue) ([]*models.Patient, error) {
+	profile, _ := GetPatientProfile(PatientProfileDefault)
+	return GenerateFakePatientsWithProfile(count, profile, catalogue)
+}
+
+// GenerateFakePatientsWithProfile 依擬真設定檔及病史目錄生成指定數量的假病患資料
+func GenerateFakePatientsWithProfile(count int, profile *PatientProfile, catalogue *PatientCatalogue) ([]*models.Patient, error) {
+	if profile == nil {
+		return nil, fmt.Errorf("未指定擬真設定檔")
+	}
+	if catalogue == nil {
+		return nil, fmt.Errorf("未載入病史目錄")
+	}
+	if len(profile.AgeBands) == 0 {
+		return nil, fmt.Errorf("擬真設定檔 %s 未設定年齡分佈", profile.Name)
+	}
+
 	patients := make([]*models.Patient, 0, count)
+	now := time.Now()
 
 	for i := 0; i < count; i++ {
-		// 隨機生成身分證字號
-		idno := generateTaiwanID()
-
 		// 基本個人信息
-		gender := ""
-		if rand.Intn(2) == 0 {
-			gender = "M"
-		} else {
-			gender = "F"
-		}
+		gender := profile.randomGender()
+
+		// 依性別生成身分證字號
+		idno := generateTaiwanID(globalRand{}, gender)
+
+		// 依年齡分佈生成出生日期
+		age := profile.randomAge()
+		birth := randomBirth(age, now)
 
-		// 隨機生成出生日期（18-90歲）
-		minAge := 18
-		maxAge := 90
-		age := rand.Intn(maxAge-minAge) + minAge
-		birthYear := time.Now().Year() - age
-		birthMonth := rand.Intn(12) + 1
-		birthDay := rand.Intn(28) + 1 // 簡化處理，避免月份天數問題
-		birth := time.Date(birthYear, time.Month(birthMonth), birthDay, 0, 0, 0, 0, time.Local)
-
-		// 隨機選擇城市和區域
-		city := getRandomKey(taiwanCityDistricts)
+		// 依城市權重選擇城市和區域
+		city := profile.randomCity()
 		districts := taiwanCityDistricts[city]
 		district := districts[rand.Intn(len(districts))]
 
 		// 隨機生成地址
-		address := fmt.Sprintf("%s%d號",
-			[]string{"中山路", "中正路", "復興路", "和平路", "民生路", "建國路", "光明街", "仁愛路", "忠孝路", "信義路"}[rand.Intn(10)],
-			rand.Intn(200)+1,
-		)
+		address := generateAddress(globalRand{})
 
 		// 隨機選擇病史和醫療史
 		otherHistoryDisease := ""
 		otherMedicalHistory := ""
 
 		// 少數情況下有自定義病史和醫療史
-		if rand.Intn(10) < 3 {
+		if rand.Float64() < profile.OtherHistoryRate {
 			otherHistoryDisease = []string{"", "家族有糖尿病史", "曾有嚴重過敏", "青少年哮喘", "其他慢性疾病"}[rand.Intn(5)]
 		}
-		if rand.Intn(10) < 3 {
+		if rand.Float64() < profile.OtherHistoryRate {
 			otherMedicalHistory = []string{"", "曾動過小手術", "曾做過重大手術", "有長期用藥", "最近有服用特殊藥物"}[rand.Intn(5)]
 		}
 
-		// 隨機選擇緊急聯絡人關係
-		relation := emergencyRelations[rand.Intn(len(emergencyRelations))]
-
 		patient := &models.Patient{
 			ID:                  int64(i + 1),
 			UserID:              int64(rand.Intn(100) + 1), // 隨機分配一個用戶ID
-			Name:                generateChineseName(),
+			Name:                generateChineseName(globalRand{}),
 			Gender:              gender,
 			IDNo:                idno,
 			Age:                 age,
@@ -319,54 +253,22 @@ func GenerateFakePatients(count int) ([]*models.Patient, error) {
 			Address:             address,
 			City:                city,
 			District:            district,
-			Phone:               generateTaiwanPhone(),
+			Phone:               generateTaiwanPhone(globalRand{}),
 			Mail:                gofakeit.Email(),
 			DiseaseID:           int64(rand.Intn(10) + 1),
-			EmergencyContact:    generateChineseName(),
-			EmergencyPhone:      generateTaiwanPhone(),
-			EmergencyRelation:   relation,
+			EmergencyContact:    generateChineseName(globalRand{}),
+			EmergencyPhone:      generateTaiwanPhone(globalRand{}),
+			EmergencyRelation:   profile.randomEmergencyRelation(),
 			OtherHistoryDisease: otherHistoryDisease,
 			OtherMedicalHistory: otherMedicalHistory,
 		}
 
-		// 隨機選擇0-3個病史
-		historyDiseaseCount := rand.Intn(4)
-		if historyDiseaseCount > 0 {
-			patient.HistoryDiseases = make([]string, 0, historyDiseaseCount)
-			// 創建一個副本以便進行隨機選擇而不重複
-			availableHistoryDiseases := make([]string, len(historyDiseases))
-			copy(availableHistoryDiseases, historyDiseases)
-
-			for j := 0; j < historyDiseaseCount; j++ {
-				if len(availableHistoryDiseases) == 0 {
-					break
-				}
-				// 隨機選擇一個病史項目
-				idx := rand.Intn(len(availableHistoryDiseases))
-				patient.HistoryDiseases = append(patient.HistoryDiseases, availableHistoryDiseases[idx])
-				// 從可用列表中移除已選項目以避免重複
-				availableHistoryDiseases = append(availableHistoryDiseases[:idx], availableHistoryDiseases[idx+1:]...)
-			}
+		// 依設定檔選擇病史（含共病）與醫療史
+		if diseases := profile.randomHistoryDiseases(catalogue.HistoryDiseases); len(diseases) > 0 {
+			patient.HistoryDiseases = diseases
 		}
-
-		// 隨機選擇0-3個醫療史
-		medicalHistoryCount := rand.Intn(4)
-		if medicalHistoryCount > 0 {
-			patient.MedicalHistories = make([]string, 0, medicalHistoryCount)
-			// 創建一個副本以便進行隨機選擇而不重複
-			availableMedicalHistories := make([]string, len(medicalHistories))
-			copy(availableMedicalHistories, medicalHistories)
-
-			for j := 0; j < medicalHistoryCount; j++ {
-				if len(availableMedicalHistories) == 0 {
-					break
-				}
-				// 隨機選擇一個醫療史項目
-				idx := rand.Intn(len(availableMedicalHistories))
-				patient.MedicalHistories = append(patient.MedicalHistories, availableMedicalHistories[idx])
-				// 從可用列表中移除已選項目以避免重複
-				availableMedicalHistories = append(availableMedicalHistories[:idx], availableMedicalHistories[idx+1:]...)
-			}
+		if histories := profile.randomMedicalHistories(catalogue.MedicalHistories); len(histories) > 0 {
+			patient.MedicalHistories = histories
 		}
 
 		patients = append(patients, patient)
@@ -375,23 +277,50 @@ func GenerateFakePatients(count int) ([]*models.Patient, error) {
 	return patients, nil
 }
 
-// generateTaiwanID 生成台灣身分證字號
-func generateTaiwanID() string {
+// randomBirth 隨機產生一個出生日期，使病患在 now 當天恰為 age 歲，且不晚於今天
+func randomBirth(age int, now time.Time) time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	latest := today.AddDate(-age, 0, 0) // 今天剛滿 age 歲
+	if latest.Day() != today.Day() {
+		// 今天是 2 月 29 日而該年沒有 2 月 29 日時，AddDate 會進位到 3 月 1 日，改用 2 月 28 日
+		latest = latest.AddDate(0, 0, -latest.Day())
+	}
+	earliest := today.AddDate(-age-1, 0, 1) // 明天就滿 age+1 歲
+	days := int(latest.Sub(earliest).Round(24*time.Hour) / (24 * time.Hour))
+	return latest.AddDate(0, 0, -rand.Intn(days+1))
+}
+
+// randSource 是 math/rand 全域函式與 *rand.Rand 共同的方法，
+// 讓生成器可使用全域亂數，或由去識別化金鑰決定、可重現的亂數
+type randSource interface {
+	Intn(n int) int
+}
+
+// globalRand 使用 math/rand 的全域亂數
+type globalRand struct{}
+
+func (globalRand) Intn(n int) int { return rand.Intn(n) }
+
+// generateTaiwanID 依性別生成台灣身分證字號
+func generateTaiwanID(r randSource, gender string) string {
 	// 第一個字母代表地區
 	letters := "ABCDEFGHJKLMNPQRSTUVXYWZIO"
-	firstLetter := string(letters[rand.Intn(len(letters))])
+	firstLetter := string(letters[r.Intn(len(letters))])
 
 	// 第二個數字代表性別（1男性，2女性）
-	genderNum := rand.Intn(2) + 1
+	genderNum := 1
+	if gender == "F" {
+		genderNum = 2
+	}
 
 	// 隨機生成其余7個數字
 	restNums := ""
 	for i := 0; i < 7; i++ {
-		restNums += fmt.Sprintf("%d", rand.Intn(10))
+		restNums += fmt.Sprintf("%d", r.Intn(10))
 	}
 
 	// 最後一個檢查碼先假設為0（實際上應該有正確的檢查碼計算）
-	return fmt.Sprintf("%s%d%s%d", firstLetter, genderNum, restNums, rand.Intn(10))
+	return fmt.Sprintf("%s%d%s%d", firstLetter, genderNum, restNums, r.Intn(10))
 }
 
 // getRandomKey 從map中隨機選擇一個鍵
@@ -404,26 +333,34 @@ func getRandomKey(m map[string][]string) string {
 }
 
 // generateChineseName 產生隨機中文姓名
-func generateChineseName() string {
+func generateChineseName(r randSource) string {
 	// 隨機挑一個姓氏
-	surname := chineseSurnames[rand.Intn(len(chineseSurnames))]
+	surname := chineseSurnames[r.Intn(len(chineseSurnames))]
 	// 決定名字長度 1 或 2 個字
-	nameLen := rand.Intn(2) + 1
+	nameLen := r.Intn(2) + 1
 	name := ""
 	for i := 0; i < nameLen; i++ {
-		name += string(chineseNameRunes[rand.Intn(len(chineseNameRunes))])
+		name += string(chineseNameRunes[r.Intn(len(chineseNameRunes))])
 	}
 	return surname + name
 }
 
+// 常見路名
+var streetNames = []string{"中山路", "中正路", "復興路", "和平路", "民生路", "建國路", "光明街", "仁愛路", "忠孝路", "信義路"}
+
+// generateAddress 產生隨機路名與門牌號碼
+func generateAddress(r randSource) string {
+	return fmt.Sprintf("%s%d號", streetNames[r.Intn(len(streetNames))], r.Intn(200)+1)
+}
+
 // generateTaiwanPhone 產生隨機台灣手機號碼
-func generateTaiwanPhone() string {
+func generateTaiwanPhone(r randSource) string {
 	// 台灣手機前兩碼通常是 09
 	prefix := "09"
 	// 隨機生成 8 位數字
 	digits := ""
 	for i := 0; i < 8; i++ {
-		digits += fmt.Sprintf("%d", rand.Intn(10))
+		digits += fmt.Sprintf("%d", r.Intn(10))
 	}
 	return prefix + digits
 }
@@ -0,0 +1,314 @@
+package utils
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// 假病患擬真設定檔名稱
+const (
+	PatientProfileDefault    = "default"
+	PatientProfileGeriatric  = "geriatric"
+	PatientProfilePediatric  = "pediatric"
+	PatientProfilePopulation = "population"
+)
+
+// AgeBand 年齡區間與其權重
+type AgeBand struct {
+	MinAge int     `json:"min_age"`
+	MaxAge int     `json:"max_age"` // 包含上限
+	Weight float64 `json:"weight"`
+}
+
+// Comorbidity 共病規則：病患已有 Trigger 疾病時，以 Probability 機率同時具有 Related 疾病
+type Comorbidity struct {
+	Trigger     string  `json:"trigger"`
+	Related     string  `json:"related"`
+	Probability float64 `json:"probability"`
+}
+
+// PatientProfile 定義假病患資料的擬真分佈設定
+type PatientProfile struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+
+	// 年齡分佈，依權重挑選區間後在區間內均勻取值
+	AgeBands []AgeBand `json:"age_bands"`
+	// 男性比例（0-1）
+	MaleRatio float64 `json:"male_ratio"`
+
+	// 病史數量權重，索引即為病史數量（例如 {1, 1, 1, 1} 代表 0-3 個均等）
+	HistoryDiseaseCountWeights []float64 `json:"history_disease_count_weights"`
+	// 個別病史的相對權重，未列出者權重為 1
+	HistoryDiseaseWeights map[string]float64 `json:"history_disease_weights,omitempty"`
+	// 共病規則
+	Comorbidities []Comorbidity `json:"comorbidities,omitempty"`
+	// 醫療史數量權重，索引即為醫療史數量
+	MedicalHistoryCountWeights []float64 `json:"medical_history_count_weights"`
+	// 有自定義病史/醫療史文字的機率（0-1）
+	OtherHistoryRate float64 `json:"other_history_rate"`
+
+	// 城市權重，為空時所有城市均等
+	CityWeights map[string]float64 `json:"city_weights,omitempty"`
+	// 緊急聯絡人關係選項，為空時使用全部關係
+	EmergencyRelations []string `json:"emergency_relations,omitempty"`
+}
+
+// 台灣各縣市人口權重（單位：萬人，約略值）
+var taiwanCityPopulation = map[string]float64{
+	"新北市": 404, "台中市": 285, "高雄市": 273, "台北市": 249, "桃園市": 232,
+	"台南市": 186, "彰化縣": 124, "屏東縣": 80, "雲林縣": 67, "新竹縣": 58,
+	"苗栗縣": 54, "嘉義縣": 49, "南投縣": 48, "宜蘭縣": 45, "新竹市": 45,
+	"基隆市": 36, "花蓮縣": 32, "嘉義市": 26, "台東縣": 21, "金門縣": 14,
+	"澎湖縣": 11, "連江縣": 1,
+}
+
+// 常見共病關聯
+var commonComorbidities = []Comorbidity{
+	{Trigger: "糖尿病", Related: "腎臟病", Probability: 0.35},
+	{Trigger: "糖尿病", Related: "心血管疾病", Probability: 0.4},
+	{Trigger: "腎臟病", Related: "心血管疾病", Probability: 0.3},
+	{Trigger: "肝臟疾病", Related: "癌症", Probability: 0.1},
+	{Trigger: "心血管疾病", Related: "中樞神經損傷", Probability: 0.15},
+}
+
+// patientProfiles 內建的擬真設定檔
+var patientProfiles = map[string]*PatientProfile{
+	PatientProfileDefault: {
+		Name:                       PatientProfileDefault,
+		DisplayName:                "一般（均勻分佈）",
+		Description:                "18-90 歲均勻分佈，0-3 個病史，城市均等",
+		AgeBands:                   []AgeBand{{MinAge: 18, MaxAge: 89, Weight: 1}},
+		MaleRatio:                  0.5,
+		HistoryDiseaseCountWeights: []float64{1, 1, 1, 1},
+		MedicalHistoryCountWeights: []float64{1, 1, 1, 1},
+		OtherHistoryRate:           0.3,
+	},
+	PatientProfileGeriatric: {
+		Name:        PatientProfileGeriatric,
+		DisplayName: "老年醫學門診",
+		Description: "65 歲以上為主、女性略多，慢性病與共病比例高",
+		AgeBands: []AgeBand{
+			{MinAge: 55, MaxAge: 64, Weight: 1},
+			{MinAge: 65, MaxAge: 74, Weight: 4},
+			{MinAge: 75, MaxAge: 84, Weight: 4},
+			{MinAge: 85, MaxAge: 99, Weight: 2},
+		},
+		MaleRatio:                  0.45,
+		HistoryDiseaseCountWeights: []float64{0.5, 2, 3, 3},
+		HistoryDiseaseWeights: map[string]float64{
+			"心血管疾病": 4, "糖尿病": 3, "腎臟病": 2, "中樞神經損傷": 2, "癌症": 1.5,
+		},
+		Comorbidities:              commonComorbidities,
+		MedicalHistoryCountWeights: []float64{1, 2, 2, 2},
+		OtherHistoryRate:           0.4,
+		CityWeights:                taiwanCityPopulation,
+		EmergencyRelations:         []string{"配偶", "兒子", "女兒", "兄弟", "姊妹", "監護人"},
+	},
+	PatientProfilePediatric: {
+		Name:        PatientProfilePediatric,
+		DisplayName: "兒科門診",
+		Description: "0-17 歲，病史少，以呼吸道與免疫相關疾病為主，緊急聯絡人為家長",
+		AgeBands: []AgeBand{
+			{MinAge: 0, MaxAge: 5, Weight: 3},
+			{MinAge: 6, MaxAge: 11, Weight: 2},
+			{MinAge: 12, MaxAge: 17, Weight: 2},
+		},
+		MaleRatio:                  0.52,
+		HistoryDiseaseCountWeights: []float64{6, 3, 1},
+		HistoryDiseaseWeights: map[string]float64{
+			"呼吸方面疾病": 6, "免疫相關疾病": 4, "中樞神經損傷": 1,
+			"心血管疾病": 0.5, "肝臟疾病": 0.2, "糖尿病": 0.3, "腎臟病": 0.2, "癌症": 0.2,
+		},
+		MedicalHistoryCountWeights: []float64{6, 3, 1},
+		OtherHistoryRate:           0.15,
+		CityWeights:                taiwanCityPopulation,
+		EmergencyRelations:         []string{"父親", "母親", "祖父", "祖母", "監護人"},
+	},
+	PatientProfilePopulation: {
+		Name:        PatientProfilePopulation,
+		DisplayName: "依人口比例",
+		Description: "成人年齡依人口結構分佈，城市依各縣市人口加權，含常見共病",
+		AgeBands: []AgeBand{
+			{MinAge: 18, MaxAge: 29, Weight: 16},
+			{MinAge: 30, MaxAge: 44, Weight: 24},
+			{MinAge: 45, MaxAge: 64, Weight: 32},
+			{MinAge: 65, MaxAge: 79, Weight: 19},
+			{MinAge: 80, MaxAge: 99, Weight: 6},
+		},
+		MaleRatio:                  0.49,
+		HistoryDiseaseCountWeights: []float64{4, 3, 2, 1},
+		Comorbidities:              commonComorbidities,
+		MedicalHistoryCountWeights: []float64{4, 3, 2, 1},
+		OtherHistoryRate:           0.3,
+		CityWeights:                taiwanCityPopulation,
+	},
+}
+
+// GetPatientProfile 依名稱取得擬真設定檔，找不到時回傳 false
+func GetPatientProfile(name string) (*PatientProfile, bool) {
+	if name == "" {
+		name = PatientProfileDefault
+	}
+	profile, ok := patientProfiles[name]
+	return profile, ok
+}
+
+// ListPatientProfiles 列出所有內建擬真設定檔，預設設定檔排在最前面
+func ListPatientProfiles() []*PatientProfile {
+	profiles := make([]*PatientProfile, 0, len(patientProfiles))
+	for _, profile := range patientProfiles {
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].Name == PatientProfileDefault {
+			return true
+		}
+		if profiles[j].Name == PatientProfileDefault {
+			return false
+		}
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles
+}
+
+// randomAge 依年齡區間權重產生年齡
+func (p *PatientProfile) randomAge() int {
+	weights := make([]float64, len(p.AgeBands))
+	for i, band := range p.AgeBands {
+		weights[i] = band.Weight
+	}
+	band := p.AgeBands[weightedIndex(weights)]
+	return band.MinAge + rand.Intn(band.MaxAge-band.MinAge+1)
+}
+
+// randomGender 依男性比例產生性別
+func (p *PatientProfile) randomGender() string {
+	if rand.Float64() < p.MaleRatio {
+		return "M"
+	}
+	return "F"
+}
+
+// randomCity 依城市權重挑選城市
+func (p *PatientProfile) randomCity() string {
+	if len(p.CityWeights) == 0 {
+		return getRandomKey(taiwanCityDistricts)
+	}
+	cities := make([]string, 0, len(p.CityWeights))
+	for city := range p.CityWeights {
+		if _, ok := taiwanCityDistricts[city]; ok {
+			cities = append(cities, city)
+		}
+	}
+	// 排序以確保相同亂數種子下結果一致
+	sort.Strings(cities)
+	weights := make([]float64, len(cities))
+	for i, city := range cities {
+		weights[i] = p.CityWeights[city]
+	}
+	return cities[weightedIndex(weights)]
+}
+
+// randomEmergencyRelation 挑選緊急聯絡人關係
+func (p *PatientProfile) randomEmergencyRelation() string {
+	relations := p.EmergencyRelations
+	if len(relations) == 0 {
+		relations = emergencyRelations
+	}
+	return relations[rand.Intn(len(relations))]
+}
+
+// randomHistoryDiseases 從病史目錄中依權重挑選病史，並套用共病規則
+func (p *PatientProfile) randomHistoryDiseases(diseases []string) []string {
+	count := weightedIndex(p.HistoryDiseaseCountWeights)
+	weights := make([]float64, len(diseases))
+	available := make(map[string]bool, len(diseases))
+	for i, disease := range diseases {
+		available[disease] = true
+		weights[i] = 1
+		if w, ok := p.HistoryDiseaseWeights[disease]; ok {
+			weights[i] = w
+		}
+	}
+	selected := weightedSample(diseases, weights, count)
+
+	// 套用共病規則，依序處理讓新增的疾病也能觸發後續規則；目錄中不存在的疾病不會被加入
+	has := make(map[string]bool, len(selected))
+	for _, disease := range selected {
+		has[disease] = true
+	}
+	for _, rule := range p.Comorbidities {
+		if !available[rule.Related] {
+			continue
+		}
+		if has[rule.Trigger] && !has[rule.Related] && rand.Float64() < rule.Probability {
+			has[rule.Related] = true
+			selected = append(selected, rule.Related)
+		}
+	}
+	return selected
+}
+
+// randomMedicalHistories 從醫療史選項中依數量權重挑選醫療史
+func (p *PatientProfile) randomMedicalHistories(options []string) []string {
+	count := weightedIndex(p.MedicalHistoryCountWeights)
+	weights := make([]float64, len(options))
+	for i := range weights {
+		weights[i] = 1
+	}
+	return weightedSample(options, weights, count)
+}
+
+// weightedIndex 依權重隨機回傳索引，權重為 0 的項目不會被選中；權重總和為 0 時回傳 0
+func weightedIndex(weights []float64) int {
+	total := 0.0
+	last := 0
+	for i, w := range weights {
+		if w > 0 {
+			total += w
+			last = i
+		}
+	}
+	if total <= 0 {
+		return 0
+	}
+	r := rand.Float64() * total
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		r -= w
+		if r < 0 {
+			return i
+		}
+	}
+	// 浮點數誤差可能讓 r 在扣完所有權重後仍不小於 0，此時回傳最後一個權重為正的項目
+	return last
+}
+
+// weightedSample 依權重不重複地挑選 n 個項目，權重為正的項目不足 n 個時只回傳這些項目
+func weightedSample(items []string, weights []float64, n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	availableItems := make([]string, len(items))
+	copy(availableItems, items)
+	availableWeights := make([]float64, len(weights))
+	copy(availableWeights, weights)
+
+	selected := make([]string, 0, n)
+	for len(selected) < n && len(availableItems) > 0 {
+		idx := weightedIndex(availableWeights)
+		if availableWeights[idx] <= 0 {
+			// 只剩權重為 0 的項目
+			break
+		}
+		selected = append(selected, availableItems[idx])
+		// 從可用列表中移除已選項目以避免重複
+		availableItems = append(availableItems[:idx], availableItems[idx+1:]...)
+		availableWeights = append(availableWeights[:idx], availableWeights[idx+1:]...)
+	}
+	return selected
+}
@@ -0,0 +1,273 @@
+package utils
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testHistoryDiseases 與預設疾病目錄相同的病史選項
+var testHistoryDiseases = []string{
+	"心血管疾病", "糖尿病", "腎臟病", "肝臟疾病",
+	"中樞神經損傷", "癌症", "呼吸方面疾病", "免疫相關疾病",
+}
+
+// assertRatio 檢查抽樣比例與預期比例的差距在容許範圍內
+func assertRatio(t *testing.T, label string, got, total int, want float64) {
+	t.Helper()
+	if ratio := float64(got) / float64(total); math.Abs(ratio-want) > 0.02 {
+		t.Errorf("%s: 比例 = %.3f, want %.3f", label, ratio, want)
+	}
+}
+
+func TestWeightedIndex(t *testing.T) {
+	if got := weightedIndex([]float64{0, 0, 0}); got != 0 {
+		t.Errorf("權重總和為 0 時應回傳 0，實際 %d", got)
+	}
+	if got := weightedIndex(nil); got != 0 {
+		t.Errorf("沒有權重時應回傳 0，實際 %d", got)
+	}
+
+	const n = 20000
+	counts := make([]int, 4)
+	for i := 0; i < n; i++ {
+		counts[weightedIndex([]float64{1, 0, 3, 0})]++
+	}
+	if counts[1] != 0 || counts[3] != 0 {
+		t.Fatalf("權重為 0 的項目不應該被選中: %v", counts)
+	}
+	assertRatio(t, "索引 0", counts[0], n, 0.25)
+	assertRatio(t, "索引 2", counts[2], n, 0.75)
+}
+
+func TestWeightedSampleSkipsZeroWeights(t *testing.T) {
+	items := []string{"a", "b", "c"}
+	for i := 0; i < 1000; i++ {
+		got := weightedSample(items, []float64{1, 0, 1}, 3)
+		if slices.Contains(got, "b") {
+			t.Fatalf("權重為 0 的項目不應該被抽中: %v", got)
+		}
+	}
+}
+
+func TestPatientProfileAgeBands(t *testing.T) {
+	const n = 20000
+	for _, profile := range ListPatientProfiles() {
+		total := 0.0
+		for _, band := range profile.AgeBands {
+			total += band.Weight
+		}
+		counts := make([]int, len(profile.AgeBands))
+		for i := 0; i < n; i++ {
+			age := profile.randomAge()
+			idx := slices.IndexFunc(profile.AgeBands, func(band AgeBand) bool {
+				return age >= band.MinAge && age <= band.MaxAge
+			})
+			if idx < 0 {
+				t.Fatalf("%s: 年齡 %d 不在任何年齡區間內", profile.Name, age)
+			}
+			counts[idx]++
+		}
+		for i, band := range profile.AgeBands {
+			assertRatio(t, profile.Name, counts[i], n, band.Weight/total)
+		}
+	}
+}
+
+func TestPatientProfileCityWeights(t *testing.T) {
+	for city := range taiwanCityPopulation {
+		if _, ok := taiwanCityDistricts[city]; !ok {
+			t.Errorf("人口權重中的 %s 不在縣市區域清單中", city)
+		}
+	}
+
+	profile, _ := GetPatientProfile(PatientProfilePopulation)
+	total := 0.0
+	for _, w := range profile.CityWeights {
+		total += w
+	}
+	const n = 20000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[profile.randomCity()]++
+	}
+	for _, city := range []string{"新北市", "台中市", "台東縣", "連江縣"} {
+		assertRatio(t, city, counts[city], n, profile.CityWeights[city]/total)
+	}
+
+	// 未設定城市權重時所有城市都可能被選中
+	profile, _ = GetPatientProfile(PatientProfileDefault)
+	seen := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		seen[profile.randomCity()] = true
+	}
+	if len(seen) != len(taiwanCityDistricts) {
+		t.Errorf("均等分佈只選到 %d 個城市，預期 %d 個", len(seen), len(taiwanCityDistricts))
+	}
+}
+
+func TestPatientProfileComorbidities(t *testing.T) {
+	profile := &PatientProfile{
+		Name:                       "test",
+		AgeBands:                   []AgeBand{{MinAge: 30, MaxAge: 30, Weight: 1}},
+		HistoryDiseaseCountWeights: []float64{0, 1},
+		HistoryDiseaseWeights:      map[string]float64{"腎臟病": 0, "心血管疾病": 0},
+		Comorbidities: []Comorbidity{
+			{Trigger: "糖尿病", Related: "腎臟病", Probability: 1},
+			{Trigger: "腎臟病", Related: "心血管疾病", Probability: 1},
+			{Trigger: "糖尿病", Related: "癌症", Probability: 1},
+		},
+	}
+	catalogue := []string{"糖尿病", "腎臟病", "心血管疾病"}
+	want := []string{"糖尿病", "腎臟病", "心血管疾病"}
+	for i := 0; i < 100; i++ {
+		got := profile.randomHistoryDiseases(catalogue)
+		if !slices.Equal(got, want) {
+			t.Fatalf("病史 = %v, want %v（共病應連鎖觸發，且不加入目錄外的疾病）", got, want)
+		}
+	}
+
+	// 共病規則會提高相關疾病的比例
+	geriatric, _ := GetPatientProfile(PatientProfileGeriatric)
+	const n = 5000
+	diabetic, withKidney := 0, 0
+	for i := 0; i < n; i++ {
+		diseases := geriatric.randomHistoryDiseases(testHistoryDiseases)
+		if slices.Contains(diseases, "糖尿病") {
+			diabetic++
+			if slices.Contains(diseases, "腎臟病") {
+				withKidney++
+			}
+		}
+	}
+	if diabetic == 0 || float64(withKidney)/float64(diabetic) < 0.35 {
+		t.Errorf("糖尿病患者合併腎臟病的比例 = %d/%d，應不低於共病機率", withKidney, diabetic)
+	}
+}
+
+func TestGenerateFakePatientsWithProfile(t *testing.T) {
+	catalogue := &PatientCatalogue{
+		HistoryDiseases:  testHistoryDiseases,
+		MedicalHistories: DefaultMedicalHistories(),
+	}
+	for _, profile := range ListPatientProfiles() {
+		patients, err := GenerateFakePatientsWithProfile(200, profile, catalogue)
+		if err != nil {
+			t.Fatalf("%s: 生成假病患失敗: %v", profile.Name, err)
+		}
+		if len(patients) != 200 {
+			t.Fatalf("%s: 生成 %d 筆，預期 200 筆", profile.Name, len(patients))
+		}
+		relations := profile.EmergencyRelations
+		if len(relations) == 0 {
+			relations = emergencyRelations
+		}
+		for _, p := range patients {
+			if p.Gender != "M" && p.Gender != "F" {
+				t.Errorf("%s: 性別 %q 不正確", profile.Name, p.Gender)
+			}
+			if len(p.IDNo) != 10 {
+				t.Errorf("%s: 身分證字號 %q 長度不正確", profile.Name, p.IDNo)
+			}
+			if p.Birth.After(time.Now()) || ageAt(p.Birth, time.Now()) != p.Age {
+				t.Errorf("%s: 出生日期 %s 與年齡 %d 不符", profile.Name, p.Birth.Format("2006-01-02"), p.Age)
+			}
+			if !slices.ContainsFunc(profile.AgeBands, func(band AgeBand) bool {
+				return p.Age >= band.MinAge && p.Age <= band.MaxAge
+			}) {
+				t.Errorf("%s: 年齡 %d 不在任何年齡區間內", profile.Name, p.Age)
+			}
+			if profile.Name == PatientProfilePediatric && p.Age > 17 {
+				t.Errorf("兒科病患年齡 %d 超過 17 歲", p.Age)
+			}
+			if !slices.Contains(taiwanCityDistricts[p.City], p.District) {
+				t.Errorf("%s: 區域 %s 不屬於 %s", profile.Name, p.District, p.City)
+			}
+			if !slices.Contains(relations, p.EmergencyRelation) {
+				t.Errorf("%s: 緊急聯絡人關係 %q 不在設定檔選項中", profile.Name, p.EmergencyRelation)
+			}
+			seen := make(map[string]bool)
+			for _, disease := range p.HistoryDiseases {
+				if seen[disease] {
+					t.Errorf("%s: 病史 %s 重複", profile.Name, disease)
+				}
+				seen[disease] = true
+				if !slices.Contains(catalogue.HistoryDiseases, disease) {
+					t.Errorf("%s: 病史 %s 不在目錄中", profile.Name, disease)
+				}
+			}
+			if len(p.MedicalHistories) >= len(profile.MedicalHistoryCountWeights) {
+				t.Errorf("%s: 醫療史數量 %d 超出設定", profile.Name, len(p.MedicalHistories))
+			}
+		}
+	}
+
+	profile, _ := GetPatientProfile(PatientProfileDefault)
+	if _, err := GenerateFakePatientsWithProfile(1, nil, catalogue); err == nil {
+		t.Error("未指定設定檔應該失敗")
+	}
+	if _, err := GenerateFakePatientsWithProfile(1, profile, nil); err == nil {
+		t.Error("未載入病史目錄應該失敗")
+	}
+	if _, err := GenerateFakePatientsWithProfile(1, &PatientProfile{Name: "empty"}, catalogue); err == nil {
+		t.Error("未設定年齡分佈應該失敗")
+	}
+}
+
+// ageAt 回傳出生日期為 birth 的人在 now 當天的實際年齡
+func ageAt(birth, now time.Time) int {
+	age := now.Year() - birth.Year()
+	if now.Month() < birth.Month() || (now.Month() == birth.Month() && now.Day() < birth.Day()) {
+		age--
+	}
+	return age
+}
+
+func TestRandomBirth(t *testing.T) {
+	nows := []time.Time{
+		time.Now(),
+		time.Date(2024, time.January, 1, 8, 0, 0, 0, time.Local),
+		time.Date(2024, time.February, 29, 23, 59, 0, 0, time.Local),
+		time.Date(2025, time.March, 1, 0, 0, 0, 0, time.Local),
+		time.Date(2025, time.December, 31, 12, 0, 0, 0, time.Local),
+	}
+	for _, now := range nows {
+		earliest, latest := now, time.Time{}
+		for i := 0; i < 10000; i++ {
+			age := i % 20 * 5
+			birth := randomBirth(age, now)
+			if birth.After(now) {
+				t.Fatalf("now=%s age=%d: 出生日期 %s 晚於今天", now.Format("2006-01-02"), age, birth.Format("2006-01-02"))
+			}
+			if got := ageAt(birth, now); got != age {
+				t.Fatalf("now=%s age=%d: 出生日期 %s 的實際年齡為 %d", now.Format("2006-01-02"), age, birth.Format("2006-01-02"), got)
+			}
+			if age == 0 {
+				if birth.Before(earliest) {
+					earliest = birth
+				}
+				if birth.After(latest) {
+					latest = birth
+				}
+			}
+		}
+		// 出生日期應分佈在整年，而不是集中在部分月份
+		if span := latest.Sub(earliest); span < 300*24*time.Hour {
+			t.Errorf("now=%s: 0 歲的出生日期只分佈在 %s 內", now.Format("2006-01-02"), span)
+		}
+	}
+}
+
+func TestListPatientProfiles(t *testing.T) {
+	profiles := ListPatientProfiles()
+	if len(profiles) != len(patientProfiles) || profiles[0].Name != PatientProfileDefault {
+		t.Fatalf("預設設定檔應排在最前面: %d 個，第一個為 %s", len(profiles), profiles[0].Name)
+	}
+	if profile, ok := GetPatientProfile(""); !ok || profile.Name != PatientProfileDefault {
+		t.Errorf("空白名稱應取得預設設定檔")
+	}
+	if _, ok := GetPatientProfile("unknown"); ok {
+		t.Errorf("未知的設定檔名稱不應該找到")
+	}
+}
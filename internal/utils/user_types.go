@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// 假使用者類型
+const (
+	UserTypeDoctor       = "doctor"
+	UserTypeTherapy      = "therapy"
+	UserTypePatient      = "patient"
+	UserTypeAdmin        = "admin"
+	UserTypePsychologist = "psychologist"
+	UserTypeSpeech       = "speech"
+	UserTypeOT           = "ot"
+	UserTypePI           = "pi"
+)
+
+// FakeUserType 定義一種假使用者類型的帳號前綴、信箱網域、狀態與預設角色
+type FakeUserType struct {
+	Name          string   `json:"name"`
+	DisplayName   string   `json:"display_name"`
+	AccountPrefix string   `json:"account_prefix"`
+	EmailDomain   string   `json:"email_domain"`
+	Statuses      []string `json:"statuses"` // 隨機挑選其中一種狀態
+	RoleIDs       []int64  `json:"role_ids"`
+	// 為 true 時從 RoleIDs 中隨機挑選一個角色，否則指派全部角色
+	RandomRole bool `json:"random_role"`
+}
+
+// fakeUserTypes 所有假使用者類型，角色對應使用資料庫角色ID常數。
+// 帳號前綴不可是其他前綴的開頭，也應避免 ot、pi 這類容易與真實帳號（例如 other、pinky）
+// 開頭相同的短字串，否則保留帳號編號時會與既有帳號互相影響
+var fakeUserTypes = []*FakeUserType{
+	{
+		Name:          UserTypeDoctor,
+		DisplayName:   "醫師",
+		AccountPrefix: "doctor",
+		EmailDomain:   "example.com",
+		Statuses:      []string{"APPROVED"},
+		RoleIDs:       []int64{DOCTOR_ROLE_ID},
+	},
+	{
+		Name:          UserTypeTherapy,
+		DisplayName:   "治療師（隨機類別）",
+		AccountPrefix: "therapy",
+		EmailDomain:   "example.com",
+		Statuses:      []string{"APPROVED"},
+		RoleIDs:       []int64{DTX_PSY_ROLE_ID, DTX_ST_ROLE_ID, DTX_OT_ROLE_ID, DTX_PI_ROLE_ID},
+		RandomRole:    true,
+	},
+	{
+		Name:          UserTypePsychologist,
+		DisplayName:   "心理師",
+		AccountPrefix: "psychologist",
+		EmailDomain:   "therapy.example.com",
+		Statuses:      []string{"APPROVED"},
+		RoleIDs:       []int64{DTX_PSY_ROLE_ID},
+	},
+	{
+		Name:          UserTypeSpeech,
+		DisplayName:   "語言治療師",
+		AccountPrefix: "speech",
+		EmailDomain:   "therapy.example.com",
+		Statuses:      []string{"APPROVED"},
+		RoleIDs:       []int64{DTX_ST_ROLE_ID},
+	},
+	{
+		Name:          UserTypeOT,
+		DisplayName:   "職能治療師",
+		AccountPrefix: "occtherapist",
+		EmailDomain:   "therapy.example.com",
+		Statuses:      []string{"APPROVED"},
+		RoleIDs:       []int64{DTX_OT_ROLE_ID},
+	},
+	{
+		Name:          UserTypePI,
+		DisplayName:   "計畫主持人",
+		AccountPrefix: "investigator",
+		EmailDomain:   "research.example.com",
+		Statuses:      []string{"APPROVED"},
+		RoleIDs:       []int64{DTX_PI_ROLE_ID},
+	},
+	{
+		Name:          UserTypePatient,
+		DisplayName:   "病患使用者",
+		AccountPrefix: "patient",
+		EmailDomain:   "mail.example.com",
+		Statuses:      []string{"APPROVED", "APPROVED", "APPROVED", "PENDING"},
+		RoleIDs:       []int64{USER_ROLE_ID},
+	},
+	{
+		Name:          UserTypeAdmin,
+		DisplayName:   "管理員",
+		AccountPrefix: "admin",
+		EmailDomain:   "admin.example.com",
+		Statuses:      []string{"APPROVED"},
+		RoleIDs:       []int64{ADMIN_ROLE_ID},
+	},
+}
+
+// ListFakeUserTypes 列出所有假使用者類型
+func ListFakeUserTypes() []*FakeUserType {
+	return fakeUserTypes
+}
+
+// GetFakeUserType 依名稱取得假使用者類型，找不到時回傳 false
+func GetFakeUserType(name string) (*FakeUserType, bool) {
+	name = strings.ToLower(name)
+	for _, userType := range fakeUserTypes {
+		if userType.Name == name {
+			return userType, true
+		}
+	}
+	return nil, false
+}
+
+// fakeUserTypeOrDefault 依名稱取得假使用者類型，未知類型沿用治療師設定
+func fakeUserTypeOrDefault(name string) *FakeUserType {
+	if userType, ok := GetFakeUserType(name); ok {
+		return userType
+	}
+	userType, _ := GetFakeUserType(UserTypeTherapy)
+	return userType
+}
+
+// DefaultRoleIDs 回傳此類型的預設角色；RandomRole 時每次隨機挑選一個
+func (t *FakeUserType) DefaultRoleIDs() []int64 {
+	if len(t.RoleIDs) == 0 {
+		return []int64{}
+	}
+	if t.RandomRole {
+		return []int64{t.RoleIDs[rand.Intn(len(t.RoleIDs))]}
+	}
+	roleIDs := make([]int64, len(t.RoleIDs))
+	copy(roleIDs, t.RoleIDs)
+	return roleIDs
+}
+
+// randomStatus 隨機挑選此類型的帳號狀態
+func (t *FakeUserType) randomStatus() string {
+	if len(t.Statuses) == 0 {
+		return "APPROVED"
+	}
+	return t.Statuses[rand.Intn(len(t.Statuses))]
+}
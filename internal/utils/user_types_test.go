@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFakeUserTypePrefixesDoNotCollide(t *testing.T) {
+	types := ListFakeUserTypes()
+	for _, a := range types {
+		if len(a.AccountPrefix) < 5 {
+			t.Errorf("%s: 帳號前綴 %q 太短，容易與其他帳號開頭相同", a.Name, a.AccountPrefix)
+		}
+		for _, b := range types {
+			if a != b && strings.HasPrefix(b.AccountPrefix, a.AccountPrefix) {
+				t.Errorf("帳號前綴 %q 是 %q 的開頭", a.AccountPrefix, b.AccountPrefix)
+			}
+		}
+	}
+}
+
+func TestDefaultRoleIDsForUserType(t *testing.T) {
+	tests := []struct {
+		userType string
+		want     []int64
+	}{
+		{UserTypeDoctor, []int64{DOCTOR_ROLE_ID}},
+		{UserTypePatient, []int64{USER_ROLE_ID}},
+		{UserTypeAdmin, []int64{ADMIN_ROLE_ID}},
+		{UserTypePsychologist, []int64{DTX_PSY_ROLE_ID}},
+		{UserTypeSpeech, []int64{DTX_ST_ROLE_ID}},
+		{UserTypeOT, []int64{DTX_OT_ROLE_ID}},
+		{UserTypePI, []int64{DTX_PI_ROLE_ID}},
+		{"DOCTOR", []int64{DOCTOR_ROLE_ID}},
+		{"unknown", []int64{}},
+	}
+	for _, tt := range tests {
+		if got := GetDefaultRoleIDsForUserType(tt.userType); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: 預設角色 = %v, want %v", tt.userType, got, tt.want)
+		}
+	}
+
+	// 治療師每次隨機挑選一種治療師角色
+	therapists := map[int64]bool{DTX_PSY_ROLE_ID: true, DTX_ST_ROLE_ID: true, DTX_OT_ROLE_ID: true, DTX_PI_ROLE_ID: true}
+	seen := make(map[int64]bool)
+	for i := 0; i < 200; i++ {
+		roleIDs := GetDefaultRoleIDsForUserType(UserTypeTherapy)
+		if len(roleIDs) != 1 || !therapists[roleIDs[0]] {
+			t.Fatalf("治療師的預設角色 = %v", roleIDs)
+		}
+		seen[roleIDs[0]] = true
+	}
+	if len(seen) != len(therapists) {
+		t.Errorf("治療師應涵蓋所有治療師角色，實際為 %v", seen)
+	}
+
+	// 回傳的是副本，修改不影響設定
+	roleIDs := GetDefaultRoleIDsForUserType(UserTypeDoctor)
+	roleIDs[0] = 0
+	if got := GetDefaultRoleIDsForUserType(UserTypeDoctor); got[0] != DOCTOR_ROLE_ID {
+		t.Errorf("修改預設角色不應影響設定: %v", got)
+	}
+}
+
+func TestGenerateFakeUserUsesTypeSettings(t *testing.T) {
+	user := GenerateFakeUser(UserTypePI, 42)
+	if user.Account != "investigator42" || user.Email != "investigator42@research.example.com" {
+		t.Errorf("帳號或信箱不符: %s %s", user.Account, user.Email)
+	}
+	// 未知類型沿用治療師的前綴
+	if got := AccountPrefixForUserType("unknown"); got != "therapy" {
+		t.Errorf("未知類型的帳號前綴 = %q, want therapy", got)
+	}
+}
@@ -0,0 +1,47 @@
+// Package version 提供建置版本資訊，於建置時以 -ldflags 設定，例如:
+//
+//	go build -ldflags "-X golang-gin-app/internal/version.Version=1.2.0 -X golang-gin-app/internal/version.Commit=$(git rev-parse HEAD)" ./cmd/app
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// 建置時以 -ldflags -X 設定；未設定時 Commit 與 BuildTime 改用 Go 工具鏈記錄的版本控制資訊
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info 建置版本資訊
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	Modified  bool   `json:"modified,omitempty"` // 建置時工作目錄有未提交的變更
+	GoVersion string `json:"go_version"`
+}
+
+// Get 回傳目前執行檔的建置版本資訊
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return info
+}
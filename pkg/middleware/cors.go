@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig 跨來源請求設定
+type CORSConfig struct {
+	AllowedOrigins   []string // 允許的來源，例如 https://example.com；"*" 允許所有來源；為空時不處理跨來源請求
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string // 允許瀏覽器腳本讀取的回應標頭
+	AllowCredentials bool     // 允許帶 cookie；AllowedOrigins 為 "*" 時不生效
+	MaxAge           time.Duration
+}
+
+// Enabled 判斷是否設定了允許的來源
+func (c CORSConfig) Enabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
+
+// CORS 處理跨來源請求：允許的來源加上 Access-Control-* 標頭，
+// 預檢請求（OPTIONS 且帶 Access-Control-Request-Method）直接以 204 回應，不允許的來源的預檢請求回傳 403
+func CORS(config CORSConfig) gin.HandlerFunc {
+	allowAll := false
+	origins := make(map[string]bool, len(config.AllowedOrigins))
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		origins[strings.TrimRight(origin, "/")] = true
+	}
+	credentials := config.AllowCredentials && !allowAll
+	methods := strings.Join(config.AllowedMethods, ", ")
+	headers := strings.Join(config.AllowedHeaders, ", ")
+	exposed := strings.Join(config.ExposedHeaders, ", ")
+	maxAge := ""
+	if config.MaxAge > 0 {
+		maxAge = strconv.Itoa(int(config.MaxAge.Seconds()))
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if !allowAll && !origins[origin] {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			// 不加上 CORS 標頭，由瀏覽器拒絕讓腳本讀取回應
+			c.Next()
+			return
+		}
+
+		if allowAll {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if credentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			if exposed != "" {
+				header.Set("Access-Control-Expose-Headers", exposed)
+			}
+			c.Next()
+			return
+		}
+
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		if methods != "" {
+			header.Set("Access-Control-Allow-Methods", methods)
+		}
+		if headers != "" {
+			header.Set("Access-Control-Allow-Headers", headers)
+		}
+		if maxAge != "" {
+			header.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
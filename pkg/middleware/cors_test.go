@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CORS(CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "X-Tenant"},
+		ExposedHeaders:   []string{RequestIDHeader},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}))
+	r.GET("/api/items", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	cases := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		status      int
+		allowOrigin string
+	}{
+		{"same origin", http.MethodGet, "", false, http.StatusOK, ""},
+		{"allowed", http.MethodGet, "https://app.example.com", false, http.StatusOK, "https://app.example.com"},
+		{"not allowed", http.MethodGet, "https://evil.example.com", false, http.StatusOK, ""},
+		{"preflight", http.MethodOptions, "https://app.example.com", true, http.StatusNoContent, "https://app.example.com"},
+		{"preflight not allowed", http.MethodOptions, "https://evil.example.com", true, http.StatusForbidden, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/api/items", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d", w.Code, tc.status)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tc.allowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tc.allowOrigin)
+			}
+			if tc.allowOrigin == "" {
+				return
+			}
+			if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Error("missing Access-Control-Allow-Credentials")
+			}
+			if tc.preflight && (w.Header().Get("Access-Control-Allow-Methods") != "GET, POST" || w.Header().Get("Access-Control-Max-Age") != "600") {
+				t.Errorf("unexpected preflight headers: %v", w.Header())
+			}
+			if !tc.preflight && w.Header().Get("Access-Control-Expose-Headers") != RequestIDHeader {
+				t.Errorf("Access-Control-Expose-Headers = %q", w.Header().Get("Access-Control-Expose-Headers"))
+			}
+		})
+	}
+}
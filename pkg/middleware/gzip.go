@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gzip 在用戶端接受 gzip 時壓縮回應內容。excludedPaths 中的路徑前綴不壓縮，
+// 例如自行處理壓縮的 /metrics。沒有內容的回應（204、304、重新導向等）與 SSE 串流不壓縮。
+func Gzip(level int, excludedPaths ...string) gin.HandlerFunc {
+	pool := sync.Pool{New: func() interface{} {
+		w, err := gzip.NewWriterLevel(nil, level)
+		if err != nil {
+			// level 無效時改用預設壓縮等級
+			w = gzip.NewWriter(nil)
+		}
+		return w
+	}}
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || !acceptsGzip(c.Request) || excluded(c.Request.URL.Path, excludedPaths) {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		w := &gzipWriter{ResponseWriter: c.Writer, pool: &pool}
+		c.Writer = w
+		defer func() {
+			w.close()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// acceptsGzip 判斷請求的 Accept-Encoding 是否接受 gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+func excluded(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipWriter 在第一次寫入內容時才開始壓縮，之前已送出標頭、已設定其他 Content-Encoding
+// 或是 SSE 串流（text/event-stream，壓縮會延遲事件送達）時不壓縮
+type gzipWriter struct {
+	gin.ResponseWriter
+	pool       *sync.Pool
+	gz         *gzip.Writer
+	decided    bool
+	compressed bool
+}
+
+func (w *gzipWriter) start(p []byte) {
+	w.decided = true
+	header := w.ResponseWriter.Header()
+	if w.ResponseWriter.Written() || header.Get("Content-Encoding") != "" ||
+		strings.HasPrefix(header.Get("Content-Type"), "text/event-stream") {
+		return
+	}
+	if header.Get("Content-Type") == "" {
+		// 以未壓縮的內容判斷類型，避免 net/http 依壓縮後的內容判斷
+		header.Set("Content-Type", http.DetectContentType(p))
+	}
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	w.gz = w.pool.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+	w.compressed = true
+}
+
+func (w *gzipWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.start(p)
+	}
+	if !w.compressed {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.gz.Write(p)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeader 移除處理器設定的 Content-Length，其值為未壓縮的長度
+func (w *gzipWriter) WriteHeader(code int) {
+	if !w.decided && w.ResponseWriter.Header().Get("Content-Encoding") == "" {
+		w.ResponseWriter.Header().Del("Content-Length")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush 送出已壓縮的內容，供串流回應使用
+func (w *gzipWriter) Flush() {
+	if w.compressed {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *gzipWriter) close() {
+	if !w.compressed {
+		return
+	}
+	w.gz.Close()
+	w.gz.Reset(nil)
+	w.pool.Put(w.gz)
+	w.gz = nil
+	w.compressed = false
+}
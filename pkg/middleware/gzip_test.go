@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGzip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := strings.Repeat("假資料 ", 200)
+	r := gin.New()
+	r.Use(Gzip(gzip.DefaultCompression, "/metrics"))
+	r.GET("/text", func(c *gin.Context) { c.String(http.StatusOK, body) })
+	r.GET("/metrics", func(c *gin.Context) { c.String(http.StatusOK, body) })
+	r.GET("/empty", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.GET("/events", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.String(http.StatusOK, body)
+	})
+
+	cases := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		wantGzip       bool
+		status         int
+	}{
+		{"compressed", "/text", "gzip, deflate", true, http.StatusOK},
+		{"not accepted", "/text", "", false, http.StatusOK},
+		{"refused", "/text", "gzip;q=0", false, http.StatusOK},
+		{"excluded path", "/metrics", "gzip", false, http.StatusOK},
+		{"no body", "/empty", "gzip", false, http.StatusNoContent},
+		{"event stream", "/events", "gzip", false, http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d", w.Code, tc.status)
+			}
+			gzipped := w.Header().Get("Content-Encoding") == "gzip"
+			if gzipped != tc.wantGzip {
+				t.Fatalf("Content-Encoding = %q, want gzip %v", w.Header().Get("Content-Encoding"), tc.wantGzip)
+			}
+			if tc.status == http.StatusNoContent {
+				if w.Body.Len() != 0 {
+					t.Errorf("body = %q, want empty", w.Body.String())
+				}
+				return
+			}
+
+			got := w.Body.String()
+			if gzipped {
+				zr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("gzip.NewReader: %v", err)
+				}
+				raw, _ := io.ReadAll(zr)
+				got = string(raw)
+				if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+					t.Errorf("Content-Type = %q, want text/plain", w.Header().Get("Content-Type"))
+				}
+			}
+			if got != body {
+				t.Errorf("body mismatch: got %d bytes, want %d", len(got), len(body))
+			}
+		})
+	}
+}
@@ -1,29 +1,196 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"html"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/metrics"
+	"golang-gin-app/internal/tracing"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Logger is a middleware that logs the incoming requests
-func Logger() gin.HandlerFunc {
+// RequestIDHeader 傳入與回傳請求 ID 的 HTTP 標頭
+const RequestIDHeader = "X-Request-ID"
+
+// ErrorIDHeader 回傳 panic 錯誤 ID 的 HTTP 標頭
+const ErrorIDHeader = "X-Error-ID"
+
+// KeyErrorID 錯誤 ID 在日誌與 JSON 回應中的欄位名稱
+const KeyErrorID = "error_id"
+
+// internalErrorMessage 與 internalErrorPage 為 panic 時回傳給用戶端的訊息，不包含 panic 內容
+const (
+	internalErrorMessage = "伺服器內部錯誤"
+	internalErrorPage    = `<!DOCTYPE html><html><head><meta charset="utf-8"><title>500</title></head>` +
+		`<body><h1>伺服器內部錯誤</h1><p>錯誤 ID：%s</p></body></html>`
+)
+
+// maxRequestIDLength 接受的外部請求 ID 最大長度，過長時改用新產生的 ID
+const maxRequestIDLength = 64
+
+// Logger is a middleware that logs the incoming requests.
+// 每個請求帶有請求 ID（沿用 X-Request-ID 標頭或新產生），並將帶有請求 ID 的 logger
+// 放入請求的 context；請求結束後記錄路由、狀態碼與耗時。
+func Logger(logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Log the request
-		// You can use a logging library or just print to console
-		fmt.Printf("Request: %s %s\n", c.Request.Method, c.Request.URL.Path)
+		start := time.Now()
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newID()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		ctx := logging.WithLogger(c.Request.Context(), logger.With(logging.KeyRequestID, requestID))
+		c.Request = c.Request.WithContext(ctx)
+
 		c.Next() // Call the next middleware/handler
+
+		// 之後的中介層（例如租戶）可能已在 context 的 logger 加上欄位
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		level := slog.LevelInfo
+		switch status := c.Writer.Status(); {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		logging.FromContext(c.Request.Context()).Log(c.Request.Context(), level, "request",
+			logging.KeyMethod, c.Request.Method,
+			logging.KeyRoute, route,
+			logging.KeyStatus, c.Writer.Status(),
+			logging.KeyLatency, time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
+
+// Metrics 記錄每個請求的次數與耗時；未匹配任何路由的請求歸為 unmatched，避免以實際路徑作為標籤
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveHTTPRequest(c.Request.Method, route, strconv.Itoa(c.Writer.Status()), time.Since(start))
+	}
+}
+
+// Tracing 為每個請求建立 server span，沿用上游以 W3C traceparent 標頭傳入的追蹤；
+// span 放入請求的 context，之後的 service 與 repository span 都是它的子 span。
+// 已取樣時在 context 的 logger 加上 trace_id，並於回應標頭回傳 traceparent。
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx, span := tracing.Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+			),
+		)
+		defer span.End()
+
+		if traceID := tracing.TraceID(ctx); traceID != "" {
+			ctx = logging.With(ctx, "trace_id", traceID)
+			otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(c.Writer.Header()))
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
 	}
 }
 
-// Recovery is a middleware that recovers from panics and writes a 500 if there was one
+// validRequestID 判斷外部傳入的請求 ID 是否可直接使用：非空、長度有限且只含可見 ASCII 字元
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newID 產生隨機的請求 ID 或錯誤 ID
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Recovery is a middleware that recovers from panics and writes a 500 if there was one.
+// 每次 panic 產生一個錯誤 ID，與堆疊一起記錄並回傳給用戶端（X-Error-ID 標頭及回應內容），
+// 方便以用戶端回報的 ID 找到對應的日誌；panic 同時記錄在請求的 span。
 func Recovery() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
-			if err := recover(); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			ctx := c.Request.Context()
+			errorID := newID()
+			logging.FromContext(ctx).Error("panic recovered",
+				KeyErrorID, errorID,
+				logging.KeyError, fmt.Sprint(recovered),
+				"stack", string(debug.Stack()),
+			)
+			span := trace.SpanFromContext(ctx)
+			span.RecordError(fmt.Errorf("panic: %v", recovered), trace.WithAttributes(attribute.String(KeyErrorID, errorID)))
+			span.SetStatus(codes.Error, "panic")
+
+			if c.Writer.Written() {
+				// 回應已開始送出，無法再改寫狀態碼
+				c.Abort()
+				return
+			}
+			c.Header(ErrorIDHeader, errorID)
+			if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+				c.Data(http.StatusInternalServerError, "text/html; charset=utf-8",
+					[]byte(fmt.Sprintf(internalErrorPage, html.EscapeString(errorID))))
+				c.Abort()
+				return
 			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error":    internalErrorMessage,
+				"code":     "internal_error",
+				KeyErrorID: errorID,
+			})
 		}()
 		c.Next()
 	}
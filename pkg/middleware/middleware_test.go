@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-gin-app/internal/logging"
+	"golang-gin-app/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestLoggerAddsRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	logger, _ := logging.New("info", "json", &buf)
+
+	r := gin.New()
+	r.Use(Logger(logger), Recovery())
+	r.GET("/items/:id", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), logging.KeyTenant, "dtxtraining"))
+		c.Status(http.StatusNoContent)
+	})
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	cases := []struct {
+		name      string
+		path      string
+		requestID string
+		wantID    string
+		status    int
+	}{
+		{"given id", "/items/1", "req-123", "req-123", http.StatusNoContent},
+		{"invalid id", "/items/1", "has space", "", http.StatusNoContent},
+		{"panic", "/panic", "", "", http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.requestID != "" {
+				req.Header.Set(RequestIDHeader, tc.requestID)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d", w.Code, tc.status)
+			}
+			id := w.Header().Get(RequestIDHeader)
+			if id == "" || (tc.wantID != "" && id != tc.wantID) || (tc.wantID == "" && id == tc.requestID) {
+				t.Fatalf("%s = %q, want %q or a generated id", RequestIDHeader, id, tc.wantID)
+			}
+
+			// 最後一行是請求日誌，需帶有請求 ID、路由與中途加入的欄位
+			lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+			var entry map[string]interface{}
+			if err := json.Unmarshal(lines[len(lines)-1], &entry); err != nil {
+				t.Fatalf("decode request log %q: %v", buf.String(), err)
+			}
+			if entry[logging.KeyRequestID] != id || entry[logging.KeyStatus] != float64(tc.status) {
+				t.Errorf("unexpected request log: %v", entry)
+			}
+			if tc.path == "/items/1" && (entry[logging.KeyRoute] != "/items/:id" || entry[logging.KeyTenant] != "dtxtraining") {
+				t.Errorf("expected route and tenant fields: %v", entry)
+			}
+		})
+	}
+}
+
+func TestTracingCreatesServerSpan(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	var buf bytes.Buffer
+	logger, _ := logging.New("info", "json", &buf)
+	r := gin.New()
+	r.Use(Logger(logger), Tracing(), Recovery())
+	r.GET("/items/:id", func(c *gin.Context) {
+		_, span := tracing.Start(c.Request.Context(), "Service.GetItem")
+		span.End()
+		c.Status(http.StatusInternalServerError)
+	})
+
+	// 上游傳入的 traceparent 應沿用為同一個追蹤
+	const parentTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	req.Header.Set("traceparent", "00-"+parentTraceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	child, server := spans[0], spans[1]
+	if server.Name != "GET /items/:id" || server.SpanKind != trace.SpanKindServer || server.Status.Code != codes.Error {
+		t.Errorf("unexpected server span: name=%q kind=%v status=%v", server.Name, server.SpanKind, server.Status.Code)
+	}
+	if server.SpanContext.TraceID().String() != parentTraceID || child.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("spans are not linked to the incoming trace: server=%v child parent=%v", server.SpanContext, child.Parent)
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &entry); err != nil {
+		t.Fatalf("decode request log %q: %v", buf.String(), err)
+	}
+	if entry["trace_id"] != parentTraceID {
+		t.Errorf("request log trace_id = %v, want %s", entry["trace_id"], parentTraceID)
+	}
+}
+
+func TestRecoveryReturnsErrorID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	logger, _ := logging.New("info", "json", &buf)
+	r := gin.New()
+	r.Use(Logger(logger), Recovery())
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	for _, accept := range []string{"application/json", "text/html"} {
+		t.Run(accept, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+			req.Header.Set("Accept", accept)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			errorID := w.Header().Get(ErrorIDHeader)
+			if w.Code != http.StatusInternalServerError || errorID == "" {
+				t.Fatalf("status = %d, %s = %q", w.Code, ErrorIDHeader, errorID)
+			}
+			if !bytes.Contains(w.Body.Bytes(), []byte(errorID)) || bytes.Contains(w.Body.Bytes(), []byte("boom")) {
+				t.Errorf("body should contain the error id but not the panic value: %s", w.Body.String())
+			}
+			if !strings.HasPrefix(w.Header().Get("Content-Type"), accept) {
+				t.Errorf("Content-Type = %q, want %s", w.Header().Get("Content-Type"), accept)
+			}
+
+			// 第一行是 panic 日誌，帶有相同的錯誤 ID 與堆疊
+			line, _, _ := bytes.Cut(buf.Bytes(), []byte("\n"))
+			var entry map[string]interface{}
+			if err := json.Unmarshal(line, &entry); err != nil {
+				t.Fatalf("decode panic log %q: %v", line, err)
+			}
+			if entry[KeyErrorID] != errorID || entry["stack"] == nil {
+				t.Errorf("unexpected panic log: %v", entry)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// 生成進度：以 SSE 訂閱生成進度並更新頁面上的進度條
+// - 帶有 data-progress 屬性的表單：送出時產生進度ID，顯示 data-progress 指定的進度區塊
+// - 帶有 data-events 屬性的進度區塊（背景工作頁）：訂閱該網址，工作結束後重新整理頁面
+(function () {
+    'use strict';
+
+    var stageNames = {
+        users: '建立使用者',
+        roles: '分配角色',
+        patients: '寫入病患',
+        slots: '儲存時段'
+    };
+
+    // 產生隨機的進度ID
+    function newProgressID() {
+        var bytes = new Uint8Array(8);
+        window.crypto.getRandomValues(bytes);
+        return Array.prototype.map.call(bytes, function (b) {
+            return ('0' + b.toString(16)).slice(-2);
+        }).join('');
+    }
+
+    // 依事件更新進度區塊
+    function render(container, event) {
+        var bar = container.querySelector('progress');
+        var text = container.querySelector('.progress-text');
+        var progress = event.progress || {};
+        if (progress.total > 0) {
+            bar.max = progress.total;
+            bar.value = progress.done;
+        }
+        var stage = stageNames[progress.stage] || progress.stage || '準備中';
+        text.textContent = stage + '：' + (progress.done || 0) + ' / ' + (progress.total || 0);
+
+        if (event.type === 'error') {
+            container.classList.add('progress-error');
+            text.textContent = '生成失敗：' + event.error;
+        } else if (event.type === 'done') {
+            text.textContent = event.status === 'cancelled' ? '已取消' : '完成，正在載入結果…';
+        }
+    }
+
+    // 訂閱 url 的進度事件，收到最後的 done 或 error 事件時關閉連線並呼叫 onFinal
+    function watch(url, container, onFinal) {
+        var source = new EventSource(url);
+        ['progress', 'done', 'error'].forEach(function (type) {
+            source.addEventListener(type, function (e) {
+                // 連線錯誤也會觸發 error 事件（沒有資料），EventSource 會自動重新連線
+                if (!e.data) {
+                    return;
+                }
+                var event = JSON.parse(e.data);
+                render(container, event);
+                if (type !== 'progress') {
+                    source.close();
+                    if (onFinal) {
+                        onFinal(event);
+                    }
+                }
+            });
+        });
+        return source;
+    }
+
+    document.addEventListener('DOMContentLoaded', function () {
+        document.querySelectorAll('form[data-progress]').forEach(function (form) {
+            form.addEventListener('submit', function (e) {
+                var background = form.querySelector('input[name="background"]');
+                if (e.defaultPrevented || (background && background.checked)) {
+                    // 背景工作送出後會導向工作狀態頁
+                    return;
+                }
+                var container = document.getElementById(form.getAttribute('data-progress'));
+                var input = form.querySelector('input[name="progressID"]');
+                if (!input) {
+                    input = document.createElement('input');
+                    input.type = 'hidden';
+                    input.name = 'progressID';
+                    form.appendChild(input);
+                }
+                input.value = newProgressID();
+                container.hidden = false;
+                // 結果頁載入後連線會隨頁面關閉
+                watch('/progress/' + input.value + '/events', container);
+            });
+        });
+
+        document.querySelectorAll('[data-events]').forEach(function (container) {
+            watch(container.getAttribute('data-events'), container, function () {
+                window.location.reload();
+            });
+        });
+    });
+})();